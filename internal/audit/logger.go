@@ -1,6 +1,7 @@
 package audit
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,10 +11,15 @@ import (
 	"github.com/Nomadcxx/moonbit/internal/paths"
 )
 
+// FormatVersion is written as "v" in every record. Version 0 is the legacy
+// free-form text format, which ReadFile still understands.
+const FormatVersion = 1
+
 type Logger struct {
 	mu       sync.Mutex
 	filePath string
 	file     *os.File
+	host     string
 }
 
 type LogEntry struct {
@@ -23,29 +29,79 @@ type LogEntry struct {
 	Args      []string
 	Result    string
 	Error     error
+
+	// Clean outcomes, set for operation "clean". Files is only filled in
+	// when per-file auditing is enabled.
+	Category     string
+	DryRun       bool
+	FilesDeleted int
+	FilesFailed  int
+	BytesFreed   uint64
+	Files        []FileOutcome
 }
 
-func NewLogger() (*Logger, error) {
+// FileOutcome is what happened to one file during a clean.
+type FileOutcome struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Bytes  uint64 `json:"bytes,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Record is one line of the audit log.
+type Record struct {
+	Version      int           `json:"v"`
+	Time         time.Time     `json:"time"`
+	Host         string        `json:"host,omitempty"`
+	User         string        `json:"user"`
+	Operation    string        `json:"op"`
+	Args         []string      `json:"args,omitempty"`
+	Result       string        `json:"result,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Category     string        `json:"category,omitempty"`
+	DryRun       bool          `json:"dry_run,omitempty"`
+	FilesDeleted int           `json:"files_deleted,omitempty"`
+	FilesFailed  int           `json:"files_failed,omitempty"`
+	BytesFreed   uint64        `json:"bytes_freed,omitempty"`
+	Files        []FileOutcome `json:"files,omitempty"`
+}
+
+// LogPath is where the audit log is kept.
+func LogPath() (string, error) {
 	logDir, err := paths.DataDir("logs")
 	if err != nil {
-		return nil, fmt.Errorf("failed to determine log directory: %w", err)
+		return "", fmt.Errorf("failed to determine log directory: %w", err)
 	}
-	if err := os.MkdirAll(logDir, 0700); err != nil {
+	return filepath.Join(logDir, "audit.log"), nil
+}
+
+func NewLogger() (*Logger, error) {
+	logPath, err := LogPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
 
-	logPath := filepath.Join(logDir, "audit.log")
 	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open audit log: %w", err)
 	}
 
+	host, _ := os.Hostname()
 	return &Logger{
 		filePath: logPath,
 		file:     file,
+		host:     host,
 	}, nil
 }
 
+// Path returns the file the logger appends to.
+func (l *Logger) Path() string {
+	return l.filePath
+}
+
 func (l *Logger) Log(entry LogEntry) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -64,21 +120,32 @@ func (l *Logger) Log(entry LogEntry) error {
 		}
 	}
 
-	logLine := fmt.Sprintf("[%s] user=%s operation=%s args=%v result=%s",
-		entry.Timestamp.Format(time.RFC3339),
-		entry.User,
-		entry.Operation,
-		entry.Args,
-		entry.Result,
-	)
-
+	record := Record{
+		Version:      FormatVersion,
+		Time:         entry.Timestamp,
+		Host:         l.host,
+		User:         entry.User,
+		Operation:    entry.Operation,
+		Args:         entry.Args,
+		Result:       entry.Result,
+		Category:     entry.Category,
+		DryRun:       entry.DryRun,
+		FilesDeleted: entry.FilesDeleted,
+		FilesFailed:  entry.FilesFailed,
+		BytesFreed:   entry.BytesFreed,
+		Files:        entry.Files,
+	}
 	if entry.Error != nil {
-		logLine += fmt.Sprintf(" error=%v", entry.Error)
+		record.Error = entry.Error.Error()
 	}
 
-	logLine += "\n"
-
-	if _, err := l.file.WriteString(logLine); err != nil {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	// One write per record keeps lines whole under O_APPEND, even with
+	// several moonbit processes logging at once.
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
 		return fmt.Errorf("failed to write audit log: %w", err)
 	}
 
@@ -112,15 +179,6 @@ func (l *Logger) LogDockerOperation(operation string, args []string, result stri
 	})
 }
 
-func (l *Logger) LogCleanOperation(filesDeleted int, bytesFreed uint64, err error) error {
-	result := fmt.Sprintf("deleted=%d bytes=%d", filesDeleted, bytesFreed)
-	return l.Log(LogEntry{
-		Operation: "clean",
-		Result:    result,
-		Error:     err,
-	})
-}
-
 func (l *Logger) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -207,24 +207,6 @@ func TestLogger_LogDockerOperation(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestLogger_LogCleanOperation(t *testing.T) {
-	tmpDir, err := os.MkdirTemp("", "moonbit-audit-test-*")
-	require.NoError(t, err)
-	defer os.RemoveAll(tmpDir)
-
-	originalHome := os.Getenv("HOME")
-	defer os.Setenv("HOME", originalHome)
-
-	os.Setenv("HOME", tmpDir)
-
-	logger, err := NewLogger()
-	require.NoError(t, err)
-	defer logger.Close()
-
-	err = logger.LogCleanOperation(10, 1024000, nil)
-	assert.NoError(t, err)
-}
-
 func TestLogger_Close(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "moonbit-audit-test-*")
 	require.NoError(t, err)
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxLineSize bounds one record; per-file clean records can be long.
+const maxLineSize = 64 << 20
+
+// legacyLine matches the text format written before FormatVersion 1:
+//
+//	[2025-01-02T15:04:05Z] user=alice operation=clean args=[] result=deleted=3 bytes=10 error=...
+var legacyLine = regexp.MustCompile(`^\[([^\]]+)\] user=(\S*) operation=(\S*) args=\[(.*?)\] result=(.*?)(?: error=(.*))?$`)
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	Since time.Time
+	Until time.Time
+	// Operation is an exact name or a glob such as "docker_*".
+	Operation string
+	User      string
+	Host      string
+	Category  string
+}
+
+// Matches reports whether r passes every set field of f.
+func (f Filter) Matches(r Record) bool {
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+	if f.Operation != "" && r.Operation != f.Operation {
+		if ok, _ := path.Match(f.Operation, r.Operation); !ok {
+			return false
+		}
+	}
+	if f.User != "" && r.User != f.User {
+		return false
+	}
+	if f.Host != "" && r.Host != f.Host {
+		return false
+	}
+	if f.Category != "" && !strings.EqualFold(r.Category, f.Category) {
+		return false
+	}
+	return true
+}
+
+// ParseLine decodes one line in either the JSON or the legacy text format.
+func ParseLine(line string) (Record, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return Record{}, fmt.Errorf("malformed audit record: %w", err)
+		}
+		return r, nil
+	}
+
+	m := legacyLine.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, fmt.Errorf("unrecognised audit line")
+	}
+	ts, err := time.Parse(time.RFC3339, m[1])
+	if err != nil {
+		return Record{}, fmt.Errorf("malformed audit timestamp: %w", err)
+	}
+	return Record{
+		Time:      ts,
+		User:      m[2],
+		Operation: m[3],
+		Args:      strings.Fields(m[4]),
+		Result:    m[5],
+		Error:     m[6],
+	}, nil
+}
+
+// Read returns the records in r that match filter, oldest first as written,
+// and the number of lines that could not be parsed.
+func Read(r io.Reader, filter Filter) ([]Record, int, error) {
+	var records []Record
+	malformed := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		record, err := ParseLine(line)
+		if err != nil {
+			malformed++
+			continue
+		}
+		if filter.Matches(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return records, malformed, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return records, malformed, nil
+}
+
+// ReadFile is Read on the log at path.
+func ReadFile(path string, filter Filter) ([]Record, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	return Read(f, filter)
+}
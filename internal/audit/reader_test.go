@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogWritesVersionedJSONLines(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	logger, err := NewLogger()
+	require.NoError(t, err)
+	defer logger.Close()
+
+	ts := time.Date(2025, 6, 3, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, logger.Log(LogEntry{
+		Timestamp:    ts,
+		Operation:    "clean",
+		User:         "alice",
+		Result:       "partial",
+		Error:        assert.AnError,
+		Category:     "Logs",
+		FilesDeleted: 1,
+		FilesFailed:  1,
+		BytesFreed:   10,
+		Files: []FileOutcome{
+			{Path: "/var/log/a.log", Action: "delete", Bytes: 10},
+			{Path: "/var/log/b.log", Action: "delete", Error: "permission denied"},
+		},
+	}))
+
+	data, err := os.ReadFile(logger.Path())
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	assert.True(t, strings.HasPrefix(lines[0], `{"v":1,`))
+
+	r, err := ParseLine(lines[0])
+	require.NoError(t, err)
+	assert.True(t, r.Time.Equal(ts))
+	assert.Equal(t, "alice", r.User)
+	assert.Equal(t, "Logs", r.Category)
+	assert.Equal(t, assert.AnError.Error(), r.Error)
+	assert.Len(t, r.Files, 2)
+	assert.Equal(t, "permission denied", r.Files[1].Error)
+	host, _ := os.Hostname()
+	assert.Equal(t, host, r.Host)
+}
+
+func TestParseLineReadsLegacyFormat(t *testing.T) {
+	r, err := ParseLine("[2025-01-02T15:04:05Z] user=bob operation=clean args=[] result=deleted=3 bytes=1024 error=boom: it broke")
+	require.NoError(t, err)
+	assert.Equal(t, 0, r.Version)
+	assert.Equal(t, time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC), r.Time.UTC())
+	assert.Equal(t, "bob", r.User)
+	assert.Equal(t, "clean", r.Operation)
+	assert.Empty(t, r.Args)
+	assert.Equal(t, "deleted=3 bytes=1024", r.Result)
+	assert.Equal(t, "boom: it broke", r.Error)
+
+	r, err = ParseLine("[2025-01-02T15:04:05+01:00] user=bob operation=docker_prune_images args=[-a -f] result=success")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-a", "-f"}, r.Args)
+	assert.Equal(t, "success", r.Result)
+	assert.Empty(t, r.Error)
+
+	_, err = ParseLine("not an audit line")
+	assert.Error(t, err)
+}
+
+func TestReadFiltersMixedLog(t *testing.T) {
+	log := strings.Join([]string{
+		"[2025-06-02T09:00:00Z] user=bob operation=clean args=[] result=deleted=1 bytes=1",
+		`{"v":1,"time":"2025-06-03T10:00:00Z","host":"x","user":"root","op":"clean","category":"Logs","files_deleted":2}`,
+		`{"v":1,"time":"2025-06-03T11:00:00Z","host":"y","user":"root","op":"docker_prune_images"}`,
+		"garbage",
+		`{"v":1,"time":"2025-06-04T00:00:00Z","host":"x","user":"root","op":"clean","category":"Cache"}`,
+		"",
+	}, "\n")
+
+	all, malformed, err := Read(strings.NewReader(log), Filter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+	assert.Equal(t, 1, malformed)
+
+	day := Filter{
+		Since: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC),
+	}
+	records, _, err := Read(strings.NewReader(log), day)
+	require.NoError(t, err)
+	assert.Len(t, records, 2, "until is exclusive")
+
+	day.Host = "x"
+	day.Operation = "clean"
+	records, _, err = Read(strings.NewReader(log), day)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Logs", records[0].Category)
+
+	records, _, err = Read(strings.NewReader(log), Filter{Operation: "docker_*"})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	records, _, err = Read(strings.NewReader(log), Filter{User: "bob"})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestReadFileMissing(t *testing.T) {
+	_, _, err := ReadFile(filepath.Join(t.TempDir(), "audit.log"), Filter{})
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTargetExists is returned when a restore would replace an existing file.
+var ErrTargetExists = errors.New("a file already exists at the restore target")
+
+// Conflict modes: what a restore does when a file already exists where a
+// backed-up file would go.
+const (
+	// ConflictSkip leaves the existing file and does not restore.
+	ConflictSkip = "skip"
+	// ConflictOverwrite replaces the existing file.
+	ConflictOverwrite = "overwrite"
+	// ConflictRename restores beside it as <name>.restored, .restored.2, ...
+	ConflictRename = "rename"
+)
+
+// maxRenames bounds the search for a free ".restored" name.
+const maxRenames = 1000
+
+// RestoreOptions selects which files a restore writes and where. The zero
+// value restores every file to its original path, skipping existing files.
+type RestoreOptions struct {
+	// Paths are glob patterns (filepath.Match syntax). A file is selected when
+	// a pattern matches its original path or one of the directories above it,
+	// so a directory restores everything beneath it. A pattern without a
+	// slash is matched against base names instead. Empty selects every file.
+	Paths []string
+	// Categories selects files from these categories, matched case
+	// insensitively. Empty selects every category.
+	Categories []string
+	// To re-roots restored files under this directory, keeping their full
+	// original path beneath it: /var/log/a.log restored to /tmp/r becomes
+	// /tmp/r/var/log/a.log.
+	To string
+	// Conflict is ConflictSkip (default), ConflictOverwrite or ConflictRename.
+	Conflict string
+}
+
+// Validate reports an unknown conflict mode or a malformed pattern.
+func (o RestoreOptions) Validate() error {
+	switch o.Conflict {
+	case "", ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return fmt.Errorf("conflict must be %q, %q or %q, got %q", ConflictSkip, ConflictOverwrite, ConflictRename, o.Conflict)
+	}
+	for _, pattern := range o.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+	if o.To != "" && !filepath.IsAbs(o.To) {
+		return fmt.Errorf("restore destination must be an absolute path, got %q", o.To)
+	}
+	return nil
+}
+
+// Selects reports whether f passes the path and category filters.
+func (o RestoreOptions) Selects(f File) bool {
+	if len(o.Categories) > 0 {
+		found := false
+		for _, c := range o.Categories {
+			if strings.EqualFold(strings.TrimSpace(c), f.Category) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(o.Paths) == 0 {
+		return true
+	}
+	for _, pattern := range o.Paths {
+		if matchPath(pattern, f.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(pattern, path string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, filepath.Base(path))
+		return ok
+	}
+	pattern = filepath.Clean(pattern)
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if ok, _ := filepath.Match(pattern, p); ok {
+			return true
+		}
+		if parent := filepath.Dir(p); parent == p {
+			return false
+		}
+	}
+}
+
+// Target is where f is restored to.
+func (o RestoreOptions) Target(f File) string {
+	if o.To == "" {
+		return f.Path
+	}
+	return filepath.Join(o.To, f.Path)
+}
+
+// RestoredFile is one file a restore wrote.
+type RestoredFile struct {
+	Path   string // original path
+	Target string // where it was written
+}
+
+// RestoreResult is the outcome of RestoreSelected.
+type RestoreResult struct {
+	Restored []RestoredFile
+	// Skipped lists original paths left alone because a file existed at the
+	// target under ConflictSkip.
+	Skipped []string
+	// Failed lists "<path>: <error>" for files that could not be restored.
+	Failed []string
+}
+
+// RestoreSelected restores the files in m that opts selects. Files are restored
+// independently; failures are collected in the result, and the error is set
+// when any file failed or the filters matched nothing.
+func (s *Store) RestoreSelected(m *Manifest, opts RestoreOptions) (RestoreResult, error) {
+	var result RestoreResult
+	if err := opts.Validate(); err != nil {
+		return result, err
+	}
+
+	selected := 0
+	for _, f := range m.Files {
+		if !opts.Selects(f) {
+			continue
+		}
+		selected++
+		target := opts.Target(f)
+
+		var err error
+		switch opts.Conflict {
+		case ConflictOverwrite:
+			err = s.restoreFile(f, target, true)
+		case ConflictRename:
+			target, err = s.restoreRenamed(f, target)
+		default:
+			err = s.restoreFile(f, target, false)
+			if errors.Is(err, ErrTargetExists) {
+				result.Skipped = append(result.Skipped, f.Path)
+				continue
+			}
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", f.Path, err))
+			continue
+		}
+		result.Restored = append(result.Restored, RestoredFile{Path: f.Path, Target: target})
+	}
+
+	if selected == 0 && (len(opts.Paths) > 0 || len(opts.Categories) > 0) {
+		return result, fmt.Errorf("no files in backup %s match the selection", m.Name)
+	}
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("restore incomplete: %d file(s) failed: %s", len(result.Failed), strings.Join(result.Failed, "; "))
+	}
+	return result, nil
+}
+
+// restoreRenamed restores to target, or beside it under the first free
+// ".restored" name, and returns the path written.
+func (s *Store) restoreRenamed(f File, target string) (string, error) {
+	candidate := target
+	for i := 0; i <= maxRenames; i++ {
+		switch i {
+		case 0:
+		case 1:
+			candidate = target + ".restored"
+		default:
+			candidate = fmt.Sprintf("%s.restored.%d", target, i)
+		}
+		err := s.restoreFile(f, candidate, false)
+		if !errors.Is(err, ErrTargetExists) {
+			return candidate, err
+		}
+	}
+	return "", fmt.Errorf("no free name beside %s", target)
+}
@@ -0,0 +1,135 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backupTree backs up files (path relative to dir -> category) into one
+// backup, removes the originals, and returns the loaded manifest.
+func backupTree(t *testing.T, store *Store, dir string, files map[string]string) *Manifest {
+	t.Helper()
+	m, err := store.Create()
+	require.NoError(t, err)
+	for rel, category := range files {
+		path := filepath.Join(dir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(rel), 0600))
+		_, err := store.Add(m, path, category)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(path))
+	}
+	require.NoError(t, store.Save(m))
+	loaded, err := store.Load(m.Name)
+	require.NoError(t, err)
+	return loaded
+}
+
+func restoredPaths(result RestoreResult) []string {
+	var out []string
+	for _, f := range result.Restored {
+		out = append(out, f.Path)
+	}
+	return out
+}
+
+func TestRestoreSelectedFilters(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "backups"))
+	m := backupTree(t, store, filepath.Join(dir, "data"), map[string]string{
+		"app/cache/a.bin": "Cache",
+		"app/cache/b.log": "Logs",
+		"other/c.log":     "Logs",
+	})
+	data := filepath.Join(dir, "data")
+
+	tests := []struct {
+		name string
+		opts RestoreOptions
+		want []string
+	}{
+		{"directory", RestoreOptions{Paths: []string{filepath.Join(data, "app")}}, []string{"app/cache/a.bin", "app/cache/b.log"}},
+		{"glob", RestoreOptions{Paths: []string{filepath.Join(data, "*", "cache", "*.bin")}}, []string{"app/cache/a.bin"}},
+		{"base name", RestoreOptions{Paths: []string{"*.log"}}, []string{"app/cache/b.log", "other/c.log"}},
+		{"category", RestoreOptions{Categories: []string{"logs"}}, []string{"app/cache/b.log", "other/c.log"}},
+		{"both", RestoreOptions{Paths: []string{"*.log"}, Categories: []string{"Cache"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range m.Files {
+				if tt.opts.Selects(f) {
+					rel, err := filepath.Rel(data, f.Path)
+					require.NoError(t, err)
+					got = append(got, rel)
+				}
+			}
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+
+	_, err := store.RestoreSelected(m, RestoreOptions{Categories: []string{"Nope"}})
+	assert.ErrorContains(t, err, "match the selection")
+}
+
+func TestRestoreSelectedToAlternateDirectory(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "backups"))
+	data := filepath.Join(dir, "data")
+	m := backupTree(t, store, data, map[string]string{"x/f.txt": "A"})
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.Files[0].ModTime = mtime
+
+	to := filepath.Join(dir, "restored")
+	result, err := store.RestoreSelected(m, RestoreOptions{To: to})
+	require.NoError(t, err)
+	target := filepath.Join(to, data, "x", "f.txt")
+	assert.Equal(t, []RestoredFile{{Path: filepath.Join(data, "x", "f.txt"), Target: target}}, result.Restored)
+	assert.NoFileExists(t, filepath.Join(data, "x", "f.txt"))
+
+	st, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	assert.True(t, st.ModTime().Equal(mtime))
+
+	_, err = store.RestoreSelected(m, RestoreOptions{To: "relative"})
+	assert.ErrorContains(t, err, "absolute")
+}
+
+func TestRestoreSelectedConflicts(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "backups"))
+	data := filepath.Join(dir, "data")
+	m := backupTree(t, store, data, map[string]string{"f": "A"})
+	path := filepath.Join(data, "f")
+	require.NoError(t, os.WriteFile(path, []byte("current"), 0644))
+
+	result, err := store.RestoreSelected(m, RestoreOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, result.Skipped)
+	data1, _ := os.ReadFile(path)
+	assert.Equal(t, "current", string(data1), "skip is the default")
+
+	result, err = store.RestoreSelected(m, RestoreOptions{Conflict: ConflictRename})
+	require.NoError(t, err)
+	result2, err := store.RestoreSelected(m, RestoreOptions{Conflict: ConflictRename})
+	require.NoError(t, err)
+	assert.Equal(t, path+".restored", result.Restored[0].Target)
+	assert.Equal(t, path+".restored.2", result2.Restored[0].Target)
+	renamed, _ := os.ReadFile(path + ".restored")
+	assert.Equal(t, "f", string(renamed))
+
+	result, err = store.RestoreSelected(m, RestoreOptions{Conflict: ConflictOverwrite})
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, restoredPaths(result))
+	overwritten, _ := os.ReadFile(path)
+	assert.Equal(t, "f", string(overwritten))
+
+	_, err = store.RestoreSelected(m, RestoreOptions{Conflict: "merge"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,597 @@
+// Package backup keeps copies of cleaned files in a content-addressed store.
+//
+// File contents are stored once per distinct SHA-256, gzip-compressed, under
+// blobs/, and shared by every backup that contains them. Each clean run writes
+// one manifest under manifests/ listing the files it backed up, their
+// metadata and the hash of their contents. A backup is named after its
+// manifest.
+package backup
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/paths"
+)
+
+// ManifestVersion is the on-disk format of a manifest.
+const ManifestVersion = 1
+
+// CompressionGzip is the only blob encoding written today.
+const CompressionGzip = "gzip"
+
+const manifestSuffix = ".json"
+
+// blobGracePeriod protects blobs written or reused this recently from Prune:
+// a run still in progress may reference them before its manifest says so.
+const blobGracePeriod = time.Hour
+
+// File is one backed-up file. Mode, owner and mtime are as they were when the
+// file was backed up.
+type File struct {
+	Path     string      `json:"path"`
+	Category string      `json:"category,omitempty"`
+	Size     int64       `json:"size"`
+	Mode     os.FileMode `json:"mode"`
+	UID      int         `json:"uid"`
+	GID      int         `json:"gid"`
+	ModTime  time.Time   `json:"mod_time"`
+	Hash     string      `json:"sha256"`
+}
+
+// Manifest lists the files one clean run backed up.
+type Manifest struct {
+	Version     int       `json:"version"`
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+	Compression string    `json:"compression"`
+	Categories  []string  `json:"categories"`
+	FileCount   int       `json:"file_count"`
+	TotalSize   uint64    `json:"total_size"`
+	// StoredSize is the compressed size of the blobs this backup added to the
+	// store. Contents already stored by an earlier backup cost nothing.
+	StoredSize uint64 `json:"stored_size"`
+	Files      []File `json:"files"`
+}
+
+// Store is a backup directory: blobs/, manifests/ and a tmp/ staging area.
+type Store struct {
+	root string
+}
+
+// NewStore returns the store rooted at root. Directories are created on the
+// first write.
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+// Open returns the store in the moonbit data directory.
+func Open() (*Store, error) {
+	root, err := paths.DataDir("backups")
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(root), nil
+}
+
+// Root is the store's directory.
+func (s *Store) Root() string {
+	return s.root
+}
+
+// Path is the path callers use to refer to the backup called name.
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.root, name)
+}
+
+func (s *Store) manifestPath(name string) string {
+	return filepath.Join(s.root, "manifests", name+manifestSuffix)
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.root, "blobs", hash[:2], hash)
+}
+
+func (s *Store) ensure() error {
+	for _, dir := range []string{"blobs", "manifests", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(s.root, dir), 0700); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Create starts a new backup named after the current time and reserves its
+// manifest, so two runs in the same second get distinct names.
+func (s *Store) Create() (*Manifest, error) {
+	if err := s.ensure(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	base := now.Format("20060102_150405")
+	for i := 1; i < 1000; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		f, err := os.OpenFile(s.manifestPath(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to create backup manifest: %w", err)
+		}
+		f.Close()
+		m := &Manifest{
+			Version:     ManifestVersion,
+			Name:        name,
+			CreatedAt:   now,
+			Compression: CompressionGzip,
+			Categories:  []string{},
+			Files:       []File{},
+		}
+		if err := s.Save(m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("no free backup name for %s", base)
+}
+
+// Add stores the contents of the regular file at path and records it in m.
+// The manifest is not written until Save.
+func (s *Store) Add(m *Manifest, path, category string) (File, error) {
+	if err := s.ensure(); err != nil {
+		return File{}, err
+	}
+
+	// O_NOFOLLOW and a check on the open descriptor: only ever back up the
+	// regular file that is about to be deleted, never a symlink's target.
+	src, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return File{}, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return File{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return File{}, fmt.Errorf("not a regular file (mode %s)", info.Mode())
+	}
+
+	hash, stored, err := s.putBlob(src)
+	if err != nil {
+		return File{}, err
+	}
+
+	file := File{
+		Path:     path,
+		Category: category,
+		Size:     info.Size(),
+		Mode:     info.Mode(),
+		UID:      -1,
+		GID:      -1,
+		ModTime:  info.ModTime(),
+		Hash:     hash,
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		file.UID, file.GID = int(st.Uid), int(st.Gid)
+	}
+
+	m.Files = append(m.Files, file)
+	m.FileCount = len(m.Files)
+	m.TotalSize += uint64(file.Size)
+	m.StoredSize += stored
+	if category != "" && !contains(m.Categories, category) {
+		m.Categories = append(m.Categories, category)
+	}
+	return file, nil
+}
+
+// putBlob compresses r into a staging file while hashing it, then moves it to
+// its content address. It returns the hash and the number of bytes added to
+// the store, which is zero when the contents were already present.
+func (s *Store) putBlob(r io.Reader) (string, uint64, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "blob-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stage backup blob: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hasher := sha256.New()
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(io.MultiWriter(zw, hasher), r); err != nil {
+		tmp.Close()
+		return "", 0, fmt.Errorf("failed to copy file into backup: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return "", 0, fmt.Errorf("failed to compress backup blob: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", 0, fmt.Errorf("failed to write backup blob: %w", err)
+	}
+	stat, err := tmp.Stat()
+	tmp.Close()
+	if err != nil {
+		return "", 0, err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	dst := s.blobPath(hash)
+	if _, err := os.Stat(dst); err == nil {
+		// Mark the blob as in use so a concurrent Prune, which has not yet
+		// seen this run's manifest, leaves it alone.
+		now := time.Now()
+		_ = os.Chtimes(dst, now, now)
+		return hash, 0, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return "", 0, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return "", 0, fmt.Errorf("failed to store backup blob: %w", err)
+	}
+	return hash, uint64(stat.Size()), nil
+}
+
+// Save writes m through a temporary file, so a crash mid-run leaves the last
+// complete manifest in place.
+func (s *Store) Save(m *Manifest) error {
+	if err := s.ensure(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "manifest-*")
+	if err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.manifestPath(m.Name)); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+// Load reads the manifest of the backup called name.
+//
+// When running as root, a manifest root does not own is refused. The store
+// normally lives in the invoking user's home, and a manifest they wrote
+// themselves could otherwise direct a root restore anywhere on the system.
+func (s *Store) Load(name string) (*Manifest, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid backup name %q", name)
+	}
+	f, err := os.OpenFile(s.manifestPath(name), os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("backup %q not found", name)
+		}
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	defer f.Close()
+
+	if os.Geteuid() == 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid != 0 {
+			return nil, fmt.Errorf("refusing backup %q: manifest is not owned by root", name)
+		}
+	}
+
+	var m Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+	if m.Version != ManifestVersion {
+		return nil, fmt.Errorf("backup manifest format v%d is not supported", m.Version)
+	}
+	if m.Compression != CompressionGzip {
+		return nil, fmt.Errorf("backup uses unsupported compression %q", m.Compression)
+	}
+	m.Name = name
+	return &m, nil
+}
+
+// List returns the names of all backups, oldest first.
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.root, "manifests"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), manifestSuffix); ok && entry.Type().IsRegular() {
+			names = append(names, name)
+		}
+	}
+	// Names start with a sortable timestamp.
+	sort.Strings(names)
+	return names, nil
+}
+
+// Restore writes every file in m back to its original path, replacing what is
+// there. Files are restored independently; the error lists every failure.
+func (s *Store) Restore(m *Manifest) error {
+	_, err := s.RestoreSelected(m, RestoreOptions{Conflict: ConflictOverwrite})
+	return err
+}
+
+// RestoreFile writes f's contents to target with its recorded mode, mtime and,
+// where permitted, owner. The contents are verified against f.Hash before
+// target is touched, and target is replaced atomically.
+func (s *Store) RestoreFile(f File, target string) error {
+	return s.restoreFile(f, target, true)
+}
+
+// restoreFile is RestoreFile. Without replace it never clobbers an existing
+// target and returns ErrTargetExists instead, deciding atomically where the
+// filesystem supports hard links.
+func (s *Store) restoreFile(f File, target string, replace bool) error {
+	if len(f.Hash) != sha256.Size*2 {
+		return fmt.Errorf("invalid content hash %q", f.Hash)
+	}
+	if _, err := hex.DecodeString(f.Hash); err != nil {
+		return fmt.Errorf("invalid content hash %q", f.Hash)
+	}
+	blob, err := os.Open(s.blobPath(f.Hash))
+	if err != nil {
+		return fmt.Errorf("backup contents missing: %w", err)
+	}
+	defer blob.Close()
+	zr, err := gzip.NewReader(blob)
+	if err != nil {
+		return fmt.Errorf("backup contents corrupted: %w", err)
+	}
+	defer zr.Close()
+
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create target directory %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create target file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), zr); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to restore contents: %w", err)
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != f.Hash {
+		tmp.Close()
+		return fmt.Errorf("backup contents corrupted: hash %s, expected %s", got, f.Hash)
+	}
+	if err := tmp.Chmod(f.Mode.Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if f.UID >= 0 && f.GID >= 0 {
+		// Only root can give a file away; anyone else restores as themselves.
+		if err := tmp.Chown(f.UID, f.GID); err != nil && os.Geteuid() == 0 {
+			tmp.Close()
+			return fmt.Errorf("failed to restore owner: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmpPath, f.ModTime, f.ModTime); err != nil {
+		return err
+	}
+	if !replace {
+		// link(2) fails if target exists, so there is no window in which a file
+		// created meanwhile could be overwritten.
+		err := os.Link(tmpPath, target)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, fs.ErrExist) {
+			return ErrTargetExists
+		}
+		// No hard links here (e.g. vfat): check, then rename.
+		if _, err := os.Lstat(target); err == nil {
+			return ErrTargetExists
+		}
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		return fmt.Errorf("failed to move restored file into place: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy selects the backups Prune removes. Zero fields are unlimited. The
+// newest backup is always kept, so pruning right after a clean never removes
+// the backup that clean just made.
+type Policy struct {
+	// KeepLast keeps the newest N backups.
+	KeepLast int
+	// MaxAge removes backups older than this.
+	MaxAge time.Duration
+	// MaxSize keeps the newest backups whose blobs fit within this many
+	// stored (compressed) bytes; the first that does not fit and every older
+	// backup are removed.
+	MaxSize uint64
+}
+
+// IsZero reports whether the policy removes nothing.
+func (p Policy) IsZero() bool {
+	return p.KeepLast <= 0 && p.MaxAge <= 0 && p.MaxSize == 0
+}
+
+// PruneResult describes what Prune removed, or would remove on a dry run.
+type PruneResult struct {
+	Removed      []string
+	BlobsRemoved int
+	BytesFreed   uint64
+	// Skipped lists manifests that could not be read. They are never removed,
+	// and while any exist no blob is collected, since they may reference it.
+	Skipped []string
+}
+
+// Prune removes the backups the policy rejects, then deletes blobs no
+// remaining backup references. With dryRun it only reports what it would do.
+func (s *Store) Prune(p Policy, now time.Time, dryRun bool) (PruneResult, error) {
+	var result PruneResult
+	names, err := s.List()
+	if err != nil {
+		return result, err
+	}
+
+	var manifests []*Manifest
+	for _, name := range names {
+		m, err := s.Load(name)
+		if err != nil {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+	// Newest first.
+	sort.SliceStable(manifests, func(i, j int) bool {
+		if !manifests[i].CreatedAt.Equal(manifests[j].CreatedAt) {
+			return manifests[i].CreatedAt.After(manifests[j].CreatedAt)
+		}
+		return manifests[i].Name > manifests[j].Name
+	})
+
+	blobSizes := make(map[string]uint64)
+	blobSize := func(hash string) uint64 {
+		if size, ok := blobSizes[hash]; ok {
+			return size
+		}
+		var size uint64
+		if len(hash) > 2 {
+			if st, err := os.Stat(s.blobPath(hash)); err == nil {
+				size = uint64(st.Size())
+			}
+		}
+		blobSizes[hash] = size
+		return size
+	}
+
+	kept := make(map[string]bool)
+	var keptSize uint64
+	overSize := false
+	var removed []*Manifest
+	for i, m := range manifests {
+		remove := false
+		if i > 0 {
+			if p.KeepLast > 0 && i >= p.KeepLast {
+				remove = true
+			}
+			if p.MaxAge > 0 && now.Sub(m.CreatedAt) > p.MaxAge {
+				remove = true
+			}
+		}
+		if !remove && p.MaxSize > 0 {
+			var added uint64
+			counted := make(map[string]bool)
+			for _, f := range m.Files {
+				if !kept[f.Hash] && !counted[f.Hash] {
+					counted[f.Hash] = true
+					added += blobSize(f.Hash)
+				}
+			}
+			// Like retention policies, once one backup does not fit every
+			// older one goes too.
+			if i > 0 && (overSize || keptSize+added > p.MaxSize) {
+				overSize = true
+				remove = true
+			} else {
+				keptSize += added
+			}
+		}
+		if remove {
+			removed = append(removed, m)
+			continue
+		}
+		for _, f := range m.Files {
+			kept[f.Hash] = true
+		}
+	}
+
+	for _, m := range removed {
+		result.Removed = append(result.Removed, m.Name)
+		if !dryRun {
+			if err := os.Remove(s.manifestPath(m.Name)); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("failed to remove backup %s: %w", m.Name, err)
+			}
+		}
+	}
+	sort.Strings(result.Removed)
+
+	if len(result.Skipped) > 0 {
+		return result, nil
+	}
+	blobs, err := filepath.Glob(filepath.Join(s.root, "blobs", "*", "*"))
+	if err != nil {
+		return result, err
+	}
+	for _, blob := range blobs {
+		hash := filepath.Base(blob)
+		if kept[hash] {
+			continue
+		}
+		if st, err := os.Stat(blob); err != nil || now.Sub(st.ModTime()) < blobGracePeriod {
+			continue
+		}
+		size := blobSize(hash)
+		if !dryRun {
+			if err := os.Remove(blob); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("failed to remove backup blob: %w", err)
+			}
+		}
+		result.BlobsRemoved++
+		result.BytesFreed += size
+	}
+	return result, nil
+}
@@ -0,0 +1,197 @@
+package backup
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRoundTripPreservesMetadata(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "backups"))
+	path := filepath.Join(dir, "data", "file.txt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("compressible ", 100)), 0640))
+	mtime := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	m, err := store.Create()
+	require.NoError(t, err)
+	file, err := store.Add(m, path, "Docs")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(m))
+	assert.Less(t, m.StoredSize, uint64(file.Size), "blobs are compressed")
+
+	require.NoError(t, os.Remove(path))
+	loaded, err := store.Load(m.Name)
+	require.NoError(t, err)
+	require.NoError(t, store.Restore(loaded))
+
+	st, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), st.Mode().Perm())
+	assert.True(t, st.ModTime().Equal(mtime))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("compressible ", 100), string(data))
+}
+
+func TestStoreDeduplicatesAcrossBackups(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "backups"))
+	path := filepath.Join(dir, "f")
+	require.NoError(t, os.WriteFile(path, []byte("shared"), 0644))
+
+	first, err := store.Create()
+	require.NoError(t, err)
+	_, err = store.Add(first, path, "A")
+	require.NoError(t, err)
+	second, err := store.Create()
+	require.NoError(t, err)
+	_, err = store.Add(second, path, "A")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.Name, second.Name, "runs in the same second get distinct names")
+	assert.NotZero(t, first.StoredSize)
+	assert.Zero(t, second.StoredSize, "contents already in the store cost nothing")
+
+	names, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{first.Name, second.Name}, names)
+}
+
+func TestRestoreFileRejectsCorruptedBlob(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "backups"))
+	path := filepath.Join(dir, "f")
+	require.NoError(t, os.WriteFile(path, []byte("genuine"), 0644))
+	m, err := store.Create()
+	require.NoError(t, err)
+	file, err := store.Add(m, path, "A")
+	require.NoError(t, err)
+
+	// Replace the blob with different, validly compressed contents.
+	blob, err := os.Create(store.blobPath(file.Hash))
+	require.NoError(t, err)
+	zw := gzip.NewWriter(blob)
+	_, err = zw.Write([]byte("tampered"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, blob.Close())
+
+	require.NoError(t, os.WriteFile(path, []byte("current"), 0644))
+	err = store.RestoreFile(file, path)
+	assert.ErrorContains(t, err, "corrupted")
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "current", string(data), "target is untouched when verification fails")
+}
+
+func TestLoadRejectsUnsafeNames(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for _, name := range []string{"", "../x", "a/b", ".hidden"} {
+		_, err := store.Load(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestAddRefusesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "backups"))
+	target := filepath.Join(dir, "target")
+	require.NoError(t, os.WriteFile(target, []byte("x"), 0644))
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(target, link))
+
+	m, err := store.Create()
+	require.NoError(t, err)
+	_, err = store.Add(m, link, "A")
+	assert.Error(t, err)
+	assert.Empty(t, m.Files)
+}
+
+// makeBackups creates one backup per content string, with CreatedAt spaced a
+// day apart ending now, and returns their names oldest first.
+func makeBackups(t *testing.T, store *Store, contents ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var names []string
+	for i, content := range contents {
+		path := filepath.Join(dir, "f")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		m, err := store.Create()
+		require.NoError(t, err)
+		_, err = store.Add(m, path, "A")
+		require.NoError(t, err)
+		m.CreatedAt = time.Now().Add(-time.Duration(len(contents)-1-i) * 24 * time.Hour)
+		require.NoError(t, store.Save(m))
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func TestPruneKeepLastCollectsUnusedBlobs(t *testing.T) {
+	store := NewStore(t.TempDir())
+	names := makeBackups(t, store, "one", "two", "two", "three")
+	later := time.Now().Add(2 * blobGracePeriod)
+
+	preview, err := store.Prune(Policy{KeepLast: 2}, later, true)
+	require.NoError(t, err)
+	assert.Equal(t, names[:2], preview.Removed)
+	remaining, _ := store.List()
+	assert.Len(t, remaining, 4, "a dry run removes nothing")
+
+	result, err := store.Prune(Policy{KeepLast: 2}, later, false)
+	require.NoError(t, err)
+	assert.Equal(t, names[:2], result.Removed)
+	assert.Equal(t, 1, result.BlobsRemoved, `"two" is still used by a kept backup; only "one" goes`)
+
+	remaining, _ = store.List()
+	assert.Equal(t, names[2:], remaining)
+	for _, name := range remaining {
+		m, err := store.Load(name)
+		require.NoError(t, err)
+		require.NoError(t, store.RestoreFile(m.Files[0], filepath.Join(t.TempDir(), "r")))
+	}
+}
+
+func TestPruneMaxAgeAlwaysKeepsNewest(t *testing.T) {
+	store := NewStore(t.TempDir())
+	names := makeBackups(t, store, "a", "b", "c")
+
+	result, err := store.Prune(Policy{MaxAge: time.Hour}, time.Now().Add(30*24*time.Hour), false)
+	require.NoError(t, err)
+	assert.Equal(t, names[:2], result.Removed)
+	remaining, _ := store.List()
+	assert.Equal(t, names[2:], remaining)
+}
+
+func TestPruneMaxSizeRemovesOlderBackups(t *testing.T) {
+	store := NewStore(t.TempDir())
+	names := makeBackups(t, store, strings.Repeat("x", 10), "y", strings.Repeat("z", 10))
+	m, err := store.Load(names[2])
+	require.NoError(t, err)
+
+	// Room for exactly the newest backup's blob plus one byte.
+	result, err := store.Prune(Policy{MaxSize: m.StoredSize + 1}, time.Now(), false)
+	require.NoError(t, err)
+	assert.Equal(t, names[:2], result.Removed)
+	assert.Zero(t, result.BlobsRemoved, "blobs within the grace period are kept")
+}
+
+func TestPruneSkipsUnreadableManifests(t *testing.T) {
+	store := NewStore(t.TempDir())
+	names := makeBackups(t, store, "a", "b")
+	require.NoError(t, os.WriteFile(store.manifestPath("broken"), []byte("{"), 0600))
+
+	result, err := store.Prune(Policy{KeepLast: 1}, time.Now().Add(2*blobGracePeriod), false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"broken"}, result.Skipped)
+	assert.Equal(t, names[:1], result.Removed)
+	assert.Zero(t, result.BlobsRemoved, "blobs may belong to the unreadable backup")
+}
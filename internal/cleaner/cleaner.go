@@ -10,23 +10,62 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/backup"
 	"github.com/Nomadcxx/moonbit/internal/config"
 	moonbiterrors "github.com/Nomadcxx/moonbit/internal/errors"
-	"github.com/Nomadcxx/moonbit/internal/paths"
+	"github.com/Nomadcxx/moonbit/internal/trash"
+	"github.com/Nomadcxx/moonbit/internal/utils"
 )
 
-// Safety configuration for cleaning operations
+// Safety configuration for cleaning operations, resolved from the [safety]
+// section of config.toml by NewSafetyConfig.
 type SafetyConfig struct {
 	RequireConfirmation bool     `toml:"require_confirmation"`
 	MaxDeletionSize     uint64   `toml:"max_deletion_size_mb"`
 	ProtectedPaths      []string `toml:"protected_paths"`
 	SafeMode            bool     `toml:"safe_mode"`
 	ShredPasses         int      `toml:"shred_passes"`
+	// Global is the resolved [safety] limits, exact to the byte; the fields
+	// above mirror it, MaxDeletionSize rounded down to whole MiB.
+	Global config.SafetyLimits `toml:"-"`
+	// Overrides are the resolved limits of categories with a
+	// [safety.categories] entry, by name; other categories use Global.
+	Overrides map[string]config.SafetyLimits `toml:"-"`
+}
+
+// NewSafetyConfig resolves a [safety] section. Its protected paths always
+// include config.DefaultProtectedPaths.
+func NewSafetyConfig(s config.SafetyConfig) *SafetyConfig {
+	global := s.Limits("")
+	safetyCfg := &SafetyConfig{
+		RequireConfirmation: true,
+		MaxDeletionSize:     global.MaxDeletionSize / (1024 * 1024),
+		ProtectedPaths:      s.EffectiveProtectedPaths(),
+		SafeMode:            global.SafeMode,
+		ShredPasses:         global.ShredPasses,
+		Global:              global,
+	}
+	for name := range s.Categories {
+		if safetyCfg.Overrides == nil {
+			safetyCfg.Overrides = make(map[string]config.SafetyLimits)
+		}
+		safetyCfg.Overrides[name] = s.Limits(name)
+	}
+	return safetyCfg
+}
+
+// limits returns the limits that apply to the named category.
+func (s *SafetyConfig) limits(category string) config.SafetyLimits {
+	if o, ok := s.Overrides[category]; ok {
+		return o
+	}
+	return s.Global
 }
 
 // CleanProgress represents progress updates during cleaning
@@ -38,11 +77,16 @@ type CleanProgress struct {
 	TotalBytes     uint64
 }
 
-// CleanComplete represents the completion of a cleaning operation
+// CleanComplete represents the completion of a cleaning operation.
+// FilesDeleted counts every file removed from its place, including those moved
+// to the trash; BytesFreed leaves trashed files out because their space is
+// only reclaimed when the trash is purged.
 type CleanComplete struct {
 	Category      string
 	FilesDeleted  int
 	BytesFreed    uint64
+	FilesTrashed  int
+	BytesTrashed  uint64
 	Duration      time.Duration
 	BackupCreated bool
 	BackupPath    string
@@ -62,25 +106,19 @@ type Cleaner struct {
 	safetyConfig  *SafetyConfig
 	backupEnabled bool
 	auditLog      *audit.Logger
+	// trash is opened on first use by a category with action "trash".
+	trash *trash.Bin
+	// backups is opened on the first backup. backupRun is the manifest of
+	// this cleaner's run, shared by every category it cleans.
+	backups   *backup.Store
+	backupRun *backup.Manifest
 }
 
 // NewCleaner creates a new cleaner instance
 func NewCleaner(cfg *config.Config) *Cleaner {
-	safetyCfg := &SafetyConfig{
-		RequireConfirmation: true,
-		MaxDeletionSize:     512000,
-		SafeMode:            true,
-		ShredPasses:         1,
-		ProtectedPaths: []string{
-			"/bin",
-			"/usr/bin",
-			"/usr/sbin",
-			"/sbin",
-			"/etc",
-			"/boot",
-			"/sys",
-			"/proc",
-		},
+	safetyCfg := GetDefaultSafetyConfig()
+	if cfg != nil {
+		safetyCfg = NewSafetyConfig(cfg.Safety)
 	}
 
 	auditLog, err := audit.NewLogger()
@@ -91,7 +129,7 @@ func NewCleaner(cfg *config.Config) *Cleaner {
 	return &Cleaner{
 		cfg:           cfg,
 		safetyConfig:  safetyCfg,
-		backupEnabled: false,
+		backupEnabled: cfg != nil && cfg.Backup.Enabled,
 		auditLog:      auditLog,
 	}
 }
@@ -111,6 +149,8 @@ func (c *Cleaner) CleanCategory(ctx context.Context, category *config.Category,
 	start := time.Now()
 
 	// Safety checks
+	limits := c.safetyConfig.limits(category.Name)
+	roots := deletionRoots(category.Paths)
 	if err := c.performSafetyChecks(category, dryRun); err != nil {
 		progressCh <- CleanMsg{Error: fmt.Errorf("safety check failed: %w", err)}
 		return err
@@ -118,11 +158,12 @@ func (c *Cleaner) CleanCategory(ctx context.Context, category *config.Category,
 
 	// Create backup if not dry run
 	var backupPath string
+	var notBackedUp map[string]error
 	if !dryRun && c.backupEnabled {
-		backupPath = c.createBackup(category)
+		backupPath, notBackedUp = c.createBackup(category)
 		if backupPath == "" {
-			// Backup failed - abort if not in safe mode
-			if c.safetyConfig.SafeMode {
+			// Backup failed - abort if in safe mode
+			if limits.SafeMode {
 				progressCh <- CleanMsg{Error: fmt.Errorf("backup creation failed, aborting for safety")}
 				return fmt.Errorf("backup creation failed")
 			}
@@ -132,7 +173,11 @@ func (c *Cleaner) CleanCategory(ctx context.Context, category *config.Category,
 	filesDeleted := 0
 	filesFailed := 0
 	bytesFreed := uint64(0)
+	filesTrashed := 0
+	bytesTrashed := uint64(0)
 	var errorMessages []string
+	perFile := c.cfg != nil && c.cfg.Audit.PerFile
+	var outcomes []audit.FileOutcome
 
 	for _, fileInfo := range category.Files {
 		select {
@@ -154,22 +199,55 @@ func (c *Cleaner) CleanCategory(ctx context.Context, category *config.Category,
 		if dryRun {
 			filesDeleted++
 			bytesFreed += fileInfo.Size
+			if perFile {
+				outcomes = append(outcomes, audit.FileOutcome{
+					Path: fileInfo.Path, Action: auditAction(fileAction(category, fileInfo), false), Bytes: fileInfo.Size,
+				})
+			}
 		} else {
+			// A file that could not be backed up is kept: the backup is the
+			// only way back from deleting it.
+			if err, ok := notBackedUp[fileInfo.Path]; ok {
+				filesFailed++
+				errorMessages = append(errorMessages, fmt.Sprintf("%s: not backed up, kept: %v", fileInfo.Path, err))
+				if perFile {
+					outcomes = append(outcomes, audit.FileOutcome{
+						Path: fileInfo.Path, Action: "keep", Error: "not backed up: " + err.Error(),
+					})
+				}
+				continue
+			}
+
 			// Shredding is enabled per file by the revalidation gate, which reads
 			// it from config. The category-level flag is only meaningful for
 			// callers passing a config category directly.
 			shred := category.ShredEnabled || fileInfo.CategoryShred
-			action := fileInfo.CategoryAction
-			if action == config.ActionDelete {
-				action = category.Action
+			shredPasses := 0
+			if shred {
+				shredPasses = limits.ShredPasses
 			}
+			action := fileAction(category, fileInfo)
 
-			var freed uint64
+			var freed, moved uint64
 			var err error
-			if action == config.ActionTruncate {
-				freed, err = c.truncateFile(fileInfo.Path)
-			} else {
-				freed, err = c.deleteFile(fileInfo.Path, shred)
+			switch action {
+			case config.ActionTruncate:
+				freed, err = c.truncateFile(roots, fileInfo.Path)
+			case config.ActionTrash:
+				moved, err = c.trashFile(roots, fileInfo.Path, category.Name)
+				if err == nil {
+					filesTrashed++
+					bytesTrashed += moved
+				}
+			default:
+				freed, err = c.deleteFile(roots, fileInfo.Path, shredPasses)
+			}
+			if perFile {
+				outcome := audit.FileOutcome{Path: fileInfo.Path, Action: auditAction(action, shred), Bytes: freed + moved}
+				if err != nil {
+					outcome.Error = err.Error()
+				}
+				outcomes = append(outcomes, outcome)
 			}
 			if err != nil {
 				filesFailed++
@@ -195,7 +273,24 @@ func (c *Cleaner) CleanCategory(ctx context.Context, category *config.Category,
 	}
 
 	if c.auditLog != nil {
-		c.auditLog.LogCleanOperation(filesDeleted, bytesFreed, cleanErr)
+		result := "success"
+		switch {
+		case filesFailed > 0 && filesDeleted == 0:
+			result = "failed"
+		case filesFailed > 0:
+			result = "partial"
+		}
+		c.auditLog.Log(audit.LogEntry{
+			Operation:    "clean",
+			Result:       result,
+			Error:        cleanErr,
+			Category:     category.Name,
+			DryRun:       dryRun,
+			FilesDeleted: filesDeleted,
+			FilesFailed:  filesFailed,
+			BytesFreed:   bytesFreed,
+			Files:        outcomes,
+		})
 	}
 
 	progressCh <- CleanMsg{
@@ -203,6 +298,8 @@ func (c *Cleaner) CleanCategory(ctx context.Context, category *config.Category,
 			Category:      category.Name,
 			FilesDeleted:  filesDeleted,
 			BytesFreed:    bytesFreed,
+			FilesTrashed:  filesTrashed,
+			BytesTrashed:  bytesTrashed,
 			Duration:      duration,
 			BackupCreated: backupPath != "",
 			BackupPath:    backupPath,
@@ -217,19 +314,40 @@ func (c *Cleaner) CleanCategory(ctx context.Context, category *config.Category,
 	return cleanErr
 }
 
+// fileAction is the action for one file: the one the revalidation gate set from
+// config, else the category's own.
+func fileAction(category *config.Category, file config.FileInfo) config.CleanAction {
+	if file.CategoryAction != config.ActionDelete {
+		return file.CategoryAction
+	}
+	return category.Action
+}
+
+// auditAction names an action in the audit log.
+func auditAction(action config.CleanAction, shred bool) string {
+	switch {
+	case action != config.ActionDelete:
+		return string(action)
+	case shred:
+		return "shred"
+	default:
+		return "delete"
+	}
+}
+
 // truncateFile reclaims a file's space without unlinking it, for files a running
 // daemon holds open. Unlinking those frees nothing until the last descriptor
 // closes and leaves the writer with a nameless handle; truncation frees the
 // space immediately and the writer keeps working.
 //
-// It returns the number of bytes reclaimed.
-func (c *Cleaner) truncateFile(path string) (uint64, error) {
+// The file is reached through its pinned parent directory, as deleteFile
+// does. It returns the number of bytes reclaimed.
+func (c *Cleaner) truncateFile(roots []string, path string) (uint64, error) {
 	if c.isProtectedPath(path) {
 		return 0, moonbiterrors.NewPathProtectedError(path, c.safetyConfig.ProtectedPaths)
 	}
 
-	// O_NOFOLLOW: never truncate through a symlink.
-	file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NOFOLLOW, 0)
+	freed, err := truncateBeneath(roots, path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return 0, moonbiterrors.NewFileNotFoundError(path, err)
@@ -237,66 +355,68 @@ func (c *Cleaner) truncateFile(path string) (uint64, error) {
 		if os.IsPermission(err) {
 			return 0, moonbiterrors.NewPermissionDeniedError(path, err)
 		}
-		return 0, fmt.Errorf("failed to open file for truncation: %w", err)
-	}
-	defer file.Close()
-
-	info, err := file.Stat()
-	if err != nil {
 		return 0, err
 	}
-	if !info.Mode().IsRegular() {
-		return 0, fmt.Errorf("refusing to truncate %s: not a regular file (mode %s)", path, info.Mode())
-	}
-
-	freed := uint64(info.Size())
-	if freed == 0 {
-		return 0, nil
-	}
-	if err := file.Truncate(0); err != nil {
-		return 0, fmt.Errorf("failed to truncate: %w", err)
-	}
 	return freed, nil
 }
 
-// deleteFile removes a single file, optionally shredding it first. It returns
-// the number of bytes actually reclaimed from disk, measured immediately before
-// removal rather than taken from the (possibly stale) scan record.
-func (c *Cleaner) deleteFile(path string, shredEnabled bool) (uint64, error) {
+// trashFile moves a single file to the trash instead of deleting it. It returns
+// the file's size; none of it is reclaimed until the trash is purged.
+//
+// Like deleteFile, it reaches the file through its pinned parent directory
+// and hands the trash that descriptor, so the move is a renameat from a
+// directory that cannot have been swapped for a symlink.
+func (c *Cleaner) trashFile(roots []string, path, category string) (uint64, error) {
 	if c.isProtectedPath(path) {
 		return 0, moonbiterrors.NewPathProtectedError(path, c.safetyConfig.ProtectedPaths)
 	}
 
-	// Lstat, not Stat: a symlink must be judged as a symlink. Stat reports the
-	// target's mode and size, which let a link inside a cache directory stand in
-	// for an arbitrary file elsewhere on the system.
-	info, err := os.Lstat(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return 0, moonbiterrors.NewFileNotFoundError(path, err)
+	if c.trash == nil {
+		bin, err := trash.Open()
+		if err != nil {
+			return 0, fmt.Errorf("failed to open trash: %w", err)
 		}
-		if os.IsPermission(err) {
-			return 0, moonbiterrors.NewPermissionDeniedError(path, err)
+		c.trash = bin
+	}
+
+	dirfd, name, err := openParentBeneath(roots, path)
+	if err == nil {
+		var entry trash.Entry
+		// PutAt refuses anything but a regular file, judged without
+		// following the name.
+		entry, err = c.trash.PutAt(dirfd, name, path, category)
+		syscall.Close(dirfd)
+		if err == nil {
+			return uint64(entry.Size), nil
 		}
-		return 0, fmt.Errorf("failed to stat file: %w", err)
 	}
-
-	if !info.Mode().IsRegular() {
-		return 0, fmt.Errorf("refusing to delete %s: not a regular file (mode %s)", path, info.Mode())
+	if os.IsNotExist(err) {
+		return 0, moonbiterrors.NewFileNotFoundError(path, err)
 	}
+	if os.IsPermission(err) {
+		return 0, moonbiterrors.NewPermissionDeniedError(path, err)
+	}
+	return 0, err
+}
 
-	// Size on disk now, not the size recorded at scan time. A log the writing
-	// daemon truncated between scan and clean must not be reported at its old size.
-	freed := uint64(info.Size())
-
-	if shredEnabled && info.Size() > 0 {
-		if err := c.shredFile(path, info.Size()); err != nil {
-			return 0, fmt.Errorf("failed to shred file: %w", err)
-		}
+// deleteFile removes a single file, shredding it first with shredPasses
+// passes when that is above zero. It returns the number of bytes actually
+// reclaimed from disk, measured immediately before removal rather than taken
+// from the (possibly stale) scan record.
+//
+// The file is reached from the category root in roots that contains it, one
+// pinned directory at a time (see unlinkBeneath), so a parent directory
+// swapped for a symlink after revalidation cannot redirect the unlink.
+func (c *Cleaner) deleteFile(roots []string, path string, shredPasses int) (uint64, error) {
+	if c.isProtectedPath(path) {
+		return 0, moonbiterrors.NewPathProtectedError(path, c.safetyConfig.ProtectedPaths)
 	}
 
-	err = os.Remove(path)
+	freed, err := c.unlinkBeneath(roots, path, shredPasses)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, moonbiterrors.NewFileNotFoundError(path, err)
+		}
 		if os.IsPermission(err) {
 			return 0, moonbiterrors.NewPermissionDeniedError(path, err)
 		}
@@ -305,31 +425,9 @@ func (c *Cleaner) deleteFile(path string, shredEnabled bool) (uint64, error) {
 	return freed, nil
 }
 
-// ShredFile overwrites a file with random data before deletion
-func (c *Cleaner) shredFile(path string, size int64) error {
-	// O_NOFOLLOW makes the kernel refuse the open outright if the final path
-	// component is a symlink, so shredding can never reach through a link and
-	// overwrite a file outside the directory being cleaned.
-	file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NOFOLLOW, 0)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Re-check on the open descriptor: a regular file cannot have been swapped
-	// for something else between the Lstat above and this open.
-	st, err := file.Stat()
-	if err != nil {
-		return err
-	}
-	if !st.Mode().IsRegular() {
-		return fmt.Errorf("refusing to shred %s: not a regular file (mode %s)", path, st.Mode())
-	}
-	if st.Size() < size {
-		size = st.Size()
-	}
-
-	passes := c.safetyConfig.ShredPasses
+// overwrite writes random data over the first size bytes of file, passes
+// times, syncing after each pass.
+func overwrite(file *os.File, size int64, passes int) error {
 	if passes < 1 {
 		passes = 1
 	}
@@ -372,20 +470,15 @@ func (c *Cleaner) shredFile(path string, size int64) error {
 }
 
 func (c *Cleaner) performSafetyChecks(category *config.Category, dryRun bool) error {
+	limits := c.safetyConfig.limits(category.Name)
 	if category.Risk == config.High && !dryRun {
-		if c.safetyConfig.SafeMode {
+		if limits.SafeMode {
 			return fmt.Errorf("high-risk category '%s' requires manual confirmation", category.Name)
 		}
 	}
 
-	maxBytes := c.safetyConfig.MaxDeletionSize * 1024 * 1024
-	if category.Size > maxBytes {
-		return moonbiterrors.NewSafetyCheckFailedError(
-			"category size exceeds maximum allowed",
-			category.Name,
-			category.Size,
-			maxBytes,
-		)
+	if err := c.CheckDeletionSize(category); err != nil {
+		return err
 	}
 
 	for _, fileInfo := range category.Files {
@@ -397,6 +490,23 @@ func (c *Cleaner) performSafetyChecks(category *config.Category, dryRun bool) er
 	return nil
 }
 
+// CheckDeletionSize refuses a category larger than its max_deletion_size.
+// CleanCategory checks it itself; a caller that cleans one category in
+// several parts must check the whole category first, since each part alone
+// may be under the limit.
+func (c *Cleaner) CheckDeletionSize(category *config.Category) error {
+	maxBytes := c.safetyConfig.limits(category.Name).MaxDeletionSize
+	if category.Size > maxBytes {
+		return moonbiterrors.NewSafetyCheckFailedError(
+			"category size exceeds maximum allowed",
+			category.Name,
+			category.Size,
+			maxBytes,
+		)
+	}
+	return nil
+}
+
 // Check if a path is protected
 func (c *Cleaner) isProtectedPath(path string) bool {
 	absPath, err := filepath.Abs(path)
@@ -431,117 +541,156 @@ func (c *Cleaner) isProtectedPath(path string) bool {
 	return false
 }
 
-func (c *Cleaner) createBackup(category *config.Category) string {
-	timestamp := time.Now().Format("20060102_150405")
-
-	backupDir, err := paths.DataDir("backups")
-	if err != nil {
-		log.Printf("ERROR: Failed to determine backup directory: %v", err)
-		return ""
-	}
-
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		mbErr := moonbiterrors.NewBackupFailedError(category.Name, err)
-		log.Printf("ERROR: %s", mbErr.UserMessage())
-		return ""
-	}
-
-	backupPath := filepath.Join(backupDir, fmt.Sprintf("%s_%s.backup",
-		sanitizeName(category.Name), timestamp))
-
-	if err := c.createBackupMetadata(backupPath, category, timestamp); err != nil {
-		mbErr := moonbiterrors.NewBackupFailedError(category.Name, err)
-		log.Printf("ERROR: %s", mbErr.UserMessage())
-		return ""
+// createBackup adds the category's files to this run's backup and saves the
+// manifest. It returns the backup's path, or "" if the backup could not be
+// started, and the files that could not be backed up. Files going to the
+// trash are skipped; the trash already keeps them.
+func (c *Cleaner) createBackup(category *config.Category) (string, map[string]error) {
+	if c.backups == nil {
+		store, err := backup.Open()
+		if err != nil {
+			log.Printf("ERROR: Failed to determine backup directory: %v", err)
+			return "", nil
+		}
+		c.backups = store
 	}
-
-	backupFilesDir := backupPath + ".files"
-	if err := os.MkdirAll(backupFilesDir, 0755); err != nil {
-		mbErr := moonbiterrors.NewBackupFailedError(category.Name, err)
-		log.Printf("ERROR: %s", mbErr.UserMessage())
-		return ""
+	if c.backupRun == nil {
+		run, err := c.backups.Create()
+		if err != nil {
+			mbErr := moonbiterrors.NewBackupFailedError(category.Name, err)
+			log.Printf("ERROR: %s", mbErr.UserMessage())
+			return "", nil
+		}
+		c.backupRun = run
 	}
 
+	failed := make(map[string]error)
 	for _, file := range category.Files {
-		if err := c.backupFile(file.Path, backupFilesDir); err != nil {
-			log.Printf("ERROR: Failed to backup file %s: %v", file.Path, err)
+		if fileAction(category, file) == config.ActionTrash {
 			continue
 		}
+		if _, err := c.backups.Add(c.backupRun, file.Path, category.Name); err != nil {
+			log.Printf("ERROR: Failed to backup file %s: %v", file.Path, err)
+			failed[file.Path] = err
+		}
 	}
 
-	return backupPath
+	if err := c.backups.Save(c.backupRun); err != nil {
+		mbErr := moonbiterrors.NewBackupFailedError(category.Name, err)
+		log.Printf("ERROR: %s", mbErr.UserMessage())
+		return "", nil
+	}
+	return c.backups.Path(c.backupRun.Name), failed
 }
 
-// sanitizeName removes special characters from names for safe filenames
-func sanitizeName(name string) string {
-	// Replace spaces and special chars with underscores
-	name = strings.ReplaceAll(name, " ", "_")
-	name = strings.ReplaceAll(name, "/", "_")
-	name = strings.ReplaceAll(name, "\\", "_")
-	return name
+// BackupPolicy converts configured backup limits into a prune policy.
+func BackupPolicy(cfg config.BackupConfig) (backup.Policy, error) {
+	if err := cfg.Validate(); err != nil {
+		return backup.Policy{}, err
+	}
+	policy := backup.Policy{KeepLast: cfg.KeepLast}
+	if cfg.MaxAge != "" {
+		policy.MaxAge, _ = utils.ParseAge(cfg.MaxAge)
+	}
+	if cfg.MaxSize != "" {
+		policy.MaxSize, _ = utils.ParseSize(cfg.MaxSize)
+	}
+	return policy, nil
 }
 
-// createBackupMetadata creates a JSON metadata file for the backup
-func (c *Cleaner) createBackupMetadata(backupPath string, category *config.Category, timestamp string) error {
-	metadata := map[string]interface{}{
-		"created_at": time.Now().Format(time.RFC3339),
-		"timestamp":  timestamp,
-		"category":   category.Name,
-		"file_count": len(category.Files),
-		"total_size": category.Size,
-		"files":      category.Files,
+// PruneBackups applies the configured backup limits after a clean. It does
+// nothing unless this run made a backup, and never removes that backup.
+func (c *Cleaner) PruneBackups() (*backup.PruneResult, error) {
+	if c.backupRun == nil || c.cfg == nil {
+		return nil, nil
 	}
-
-	data, err := json.MarshalIndent(metadata, "", "  ")
+	policy, err := BackupPolicy(c.cfg.Backup)
 	if err != nil {
-		log.Printf("ERROR: Failed to marshal backup metadata for %s: %v", category.Name, err)
-		return err
+		return nil, err
 	}
-
-	metaPath := backupPath + ".json"
-	return os.WriteFile(metaPath, data, 0644)
+	if policy.IsZero() {
+		return nil, nil
+	}
+	result, err := c.backups.Prune(policy, time.Now(), false)
+	return &result, err
 }
 
-// backupFile copies a single file to backup directory
-func (c *Cleaner) backupFile(srcPath, backupDir string) error {
-	srcInfo, err := os.Stat(srcPath)
-	if err != nil {
-		return fmt.Errorf("stat source file %s: %w", srcPath, err)
+// LoadBackup reads the manifest of the backup at backupPath. A backup in the
+// old layout is converted from its metadata JSON; it has no content hashes
+// and Compression is empty.
+func LoadBackup(backupPath string) (*backup.Manifest, error) {
+	if _, err := os.Stat(backupPath + ".json"); err != nil {
+		return backup.NewStore(filepath.Dir(backupPath)).Load(filepath.Base(backupPath))
 	}
 
-	// Skip if it's a directory (we only backup files)
-	if srcInfo.IsDir() {
-		return nil
+	data, err := os.ReadFile(backupPath + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup metadata: %w", err)
+	}
+	var metadata struct {
+		CreatedAt string            `json:"created_at"`
+		Category  string            `json:"category"`
+		Files     []config.FileInfo `json:"files"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse backup metadata: %w", err)
 	}
 
-	// Create safe filename (hash of original path to avoid collisions)
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(srcPath)))
-	dstPath := filepath.Join(backupDir, hash[:16])
-
-	// Copy file
-	src, err := os.Open(srcPath)
-	if err != nil {
-		log.Printf("ERROR: Failed to open source file %s: %v", srcPath, err)
-		return err
+	m := &backup.Manifest{Name: filepath.Base(backupPath), Files: []backup.File{}}
+	m.CreatedAt, _ = time.Parse(time.RFC3339, metadata.CreatedAt)
+	if metadata.Category != "" {
+		m.Categories = []string{metadata.Category}
 	}
-	defer src.Close()
+	for _, f := range metadata.Files {
+		modTime, _ := time.Parse(time.RFC3339, f.ModTime)
+		m.Files = append(m.Files, backup.File{
+			Path: f.Path, Category: metadata.Category, Size: int64(f.Size),
+			UID: -1, GID: -1, ModTime: modTime,
+		})
+		m.TotalSize += f.Size
+	}
+	m.FileCount = len(m.Files)
+	return m, nil
+}
 
-	dst, err := os.Create(dstPath)
+// RestoreBackup restores every file in the backup at backupPath, a store
+// directory joined with a backup name as listed by ListBackups. Backups made
+// before the content-addressed store, with a <name>.json beside them, are
+// still restored from their per-file copies.
+func RestoreBackup(backupPath string) error {
+	if _, err := os.Stat(backupPath + ".json"); err == nil {
+		return restoreLegacyBackup(backupPath)
+	}
+	store := backup.NewStore(filepath.Dir(backupPath))
+	manifest, err := store.Load(filepath.Base(backupPath))
 	if err != nil {
-		log.Printf("ERROR: Failed to create backup destination %s: %v", dstPath, err)
 		return err
 	}
-	defer dst.Close()
+	return store.Restore(manifest)
+}
 
-	_, err = io.Copy(dst, src)
+// RestoreBackupFiles restores the files opts selects from the backup at
+// backupPath. Backups in the old layout only support restoring everything
+// over the original paths.
+func RestoreBackupFiles(backupPath string, opts backup.RestoreOptions) (backup.RestoreResult, error) {
+	if _, err := os.Stat(backupPath + ".json"); err == nil {
+		if len(opts.Paths) > 0 || len(opts.Categories) > 0 || opts.To != "" || opts.Conflict != backup.ConflictOverwrite {
+			return backup.RestoreResult{}, fmt.Errorf("backup %s predates selective restore; "+
+				"restore all of it with --conflict %s", filepath.Base(backupPath), backup.ConflictOverwrite)
+		}
+		return backup.RestoreResult{}, restoreLegacyBackup(backupPath)
+	}
+	store := backup.NewStore(filepath.Dir(backupPath))
+	manifest, err := store.Load(filepath.Base(backupPath))
 	if err != nil {
-		log.Printf("ERROR: Failed to copy file %s to backup: %v", srcPath, err)
+		return backup.RestoreResult{}, err
 	}
-	return err
+	return store.RestoreSelected(manifest, opts)
 }
 
-// RestoreBackup restores files from a backup
-func RestoreBackup(backupPath string) error {
+// restoreLegacyBackup restores a backup written in the old layout:
+// <name>.json metadata and <name>.files/<sha256(path)[:16]> copies.
+func restoreLegacyBackup(backupPath string) error {
 	// Read metadata
 	metaPath := backupPath + ".json"
 	data, err := os.ReadFile(metaPath)
@@ -616,51 +765,66 @@ func RestoreBackup(backupPath string) error {
 	return nil
 }
 
-// ListBackups returns a list of available backups
+// ListBackups returns the names of available backups, oldest first, including
+// any left in the old layout.
 func ListBackups() ([]string, error) {
-	backupDir, err := paths.DataDir("backups")
+	store, err := backup.Open()
 	if err != nil {
 		return nil, err
 	}
-
-	// Check if backup directory exists
-	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
-		return []string{}, nil
-	}
-
-	entries, err := os.ReadDir(backupDir)
+	backups, err := store.List()
 	if err != nil {
 		return nil, err
 	}
 
-	var backups []string
+	entries, err := os.ReadDir(store.Root())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
 	for _, entry := range entries {
-		if strings.HasSuffix(entry.Name(), ".backup") {
-			backups = append(backups, entry.Name())
+		if name, ok := strings.CutSuffix(entry.Name(), ".backup.json"); ok {
+			backups = append(backups, name+".backup")
 		}
 	}
 
+	// Old-layout names start with the category and new ones with the time,
+	// so order by the time in each name rather than by the name itself.
+	sort.SliceStable(backups, func(i, j int) bool {
+		ti, tj := backupTime(backups[i]), backupTime(backups[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return backups[i] < backups[j]
+	})
 	return backups, nil
 }
 
+// backupTimeLayout is the timestamp both backup layouts put in their names.
+const backupTimeLayout = "20060102_150405"
+
+// backupTime reads the creation time from a backup name: the start of a
+// store backup's name ("20250105_120000", "20250105_120000_2") or the end
+// of an old-layout one ("Logs_20250105_120000.backup"). It is zero when the
+// name carries no time.
+func backupTime(name string) time.Time {
+	n := len(backupTimeLayout)
+	if stem, ok := strings.CutSuffix(name, ".backup"); ok {
+		if len(stem) >= n {
+			if t, err := time.Parse(backupTimeLayout, stem[len(stem)-n:]); err == nil {
+				return t
+			}
+		}
+		return time.Time{}
+	}
+	if len(name) >= n {
+		if t, err := time.Parse(backupTimeLayout, name[:n]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
 // GetDefaultSafetyConfig returns default safety configuration
 func GetDefaultSafetyConfig() *SafetyConfig {
-	return &SafetyConfig{
-		RequireConfirmation: true,
-		MaxDeletionSize:     512000, // 500GB (in MB) - increased for systemd journals and large caches
-		SafeMode:            true,
-		ShredPasses:         1,
-		ProtectedPaths: []string{
-			"/bin",
-			"/usr/bin",
-			"/usr/sbin",
-			"/sbin",
-			"/etc",
-			"/boot",
-			"/sys",
-			"/proc",
-			// Note: /var/lib removed to allow Docker cleanup
-			// Categories should be specific about what they clean
-		},
-	}
+	return NewSafetyConfig(config.DefaultSafetyConfig())
 }
@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/Nomadcxx/moonbit/internal/audit"
 	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/trash"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -143,6 +146,62 @@ func TestPerformSafetyChecks(t *testing.T) {
 	})
 }
 
+func TestPerformSafetyChecksUsesConfiguredPolicy(t *testing.T) {
+	cfg := config.DefaultConfig()
+	off := false
+	cfg.Safety.MaxDeletionSize = "10G"
+	cfg.Safety.ProtectedPaths = []string{"/srv/keep"}
+	cfg.Safety.Categories = map[string]config.SafetyOverride{
+		"System Logs": {MaxDeletionSize: "100M"},
+		"Scratch":     {SafeMode: &off},
+	}
+	c := NewCleaner(cfg)
+
+	category := func(name string, size uint64, risk config.RiskLevel, path string) *config.Category {
+		return &config.Category{
+			Name:  name,
+			Files: []config.FileInfo{{Path: path, Size: size}},
+			Size:  size,
+			Risk:  risk,
+		}
+	}
+
+	err := c.performSafetyChecks(category("System Logs", 200<<20, config.Low, "/var/log/x.1"), false)
+	assert.ErrorContains(t, err, "exceeds maximum", "the per-category limit applies")
+	assert.NoError(t, c.performSafetyChecks(category("User Cache", 200<<20, config.Low, "/tmp/x"), false))
+	assert.ErrorContains(t, c.performSafetyChecks(category("User Cache", 20<<30, config.Low, "/tmp/x"), false), "exceeds maximum")
+
+	assert.NoError(t, c.performSafetyChecks(category("Scratch", 1024, config.High, "/tmp/x"), false),
+		"safe mode is off for this category only")
+	assert.Error(t, c.performSafetyChecks(category("Other", 1024, config.High, "/tmp/x"), false))
+
+	assert.ErrorContains(t, c.performSafetyChecks(category("User Cache", 1024, config.Low, "/srv/keep/a"), false), "protected")
+	assert.True(t, c.isProtectedPath("/etc/passwd"), "configured paths add to the built-in ones")
+}
+
+func TestMaxDeletionSizeKeepsSubMiBPrecision(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Safety.MaxDeletionSize = "1500KiB"
+	c := NewCleaner(cfg)
+
+	category := func(size uint64) *config.Category {
+		return &config.Category{
+			Name:  "User Cache",
+			Files: []config.FileInfo{{Path: "/tmp/x", Size: size}},
+			Size:  size,
+			Risk:  config.Low,
+		}
+	}
+
+	assert.Equal(t, uint64(1500*1024), c.safetyConfig.limits("User Cache").MaxDeletionSize)
+	assert.NoError(t, c.CheckDeletionSize(category(1400*1024)), "1400KiB is under a 1500KiB limit")
+	assert.ErrorContains(t, c.CheckDeletionSize(category(1600*1024)), "exceeds maximum")
+
+	cfg.Safety.MaxDeletionSize = "512KiB"
+	c = NewCleaner(cfg)
+	assert.NoError(t, c.CheckDeletionSize(category(100*1024)), "a limit under 1MiB must not become zero")
+}
+
 func TestCleanCategoryDryRun(t *testing.T) {
 	cfg := config.DefaultConfig()
 	c := NewCleaner(cfg)
@@ -196,7 +255,8 @@ func TestCleanCategoryWithRealFiles(t *testing.T) {
 
 	// Create category
 	category := &config.Category{
-		Name: "Test",
+		Name:  "Test",
+		Paths: []string{tempDir},
 		Files: []config.FileInfo{
 			{Path: file1, Size: 14},
 			{Path: file2, Size: 14},
@@ -231,6 +291,49 @@ func TestCleanCategoryWithRealFiles(t *testing.T) {
 	assert.True(t, os.IsNotExist(err))
 }
 
+func TestCleanCategoryTrashActionMovesFilesAside(t *testing.T) {
+	c := NewCleaner(config.DefaultConfig())
+	tempDir := t.TempDir()
+	c.trash = trash.NewBin("", nil, trash.NewStore(filepath.Join(tempDir, "trash")))
+
+	file := filepath.Join(tempDir, "cache", "thumb.png")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.NoError(t, os.WriteFile(file, []byte("image"), 0644))
+
+	category := &config.Category{
+		Name:  "Thumbnails",
+		Paths: []string{filepath.Dir(file)},
+		Files: []config.FileInfo{{Path: file, Size: 5, CategoryAction: config.ActionTrash}},
+		Size:  5,
+		Risk:  config.Medium,
+	}
+
+	progressCh := make(chan CleanMsg, 10)
+	go c.CleanCategory(context.Background(), category, false, progressCh)
+	var complete *CleanComplete
+	for msg := range progressCh {
+		require.NoError(t, msg.Error)
+		if msg.Complete != nil {
+			complete = msg.Complete
+		}
+	}
+
+	require.NotNil(t, complete)
+	assert.Equal(t, 1, complete.FilesDeleted)
+	assert.Equal(t, 1, complete.FilesTrashed)
+	assert.Equal(t, uint64(5), complete.BytesTrashed)
+	assert.Zero(t, complete.BytesFreed, "nothing is freed until the trash is purged")
+	assert.NoFileExists(t, file)
+
+	entries, err := c.trash.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, file, entries[0].OriginalPath)
+	assert.Equal(t, "Thumbnails", entries[0].Category)
+	require.NoError(t, c.trash.Restore(entries[0]))
+	assert.FileExists(t, file)
+}
+
 func TestCleanCategoryReturnsPartialFailure(t *testing.T) {
 	cfg := config.DefaultConfig()
 	c := NewCleaner(cfg)
@@ -294,7 +397,7 @@ func TestDeleteFile(t *testing.T) {
 		testFile := filepath.Join(tempDir, "test.txt")
 		os.WriteFile(testFile, []byte("test"), 0644)
 
-		freed, err := c.deleteFile(testFile, false)
+		freed, err := c.deleteFile([]string{tempDir}, testFile, 0)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(4), freed, "should report the bytes actually reclaimed")
 
@@ -303,18 +406,18 @@ func TestDeleteFile(t *testing.T) {
 	})
 
 	t.Run("Protected path rejection", func(t *testing.T) {
-		_, err := c.deleteFile("/bin/ls", false)
+		_, err := c.deleteFile(nil, "/bin/ls", 0)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "protected")
 	})
 
 	t.Run("Nonexistent file", func(t *testing.T) {
-		_, err := c.deleteFile("/tmp/nonexistent.txt", false)
+		_, err := c.deleteFile([]string{"/tmp"}, "/tmp/nonexistent.txt", 0)
 		assert.Error(t, err)
 	})
 }
 
-func TestShredFile(t *testing.T) {
+func TestShredAt(t *testing.T) {
 	cfg := config.DefaultConfig()
 	c := NewCleaner(cfg)
 
@@ -324,171 +427,161 @@ func TestShredFile(t *testing.T) {
 		content := []byte("sensitive data that should be shredded")
 		os.WriteFile(testFile, content, 0644)
 
-		info, err := os.Stat(testFile)
+		dirfd, err := syscall.Open(tempDir, syscall.O_RDONLY|syscall.O_DIRECTORY, 0)
+		require.NoError(t, err)
+		defer syscall.Close(dirfd)
+		st, err := regularFileAt(dirfd, "test.txt", testFile, "shred")
 		require.NoError(t, err)
-		originalSize := info.Size()
 
-		err = c.shredFile(testFile, originalSize)
+		err = c.shredAt(dirfd, "test.txt", testFile, st, 1)
 		assert.NoError(t, err)
 
-		// File should still exist (shredFile only overwrites, doesn't delete)
-		info, err = os.Stat(testFile)
+		// File should still exist (shredAt only overwrites, doesn't delete)
+		info, err := os.Stat(testFile)
 		assert.NoError(t, err)
-		assert.Equal(t, originalSize, info.Size())
+		assert.Equal(t, st.Size, info.Size())
 
 		// Verify file content was overwritten (should be random data, not original)
 		shreddedContent, err := os.ReadFile(testFile)
 		assert.NoError(t, err)
 		assert.NotEqual(t, content, shreddedContent) // Content should be different
-		assert.Equal(t, originalSize, int64(len(shreddedContent)))
+		assert.Equal(t, st.Size, int64(len(shreddedContent)))
+	})
+
+	t.Run("Shred and delete", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testFile := filepath.Join(tempDir, "test.txt")
+		require.NoError(t, os.WriteFile(testFile, []byte("sensitive"), 0644))
+
+		freed, err := c.unlinkBeneath([]string{tempDir}, testFile, 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(9), freed)
+		_, err = os.Stat(testFile)
+		assert.True(t, os.IsNotExist(err))
 	})
 
 	t.Run("Shred nonexistent file", func(t *testing.T) {
-		err := c.shredFile("/tmp/nonexistent.txt", 1024)
+		_, err := c.unlinkBeneath([]string{"/tmp"}, "/tmp/nonexistent.txt", 1)
 		assert.Error(t, err)
 	})
 }
 
-func TestSanitizeName(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{"Simple name", "Test", "Test"},
-		{"With spaces", "Test Category", "Test_Category"},
-		{"With slashes", "Test/Category", "Test_Category"},
-		{"With backslashes", "Test\\Category", "Test_Category"},
-		{"Complex", "Test/Category Name\\Path", "Test_Category_Name_Path"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := sanitizeName(tt.input)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
 func TestCreateBackup(t *testing.T) {
-	cfg := config.DefaultConfig()
-	c := NewCleaner(cfg)
-
-	// Enable backup for testing
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	c := NewCleaner(config.DefaultConfig())
 	c.backupEnabled = true
 
 	tempDir := t.TempDir()
 	testFile1 := filepath.Join(tempDir, "test1.txt")
 	testFile2 := filepath.Join(tempDir, "test2.txt")
-
-	os.WriteFile(testFile1, []byte("test content 1"), 0644)
-	os.WriteFile(testFile2, []byte("test content 2"), 0644)
+	require.NoError(t, os.WriteFile(testFile1, []byte("same content"), 0644))
+	require.NoError(t, os.WriteFile(testFile2, []byte("same content"), 0600))
 
 	category := &config.Category{
 		Name: "Test Category",
 		Files: []config.FileInfo{
-			{Path: testFile1, Size: 14},
-			{Path: testFile2, Size: 14},
+			{Path: testFile1, Size: 12},
+			{Path: testFile2, Size: 12},
+			{Path: filepath.Join(tempDir, "missing"), Size: 1},
 		},
-		Size: 28,
+		Size: 25,
 	}
 
-	// Override backup directory to use temp dir
-	originalDataHome := os.Getenv("XDG_DATA_HOME")
-	defer os.Setenv("XDG_DATA_HOME", originalDataHome)
-
-	os.Setenv("XDG_DATA_HOME", tempDir)
-
-	backupPath := c.createBackup(category)
-	assert.NotEmpty(t, backupPath)
-	assert.Contains(t, backupPath, "Test_Category")
-	assert.Contains(t, backupPath, ".backup")
-
-	// Verify backup files directory exists
-	backupFilesDir := backupPath + ".files"
-	_, err := os.Stat(backupFilesDir)
-	assert.NoError(t, err)
-
-	// Verify metadata file exists
-	metaPath := backupPath + ".json"
-	_, err = os.Stat(metaPath)
-	assert.NoError(t, err)
+	backupPath, failed := c.createBackup(category)
+	require.NotEmpty(t, backupPath)
+	assert.Len(t, failed, 1, "a file that cannot be read is reported, not silently dropped")
+
+	manifest, err := c.backups.Load(filepath.Base(backupPath))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Test Category"}, manifest.Categories)
+	assert.Equal(t, 2, manifest.FileCount)
+	assert.Equal(t, uint64(24), manifest.TotalSize)
+	assert.Equal(t, manifest.Files[0].Hash, manifest.Files[1].Hash)
+	assert.Equal(t, os.FileMode(0600), manifest.Files[1].Mode.Perm())
+
+	blobs, err := filepath.Glob(filepath.Join(c.backups.Root(), "blobs", "*", "*"))
+	require.NoError(t, err)
+	assert.Len(t, blobs, 1, "identical contents are stored once")
+
+	// A second category in the same run shares the manifest.
+	other := filepath.Join(tempDir, "other.txt")
+	require.NoError(t, os.WriteFile(other, []byte("other"), 0644))
+	secondPath, _ := c.createBackup(&config.Category{Name: "Second", Files: []config.FileInfo{{Path: other, Size: 5}}})
+	assert.Equal(t, backupPath, secondPath)
+	manifest, err = c.backups.Load(filepath.Base(backupPath))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Test Category", "Second"}, manifest.Categories)
+	assert.Equal(t, 3, manifest.FileCount)
 }
 
-func TestCreateBackupMetadata(t *testing.T) {
-	cfg := config.DefaultConfig()
-	c := NewCleaner(cfg)
+func TestCleanCategoryKeepsFilesThatWereNotBackedUp(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	c := NewCleaner(config.DefaultConfig())
+	c.backupEnabled = true
 
 	tempDir := t.TempDir()
-	backupPath := filepath.Join(tempDir, "test.backup")
+	readable := filepath.Join(tempDir, "readable")
+	require.NoError(t, os.WriteFile(readable, []byte("r"), 0644))
+	// A symlink cannot be backed up (O_NOFOLLOW), so it must not be deleted.
+	link := filepath.Join(tempDir, "link")
+	require.NoError(t, os.Symlink(readable, link))
 
 	category := &config.Category{
-		Name: "Test",
-		Files: []config.FileInfo{
-			{Path: "/tmp/test.txt", Size: 1024},
-		},
-		Size: 1024,
+		Name:  "Test",
+		Paths: []string{tempDir},
+		Files: []config.FileInfo{{Path: readable, Size: 1}, {Path: link, Size: 1}},
+		Size:  2,
+	}
+	progressCh := make(chan CleanMsg, 10)
+	go c.CleanCategory(context.Background(), category, false, progressCh)
+	var complete *CleanComplete
+	for msg := range progressCh {
+		if msg.Complete != nil {
+			complete = msg.Complete
+		}
 	}
 
-	err := c.createBackupMetadata(backupPath, category, "20250105_120000")
-	assert.NoError(t, err)
-
-	// Verify metadata file was created
-	metaPath := backupPath + ".json"
-	data, err := os.ReadFile(metaPath)
-	assert.NoError(t, err)
-	assert.Contains(t, string(data), "Test")
-	assert.Contains(t, string(data), "20250105_120000")
-}
-
-func TestBackupFile(t *testing.T) {
-	cfg := config.DefaultConfig()
-	c := NewCleaner(cfg)
-
-	tempDir := t.TempDir()
-	srcFile := filepath.Join(tempDir, "source.txt")
-	backupDir := filepath.Join(tempDir, "backup")
-
-	content := []byte("test backup content")
-	os.WriteFile(srcFile, content, 0644)
-	os.MkdirAll(backupDir, 0755)
-
-	err := c.backupFile(srcFile, backupDir)
-	assert.NoError(t, err)
-
-	// Verify backup file exists (hashed name)
-	files, err := os.ReadDir(backupDir)
+	require.NotNil(t, complete)
+	assert.Equal(t, 1, complete.FilesDeleted)
+	require.Len(t, complete.Errors, 1)
+	assert.Contains(t, complete.Errors[0], "not backed up")
+	_, err := os.Lstat(link)
 	assert.NoError(t, err)
-	assert.Greater(t, len(files), 0)
 
-	// Verify content matches
-	backupFile := filepath.Join(backupDir, files[0].Name())
-	backupContent, err := os.ReadFile(backupFile)
-	assert.NoError(t, err)
-	assert.Equal(t, content, backupContent)
+	require.NoError(t, RestoreBackup(complete.BackupPath))
+	data, err := os.ReadFile(readable)
+	require.NoError(t, err)
+	assert.Equal(t, "r", string(data))
 }
 
 func TestListBackups(t *testing.T) {
 	tempDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", tempDir)
 
-	// Override data home
-	originalDataHome := os.Getenv("XDG_DATA_HOME")
-	defer os.Setenv("XDG_DATA_HOME", originalDataHome)
-
-	os.Setenv("XDG_DATA_HOME", tempDir)
+	c := NewCleaner(config.DefaultConfig())
+	file := filepath.Join(tempDir, "f")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0644))
+	backupPath, _ := c.createBackup(&config.Category{Name: "A", Files: []config.FileInfo{{Path: file}}})
+	require.NotEmpty(t, backupPath)
 
+	// Backups in the old layout are still listed, in time order with the
+	// new ones rather than after them by name.
 	backupDir := filepath.Join(tempDir, "moonbit", "backups")
-	os.MkdirAll(backupDir, 0755)
-
-	// Create some backup files
-	backup1 := filepath.Join(backupDir, "test1_20250105.backup")
-	backup2 := filepath.Join(backupDir, "test2_20250105.backup")
-	os.WriteFile(backup1, []byte("backup1"), 0644)
-	os.WriteFile(backup2, []byte("backup2"), 0644)
+	require.NoError(t, os.WriteFile(filepath.Join(backupDir, "Old_20250105_120000.backup.json"), []byte("{}"), 0644))
+	future := time.Now().AddDate(1, 0, 0).Format("20060102_150405")
+	require.NoError(t, os.WriteFile(filepath.Join(backupDir, "A_"+future+".backup.json"), []byte("{}"), 0644))
 
 	backups, err := ListBackups()
-	assert.NoError(t, err)
-	assert.GreaterOrEqual(t, len(backups), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Old_20250105_120000.backup", filepath.Base(backupPath), "A_" + future + ".backup"}, backups)
+}
+
+func TestBackupTime(t *testing.T) {
+	want := time.Date(2025, 1, 5, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, want, backupTime("20250105_120000"))
+	assert.Equal(t, want, backupTime("20250105_120000_2"))
+	assert.Equal(t, want, backupTime("System_Logs_20250105_120000.backup"))
+	assert.True(t, backupTime("notes").IsZero())
 }
 
 func TestRestoreBackup(t *testing.T) {
@@ -550,13 +643,97 @@ func TestRestoreBackupReturnsErrorForMissingBackupFile(t *testing.T) {
 		Size: 12,
 	}
 
-	cfg := config.DefaultConfig()
-	c := NewCleaner(cfg)
-	require.NoError(t, c.createBackupMetadata(backupPath, category, "20260602_120000"))
+	metadata, err := json.Marshal(map[string]interface{}{"category": category.Name, "files": category.Files})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(backupPath+".json", metadata, 0644))
 	require.NoError(t, os.MkdirAll(backupPath+".files", 0755))
 
-	err := RestoreBackup(backupPath)
+	err = RestoreBackup(backupPath)
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "restore incomplete")
 }
+
+func TestPruneBackupsOnlyAfterThisRunBackedUp(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", tempDir)
+	cfg := config.DefaultConfig()
+	cfg.Backup.KeepLast = 1
+
+	c := NewCleaner(cfg)
+	result, err := c.PruneBackups()
+	require.NoError(t, err)
+	assert.Nil(t, result, "no backup this run, nothing to prune")
+
+	file := filepath.Join(tempDir, "f")
+	for i := 0; i < 3; i++ {
+		require.NoError(t, os.WriteFile(file, []byte{byte(i)}, 0644))
+		c = NewCleaner(cfg)
+		path, _ := c.createBackup(&config.Category{Name: "A", Files: []config.FileInfo{{Path: file}}})
+		require.NotEmpty(t, path)
+	}
+	result, err = c.PruneBackups()
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Len(t, result.Removed, 2)
+
+	backups, err := ListBackups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{c.backupRun.Name}, backups, "the run's own backup survives")
+}
+
+func TestLoadBackupReadsLegacyMetadata(t *testing.T) {
+	backupPath := filepath.Join(t.TempDir(), "Logs_20250105_120000.backup")
+	metadata, err := json.Marshal(map[string]interface{}{
+		"created_at": "2025-01-05T12:00:00Z",
+		"category":   "Logs",
+		"files":      []config.FileInfo{{Path: "/var/log/a.1", Size: 10}, {Path: "/var/log/b.1", Size: 5}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(backupPath+".json", metadata, 0644))
+
+	m, err := LoadBackup(backupPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Logs"}, m.Categories)
+	assert.Equal(t, 2, m.FileCount)
+	assert.Equal(t, uint64(15), m.TotalSize)
+	assert.Empty(t, m.Compression)
+}
+
+func TestCleanCategoryAuditsPerFileOutcomes(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Audit.PerFile = true
+	c := NewCleaner(cfg)
+	require.NotNil(t, c.auditLog)
+	defer c.Close()
+
+	tempDir := t.TempDir()
+	present := filepath.Join(tempDir, "present.log")
+	require.NoError(t, os.WriteFile(present, []byte("12345"), 0644))
+	missing := filepath.Join(tempDir, "missing.log")
+	category := &config.Category{
+		Name:  "Logs",
+		Paths: []string{tempDir},
+		Files: []config.FileInfo{{Path: present, Size: 5}, {Path: missing, Size: 3}},
+		Size:  8,
+		Risk:  config.Low,
+	}
+
+	progressCh := make(chan CleanMsg, 10)
+	go c.CleanCategory(context.Background(), category, false, progressCh)
+	for range progressCh {
+	}
+
+	records, _, err := audit.ReadFile(c.auditLog.Path(), audit.Filter{Operation: "clean", Category: "logs"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	r := records[0]
+	assert.Equal(t, "partial", r.Result)
+	assert.Equal(t, 1, r.FilesDeleted)
+	assert.Equal(t, 1, r.FilesFailed)
+	require.Len(t, r.Files, 2)
+	assert.Equal(t, audit.FileOutcome{Path: present, Action: "delete", Bytes: 5}, r.Files[0])
+	assert.Equal(t, missing, r.Files[1].Path)
+	assert.NotEmpty(t, r.Files[1].Error)
+}
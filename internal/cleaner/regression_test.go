@@ -5,9 +5,12 @@ import (
 	"crypto/sha256"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/trash"
 )
 
 func runClean(t *testing.T, cat *config.Category) (deleted int, freed uint64, errs []string) {
@@ -80,8 +83,8 @@ func TestShredDoesNotFollowSymlinks(t *testing.T) {
 	}
 }
 
-// SEC-1 at the syscall layer: shredFile itself must refuse a symlink.
-func TestShredFileRefusesSymlink(t *testing.T) {
+// SEC-1 at the syscall layer: shredding must refuse a symlink.
+func TestShredRefusesSymlink(t *testing.T) {
 	tmp := t.TempDir()
 	victim := filepath.Join(tmp, "victim")
 	if err := os.WriteFile(victim, []byte("precious"), 0644); err != nil {
@@ -94,11 +97,26 @@ func TestShredFileRefusesSymlink(t *testing.T) {
 		t.Skipf("symlinks unsupported: %v", err)
 	}
 
-	if err := NewCleaner(&config.Config{}).shredFile(link, 8); err == nil {
-		t.Error("shredFile must refuse to open a symlink (O_NOFOLLOW)")
+	c := NewCleaner(&config.Config{})
+	if _, err := c.unlinkBeneath([]string{tmp}, link, 1); err == nil {
+		t.Error("unlinkBeneath must refuse to shred a symlink")
+	}
+
+	// Even handed the victim's identity, shredAt must not open the link.
+	var st syscall.Stat_t
+	if err := syscall.Stat(victim, &st); err != nil {
+		t.Fatal(err)
+	}
+	dirfd, err := syscall.Open(tmp, syscall.O_RDONLY|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(dirfd)
+	if err := c.shredAt(dirfd, "link", link, st, 1); err == nil {
+		t.Error("shredAt must refuse to open a symlink (O_NOFOLLOW)")
 	}
 	if sha(t, victim) != before {
-		t.Error("shredFile overwrote the symlink target")
+		t.Error("shredding overwrote the symlink target")
 	}
 }
 
@@ -132,7 +150,8 @@ func TestReportedBytesReflectDiskNotCache(t *testing.T) {
 
 	cat := &config.Category{
 		Name: "Test", Risk: config.Low,
-		Size: 15 * 1024 * 1024,
+		Paths: []string{tmp},
+		Size:  15 * 1024 * 1024,
 		Files: []config.FileInfo{
 			{Path: shrunk, Size: 10 * 1024 * 1024},
 			{Path: gone, Size: 5 * 1024 * 1024},
@@ -163,7 +182,7 @@ func TestDeleteFileRefusesNonRegularFiles(t *testing.T) {
 
 	dir := filepath.Join(tmp, "adir")
 	os.MkdirAll(dir, 0755)
-	if _, err := c.deleteFile(dir, false); err == nil {
+	if _, err := c.deleteFile([]string{tmp}, dir, 0); err == nil {
 		t.Error("deleteFile must refuse a directory")
 	}
 
@@ -171,7 +190,7 @@ func TestDeleteFileRefusesNonRegularFiles(t *testing.T) {
 	target := filepath.Join(tmp, "target")
 	os.WriteFile(target, []byte("x"), 0644)
 	if err := os.Symlink(target, link); err == nil {
-		if _, err := c.deleteFile(link, false); err == nil {
+		if _, err := c.deleteFile([]string{tmp}, link, 0); err == nil {
 			t.Error("deleteFile must refuse a symlink")
 		}
 		if _, err := os.Stat(target); err != nil {
@@ -191,6 +210,7 @@ func TestTruncateActionReclaimsWithoutUnlinking(t *testing.T) {
 
 	deleted, freed, errs := runClean(t, &config.Category{
 		Name:   "Docker Container Logs",
+		Paths:  []string{tmp},
 		Risk:   config.Medium,
 		Action: config.ActionTruncate,
 		Size:   8192,
@@ -225,6 +245,7 @@ func TestPerFileTruncateActionIsHonoured(t *testing.T) {
 
 	_, freed, errs := runClean(t, &config.Category{
 		Name:  "Aggregate",
+		Paths: []string{tmp},
 		Risk:  config.Low,
 		Files: []config.FileInfo{{Path: logFile, Size: 512, CategoryAction: config.ActionTruncate}},
 	})
@@ -248,7 +269,7 @@ func TestTruncateFileRefusesSymlink(t *testing.T) {
 		t.Skipf("symlinks unsupported: %v", err)
 	}
 
-	if _, err := NewCleaner(&config.Config{}).truncateFile(link); err == nil {
+	if _, err := NewCleaner(&config.Config{}).truncateFile([]string{tmp}, link); err == nil {
 		t.Error("truncateFile must refuse a symlink (O_NOFOLLOW)")
 	}
 	st, _ := os.Stat(target)
@@ -256,3 +277,196 @@ func TestTruncateFileRefusesSymlink(t *testing.T) {
 		t.Error("truncateFile truncated through a symlink")
 	}
 }
+
+// SEC-4: a parent directory swapped for a symlink after revalidation must not
+// redirect the unlink. deleteFile walks from the category root with O_NOFOLLOW
+// at every component, so the swapped directory is refused, not followed.
+func TestDeleteRefusesParentSwappedForSymlink(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "cache")
+	sub := filepath.Join(root, "sub")
+	victimDir := filepath.Join(tmp, "victim")
+	os.MkdirAll(sub, 0755)
+	os.MkdirAll(victimDir, 0755)
+	os.WriteFile(filepath.Join(sub, "x.tmp"), []byte("cache"), 0644)
+	victim := filepath.Join(victimDir, "x.tmp")
+	os.WriteFile(victim, []byte("precious"), 0644)
+
+	// The swap lands after revalidation passed.
+	os.Rename(sub, sub+".real")
+	if err := os.Symlink(victimDir, sub); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	c := NewCleaner(&config.Config{})
+	if _, err := c.deleteFile([]string{root}, filepath.Join(sub, "x.tmp"), 0); err == nil {
+		t.Error("deleteFile must refuse a path whose parent is now a symlink")
+	}
+	if _, err := c.deleteFile(deletionRoots([]string{filepath.Join(root, "*")}), filepath.Join(sub, "x.tmp"), 1); err == nil {
+		t.Error("a directory a glob matched is walked, not trusted as a root")
+	}
+	if _, err := c.deleteFile(nil, filepath.Join(sub, "x.tmp"), 1); err == nil {
+		t.Error("a path beneath no category root must be refused")
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("SEC-4 regression: the symlinked directory's file was removed: %v", err)
+	}
+}
+
+// SEC-4 for the other actions: trashing and truncating walk the same pinned
+// directories as deletion, so a swapped parent redirects neither.
+func TestTrashAndTruncateRefuseParentSwappedForSymlink(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "cache")
+	sub := filepath.Join(root, "sub")
+	victimDir := filepath.Join(tmp, "victim")
+	os.MkdirAll(sub, 0755)
+	os.MkdirAll(victimDir, 0755)
+	victim := filepath.Join(victimDir, "x.log")
+	os.WriteFile(victim, []byte("precious"), 0644)
+
+	os.Rename(sub, sub+".real")
+	if err := os.Symlink(victimDir, sub); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	holding := filepath.Join(tmp, "holding")
+	c := NewCleaner(&config.Config{})
+	c.trash = trash.NewBin(filepath.Join(tmp, "home"), nil, trash.NewStore(holding))
+
+	if _, err := c.trashFile([]string{root}, filepath.Join(sub, "x.log"), "Logs"); err == nil {
+		t.Error("trashFile must refuse a path whose parent is now a symlink")
+	}
+	if _, err := c.truncateFile([]string{root}, filepath.Join(sub, "x.log")); err == nil {
+		t.Error("truncateFile must refuse a path whose parent is now a symlink")
+	}
+	data, err := os.ReadFile(victim)
+	if err != nil || string(data) != "precious" {
+		t.Fatalf("SEC-4 regression: the symlinked directory's file was touched: %q, %v", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(holding, "files")); len(entries) != 0 {
+		t.Errorf("nothing may reach the holding area, found %d entries", len(entries))
+	}
+}
+
+// SEC-4 under a live race: one goroutine keeps swapping a cache subdirectory
+// for a symlink to a victim directory while files in it are deleted. Deleting
+// by name would sooner or later remove the victim's file of the same name.
+func TestDeleteRacingSymlinkSwap(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "cache")
+	sub := filepath.Join(root, "sub")
+	victimDir := filepath.Join(tmp, "victim")
+	os.MkdirAll(sub, 0755)
+	os.MkdirAll(victimDir, 0755)
+	victim := filepath.Join(victimDir, "x.tmp")
+	os.WriteFile(victim, []byte("precious"), 0644)
+	if err := os.Symlink(victimDir, filepath.Join(tmp, "probe")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	// The real directory is refilled through its descriptor, which follows it
+	// across renames and never writes through the symlink.
+	realDir, err := os.Open(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer realDir.Close()
+	refill := func() {
+		fd, err := syscall.Openat(int(realDir.Fd()), "x.tmp", syscall.O_CREAT|syscall.O_WRONLY|syscall.O_CLOEXEC, 0644)
+		if err == nil {
+			syscall.Close(fd)
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			os.Rename(sub, sub+".real")
+			os.Symlink(victimDir, sub)
+			os.Remove(sub)
+			os.Rename(sub+".real", sub)
+		}
+	}()
+
+	c := NewCleaner(&config.Config{})
+	deleted := 0
+	for i := 0; i < 2000; i++ {
+		refill()
+		if _, err := c.deleteFile([]string{root}, filepath.Join(sub, "x.tmp"), 0); err == nil {
+			deleted++
+		}
+	}
+	close(stop)
+	<-done
+
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("SEC-4 regression: a racing symlink swap redirected the unlink: %v", err)
+	}
+	t.Logf("%d of 2000 deletions went through the real directory", deleted)
+}
+
+// A configured root may itself be a symlink: it is trusted as configured,
+// and only what lies beneath it is pinned.
+func TestDeleteThroughSymlinkedRoot(t *testing.T) {
+	tmp := t.TempDir()
+	real := filepath.Join(tmp, "real-cache")
+	os.MkdirAll(filepath.Join(real, "a"), 0755)
+	file := filepath.Join(real, "a", "x.tmp")
+	os.WriteFile(file, []byte("12345"), 0644)
+	root := filepath.Join(tmp, "cache")
+	if err := os.Symlink(real, root); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	freed, err := NewCleaner(&config.Config{}).deleteFile([]string{root}, filepath.Join(root, "a", "x.tmp"), 0)
+	if err != nil {
+		t.Fatalf("deleteFile through a configured symlinked root: %v", err)
+	}
+	if freed != 5 {
+		t.Errorf("freed = %d, want 5", freed)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("file still present: %v", err)
+	}
+}
+
+// Deletion roots are the literal prefix of each configured path, never what
+// a glob in it matched, since the matches are as swappable as any directory.
+func TestDeletionRootsStopAtFirstGlob(t *testing.T) {
+	got := deletionRoots([]string{"/home/u/.cache/yay/*", "/var/cache/pacman/pkg", "/var/log/*/old/*.gz", "/*"})
+	want := []string{"/var/cache/pacman/pkg", "/home/u/.cache/yay", "/var/log", "/"}
+	if len(got) != len(want) {
+		t.Fatalf("deletionRoots = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("deletionRoots = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+// A file beneath none of its category's roots is refused outright rather than
+// walked from /, which would also refuse every path under a symlinked /home.
+func TestDeleteRefusesPathOutsideRoots(t *testing.T) {
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, "other", "x.tmp")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	os.WriteFile(file, []byte("x"), 0644)
+
+	_, err := NewCleaner(&config.Config{}).deleteFile([]string{filepath.Join(tmp, "cache")}, file, 0)
+	if err == nil || !strings.Contains(err.Error(), "not beneath") {
+		t.Errorf("deleteFile outside its roots: err = %v, want a refusal", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("file outside the roots was removed: %v", err)
+	}
+}
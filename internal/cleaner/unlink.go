@@ -0,0 +1,246 @@
+package cleaner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// deletionRoots returns the directories deletion walks from: the literal,
+// glob-free prefix of each of a category's paths, longest first so the most
+// specific root containing a file wins. ~/.cache/yay/* becomes ~/.cache/yay,
+// so a directory the glob matched is walked like any other component rather
+// than trusted as a root.
+func deletionRoots(paths []string) []string {
+	var roots []string
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		roots = append(roots, literalPrefix(p))
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return len(roots[i]) > len(roots[j]) })
+	return roots
+}
+
+// literalPrefix is p up to, not including, its first component with a glob
+// metacharacter. A component with an escape is treated as a pattern too.
+func literalPrefix(p string) string {
+	p = filepath.Clean(p)
+	if !strings.ContainsAny(p, `*?[\`) {
+		return p
+	}
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if strings.ContainsAny(part, `*?[\`) {
+			if prefix := strings.Join(parts[:i], "/"); prefix != "" {
+				return prefix
+			}
+			return "/"
+		}
+	}
+	return p
+}
+
+// openParentBeneath opens the directory that holds path and returns its
+// descriptor and the file's name in it.
+//
+// The walk starts at the deletion root containing path. The root is opened
+// as configured, symlinks and all; every component below it is opened
+// relative to the one before with O_NOFOLLOW, so none can be swapped for a
+// symlink between revalidation and the unlink, rename or truncate that
+// follows. A root that is the file itself is opened through its parent
+// directory. A path beneath none of the roots is refused.
+func openParentBeneath(roots []string, path string) (int, string, error) {
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) {
+		return -1, "", fmt.Errorf("refusing to clean %s: not an absolute path", path)
+	}
+
+	root := ""
+	for _, r := range roots {
+		if r == path {
+			root = filepath.Dir(path)
+			break
+		}
+		if strings.HasPrefix(path, strings.TrimSuffix(r, "/")+"/") {
+			root = r
+			break
+		}
+	}
+	if root == "" {
+		return -1, "", fmt.Errorf("refusing to clean %s: not beneath any of its category's paths", path)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return -1, "", fmt.Errorf("refusing to clean %s: not beneath %s", path, root)
+	}
+
+	dirfd, err := syscall.Open(root, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, "", &os.PathError{Op: "open", Path: root, Err: err}
+	}
+	components := strings.Split(rel, "/")
+	current := root
+	for _, name := range components[:len(components)-1] {
+		current = filepath.Join(current, name)
+		fd, err := syscall.Openat(dirfd, name, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+		syscall.Close(dirfd)
+		if err != nil {
+			if err == syscall.ELOOP || err == syscall.ENOTDIR {
+				return -1, "", fmt.Errorf("refusing to clean %s: %s is no longer a directory", path, current)
+			}
+			return -1, "", &os.PathError{Op: "openat", Path: current, Err: err}
+		}
+		dirfd = fd
+	}
+	return dirfd, components[len(components)-1], nil
+}
+
+// unlinkBeneath removes the regular file at path through a descriptor of
+// its pinned parent directory, shredding it first when shredPasses is above
+// zero. It returns the file's size as it was just before removal.
+//
+// The file is judged by regularFileAt, and the descriptor it is shredded
+// through must be the same inode. The name can still be replaced inside the
+// pinned directory before unlinkat, but only
+// by someone who can write to that directory and so could remove the
+// replacement anyway; a symlink put there is removed, not followed.
+func (c *Cleaner) unlinkBeneath(roots []string, path string, shredPasses int) (uint64, error) {
+	dirfd, name, err := openParentBeneath(roots, path)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Close(dirfd)
+
+	st, err := regularFileAt(dirfd, name, path, "delete")
+	if err != nil {
+		return 0, err
+	}
+
+	if shredPasses > 0 && st.Size > 0 {
+		if err := c.shredAt(dirfd, name, path, st, shredPasses); err != nil {
+			return 0, fmt.Errorf("failed to shred file: %w", err)
+		}
+	}
+
+	if err := syscall.Unlinkat(dirfd, name); err != nil {
+		return 0, &os.PathError{Op: "unlinkat", Path: path, Err: err}
+	}
+	return uint64(st.Size), nil
+}
+
+// truncateBeneath truncates the regular file at path to zero through a
+// descriptor of its pinned parent directory, as unlinkBeneath does for
+// deletion. It returns the file's size just before truncation.
+func truncateBeneath(roots []string, path string) (uint64, error) {
+	dirfd, name, err := openParentBeneath(roots, path)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Close(dirfd)
+
+	want, err := regularFileAt(dirfd, name, path, "truncate")
+	if err != nil {
+		return 0, err
+	}
+	file, err := openSameAt(dirfd, name, path, want, "truncate")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	freed := uint64(info.Size())
+	if freed == 0 {
+		return 0, nil
+	}
+	if err := file.Truncate(0); err != nil {
+		return 0, fmt.Errorf("failed to truncate: %w", err)
+	}
+	return freed, nil
+}
+
+// regularFileAt judges name in dirfd with fstat on an O_PATH|O_NOFOLLOW
+// descriptor, which opens the name without opening what is behind it, so a
+// device or FIFO is never touched and a symlink is seen as itself. verb
+// names the refused operation in the error.
+func regularFileAt(dirfd int, name, path, verb string) (syscall.Stat_t, error) {
+	var st syscall.Stat_t
+	pathfd, err := syscall.Openat(dirfd, name, unix.O_PATH|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return st, &os.PathError{Op: "openat", Path: path, Err: err}
+	}
+	err = syscall.Fstat(pathfd, &st)
+	syscall.Close(pathfd)
+	if err != nil {
+		return st, &os.PathError{Op: "fstat", Path: path, Err: err}
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return st, fmt.Errorf("refusing to %s %s: not a regular file (mode %s)", verb, path, fileMode(st.Mode))
+	}
+	return st, nil
+}
+
+// openSameAt opens name in dirfd for writing, provided it is still the inode
+// described by want.
+func openSameAt(dirfd int, name, path string, want syscall.Stat_t, verb string) (*os.File, error) {
+	fd, err := syscall.Openat(dirfd, name, syscall.O_WRONLY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: path, Err: err}
+	}
+	file := os.NewFile(uintptr(fd), path)
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if st.Dev != want.Dev || st.Ino != want.Ino {
+		file.Close()
+		return nil, fmt.Errorf("refusing to %s %s: replaced since it was checked", verb, path)
+	}
+	return file, nil
+}
+
+// shredAt opens name in dirfd for writing and overwrites it, provided it is
+// still the inode described by want.
+func (c *Cleaner) shredAt(dirfd int, name, path string, want syscall.Stat_t, passes int) error {
+	file, err := openSameAt(dirfd, name, path, want, "shred")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return overwrite(file, info.Size(), passes)
+}
+
+// fileMode converts a raw st_mode file type for error messages.
+func fileMode(mode uint32) os.FileMode {
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		return os.ModeDir
+	case syscall.S_IFLNK:
+		return os.ModeSymlink
+	case syscall.S_IFIFO:
+		return os.ModeNamedPipe
+	case syscall.S_IFSOCK:
+		return os.ModeSocket
+	case syscall.S_IFCHR:
+		return os.ModeDevice | os.ModeCharDevice
+	case syscall.S_IFBLK:
+		return os.ModeDevice
+	}
+	return 0
+}
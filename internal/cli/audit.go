@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the audit log",
+	Long: "moonbit records every cleaning, package, service and container operation in an\n" +
+		"append-only audit log (~/.local/share/moonbit/logs/audit.log, or root's when run\n" +
+		"with sudo). Set per_file = true under [audit] to record each file a clean touches.",
+}
+
+var auditShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show audit log entries",
+	Long: "Shows audit log entries, oldest first. --since and --until take an age such as\n" +
+		"7d or 36h, a date (2006-01-02), a local time (\"2006-01-02 15:04\") or an RFC 3339\n" +
+		"timestamp. --operation accepts globs such as 'docker_*'.",
+	Example: "  moonbit audit show --since 2025-06-03 --until 2025-06-04 --operation clean --files\n" +
+		"  moonbit audit show --file /srv/logs/host-x/audit.log --host host-x --json",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		var filter audit.Filter
+		var err error
+		if s, _ := cmd.Flags().GetString("since"); s != "" {
+			if filter.Since, err = parseAuditTime(s, now); err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+		}
+		if s, _ := cmd.Flags().GetString("until"); s != "" {
+			if filter.Until, err = parseAuditTime(s, now); err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+		}
+		filter.Operation, _ = cmd.Flags().GetString("operation")
+		filter.User, _ = cmd.Flags().GetString("user")
+		filter.Host, _ = cmd.Flags().GetString("host")
+		filter.Category, _ = cmd.Flags().GetString("category")
+		showFiles, _ := cmd.Flags().GetBool("files")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		logPath, _ := cmd.Flags().GetString("file")
+		if logPath == "" {
+			if logPath, err = audit.LogPath(); err != nil {
+				return err
+			}
+		}
+		records, malformed, err := audit.ReadFile(logPath, filter)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("no audit log at %s", logPath)
+			}
+			return err
+		}
+		if malformed > 0 {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipped %d unreadable lines in %s\n", malformed, logPath)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			for _, r := range records {
+				if !showFiles {
+					r.Files = nil
+				}
+				if err := enc.Encode(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if len(records) == 0 {
+			fmt.Println("No matching audit entries")
+			return nil
+		}
+		for _, r := range records {
+			printAuditRecord(r, showFiles)
+		}
+		fmt.Printf("\n%d entries\n", len(records))
+		return nil
+	},
+}
+
+func printAuditRecord(r audit.Record, showFiles bool) {
+	host := r.Host
+	if host == "" {
+		host = "-"
+	}
+	line := fmt.Sprintf("%s  %s  %s  %s", r.Time.Local().Format("2006-01-02 15:04:05"), host, r.User, S.Bold(r.Operation))
+	if r.Category != "" {
+		line += " " + S.Muted("["+r.Category+"]")
+	}
+	if r.DryRun {
+		line += " " + S.Muted("(dry run)")
+	}
+	if r.Operation == "clean" && r.Version >= 1 {
+		line += fmt.Sprintf("  %s: %d files, %s", r.Result, r.FilesDeleted, utils.HumanizeBytes(r.BytesFreed))
+		if r.FilesFailed > 0 {
+			line += fmt.Sprintf(", %d failed", r.FilesFailed)
+		}
+	} else {
+		if len(r.Args) > 0 {
+			line += "  " + strings.Join(r.Args, " ")
+		}
+		if r.Result != "" {
+			line += "  " + r.Result
+		}
+	}
+	fmt.Println(line)
+	if r.Error != "" {
+		fmt.Printf("    %s %s\n", S.Error("error:"), r.Error)
+	}
+	if showFiles {
+		for _, f := range r.Files {
+			fmt.Printf("    %-8s %10s  %s\n", f.Action, utils.HumanizeBytes(f.Bytes), f.Path)
+			if f.Error != "" {
+				fmt.Printf("             %s %s\n", S.Error("error:"), f.Error)
+			}
+		}
+	}
+}
+
+// parseAuditTime reads an age ("7d", "36h"), a date, a local time or an RFC
+// 3339 timestamp. Ages count back from now.
+func parseAuditTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if age, err := utils.ParseAge(s); err == nil {
+		return now.Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an age, date or timestamp", s)
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditShowCmd)
+
+	auditShowCmd.Flags().String("since", "", "Only entries at or after this time or age (e.g. 7d, 2025-06-03)")
+	auditShowCmd.Flags().String("until", "", "Only entries before this time or age")
+	auditShowCmd.Flags().String("operation", "", "Only this operation, e.g. clean or 'docker_*'")
+	auditShowCmd.Flags().String("user", "", "Only entries recorded for this user")
+	auditShowCmd.Flags().String("host", "", "Only entries recorded on this host")
+	auditShowCmd.Flags().String("category", "", "Only clean entries for this category")
+	auditShowCmd.Flags().Bool("files", false, "Show per-file outcomes, where recorded")
+	auditShowCmd.Flags().Bool("json", false, "Print matching records as JSON lines")
+	auditShowCmd.Flags().String("file", "", "Read this audit log instead of the local one")
+}
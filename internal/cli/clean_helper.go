@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Nomadcxx/moonbit/internal/privsep"
+	"github.com/spf13/cobra"
+)
+
+// cleanHelperCmd is the unprivileged half of a clean run as root: see
+// package privsep. It is started by moonbit itself, never by hand.
+var cleanHelperCmd = &cobra.Command{
+	Use:    privsep.HelperCommand,
+	Short:  "Clean home-directory files as the invoking user (internal)",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The helper exists to not be root; running it as root would defeat it.
+		if isRunningAsRoot() {
+			return fmt.Errorf("%s must not run as root", privsep.HelperCommand)
+		}
+		return privsep.Serve(cmd.Context(), os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanHelperCmd)
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/paths"
+	"github.com/Nomadcxx/moonbit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the configuration file, or the safety policy in effect",
+	Long: "Shows config.toml as written. With --effective, shows the safety policy every\n" +
+		"clean is checked against: the [safety] section resolved against its defaults and\n" +
+		"each category's [safety.categories] override.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := paths.ConfigFile()
+		if err != nil {
+			return fmt.Errorf("failed to determine config path: %w", err)
+		}
+		if effective, _ := cmd.Flags().GetBool("effective"); !effective {
+			data, err := os.ReadFile(path)
+			if os.IsNotExist(err) {
+				fmt.Printf("No config file at %s; the defaults apply.\n", path)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("# %s\n", path)
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			return err
+		}
+		fmt.Println(S.Header("🛡️  Effective safety policy"))
+		fmt.Println(S.Muted(path))
+		fmt.Println(S.Separator())
+		writeEffectiveSafety(os.Stdout, cfg)
+		return nil
+	},
+}
+
+// writeEffectiveSafety writes the protected paths and the limits each
+// category is cleaned under, marking those set by an override.
+func writeEffectiveSafety(out io.Writer, cfg *config.Config) {
+	builtin := make(map[string]bool)
+	for _, path := range config.DefaultProtectedPaths() {
+		builtin[path] = true
+	}
+	fmt.Fprintln(out, S.Bold("Protected paths:"))
+	for _, path := range cfg.Safety.EffectiveProtectedPaths() {
+		source := "configured"
+		if builtin[path] {
+			source = "built-in"
+		}
+		fmt.Fprintf(out, "  %-30s %s\n", path, S.Muted(source))
+	}
+
+	global := cfg.Safety.Limits("")
+	fmt.Fprintf(out, "\n%s\n", S.Bold("Limits:"))
+	fmt.Fprintf(out, "  max_deletion_size  %s\n", utils.HumanizeBytes(global.MaxDeletionSize))
+	fmt.Fprintf(out, "  safe_mode          %t\n", global.SafeMode)
+	fmt.Fprintf(out, "  shred_passes       %d\n", global.ShredPasses)
+
+	categories := config.AuthoritativeCategories(cfg)
+	fmt.Fprintf(out, "\n%s\n", S.Bold("Categories:"))
+	fmt.Fprintf(out, "  %-32s %-12s %-9s %s\n", "NAME", "MAX SIZE", "SAFE MODE", "SHRED PASSES")
+	for _, cat := range categories {
+		limits := cfg.Safety.Limits(cat.Name)
+		name := cat.Name
+		if _, ok := cfg.Safety.Categories[cat.Name]; ok {
+			name += " *"
+		}
+		fmt.Fprintf(out, "  %-32s %-12s %-9t %d\n", name, utils.HumanizeBytes(limits.MaxDeletionSize), limits.SafeMode, limits.ShredPasses)
+	}
+	if len(cfg.Safety.Categories) > 0 {
+		fmt.Fprintln(out, S.Muted("  * set by [safety.categories]"))
+	}
+	for _, name := range cfg.Safety.UnknownSafetyOverrides(categories) {
+		fmt.Fprintf(out, "%s [safety.categories.%q] names no category and applies to nothing\n", S.Warning("⚠️"), name)
+	}
+}
+
+// protectedPaths returns the protected paths configured under [safety],
+// which always include the built-in ones. If the config cannot be loaded,
+// the built-in ones alone apply.
+func protectedPaths() []string {
+	cfg, err := config.Load("")
+	if err != nil {
+		return config.DefaultProtectedPaths()
+	}
+	return cfg.Safety.EffectiveProtectedPaths()
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+	configShowCmd.Flags().Bool("effective", false, "Show the resolved safety policy instead of the file")
+}
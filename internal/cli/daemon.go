@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/session"
 	"github.com/Nomadcxx/moonbit/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -139,6 +141,7 @@ var opSem = make(chan struct{}, 1)
 var daemonOut io.Writer = os.Stdout
 var daemonErr io.Writer = os.Stderr
 var daemonCleanSession = CleanSession
+var daemonVacuumJournal = vacuumJournalPolicy
 
 var daemonCmd = &cobra.Command{
 	Use:   "daemon",
@@ -360,7 +363,15 @@ func performScan() {
 	start := time.Now()
 
 	// Run scan
-	if err := ScanAndSave(); err != nil {
+	err := ScanAndSave()
+	var locked *session.LockedError
+	if errors.As(err, &locked) {
+		// A clean started by hand or by a timer is running; the next
+		// tick will scan.
+		fmt.Fprintf(daemonOut, "%s Skipping scan — %v\n", S.Warning("⚠"), locked)
+		return
+	}
+	if err != nil {
 		fmt.Fprintf(daemonOut, "%s Scan failed: %v\n", S.Error("✗"), err)
 
 		if logger := daemonState.auditLogger(); logger != nil {
@@ -403,10 +414,23 @@ func performClean() {
 		S.Bold("🧹"),
 		now.Format("2006-01-02 15:04:05"))
 
+	// The journal policy applies whether or not the clean succeeds.
+	defer func() {
+		if err := daemonVacuumJournal(daemonOut, daemonState.auditLogger()); err != nil {
+			fmt.Fprintf(daemonOut, "%s Journal vacuum failed: %v\n", S.Error("✗"), err)
+		}
+	}()
+
 	start := time.Now()
 
 	// Run clean
-	if err := daemonCleanSession(false); err != nil {
+	err := daemonCleanSession(false)
+	var locked *session.LockedError
+	if errors.As(err, &locked) {
+		fmt.Fprintf(daemonOut, "%s Skipping clean — %v\n", S.Warning("⚠"), locked)
+		return
+	}
+	if err != nil {
 		fmt.Fprintf(daemonOut, "%s Clean failed: %v\n", S.Error("✗"), err)
 
 		if logger := daemonState.auditLogger(); logger != nil {
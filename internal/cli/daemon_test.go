@@ -1,11 +1,14 @@
 package cli
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"testing"
 	"time"
 
 	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/session"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,10 +25,12 @@ func TestParseDurationRejectsNonPositive(t *testing.T) {
 
 func TestPerformCleanUsesLiveClean(t *testing.T) {
 	originalClean := daemonCleanSession
+	originalVacuum := daemonVacuumJournal
 	originalState := daemonState
 	originalOut := daemonOut
 	defer func() {
 		daemonCleanSession = originalClean
+		daemonVacuumJournal = originalVacuum
 		daemonState = originalState
 		daemonOut = originalOut
 	}()
@@ -38,9 +43,68 @@ func TestPerformCleanUsesLiveClean(t *testing.T) {
 		gotDryRun = dryRun
 		return nil
 	}
+	vacuumed := false
+	daemonVacuumJournal = func(io.Writer, *audit.Logger) error {
+		vacuumed = true
+		return nil
+	}
 
 	performClean()
 
 	assert.False(t, gotDryRun, "scheduled daemon clean should actually clean")
 	assert.Equal(t, 1, daemonState.stats().CleanCount)
+	assert.True(t, vacuumed, "scheduled clean should apply the journal policy")
+}
+
+func TestPerformCleanVacuumsJournalAfterFailedClean(t *testing.T) {
+	originalClean := daemonCleanSession
+	originalVacuum := daemonVacuumJournal
+	originalState := daemonState
+	originalOut := daemonOut
+	defer func() {
+		daemonCleanSession = originalClean
+		daemonVacuumJournal = originalVacuum
+		daemonState = originalState
+		daemonOut = originalOut
+	}()
+
+	daemonState = &DaemonState{StartTime: time.Now(), logger: (*audit.Logger)(nil)}
+	daemonOut = io.Discard
+	daemonCleanSession = func(bool) error { return assert.AnError }
+	vacuumed := false
+	daemonVacuumJournal = func(io.Writer, *audit.Logger) error {
+		vacuumed = true
+		return nil
+	}
+
+	performClean()
+
+	assert.True(t, vacuumed, "the journal policy does not depend on the clean")
+}
+
+func TestPerformCleanSkipsWhenLocked(t *testing.T) {
+	originalClean := daemonCleanSession
+	originalVacuum := daemonVacuumJournal
+	originalState := daemonState
+	originalOut := daemonOut
+	defer func() {
+		daemonCleanSession = originalClean
+		daemonVacuumJournal = originalVacuum
+		daemonState = originalState
+		daemonOut = originalOut
+	}()
+
+	daemonState = &DaemonState{StartTime: time.Now(), logger: (*audit.Logger)(nil)}
+	var out bytes.Buffer
+	daemonOut = &out
+	daemonCleanSession = func(bool) error {
+		return fmt.Errorf("%w; re-run with --wait", &session.LockedError{Path: "/run/moonbit/moonbit.lock", PID: 42, Op: "clean"})
+	}
+	daemonVacuumJournal = func(io.Writer, *audit.Logger) error { return nil }
+
+	performClean()
+
+	assert.Contains(t, out.String(), "Skipping clean")
+	assert.Contains(t, out.String(), "locked by PID 42")
+	assert.NotContains(t, out.String(), "Clean failed")
 }
@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/docker"
+	"github.com/Nomadcxx/moonbit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// newDockerClients returns a client per detected container runtime; tests
+// replace it.
+var newDockerClients = func(ctx context.Context, names []string) ([]docker.Client, error) {
+	runtimes, err := docker.Detect(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+	clients := make([]docker.Client, len(runtimes))
+	for i, rt := range runtimes {
+		clients[i] = docker.NewClient(rt)
+	}
+	return clients, nil
+}
+
+var dockerCmd = &cobra.Command{
+	Use:   "docker",
+	Short: "Clean Docker, Podman and nerdctl resources",
+	Long: "Clean unused images, containers, volumes, and build cache through the docker, podman\n" +
+		"or nerdctl CLI, in rootful and rootless storage.\n\n" +
+		"Every installed runtime is cleaned unless --runtime picks one. Run with sudo, podman and\n" +
+		"nerdctl are also run as the invoking user to reach their rootless storage.\n\n" +
+		"Every subcommand previews what it would prune, with sizes from the runtime's disk usage,\n" +
+		"until you pass --force. --until and --label are passed to the runtime's prune filters.",
+}
+
+// dockerPruneCmd builds the subcommand that prunes resources.
+func dockerPruneCmd(use, short string, resources ...docker.Resource) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filters, err := dockerFiltersFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			// Mirror `clean`: preview by default, --force applies.
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			if force, _ := cmd.Flags().GetBool("force"); force {
+				dryRun = false
+			}
+
+			auditLog, _ := audit.NewLogger()
+			if auditLog != nil {
+				defer auditLog.Close()
+			}
+			var names []string
+			if runtime, _ := cmd.Flags().GetString("runtime"); runtime != "" && runtime != "auto" {
+				names = []string{runtime}
+			}
+			clients, err := newDockerClients(cmd.Context(), names)
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, client := range clients {
+				if len(clients) > 1 {
+					fmt.Printf("\n%s\n", S.Bold("== "+client.Runtime().String()+" =="))
+				}
+				if err := runDockerPrune(cmd.Context(), client, resources, filters, dryRun, os.Stdout, auditLog); err != nil {
+					if len(clients) == 1 {
+						return err
+					}
+					fmt.Printf("%s %s: %v\n", S.Error("❌"), client.Runtime().Name, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d container runtimes failed", failed, len(clients))
+			}
+			return nil
+		},
+	}
+}
+
+var (
+	dockerImagesCmd     = dockerPruneCmd("images", "Remove unused Docker images", docker.Images)
+	dockerContainersCmd = dockerPruneCmd("containers", "Remove stopped Docker containers", docker.Containers)
+	dockerVolumesCmd    = dockerPruneCmd("volumes", "Remove unused Docker volumes", docker.Volumes)
+	dockerBuilderCmd    = dockerPruneCmd("builder", "Remove unused Docker build cache", docker.BuildCache)
+	dockerAllCmd        = dockerPruneCmd("all", "Remove all unused Docker resources", docker.AllResources...)
+)
+
+// dockerFiltersFromFlags reads --until, --label and the flag for named
+// volumes: --all on volumes, --all-volumes on all.
+func dockerFiltersFromFlags(cmd *cobra.Command) (docker.Filters, error) {
+	var f docker.Filters
+	if until, _ := cmd.Flags().GetString("until"); until != "" {
+		d, err := utils.ParseAge(until)
+		if err != nil {
+			return f, fmt.Errorf("--until: %w", err)
+		}
+		f.Until = d
+	}
+	f.Labels, _ = cmd.Flags().GetStringArray("label")
+	for _, name := range []string{"all", "all-volumes"} {
+		if cmd.Flags().Lookup(name) != nil {
+			f.AllVolumes, _ = cmd.Flags().GetBool(name)
+		}
+	}
+	return f, nil
+}
+
+// runDockerPrune previews or prunes each resource in turn with one
+// runtime. A failed prune is reported and the rest still run; the error
+// says how many failed.
+func runDockerPrune(ctx context.Context, client docker.Client, resources []docker.Resource, f docker.Filters, dryRun bool, out io.Writer, auditLog *audit.Logger) error {
+	rt := client.Runtime()
+	// `all` skips resources a filter or the runtime cannot handle, such as
+	// volumes by age; a single resource fails instead.
+	var selected []docker.Resource
+	for _, r := range resources {
+		if _, err := rt.PruneArgs(r, f); err != nil {
+			if len(resources) == 1 {
+				return err
+			}
+			fmt.Fprintf(out, "%s Skipping %s: %v\n", S.Warning("⚠️"), r, err)
+			continue
+		}
+		selected = append(selected, r)
+	}
+
+	if err := client.Ping(ctx); err != nil {
+		fmt.Fprintf(out, "❌ %s is not installed or not running\n", rt.Name)
+		if auditLog != nil {
+			auditLog.LogDockerOperation("check", []string{rt.Name}, "failed", err)
+		}
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(out, "%s\n", S.Header("🐳 "+rt.Name+" resources that would be pruned"))
+		fmt.Fprintln(out, S.Separator())
+		var total uint64
+		for _, r := range selected {
+			items, err := docker.Preview(ctx, client, r, f)
+			if errors.Is(err, docker.ErrNoDiskUsage) {
+				args, _ := rt.PruneArgs(r, f)
+				fmt.Fprintf(out, "\n%s: sizes unavailable, would run: %s %s\n", S.Bold(string(r)), rt.Name, strings.Join(args, " "))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if r == docker.Networks {
+				fmt.Fprintf(out, "\n%s: unused networks (no disk space)\n", S.Bold(string(r)))
+				continue
+			}
+			var size uint64
+			for _, item := range items {
+				size += item.Size
+			}
+			total += size
+			fmt.Fprintf(out, "\n%s: %d, %s\n", S.Bold(string(r)), len(items), utils.HumanizeBytes(size))
+			for _, item := range items {
+				fmt.Fprintf(out, "  %-10s  %-14s  %s\n", utils.HumanizeBytes(item.Size), docker.ShortID(item.ID), item.Name)
+			}
+		}
+		fmt.Fprintf(out, "\n%s\n", S.Separator())
+		fmt.Fprintf(out, "DRY RUN - would free up to %s (image sizes include layers shared with images that stay)\n", utils.HumanizeBytes(total))
+		fmt.Fprintln(out, "\n💡 Use --force to actually prune")
+		return nil
+	}
+
+	var reclaimed uint64
+	failed := 0
+	for _, r := range selected {
+		args, _ := rt.PruneArgs(r, f)
+		fmt.Fprintf(out, "\n🗑️  Running: %s %s\n", rt.Name, strings.Join(args, " "))
+		result, err := client.Prune(ctx, r, f)
+		fmt.Fprint(out, result.Output)
+		if auditLog != nil {
+			status := fmt.Sprintf("success bytes=%d", result.Reclaimed)
+			if err != nil {
+				status = "failed"
+			}
+			auditLog.LogDockerOperation("prune_"+string(r), append([]string{rt.Name}, result.Args...), status, err)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "❌ Failed to prune %s: %v\n", r, err)
+			failed++
+			continue
+		}
+		reclaimed += result.Reclaimed
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %s prunes failed", failed, len(selected), rt.Name)
+	}
+	fmt.Fprintf(out, "\n%s %s cleanup complete, reclaimed %s\n", S.Success("✅"), rt.Name, utils.HumanizeBytes(reclaimed))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(dockerCmd)
+	dockerCmd.PersistentFlags().String("runtime", "auto", "Container runtime to clean: auto, docker, podman or nerdctl")
+	for _, cmd := range []*cobra.Command{dockerImagesCmd, dockerContainersCmd, dockerVolumesCmd, dockerBuilderCmd, dockerAllCmd} {
+		dockerCmd.AddCommand(cmd)
+		cmd.Flags().String("until", "", "Only prune objects older than this (e.g. 24h, 7d)")
+		cmd.Flags().StringArray("label", nil, "Only prune objects with this label, key or key=value (repeatable)")
+		cmd.Flags().Bool("dry-run", true, "Preview only (default); pass --force to apply")
+		cmd.Flags().Bool("force", false, "Actually prune")
+	}
+	dockerVolumesCmd.Flags().Bool("all", false, "Also prune unused named volumes, not only anonymous ones")
+	dockerAllCmd.Flags().Bool("all-volumes", false, "Also prune unused named volumes, not only anonymous ones")
+}
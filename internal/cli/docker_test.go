@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDocker records prunes instead of running a runtime; it acts as
+// docker unless name is set.
+type fakeDocker struct {
+	name   string
+	pruned []docker.Resource
+}
+
+func (c *fakeDocker) Runtime() docker.Runtime {
+	if c.name == "" {
+		return docker.Runtime{Name: docker.Docker}
+	}
+	return docker.Runtime{Name: c.name}
+}
+
+func (c *fakeDocker) Ping(context.Context) error { return nil }
+
+func (c *fakeDocker) DiskUsage(context.Context) (*docker.DiskUsage, error) {
+	if c.name == docker.Nerdctl {
+		return nil, docker.ErrNoDiskUsage
+	}
+	return &docker.DiskUsage{
+		Images: []docker.ImageUsage{{ID: "sha256:abc", Repository: "old", Tag: "1", Containers: "0",
+			CreatedAt: "2020-01-01 10:00:00 +0000 UTC", Size: "1GB"}},
+	}, nil
+}
+
+func (c *fakeDocker) ImageLabels(context.Context, []string) (map[string]map[string]string, error) {
+	return nil, nil
+}
+
+func (c *fakeDocker) Prune(_ context.Context, r docker.Resource, f docker.Filters) (docker.PruneResult, error) {
+	c.pruned = append(c.pruned, r)
+	args, err := c.Runtime().PruneArgs(r, f)
+	return docker.PruneResult{Args: args, Output: "Total reclaimed space: 1GB\n", Reclaimed: 1_000_000_000}, err
+}
+
+func TestDockerPruneDryRunPrunesNothing(t *testing.T) {
+	client := &fakeDocker{}
+	var out bytes.Buffer
+
+	err := runDockerPrune(context.Background(), client, []docker.Resource{docker.Images}, docker.Filters{}, true, &out, nil)
+	require.NoError(t, err)
+
+	assert.Empty(t, client.pruned)
+	assert.Contains(t, out.String(), "old:1")
+	assert.Contains(t, out.String(), "DRY RUN")
+}
+
+func TestDockerPruneAllSkipsUnsupportedFilters(t *testing.T) {
+	client := &fakeDocker{}
+	var out bytes.Buffer
+
+	f := docker.Filters{Until: 24 * time.Hour}
+	err := runDockerPrune(context.Background(), client, docker.AllResources, f, false, &out, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []docker.Resource{docker.Containers, docker.Images, docker.Networks, docker.BuildCache}, client.pruned,
+		"volumes cannot be filtered by age")
+	assert.Contains(t, out.String(), "Skipping volumes")
+}
+
+func TestDockerPruneSingleResourceRejectsUnsupportedFilters(t *testing.T) {
+	client := &fakeDocker{}
+	err := runDockerPrune(context.Background(), client, []docker.Resource{docker.Volumes},
+		docker.Filters{Until: time.Hour}, false, &bytes.Buffer{}, nil)
+	assert.Error(t, err)
+	assert.Empty(t, client.pruned)
+}
+
+func TestDockerPruneAllSkipsWhatTheRuntimeCannotPrune(t *testing.T) {
+	client := &fakeDocker{name: docker.Podman}
+	var out bytes.Buffer
+
+	err := runDockerPrune(context.Background(), client, docker.AllResources, docker.Filters{}, false, &out, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []docker.Resource{docker.Containers, docker.Images, docker.Networks}, client.pruned,
+		"podman volumes need --all-volumes and podman has no build cache")
+	assert.Contains(t, out.String(), "Running: podman image prune")
+}
+
+func TestDockerPruneDryRunWithoutDiskUsage(t *testing.T) {
+	client := &fakeDocker{name: docker.Nerdctl}
+	var out bytes.Buffer
+
+	err := runDockerPrune(context.Background(), client, []docker.Resource{docker.Images}, docker.Filters{}, true, &out, nil)
+	require.NoError(t, err)
+
+	assert.Empty(t, client.pruned)
+	assert.Contains(t, out.String(), "would run: nerdctl image prune -a -f")
+}
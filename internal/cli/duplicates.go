@@ -0,0 +1,451 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/duplicates"
+	"github.com/Nomadcxx/moonbit/internal/paths"
+	"github.com/Nomadcxx/moonbit/internal/utils"
+	"github.com/Nomadcxx/moonbit/internal/validation"
+	"github.com/spf13/cobra"
+)
+
+var duplicatesDedupeCmd = &cobra.Command{
+	Use:   "dedupe [paths...]",
+	Short: "Replace duplicate files with links to one copy",
+	Long: "Scans specified paths (or home directory) for duplicates and replaces every copy\n" +
+		"but the one chosen by --keep (the oldest by default) with a link to it, so each\n" +
+		"path keeps working.\n\n" +
+		"  hardlink  another name for the same file; same filesystem only\n" +
+		"  reflink   copy-on-write clone (btrfs, xfs); copies stay independent\n" +
+		"  symlink   a symbolic link to the kept file's absolute path\n\n" +
+		"Each pair is compared byte by byte immediately before the copy is replaced.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		modeFlag, _ := cmd.Flags().GetString("mode")
+		mode, err := duplicates.ParseLinkMode(modeFlag)
+		if err != nil {
+			return err
+		}
+		// Mirror `clean`: preview by default, --force applies.
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if force, _ := cmd.Flags().GetBool("force"); force {
+			dryRun = false
+		}
+
+		paths := args
+		if len(paths) == 0 {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+			paths = []string{homeDir}
+		}
+		minSize, _ := cmd.Flags().GetInt64("min-size")
+		verify, _ := cmd.Flags().GetBool("verify")
+
+		fmt.Println(S.Header("🔍 Scanning for duplicate files..."))
+		fmt.Printf("📁 Paths: %v\n", paths)
+		keep, err := keepPolicyFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		opts := duplicates.ScanOptions{Paths: paths, MinSize: minSize, Verify: verify, Keep: keep}
+		applyScanScope(cmd, &opts)
+		saveCache := useHashCache(cmd, &opts)
+		ctx, stop := scanContext(cmd)
+		defer stop()
+		result, err := scanDuplicates(ctx, opts)
+		if err != nil {
+			return err
+		}
+		saveCache()
+
+		if len(result.Groups) == 0 {
+			fmt.Println(S.Success("✅ No duplicate files found."))
+			return nil
+		}
+
+		var replacements []duplicates.Replacement
+		var total int64
+		protected := protectedPaths()
+		for _, group := range result.Groups {
+			keep := group.Files[0]
+			for _, file := range group.Kept() {
+				fmt.Printf("\n  %s %s", S.Success("✓ KEEP"), file.Path)
+			}
+			fmt.Println()
+			for _, file := range group.Removable() {
+				skipped := false
+				for _, path := range file.Paths() {
+					if err := validation.ValidateFilePath(path, protected...); err != nil {
+						fmt.Printf("  %s %s (%v)\n", S.Warning("⚠️  SKIP"), path, err)
+						skipped = true
+						continue
+					}
+					fmt.Printf("  %s %s\n", S.Muted("→ "+string(mode)), path)
+					replacements = append(replacements, duplicates.Replacement{Keep: keep.Path, Duplicate: path})
+				}
+				if !skipped {
+					total += file.Reclaimable()
+				}
+			}
+		}
+
+		// A reflink clone shares its blocks with the kept copy instead of
+		// freeing them outright.
+		effect, bytesKey := "freeing", "bytes"
+		if mode == duplicates.ModeReflink {
+			effect, bytesKey = "sharing", "bytes_shared"
+		}
+
+		fmt.Printf("\n%s\n", S.Separator())
+		if dryRun {
+			fmt.Printf("DRY RUN - Would replace %d files with %ss, %s %s\n",
+				len(replacements), mode, effect, utils.HumanizeBytes(uint64(total)))
+			fmt.Println("\n💡 Use --force to actually replace them")
+			return nil
+		}
+
+		replaced, freed, errs := duplicates.DedupeDuplicates(replacements, mode)
+		if auditLog, _ := audit.NewLogger(); auditLog != nil {
+			var auditErr error
+			if len(errs) > 0 {
+				auditErr = fmt.Errorf("%d files not replaced", len(errs))
+			}
+			auditLog.Log(audit.LogEntry{
+				Operation: "duplicates_dedupe",
+				Args:      append([]string{"--mode=" + string(mode)}, paths...),
+				Result:    fmt.Sprintf("replaced=%d %s=%d", replaced, bytesKey, freed),
+				Error:     auditErr,
+			})
+			auditLog.Close()
+		}
+
+		for _, msg := range errs {
+			fmt.Printf("  %s\n", S.Error(msg))
+		}
+		if replaced > 0 {
+			fmt.Printf("%s Replaced %d files with %ss, %s %s\n",
+				S.Success("✅"), replaced, mode, effect, utils.HumanizeBytes(uint64(freed)))
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d of %d files could not be replaced", len(errs), len(replacements))
+		}
+		return nil
+	},
+}
+
+var duplicatesApplyCmd = &cobra.Command{
+	Use:   "apply <report>",
+	Short: "Remove or link the files marked in a duplicate report",
+	Long: "Applies a report written by 'moonbit duplicates find --report', after it has been\n" +
+		"reviewed and edited. Each file's action is one of:\n\n" +
+		"  keep      leave it alone (also what an empty action means)\n" +
+		"  remove    delete it and every hardlink to it\n" +
+		"  hardlink, reflink, symlink\n" +
+		"            replace it with a link to the group's first kept file\n\n" +
+		"Every file is re-checked first: one whose size, mtime or hash no longer match\n" +
+		"the report is skipped, as is every change in a group with no unchanged kept copy.\n" +
+		"Reports ending in .csv are read as CSV, anything else as JSON.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if force, _ := cmd.Flags().GetBool("force"); force {
+			dryRun = false
+		}
+
+		report, err := duplicates.ReadReport(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(S.Header("🔍 Re-checking files in " + args[0] + "..."))
+		steps, skipped := report.Plan()
+
+		var removals []string
+		linkSteps := make(map[duplicates.LinkMode][]duplicates.Replacement)
+		var total int64
+		protected := protectedPaths()
+		for _, step := range steps {
+			valid := true
+			for _, path := range step.Paths {
+				if err := validation.ValidateFilePath(path, protected...); err != nil {
+					skipped = append(skipped, fmt.Sprintf("%s: %v", path, err))
+					valid = false
+				}
+			}
+			if !valid {
+				continue
+			}
+			for _, path := range step.Paths {
+				if step.Action == duplicates.ActionRemove {
+					fmt.Printf("  %s %s\n", S.Error("✗ REMOVE"), path)
+					removals = append(removals, path)
+					continue
+				}
+				mode := duplicates.LinkMode(step.Action)
+				fmt.Printf("  %s %s → %s\n", S.Muted("→ "+string(mode)), path, step.Keep)
+				linkSteps[mode] = append(linkSteps[mode], duplicates.Replacement{Keep: step.Keep, Duplicate: path})
+			}
+			total += step.Size
+		}
+		for _, msg := range skipped {
+			fmt.Printf("  %s %s\n", S.Warning("⚠️  SKIP"), msg)
+		}
+
+		changes := len(removals)
+		for _, reps := range linkSteps {
+			changes += len(reps)
+		}
+		fmt.Printf("\n%s\n", S.Separator())
+		if changes == 0 {
+			fmt.Println(S.Info("💡 Nothing to apply."))
+			return nil
+		}
+		if dryRun {
+			fmt.Printf("DRY RUN - Would change %d files, freeing %s\n", changes, utils.HumanizeBytes(uint64(total)))
+			fmt.Println("\n💡 Use --force to actually apply the report")
+			return nil
+		}
+
+		done, freed, errs := duplicates.RemoveDuplicates(removals)
+		var shared int64
+		for mode, reps := range linkSteps {
+			n, f, e := duplicates.DedupeDuplicates(reps, mode)
+			done, errs = done+n, append(errs, e...)
+			if mode == duplicates.ModeReflink {
+				shared += f
+			} else {
+				freed += f
+			}
+		}
+		if auditLog, _ := audit.NewLogger(); auditLog != nil {
+			var auditErr error
+			if len(errs) > 0 {
+				auditErr = fmt.Errorf("%d files not changed", len(errs))
+			}
+			auditLog.Log(audit.LogEntry{
+				Operation: "duplicates_apply",
+				Args:      args,
+				Result:    fmt.Sprintf("changed=%d skipped=%d bytes=%d bytes_shared=%d", done, len(skipped), freed, shared),
+				Error:     auditErr,
+			})
+			auditLog.Close()
+		}
+
+		for _, msg := range errs {
+			fmt.Printf("  %s\n", S.Error(msg))
+		}
+		if done > 0 {
+			fmt.Printf("%s Changed %d files, freeing %s", S.Success("✅"), done, utils.HumanizeBytes(uint64(freed)))
+			if shared > 0 {
+				fmt.Printf(" and sharing %s through reflinks", utils.HumanizeBytes(uint64(shared)))
+			}
+			fmt.Println()
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d of %d files could not be changed", len(errs), changes)
+		}
+		return nil
+	},
+}
+
+// useHashCache loads the duplicate hash cache into opts, unless --no-cache is
+// set, and returns a function that saves it after the scan. Cache problems
+// are reported and otherwise ignored: the cache only saves time.
+func useHashCache(cmd *cobra.Command, opts *duplicates.ScanOptions) func() {
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		return func() {}
+	}
+	opts.Rehash, _ = cmd.Flags().GetBool("rehash")
+	path, err := duplicates.HashCachePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Hash cache disabled: %v\n", err)
+		return func() {}
+	}
+	cache, err := duplicates.LoadHashCache(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+	opts.Cache = cache
+	return func() {
+		if err := cache.Save(path); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+	}
+}
+
+// applyScanScope sets the depth limit from --max-depth and skips what
+// scan.ignore_patterns skips, so duplicate scans stay out of the same
+// directories as category scans.
+func applyScanScope(cmd *cobra.Command, opts *duplicates.ScanOptions) {
+	opts.MaxDepth, _ = cmd.Flags().GetInt("max-depth")
+	cfg, err := config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Ignore patterns not applied: %v\n", err)
+		return
+	}
+	opts.IgnorePatterns = cfg.Scan.IgnorePatterns
+}
+
+// scanContext is cancelled on Ctrl-C or SIGTERM so a long scan stops
+// between files rather than being killed mid-hash.
+func scanContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+}
+
+// keepPolicyFromFlags builds the keep policy from --keep, --prefer,
+// --protect and --keep-regex.
+func keepPolicyFromFlags(cmd *cobra.Command) (duplicates.KeepPolicy, error) {
+	var policy duplicates.KeepPolicy
+	rule, _ := cmd.Flags().GetString("keep")
+	var err error
+	if policy.Rule, err = duplicates.ParseKeepRule(rule); err != nil {
+		return policy, err
+	}
+	prefer, _ := cmd.Flags().GetStringSlice("prefer")
+	if policy.Prefer, err = absDirs(prefer); err != nil {
+		return policy, err
+	}
+	protect, _ := cmd.Flags().GetStringSlice("protect")
+	if policy.Protect, err = absDirs(protect); err != nil {
+		return policy, err
+	}
+	patterns, _ := cmd.Flags().GetStringArray("keep-regex")
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return policy, fmt.Errorf("invalid --keep-regex %q: %w", pattern, err)
+		}
+		policy.KeepMatching = append(policy.KeepMatching, re)
+	}
+	return policy, nil
+}
+
+// absDirs makes directories absolute, expanding a leading "~/".
+func absDirs(dirs []string) ([]string, error) {
+	var out []string
+	for _, dir := range dirs {
+		if rest, ok := strings.CutPrefix(dir, "~/"); ok || dir == "~" {
+			home, err := paths.HomeDir()
+			if err != nil {
+				return nil, err
+			}
+			dir = filepath.Join(home, rest)
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, abs)
+	}
+	return out, nil
+}
+
+// describeKeepPolicy says in a few words which copy of a group is kept.
+func describeKeepPolicy(p duplicates.KeepPolicy) string {
+	desc := map[duplicates.KeepRule]string{
+		duplicates.KeepOldest:       "the oldest copy",
+		duplicates.KeepNewest:       "the newest copy",
+		duplicates.KeepShortestPath: "the copy with the shortest path",
+	}[p.Rule]
+	if len(p.Prefer) > 0 {
+		desc += " under " + strings.Join(p.Prefer, " or ") + " if any"
+	}
+	if len(p.Protect) > 0 || len(p.KeepMatching) > 0 {
+		desc = "every protected copy, otherwise " + desc
+	}
+	return desc
+}
+
+// addKeepPolicyFlags registers the flags keepPolicyFromFlags reads.
+func addKeepPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("keep", string(duplicates.KeepOldest), "Which copy to keep: oldest, newest or shortest-path")
+	cmd.Flags().StringSlice("prefer", nil, "Keep the copy under this directory (repeatable, most preferred first)")
+	cmd.Flags().StringSlice("protect", nil, "Never remove copies under this directory (repeatable)")
+	cmd.Flags().StringArray("keep-regex", nil, "Never remove copies whose path matches this regular expression (repeatable)")
+}
+
+// addScanScopeFlags registers the flags applyScanScope reads.
+func addScanScopeFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("max-depth", duplicates.DefaultMaxDepth, "Directory levels to descend below each path (-1 for no limit)")
+}
+
+// addHashCacheFlags registers the flags useHashCache reads.
+func addHashCacheFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("rehash", false, "Hash every file again instead of reusing cached hashes")
+	cmd.Flags().Bool("no-cache", false, "Neither read nor update the hash cache")
+}
+
+// scanDuplicates runs a duplicate scan, printing progress and a summary.
+func scanDuplicates(ctx context.Context, opts duplicates.ScanOptions) (*duplicates.ScanResult, error) {
+	progressCh := make(chan duplicates.ScanProgress, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for progress := range progressCh {
+			if progress.Phase != "" {
+				fmt.Printf("\r%s - %d files scanned (%s)",
+					progress.Phase,
+					progress.FilesScanned,
+					utils.HumanizeBytes(uint64(progress.BytesScanned)))
+			}
+		}
+	}()
+
+	result, err := duplicates.NewScanner(opts).Scan(ctx, progressCh)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("\n\n%s\n", S.Header("📊 Scan Results"))
+	fmt.Println(S.Separator())
+	fmt.Printf("Files scanned: %d\n", result.FilesScanned)
+	fmt.Printf("Duplicate groups: %d\n", len(result.Groups))
+	fmt.Printf("Duplicate files: %d\n", result.TotalDupes)
+	fmt.Printf("Wasted space: %s\n", utils.HumanizeBytes(uint64(result.WastedSpace)))
+	printHardlinked(result)
+	return result, nil
+}
+
+// printHardlinked reports hardlinks the scan found, which already share one
+// copy and are not counted as wasted space.
+func printHardlinked(result *duplicates.ScanResult) {
+	if result.HardlinkedFiles > 0 {
+		fmt.Printf("Already hardlinked: %d files, saving %s\n",
+			result.HardlinkedFiles, utils.HumanizeBytes(uint64(result.HardlinkedSpace)))
+	}
+}
+
+func init() {
+	duplicatesCmd.AddCommand(duplicatesDedupeCmd)
+	duplicatesCmd.AddCommand(duplicatesApplyCmd)
+
+	duplicatesDedupeCmd.Flags().String("mode", string(duplicates.ModeHardlink), "Link type: hardlink, reflink or symlink")
+	duplicatesDedupeCmd.Flags().Int64("min-size", int64(duplicates.DefaultMinSize), "Minimum file size to consider (bytes)")
+	duplicatesDedupeCmd.Flags().Bool("verify", false, "Compare matching files byte by byte after hashing")
+	duplicatesDedupeCmd.Flags().Bool("dry-run", true, "Preview only (default); pass --force to apply")
+	duplicatesDedupeCmd.Flags().Bool("force", false, "Actually replace the duplicates")
+	duplicatesApplyCmd.Flags().Bool("dry-run", true, "Preview only (default); pass --force to apply")
+	duplicatesApplyCmd.Flags().Bool("force", false, "Actually remove or link the marked files")
+	duplicatesFindCmd.Flags().String("report", "", "Write the groups to this file for review (.csv for CSV, otherwise JSON)")
+
+	addScanScopeFlags(duplicatesDedupeCmd)
+	addScanScopeFlags(duplicatesFindCmd)
+	addScanScopeFlags(duplicatesCleanCmd)
+	addHashCacheFlags(duplicatesDedupeCmd)
+	addHashCacheFlags(duplicatesFindCmd)
+	addHashCacheFlags(duplicatesCleanCmd)
+	addKeepPolicyFlags(duplicatesDedupeCmd)
+	addKeepPolicyFlags(duplicatesFindCmd)
+	addKeepPolicyFlags(duplicatesCleanCmd)
+}
@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -52,18 +55,25 @@ func scanCategory(t *testing.T, cat *config.Category) *config.Category {
 	return stats
 }
 
-func cleanVerified(t *testing.T, cat *config.Category) (int, uint64, []string) {
+// cleanVerified cleans a revalidated cache one category at a time, as
+// cleanSession does, and totals the outcomes.
+func cleanVerified(t *testing.T, verified *config.SessionCache) (int, uint64, []string) {
 	t.Helper()
-	ch := make(chan cleaner.CleanMsg, 128)
 	c := cleaner.NewCleaner(&config.Config{})
 	defer c.Close()
-	go c.CleanCategory(context.Background(), cat, false, ch)
 	var deleted int
 	var freed uint64
 	var errs []string
-	for msg := range ch {
-		if msg.Complete != nil {
-			deleted, freed, errs = msg.Complete.FilesDeleted, msg.Complete.BytesFreed, msg.Complete.Errors
+	categories := verified.ByCategory()
+	for i := range categories {
+		ch := make(chan cleaner.CleanMsg, 128)
+		go c.CleanCategory(context.Background(), &categories[i], false, ch)
+		for msg := range ch {
+			if msg.Complete != nil {
+				deleted += msg.Complete.FilesDeleted
+				freed += msg.Complete.BytesFreed
+				errs = append(errs, msg.Complete.Errors...)
+			}
 		}
 	}
 	return deleted, freed, errs
@@ -134,7 +144,7 @@ func TestEndToEndScanCacheRevalidateClean(t *testing.T) {
 		t.Fatalf("expected %d files, got %d", len(sizes), verified.TotalFiles)
 	}
 
-	deleted, freed, errs := cleanVerified(t, verified.ScanResults)
+	deleted, freed, errs := cleanVerified(t, verified)
 	if len(errs) != 0 {
 		t.Fatalf("unexpected clean errors: %v", errs)
 	}
@@ -233,7 +243,7 @@ func TestEndToEndTamperedCacheIsRejected(t *testing.T) {
 		t.Errorf("expected 2 out-of-category drops, got %v", report.Dropped)
 	}
 
-	cleanVerified(t, verified.ScanResults)
+	cleanVerified(t, verified)
 
 	// The critical files must still be present and unmodified.
 	for _, p := range []string{systemFile, otherUserDoc} {
@@ -453,3 +463,242 @@ func TestCLIScanThenCleanEndToEnd(t *testing.T) {
 		t.Error("the symlink itself should have been left alone, not unlinked")
 	}
 }
+
+// writeSandboxConfig saves a config containing only the given categories, after
+// checking every path lives under root, so the pipeline can never reach the
+// real machine's caches.
+func writeSandboxConfig(t *testing.T, root string, categories ...config.Category) {
+	t.Helper()
+	for _, cat := range categories {
+		for _, p := range cat.Paths {
+			if !strings.HasPrefix(filepath.Clean(p), root) {
+				t.Fatalf("refusing to run: category %q targets %s, outside the temp root %s",
+					cat.Name, p, root)
+			}
+		}
+	}
+	cfgPath, err := paths.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Save(&config.Config{Categories: categories}, cfgPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// --output json: the documents scan and clean return must describe the run
+// per category, including revalidation drops, without any styled text.
+func TestCLIJSONReportsDescribeScanAndClean(t *testing.T) {
+	root := isolate(t)
+	cacheDir := filepath.Join(root, "appcache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"a.tmp": 100, "b.tmp": 200} {
+		if err := os.WriteFile(filepath.Join(cacheDir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSandboxConfig(t, root,
+		config.Category{Name: "Test Cache", Paths: []string{cacheDir}, Filters: []string{`\.tmp$`}, Risk: config.Low, Selected: true},
+		config.Category{Name: "Absent Cache", Paths: []string{filepath.Join(root, "nope")}, Risk: config.Low, Selected: true},
+	)
+
+	prevMode, prevInc, prevExc, prevOut := scanMode, includeCategories, excludeCategories, cliOut
+	t.Cleanup(func() { scanMode, includeCategories, excludeCategories, cliOut = prevMode, prevInc, prevExc, prevOut })
+	scanMode, includeCategories, excludeCategories = "", nil, nil
+	var human bytes.Buffer
+	cliOut = &human
+
+	scanReport, err := scanAndSave("")
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if scanReport.TotalFiles != 2 || scanReport.TotalBytes != 300 {
+		t.Errorf("scan report totals = %d files / %d bytes, want 2 / 300",
+			scanReport.TotalFiles, scanReport.TotalBytes)
+	}
+	byName := make(map[string]CategoryScanReport)
+	for _, c := range scanReport.Categories {
+		byName[c.Name] = c
+	}
+	if got := byName["Test Cache"]; got.Status != "scanned" || got.Files != 2 || got.Bytes != 300 {
+		t.Errorf("Test Cache line = %+v", got)
+	}
+	if got := byName["Absent Cache"]; got.Status != "not_found" {
+		t.Errorf("Absent Cache line = %+v, want status not_found", got)
+	}
+	if scanReport.CachePath == "" || scanReport.ScannedAt.IsZero() {
+		t.Error("scan report must name the cache it wrote and when")
+	}
+
+	// One file disappears between scan and clean: the gate drops it and the
+	// clean report must say why.
+	if err := os.Remove(filepath.Join(cacheDir, "b.tmp")); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanReport, err := cleanSession(true)
+	if err != nil {
+		t.Fatalf("dry-run clean failed: %v", err)
+	}
+	if !cleanReport.DryRun || cleanReport.FilesDeleted != 0 {
+		t.Errorf("dry run must not report deletions: %+v", cleanReport)
+	}
+	if cleanReport.TotalFiles != 1 || cleanReport.TotalBytes != 100 {
+		t.Errorf("clean plan = %d files / %d bytes, want 1 / 100", cleanReport.TotalFiles, cleanReport.TotalBytes)
+	}
+	if cleanReport.Revalidation == nil || cleanReport.Revalidation.Dropped != 1 ||
+		len(cleanReport.Revalidation.Reasons) != 1 || cleanReport.Revalidation.Reasons[0].Code != "missing" {
+		t.Errorf("revalidation = %+v, want one 'missing' drop", cleanReport.Revalidation)
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSONReport(&buf, cleanReport); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("clean report is not valid JSON: %v", err)
+	}
+	if strings.Contains(buf.String(), "\033[") {
+		t.Error("JSON report must not contain ANSI styling")
+	}
+	if human.Len() == 0 {
+		t.Error("human-readable progress should still be written to cliOut")
+	}
+}
+
+// writeTestConfig saves cfg as the isolated config, refusing any category
+// outside root: CleanSession deletes for real.
+func writeTestConfig(t *testing.T, root string, cfg *config.Config) {
+	t.Helper()
+	for _, cat := range cfg.Categories {
+		for _, p := range cat.Paths {
+			if !strings.HasPrefix(filepath.Clean(p), root) {
+				t.Fatalf("refusing to run: category %q targets %s, outside the temp root %s", cat.Name, p, root)
+			}
+		}
+	}
+	cfgPath, err := paths.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Save(cfg, cfgPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Run through sudo, a clean splits a category between root and the helper.
+// The size limit must judge the whole category, since each half alone fits.
+func TestCleanSessionChecksSizeLimitBeforeSplitting(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("categories are only split when running as root")
+	}
+	if _, err := user.Lookup("nobody"); err != nil {
+		t.Skip("no unprivileged user to clean for")
+	}
+	root := isolate(t)
+	home := filepath.Join(root, "home")
+	t.Setenv("MOONBIT_HOME", home)
+	t.Setenv("SUDO_USER", "nobody")
+	t.Setenv("PKEXEC_UID", "")
+
+	owned := filepath.Join(home, "junk", "a.bin")
+	system := filepath.Join(root, "sys", "b.bin")
+	for _, f := range []string{owned, system} {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, make([]byte, 700*1024), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestConfig(t, root, &config.Config{
+		Safety: config.SafetyConfig{MaxDeletionSize: "1MiB"},
+		Categories: []config.Category{{
+			Name:     "Mixed",
+			Paths:    []string{filepath.Dir(owned), filepath.Dir(system)},
+			Filters:  []string{`\.bin$`},
+			Risk:     config.Low,
+			Selected: true,
+		}},
+	})
+
+	prevMode, prevInc, prevExc := scanMode, includeCategories, excludeCategories
+	t.Cleanup(func() { scanMode, includeCategories, excludeCategories = prevMode, prevInc, prevExc })
+	scanMode, includeCategories, excludeCategories = "", nil, nil
+
+	if err := ScanAndSave(); err != nil {
+		t.Fatalf("ScanAndSave failed: %v", err)
+	}
+	err := CleanSession(false)
+	if err == nil || !strings.Contains(err.Error(), "exceeds maximum") {
+		t.Errorf("CleanSession: err = %v, want the category refused for its size", err)
+	}
+	for _, f := range []string{owned, system} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("%s was cleaned although its category is over the limit: %v", f, err)
+		}
+	}
+}
+
+// 'moonbit scan' re-executes as root and leaves root's sealed cache. The
+// default 'moonbit clean', a preview, must read that same cache rather than
+// a stale or missing one in the user's ~/.cache.
+func TestCLIRootScanThenDryRunClean(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("the sealed cache is only used as root")
+	}
+	root := isolate(t)
+	origState, origLock, origRootOwned := session.StateDir, session.LockDir, session.RootOwned
+	session.StateDir, session.LockDir = filepath.Join(root, "state"), filepath.Join(root, "run")
+	session.RootOwned = func() bool { return true }
+	t.Cleanup(func() { session.StateDir, session.LockDir, session.RootOwned = origState, origLock, origRootOwned })
+
+	appCache := filepath.Join(root, ".cache", "app")
+	if err := os.MkdirAll(appCache, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.tmp", "b.tmp"} {
+		if err := os.WriteFile(filepath.Join(appCache, name), make([]byte, 1024), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestConfig(t, root, &config.Config{Categories: []config.Category{{
+		Name:     "App Cache",
+		Paths:    []string{appCache},
+		Filters:  []string{`\.tmp$`},
+		Risk:     config.Low,
+		Selected: true,
+	}}})
+
+	prevMode, prevInc, prevExc := scanMode, includeCategories, excludeCategories
+	t.Cleanup(func() { scanMode, includeCategories, excludeCategories = prevMode, prevInc, prevExc })
+	scanMode, includeCategories, excludeCategories = "", nil, nil
+
+	if err := ScanAndSave(); err != nil {
+		t.Fatalf("ScanAndSave failed: %v", err)
+	}
+	report, err := cleanSession(true)
+	if err != nil {
+		t.Fatalf("dry-run clean failed: %v", err)
+	}
+	if want := filepath.Join(session.StateDir, "scan_results.json"); report.CachePath != want {
+		t.Errorf("dry run read %s, want the sealed cache %s", report.CachePath, want)
+	}
+	if report.TotalFiles != 2 || report.TotalBytes != 2048 {
+		t.Errorf("dry run previewed %d files (%d bytes), want 2 (2048 bytes)", report.TotalFiles, report.TotalBytes)
+	}
+	for _, name := range []string{"a.tmp", "b.tmp"} {
+		if _, err := os.Stat(filepath.Join(appCache, name)); err != nil {
+			t.Errorf("a dry run removed %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "cache", "moonbit", "scan_results.json")); !os.IsNotExist(err) {
+		t.Errorf("a root scan wrote the per-user cache: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/utils"
+)
+
+// journalUsagePattern finds the size in `journalctl --disk-usage` output,
+// which has read both "Journals take up 3.9G on disk." and "Archived and
+// active journals take up 1.2G in the file system."
+var journalUsagePattern = regexp.MustCompile(`take up ([0-9]+(?:\.[0-9]+)?)\s*([KMGTPE]?)i?B?\b`)
+
+// parseJournalUsage returns the bytes `journalctl --disk-usage` reports.
+// journalctl prints sizes in powers of 1024.
+func parseJournalUsage(output string) (uint64, error) {
+	m := journalUsagePattern.FindStringSubmatch(output)
+	if m == nil {
+		return 0, fmt.Errorf("unrecognised journalctl --disk-usage output: %q", strings.TrimSpace(output))
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid journal size %q: %w", m[1], err)
+	}
+	shift := strings.Index("KMGTPE", m[2]) + 1
+	if m[2] == "" {
+		shift = 0
+	}
+	return uint64(value * float64(uint64(1)<<(10*shift))), nil
+}
+
+// journalDiskUsage runs `journalctl --disk-usage`.
+func journalDiskUsage() (uint64, error) {
+	out, err := exec.Command("journalctl", "--disk-usage").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("journalctl --disk-usage failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return parseJournalUsage(string(out))
+}
+
+// journalVacuumArgs builds the journalctl arguments for a retention policy.
+// Unset limits are left out. size is parsed with utils.ParseSize and passed
+// as plain bytes, since journalctl rejects suffixes such as "GiB" or "gb"
+// that moonbit accepts elsewhere.
+func journalVacuumArgs(size, age string, files int) ([]string, error) {
+	var args []string
+	if size != "" {
+		bytes, err := utils.ParseSize(size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--vacuum-size="+strconv.FormatUint(bytes, 10))
+	}
+	if age != "" {
+		args = append(args, "--vacuum-time="+age)
+	}
+	if files > 0 {
+		args = append(args, "--vacuum-files="+strconv.Itoa(files))
+	}
+	return args, nil
+}
+
+// projectedJournalSavings is what vacuuming to size would free at most,
+// given the current usage. Only archived files are removed, so the journal
+// may stay above size. Returns false when size is unset or unparseable:
+// savings by age or file count cannot be known without reading the journal.
+func projectedJournalSavings(usage uint64, size string) (uint64, bool) {
+	if size == "" {
+		return 0, false
+	}
+	limit, err := utils.ParseSize(size)
+	if err != nil {
+		return 0, false
+	}
+	if usage <= limit {
+		return 0, true
+	}
+	return usage - limit, true
+}
+
+// runJournalVacuum runs journalctl with args, writing its output to out, and
+// records the outcome with LogSystemdOperation. It returns the space freed,
+// measured with --disk-usage before and after; zero if either failed.
+func runJournalVacuum(args []string, out io.Writer, auditLog *audit.Logger) (uint64, error) {
+	before, beforeErr := journalDiskUsage()
+
+	vacuum := exec.Command("journalctl", args...)
+	vacuum.Stdout = out
+	vacuum.Stderr = out
+	err := vacuum.Run()
+
+	var freed uint64
+	if after, afterErr := journalDiskUsage(); err == nil && beforeErr == nil && afterErr == nil && after < before {
+		freed = before - after
+	}
+	if auditLog != nil {
+		result := fmt.Sprintf("success freed=%d", freed)
+		if err != nil {
+			result = "failed"
+		}
+		auditLog.LogSystemdOperation("journal_vacuum", strings.Join(args, " "), result, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("journal vacuum failed: %w", err)
+	}
+	return freed, nil
+}
+
+// vacuumJournalPolicy applies the [journal] policy from the config, if one
+// is enabled. The daemon runs it after each scheduled clean.
+func vacuumJournalPolicy(out io.Writer, auditLog *audit.Logger) error {
+	cfg, err := config.Load("")
+	if err != nil {
+		return err
+	}
+	policy := cfg.Journal
+	if !policy.Enabled {
+		return nil
+	}
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return fmt.Errorf("journal policy set but journalctl not found")
+	}
+
+	args, err := journalVacuumArgs(policy.MaxSize, policy.MaxAge, policy.MaxFiles)
+	if err != nil {
+		return fmt.Errorf("journal max_size: %w", err)
+	}
+	fmt.Fprintf(out, "🗑️  Running: journalctl %s\n", strings.Join(args, " "))
+	freed, err := runJournalVacuum(args, out, auditLog)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Journal vacuumed, freed %s\n", utils.HumanizeBytes(freed))
+	return nil
+}
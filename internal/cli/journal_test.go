@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJournalUsage(t *testing.T) {
+	tests := map[string]uint64{
+		"Archived and active journals take up 1.5G in the file system.\n": 3 << 29,
+		"Journals take up 8.0M on disk.\n":                                8 << 20,
+		"Archived and active journals take up 512B in the file system.":   512,
+		"Archived and active journals take up 24.0K in the file system.":  24 << 10,
+	}
+	for output, want := range tests {
+		got, err := parseJournalUsage(output)
+		require.NoError(t, err, output)
+		assert.Equal(t, want, got, output)
+	}
+
+	_, err := parseJournalUsage("No journal files were found.")
+	assert.Error(t, err)
+}
+
+func TestJournalVacuumArgs(t *testing.T) {
+	args, err := journalVacuumArgs("500M", "14d", 5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--vacuum-size=524288000", "--vacuum-time=14d", "--vacuum-files=5"}, args)
+
+	// Suffixes journalctl would reject reach it as plain bytes.
+	for _, size := range []string{"2GiB", "2gb", "2G"} {
+		args, err = journalVacuumArgs(size, "", 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--vacuum-size=2147483648"}, args, size)
+	}
+
+	args, err = journalVacuumArgs("", "", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--vacuum-files=3"}, args)
+	args, err = journalVacuumArgs("", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, args)
+
+	_, err = journalVacuumArgs("lots", "", 0)
+	assert.Error(t, err)
+}
+
+func TestProjectedJournalSavings(t *testing.T) {
+	savings, ok := projectedJournalSavings(3<<30, "1G")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2<<30), savings)
+
+	savings, ok = projectedJournalSavings(100<<20, "500M")
+	assert.True(t, ok)
+	assert.Zero(t, savings, "already under the limit")
+
+	_, ok = projectedJournalSavings(1<<30, "")
+	assert.False(t, ok, "savings by age or file count are unknown")
+}
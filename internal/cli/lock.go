@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Nomadcxx/moonbit/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// waitForLock makes scan and clean wait for another moonbit operation to
+// finish instead of failing. Set by --wait and --no-wait; without either,
+// only a run with a terminal waits. The daemon never waits.
+var waitForLock bool
+
+// applyLockFlags resolves --wait and --no-wait into waitForLock.
+func applyLockFlags(cmd *cobra.Command) error {
+	wait, err := cmd.Flags().GetBool("wait")
+	if err != nil {
+		return fmt.Errorf("failed to read wait flag: %w", err)
+	}
+	noWait, err := cmd.Flags().GetBool("no-wait")
+	if err != nil {
+		return fmt.Errorf("failed to read no-wait flag: %w", err)
+	}
+	switch {
+	case wait && noWait:
+		return fmt.Errorf("--wait and --no-wait cannot be used together")
+	case wait:
+		waitForLock = true
+	case noWait:
+		waitForLock = false
+	default:
+		waitForLock = hasControllingTerminal()
+	}
+	return nil
+}
+
+// acquireOpLock takes the lock shared by every scan and clean, waiting for
+// it if waitForLock is set. The caller must Unlock it.
+func acquireOpLock(sessionMgr *session.Manager, op string) (*session.Lock, error) {
+	lock, err := sessionMgr.TryLock(op)
+	var locked *session.LockedError
+	if !errors.As(err, &locked) {
+		return lock, err
+	}
+	if !waitForLock {
+		return nil, fmt.Errorf("%w; wait for it to finish or re-run with --wait", err)
+	}
+	fmt.Fprintf(cliOut, "%s %v; waiting for it to finish...\n", S.Warning("⏳"), locked)
+	return sessionMgr.WaitLock(context.Background(), op)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{scanCmd, cleanCmd} {
+		cmd.Flags().Bool("wait", false, "Wait for another running scan or clean to finish (default when run from a terminal)")
+		cmd.Flags().Bool("no-wait", false, "Fail at once if another scan or clean is running")
+	}
+}
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/validation"
+)
+
+// ReportSchemaVersion is bumped whenever a field in ScanReport or CleanReport
+// changes meaning or is removed. Adding fields does not bump it.
+const ReportSchemaVersion = 1
+
+// Output formats accepted by --output.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// outputFormat is the --output flag shared by scan and clean.
+var outputFormat string
+
+// cliOut receives the human-readable scan and clean output. In --output json
+// mode it points at stderr, so stdout carries only the JSON document and
+// scripts no longer have to scrape styled text.
+var cliOut io.Writer = os.Stdout
+
+func jsonOutput() bool {
+	return outputFormat == OutputJSON
+}
+
+// applyOutputFlag validates --output and routes human-readable output away
+// from stdout when a JSON document is going to be written there.
+func applyOutputFlag() error {
+	if err := validation.ValidateOutputFormat(outputFormat); err != nil {
+		return err
+	}
+	if jsonOutput() {
+		cliOut = os.Stderr
+	} else {
+		cliOut = os.Stdout
+	}
+	return nil
+}
+
+// CategoryScanReport is one category's line in a ScanReport.
+type CategoryScanReport struct {
+	Name string           `json:"name"`
+	Risk config.RiskLevel `json:"risk"`
+	// Status is "scanned", "not_found" (no configured path exists) or "error".
+	Status     string `json:"status"`
+	Files      int    `json:"files"`
+	Bytes      uint64 `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ScanReport is the document `moonbit scan --output json` writes to stdout.
+type ScanReport struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Command       string               `json:"command"`
+	Mode          string               `json:"mode"`
+	ScannedAt     time.Time            `json:"scanned_at"`
+	DurationMs    int64                `json:"duration_ms"`
+	CachePath     string               `json:"cache_path,omitempty"`
+	TotalFiles    int                  `json:"total_files"`
+	TotalBytes    uint64               `json:"total_bytes"`
+	Categories    []CategoryScanReport `json:"categories"`
+	// DirsReused and DirsListed count directories whose listing came from the
+	// directory index versus ones read from disk during this scan.
+	DirsReused int    `json:"dirs_reused"`
+	DirsListed int    `json:"dirs_listed"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DropReport is one revalidation drop reason with its count.
+type DropReport struct {
+	Code        string   `json:"code"`
+	Description string   `json:"description"`
+	Count       int      `json:"count"`
+	Examples    []string `json:"examples,omitempty"`
+}
+
+// RevalidationReport is the JSON form of validation.Report.
+type RevalidationReport struct {
+	Accepted int          `json:"accepted"`
+	Dropped  int          `json:"dropped"`
+	Reasons  []DropReport `json:"reasons"`
+}
+
+// CategoryCleanReport is one category's share of a clean. Files and Bytes are
+// the verified plan; the remaining fields describe what happened and stay zero
+// on a dry run.
+type CategoryCleanReport struct {
+	Name         string           `json:"name"`
+	Risk         config.RiskLevel `json:"risk"`
+	Files        int              `json:"files"`
+	Bytes        uint64           `json:"bytes"`
+	FilesDeleted int              `json:"files_deleted"`
+	BytesFreed   uint64           `json:"bytes_freed"`
+	FilesTrashed int              `json:"files_trashed,omitempty"`
+	BytesTrashed uint64           `json:"bytes_trashed,omitempty"`
+	BackupPath   string           `json:"backup_path,omitempty"`
+	Errors       []string         `json:"errors,omitempty"`
+	// Error is set when the cleaner refused the whole category.
+	Error string `json:"error,omitempty"`
+}
+
+// CleanReport is the document `moonbit clean --output json` writes to stdout.
+//
+// TotalFiles/TotalBytes describe the verified delete list after revalidation
+// and filters. FilesDeleted/BytesFreed are what actually happened and stay zero
+// on a dry run. Files moved to the trash count in FilesDeleted and
+// FilesTrashed, and their size in BytesTrashed rather than BytesFreed.
+type CleanReport struct {
+	SchemaVersion int                   `json:"schema_version"`
+	Command       string                `json:"command"`
+	Mode          string                `json:"mode"`
+	DryRun        bool                  `json:"dry_run"`
+	CachePath     string                `json:"cache_path,omitempty"`
+	ScannedAt     *time.Time            `json:"scanned_at,omitempty"`
+	DurationMs    int64                 `json:"duration_ms"`
+	Revalidation  *RevalidationReport   `json:"revalidation,omitempty"`
+	TotalFiles    int                   `json:"total_files"`
+	TotalBytes    uint64                `json:"total_bytes"`
+	Categories    []CategoryCleanReport `json:"categories"`
+	FilesDeleted  int                   `json:"files_deleted"`
+	BytesFreed    uint64                `json:"bytes_freed"`
+	FilesTrashed  int                   `json:"files_trashed,omitempty"`
+	BytesTrashed  uint64                `json:"bytes_trashed,omitempty"`
+	BackupsPruned []string              `json:"backups_pruned,omitempty"`
+	Errors        []string              `json:"errors"`
+	Message       string                `json:"message,omitempty"`
+	Error         string                `json:"error,omitempty"`
+}
+
+func reportMode(mode string) string {
+	if mode == "" {
+		return "all"
+	}
+	return mode
+}
+
+func newScanReport(mode string) *ScanReport {
+	return &ScanReport{
+		SchemaVersion: ReportSchemaVersion,
+		Command:       "scan",
+		Mode:          reportMode(mode),
+		Categories:    []CategoryScanReport{},
+	}
+}
+
+func newCleanReport(mode string, dryRun bool) *CleanReport {
+	return &CleanReport{
+		SchemaVersion: ReportSchemaVersion,
+		Command:       "clean",
+		Mode:          reportMode(mode),
+		DryRun:        dryRun,
+		Categories:    []CategoryCleanReport{},
+		Errors:        []string{},
+	}
+}
+
+// categoryScanReports converts per-category scan outcomes into report lines.
+func categoryScanReports(results []categoryScanResult) []CategoryScanReport {
+	out := make([]CategoryScanReport, 0, len(results))
+	for _, r := range results {
+		line := CategoryScanReport{
+			Name:       r.Category.Name,
+			Risk:       r.Category.Risk,
+			DurationMs: r.Duration.Milliseconds(),
+		}
+		switch {
+		case r.NotFound:
+			line.Status = "not_found"
+		case r.Err != nil:
+			line.Status = "error"
+			line.Error = r.Err.Error()
+		default:
+			line.Status = "scanned"
+			if r.Stats != nil {
+				line.Files = len(r.Stats.Files)
+				for _, f := range r.Stats.Files {
+					line.Bytes += f.Size
+				}
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// revalidationReport converts the gate's report, ordering reasons by code so
+// the document is stable from run to run.
+func revalidationReport(r *validation.Report) *RevalidationReport {
+	if r == nil {
+		return nil
+	}
+	out := &RevalidationReport{
+		Accepted: r.Accepted,
+		Dropped:  r.TotalDropped(),
+		Reasons:  []DropReport{},
+	}
+	for reason, n := range r.Dropped {
+		out.Reasons = append(out.Reasons, DropReport{
+			Code:        reason.Code(),
+			Description: string(reason),
+			Count:       n,
+			Examples:    r.Examples[reason],
+		})
+	}
+	sort.Slice(out.Reasons, func(i, j int) bool {
+		return out.Reasons[i].Code < out.Reasons[j].Code
+	})
+	return out
+}
+
+// categoryCleanReports seeds one report line per category in the session
+// cache's per-category section.
+func categoryCleanReports(summaries []config.CategorySummary) []CategoryCleanReport {
+	out := make([]CategoryCleanReport, 0, len(summaries))
+	for _, summary := range summaries {
+		out = append(out, CategoryCleanReport{
+			Name:  summary.Name,
+			Risk:  summary.Risk,
+			Files: summary.FileCount,
+			Bytes: summary.Size,
+		})
+	}
+	return out
+}
+
+// writeJSONReport writes v to w as indented JSON followed by a newline.
+func writeJSONReport(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
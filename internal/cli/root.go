@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,10 +12,12 @@ import (
 	"time"
 
 	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/backup"
 	"github.com/Nomadcxx/moonbit/internal/cleaner"
 	"github.com/Nomadcxx/moonbit/internal/config"
 	"github.com/Nomadcxx/moonbit/internal/duplicates"
 	"github.com/Nomadcxx/moonbit/internal/paths"
+	"github.com/Nomadcxx/moonbit/internal/privsep"
 	"github.com/Nomadcxx/moonbit/internal/scanner"
 	"github.com/Nomadcxx/moonbit/internal/session"
 	"github.com/Nomadcxx/moonbit/internal/ui"
@@ -28,19 +31,13 @@ var (
 	dryRun            bool
 	cleanForce        bool
 	scanNoPrompt      bool
+	scanFull          bool
 	listCategories    bool
 	includeCategories []string
 	excludeCategories []string
 	scanMode          string // "quick", "deep", or "" (all)
 )
 
-// Constants for scan operations
-const (
-	// ScanDelayBetweenCategories is the delay between scanning different categories
-	// to prevent overwhelming the filesystem and provide smoother progress updates
-	ScanDelayBetweenCategories = 100 * time.Millisecond
-)
-
 var rootCmd = &cobra.Command{
 	Use:   "moonbit",
 	Short: "MoonBit - System Cleaner for Linux",
@@ -81,6 +78,12 @@ var scanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Scan system for cleanable files",
 	Long:  "Scan the system for cleanable files and cache locations",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyOutputFlag(); err != nil {
+			return err
+		}
+		return applyLockFlags(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if scanMode != "" {
 			if err := validation.ValidateMode(scanMode); err != nil {
@@ -102,6 +105,25 @@ var scanCmd = &cobra.Command{
 			return
 		}
 
+		if jsonOutput() {
+			report, err := scanAndSave(scanMode)
+			if report != nil {
+				if err != nil {
+					report.Error = err.Error()
+				}
+				if werr := writeJSONReport(os.Stdout, report); werr != nil {
+					fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", werr)
+					os.Exit(1)
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Scan failed: %v\n", err)
+				os.Exit(1)
+			}
+			// A JSON consumer is a script; there is nobody to answer the prompt.
+			return
+		}
+
 		if err := ScanAndSave(); err != nil {
 			fmt.Fprintf(os.Stderr, "Scan failed: %v\n", err)
 			os.Exit(1)
@@ -131,16 +153,43 @@ var scanCmd = &cobra.Command{
 var cleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "Clean files from last scan",
-	Long:  "Clean files discovered in the last scan\n\nBy default, this previews what would be deleted. Use --force to actually delete files.",
+	Long: "Clean files discovered in the last scan\n\nBy default, this previews what would be deleted. Use --force to actually delete files.\n" +
+		"Both run through sudo, since the scan cache they read is root's.",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyOutputFlag(); err != nil {
+			return err
+		}
+		if err := applyLockFlags(cmd); err != nil {
+			return err
+		}
 		return applyCleanFlags(cmd)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if !isRunningAsRoot() && !dryRun {
+		// A preview re-executes too: 'moonbit scan' runs as root, so the cache
+		// it leaves is root's sealed one, which only root can read and verify.
+		if !isRunningAsRoot() {
 			reexecWithSudo()
 			return
 		}
 
+		if jsonOutput() {
+			report, err := cleanSession(dryRun)
+			if report != nil {
+				if err != nil {
+					report.Error = err.Error()
+				}
+				if werr := writeJSONReport(os.Stdout, report); werr != nil {
+					fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", werr)
+					os.Exit(1)
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		if err := CleanSession(dryRun); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -181,11 +230,11 @@ func isRunningAsRoot() bool {
 
 // reexecWithSudo re-executes the current command with sudo
 func reexecWithSudo() {
-	fmt.Println(S.ASCIIHeader())
-	fmt.Println(S.Warning("⚠ Root Access Required"))
-	fmt.Println(S.Separator())
-	fmt.Println("MoonBit needs root access to scan and clean system-wide caches.")
-	fmt.Println(S.Muted("You will be prompted for your password...\n"))
+	fmt.Fprintln(cliOut, S.ASCIIHeader())
+	fmt.Fprintln(cliOut, S.Warning("⚠ Root Access Required"))
+	fmt.Fprintln(cliOut, S.Separator())
+	fmt.Fprintln(cliOut, "MoonBit needs root access to scan and clean system-wide caches.")
+	fmt.Fprintln(cliOut, S.Muted("You will be prompted for your password...\n"))
 
 	// Get the current executable path
 	exe, err := os.Executable()
@@ -221,33 +270,71 @@ func ScanAndSave() error {
 
 // ScanAndSaveWithMode runs a scan filtered by mode (quick/deep)
 func ScanAndSaveWithMode(mode string) error {
+	_, err := scanAndSave(mode)
+	return err
+}
+
+// scanAndSave runs the scan, persists the session cache and returns the
+// machine-readable report. The report is returned alongside any error once the
+// scan has started, so --output json can still describe what happened.
+func scanAndSave(mode string) (*ScanReport, error) {
 	displayScanHeader(mode)
 
+	// Hold the operation lock for the whole scan, so no clean reads the
+	// cache while it is being replaced.
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session manager: %w", err)
+	}
+	lock, err := acquireOpLock(sessionMgr, "scan")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = lock.Unlock() }()
+
 	cfg, s, err := initializeScanner()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	categories, err := prepareScanCategories(mode, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	categories, err = applyCategorySelection(categories, includeCategories, excludeCategories)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	totalSize, totalFiles, scanResults, err := scanAllCategories(s, categories)
-	if err != nil {
-		return err
+	idx, idxPath := openDirIndex(scanFull)
+	if idx != nil {
+		s.UseIndex(idx)
 	}
 
-	if err := saveScanResults(totalSize, totalFiles, scanResults); err != nil {
-		return err
+	summary := scanAllCategories(s, categories)
+
+	report := newScanReport(mode)
+	if idx != nil {
+		report.DirsReused, report.DirsListed = idx.Stats()
+		if err := session.SaveDirIndex(idx, idxPath); err != nil {
+			fmt.Fprintf(cliOut, "Warning: could not save directory index: %v\n", err)
+		}
 	}
+	report.DurationMs = summary.Duration.Milliseconds()
+	report.TotalFiles = summary.TotalFiles
+	report.TotalBytes = summary.TotalSize
+	report.Categories = categoryScanReports(summary.Categories)
 
-	displayScanResults(totalFiles, totalSize)
-	return nil
+	scannedAt := time.Now()
+	cachePath, err := saveScanResults(summary, scannedAt)
+	if err != nil {
+		return report, err
+	}
+	report.ScannedAt = scannedAt
+	report.CachePath = cachePath
+
+	displayScanResults(summary.TotalFiles, summary.TotalSize)
+	return report, nil
 }
 
 func ListCategories(mode string) error {
@@ -289,15 +376,15 @@ func displayScanHeader(mode string) {
 		modeLabel = "Deep"
 	}
 
-	fmt.Println(S.ASCIIHeader())
-	fmt.Println(S.Header(fmt.Sprintf("%s Scan", modeLabel)))
-	fmt.Println(S.Separator())
+	fmt.Fprintln(cliOut, S.ASCIIHeader())
+	fmt.Fprintln(cliOut, S.Header(fmt.Sprintf("%s Scan", modeLabel)))
+	fmt.Fprintln(cliOut, S.Separator())
 }
 
 // initializeScanner loads config and creates a scanner instance
 func initializeScanner() (*config.Config, *scanner.Scanner, error) {
 	if configPath, err := paths.ConfigFile(); err == nil {
-		fmt.Printf("Using config: %s\n", configPath)
+		fmt.Fprintf(cliOut, "Using config: %s\n", configPath)
 	}
 
 	cfg, err := config.Load("")
@@ -309,6 +396,27 @@ func initializeScanner() (*config.Config, *scanner.Scanner, error) {
 	return cfg, s, nil
 }
 
+// openDirIndex loads the directory index used for incremental rescans. A full
+// scan starts from an empty index, so every directory is read again and the
+// index is rebuilt from what is on disk now. It returns nil when the index
+// location cannot be determined; the scan then simply walks everything. As
+// root the index lives in the root-owned state directory, not in the
+// invoking user's cache.
+func openDirIndex(full bool) (*scanner.DirIndex, string) {
+	idxPath, err := session.DirIndexPath()
+	if err != nil {
+		return nil, ""
+	}
+	if full {
+		return scanner.NewDirIndex(), idxPath
+	}
+	idx, idxPath, err := session.OpenDirIndex()
+	if err != nil {
+		fmt.Fprintf(cliOut, "Warning: %v; doing a full scan\n", err)
+	}
+	return idx, idxPath
+}
+
 // prepareScanCategories filters and prepares categories based on scan mode
 func prepareScanCategories(mode string, cfg *config.Config) ([]config.Category, error) {
 	availableCategories := detectAvailableCategories()
@@ -328,52 +436,123 @@ func prepareScanCategories(mode string, cfg *config.Config) ([]config.Category,
 	return filteredCategories, nil
 }
 
-// scanAllCategories scans all provided categories and aggregates results
-func scanAllCategories(s *scanner.Scanner, categories []config.Category) (uint64, int, config.Category, error) {
+// categoryScanResult is the outcome of scanning one category.
+type categoryScanResult struct {
+	Category config.Category
+	Stats    *config.Category // nil unless the scan completed
+	Duration time.Duration
+	NotFound bool     // none of the category's paths exist
+	Roots    []string // expanded paths that were walked
+	Warnings []string // non-fatal scan problems
+	Err      error
+}
+
+// summary is the session cache entry for a scanned category. Categories that
+// were not found are left out; a failed scan is kept so its error is recorded.
+func (r categoryScanResult) summary() (config.CategorySummary, bool) {
+	if r.NotFound {
+		return config.CategorySummary{}, false
+	}
+	entry := config.CategorySummary{
+		Name:       r.Category.Name,
+		Risk:       r.Category.Risk,
+		Selected:   r.Category.Selected,
+		DurationMs: r.Duration.Milliseconds(),
+		Errors:     append([]string(nil), r.Warnings...),
+		ScanRoots:  r.Roots,
+	}
+	if r.Err != nil {
+		entry.Errors = append(entry.Errors, r.Err.Error())
+	}
+	if r.Stats != nil {
+		entry.FileCount = len(r.Stats.Files)
+		for _, file := range r.Stats.Files {
+			entry.Size += file.Size
+		}
+	}
+	return entry, true
+}
+
+// scanSummary aggregates a scan across categories.
+type scanSummary struct {
+	TotalSize  uint64
+	TotalFiles int
+	Results    config.Category // every scanned file, with per-file provenance
+	Categories []categoryScanResult
+	Duration   time.Duration
+}
+
+// scanAllCategories scans all provided categories on the scanner's worker pool
+// and aggregates results in category order
+func scanAllCategories(s *scanner.Scanner, categories []config.Category) *scanSummary {
 	started := time.Now()
-	var totalSize uint64
-	var totalFiles int
+	summary := &scanSummary{}
 	var categoriesScanned int
-	var scanResults config.Category
-	scanResults.Name = "Total Cleanable"
-	scanResults.Files = []config.FileInfo{}
+	summary.Results.Name = "Total Cleanable"
+	summary.Results.Files = []config.FileInfo{}
 
+	results := make([]categoryScanResult, len(categories))
+	var found []config.Category
+	var positions []int
 	for i, category := range categories {
 		if !categoryPathExists(&category) {
-			fmt.Printf("Skipping %s (not found)\n", category.Name)
+			fmt.Fprintf(cliOut, "Skipping %s (not found)\n", category.Name)
+			results[i] = categoryScanResult{Category: category, NotFound: true}
 			continue
 		}
+		found = append(found, category)
+		positions = append(positions, i)
+	}
 
-		fmt.Printf("Scanning %s (%d/%d)...\n", category.Name, i+1, len(categories))
+	if len(found) > 0 {
+		fmt.Fprintf(cliOut, "Scanning %d categories...\n", len(found))
+	}
 
-		categoryStarted := time.Now()
-		stats, err := scanSingleCategory(s, &category)
-		categoryDuration := time.Since(categoryStarted)
-		if err != nil {
-			fmt.Printf("  Error: %v\n", err)
+	progressCh := make(chan scanner.ScanMsg, 10)
+	go s.ScanCategories(context.Background(), found, progressCh)
+
+	// Completions arrive in category order, one per category.
+	done := 0
+	for msg := range progressCh {
+		if msg.Complete == nil && msg.Error == nil {
 			continue
 		}
+		i := positions[done]
+		category := categories[i]
+		done++
+		fmt.Fprintf(cliOut, "Scanned %s (%d/%d)\n", category.Name, done, len(found))
 
+		if msg.Error != nil {
+			fmt.Fprintf(cliOut, "  Error: %v\n", msg.Error)
+			results[i] = categoryScanResult{Category: category, Err: msg.Error}
+			continue
+		}
+
+		complete := msg.Complete
+		stats := complete.Stats
 		if stats != nil {
 			var categorySize uint64
 			for _, file := range stats.Files {
 				categorySize += file.Size
 			}
 
-			fmt.Println(formatScanCategoryResult(len(stats.Files), categorySize, categoryDuration))
+			fmt.Fprintln(cliOut, formatScanCategoryResult(len(stats.Files), categorySize, complete.Duration))
 
-			totalSize += categorySize
-			totalFiles += len(stats.Files)
+			summary.TotalSize += categorySize
+			summary.TotalFiles += len(stats.Files)
 			categoriesScanned++
-			scanResults.Files = append(scanResults.Files, stats.Files...)
+			summary.Results.Files = append(summary.Results.Files, stats.Files...)
+		}
+		results[i] = categoryScanResult{
+			Category: category, Stats: stats, Duration: complete.Duration,
+			Roots: complete.Roots, Warnings: complete.Errors,
 		}
-
-		// Small delay between scans to prevent overwhelming the filesystem
-		time.Sleep(ScanDelayBetweenCategories)
 	}
+	summary.Categories = results
 
-	fmt.Println(formatScanSummary(categoriesScanned, totalFiles, totalSize, time.Since(started)))
-	return totalSize, totalFiles, scanResults, nil
+	summary.Duration = time.Since(started)
+	fmt.Fprintln(cliOut, formatScanSummary(categoriesScanned, summary.TotalFiles, summary.TotalSize, summary.Duration))
+	return summary
 }
 
 func formatScanCategoryResult(files int, size uint64, duration time.Duration) string {
@@ -414,56 +593,70 @@ func categoryPathExists(category *config.Category) bool {
 	return false
 }
 
-// scanSingleCategory scans a single category and returns its stats
-func scanSingleCategory(s *scanner.Scanner, category *config.Category) (*config.Category, error) {
-	progressCh := make(chan scanner.ScanMsg, 10)
-	go s.ScanCategory(context.Background(), category, progressCh)
-
-	for msg := range progressCh {
-		if msg.Complete != nil {
-			return msg.Complete.Stats, nil
-		}
-		if msg.Error != nil {
-			return nil, msg.Error
-		}
-	}
-
-	return nil, fmt.Errorf("scan completed without results")
-}
-
-// saveScanResults creates and saves the session cache
-func saveScanResults(totalSize uint64, totalFiles int, scanResults config.Category) error {
+// saveScanResults creates and saves the session cache, returning its path
+func saveScanResults(summary *scanSummary, scannedAt time.Time) (string, error) {
 	cache := &config.SessionCache{
-		ScanResults: &scanResults,
-		TotalSize:   totalSize,
-		TotalFiles:  totalFiles,
-		ScannedAt:   time.Now(),
+		Version:     config.SessionCacheVersion,
+		ScanResults: &summary.Results,
+		TotalSize:   summary.TotalSize,
+		TotalFiles:  summary.TotalFiles,
+		ScannedAt:   scannedAt,
+	}
+	for _, result := range summary.Categories {
+		if entry, ok := result.summary(); ok {
+			cache.Categories = append(cache.Categories, entry)
+		}
 	}
 
 	sessionMgr, err := session.NewManager()
 	if err != nil {
-		return fmt.Errorf("failed to create session manager: %w", err)
+		return "", fmt.Errorf("failed to create session manager: %w", err)
 	}
 
 	if err := sessionMgr.Save(cache); err != nil {
-		return fmt.Errorf("failed to save session cache: %w", err)
+		return "", fmt.Errorf("failed to save session cache: %w", err)
 	}
 
-	fmt.Printf("Saved scan cache: %s\n", sessionMgr.Path())
-	return nil
+	fmt.Fprintf(cliOut, "Saved scan cache: %s\n", sessionMgr.Path())
+	return sessionMgr.Path(), nil
 }
 
 // displayScanResults shows the final scan results summary
 func displayScanResults(totalFiles int, totalSize uint64) {
-	fmt.Println()
-	fmt.Println(S.Header("Scan Results"))
-	fmt.Println(S.Separator())
-	fmt.Printf("  %s %d\n", S.Bold("Files found:"), totalFiles)
-	fmt.Printf("  %s %s\n", S.Bold("Space available:"), S.Success(utils.HumanizeBytes(totalSize)))
+	fmt.Fprintln(cliOut)
+	fmt.Fprintln(cliOut, S.Header("Scan Results"))
+	fmt.Fprintln(cliOut, S.Separator())
+	fmt.Fprintf(cliOut, "  %s %d\n", S.Bold("Files found:"), totalFiles)
+	fmt.Fprintf(cliOut, "  %s %s\n", S.Bold("Space available:"), S.Success(utils.HumanizeBytes(totalSize)))
+}
+
+// loadCleanCache loads the scan cache a clean acts on. A root-owned cache
+// that fails its integrity check is reported as such, not as a missing scan.
+func loadCleanCache(sessionMgr *session.Manager) (*config.SessionCache, error) {
+	cache, err := sessionMgr.Load()
+	if errors.Is(err, session.ErrUntrusted) {
+		return nil, fmt.Errorf("refusing to clean: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no scan results found - run scan first: %w", err)
+	}
+	return cache, nil
 }
 
 // CleanSession executes the actual cleaning based on session cache
 func CleanSession(dryRun bool) error {
+	_, err := cleanSession(dryRun)
+	return err
+}
+
+// cleanSession cleans from the session cache and returns the machine-readable
+// report. As with scanAndSave, the report accompanies any error raised after
+// the cache was located so --output json can still describe the failure.
+func cleanSession(dryRun bool) (*CleanReport, error) {
+	started := time.Now()
+	report := newCleanReport(scanMode, dryRun)
+	defer func() { report.DurationMs = time.Since(started).Milliseconds() }()
+
 	modeLabel := "Standard"
 	if scanMode == "quick" {
 		modeLabel = "Quick"
@@ -471,152 +664,319 @@ func CleanSession(dryRun bool) error {
 		modeLabel = "Deep"
 	}
 
-	fmt.Println(S.ASCIIHeader())
-	fmt.Println(S.Header(fmt.Sprintf("%s Clean", modeLabel)))
-	fmt.Println(S.Separator())
+	fmt.Fprintln(cliOut, S.ASCIIHeader())
+	fmt.Fprintln(cliOut, S.Header(fmt.Sprintf("%s Clean", modeLabel)))
+	fmt.Fprintln(cliOut, S.Separator())
 
 	// Load session cache
 	sessionMgr, err := session.NewManager()
 	if err != nil {
-		return fmt.Errorf("failed to create session manager: %w", err)
+		return nil, fmt.Errorf("failed to create session manager: %w", err)
 	}
-	fmt.Printf("Using scan cache: %s\n", sessionMgr.Path())
-	cache, err := sessionMgr.Load()
+	report.CachePath = sessionMgr.Path()
+	lock, err := acquireOpLock(sessionMgr, "clean")
 	if err != nil {
-		return fmt.Errorf("no scan results found - run scan first: %w", err)
+		return report, err
+	}
+	defer func() { _ = lock.Unlock() }()
+	fmt.Fprintf(cliOut, "Using scan cache: %s\n", sessionMgr.Path())
+	cache, err := loadCleanCache(sessionMgr)
+	if err != nil {
+		return report, err
 	}
 	if cache.ScanResults == nil {
-		return fmt.Errorf("invalid scan results: missing scan result details")
+		return report, fmt.Errorf("invalid scan results: missing scan result details")
 	}
+	scannedAt := cache.ScannedAt
+	report.ScannedAt = &scannedAt
 
 	if cache.TotalFiles == 0 {
-		fmt.Println("No files to clean.")
-		return nil
+		report.Message = "No files to clean."
+		fmt.Fprintln(cliOut, report.Message)
+		return report, nil
 	}
 
 	// Load config and create cleaner
 	cfg, err := config.Load("")
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return report, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	// Re-derive the delete list from config before anything acts on it. The cache
 	// is user-writable and consumed as root, so its paths are claims to verify,
 	// not instructions to follow.
-	cache, err = revalidateSessionCache(cache, cfg)
+	cache, gateReport, err := revalidateSessionCacheReport(cache, cfg)
 	if err != nil {
-		return err
+		return report, err
 	}
+	report.Revalidation = revalidationReport(gateReport)
 	if cache.TotalFiles == 0 {
-		fmt.Println("No files to clean: nothing in the scan cache could be verified against the current config.")
-		return nil
+		report.Message = "No files to clean: nothing in the scan cache could be verified against the current config."
+		fmt.Fprintln(cliOut, report.Message)
+		return report, nil
 	}
 
 	// Filter cache by mode if specified
 	if scanMode != "" {
 		cache = filterCacheByMode(cache, cfg, scanMode)
 		if cache.TotalFiles == 0 {
-			fmt.Printf("No files to clean in %s mode.\n", scanMode)
-			return nil
+			report.Message = fmt.Sprintf("No files to clean in %s mode.", scanMode)
+			fmt.Fprintln(cliOut, report.Message)
+			return report, nil
 		}
 	}
 
 	cache, err = filterCacheByCategorySelection(cache, includeCategories, excludeCategories)
 	if err != nil {
-		return err
+		return report, err
 	}
 	if cache.TotalFiles == 0 {
-		fmt.Println("No files to clean after category filters.")
-		return nil
+		report.Message = "No files to clean after category filters."
+		fmt.Fprintln(cliOut, report.Message)
+		return report, nil
 	}
 
+	report.TotalFiles = cache.TotalFiles
+	report.TotalBytes = cache.TotalSize
+	report.Categories = categoryCleanReports(cache.CategorySummaries())
+
 	c := cleaner.NewCleaner(cfg)
+	defer func() { _ = c.Close() }()
 	ctx := context.Background()
 
 	if dryRun {
-		fmt.Printf("DRY RUN - Would delete %d files (%s)\n",
+		fmt.Fprintf(cliOut, "DRY RUN - Would delete %d files (%s)\n",
 			cache.TotalFiles, utils.HumanizeBytes(cache.TotalSize))
+		for _, summary := range cache.CategorySummaries() {
+			fmt.Fprintf(cliOut, "   %s: %d files (%s)\n",
+				summary.Name, summary.FileCount, utils.HumanizeBytes(summary.Size))
+		}
 
 		// Show preview of what would be cleaned
 		if cache.ScanResults != nil && len(cache.ScanResults.Files) > 0 {
-			fmt.Println("\n📋 Files that would be deleted:")
+			fmt.Fprintln(cliOut, "\n📋 Files that would be deleted:")
 			for i, file := range cache.ScanResults.Files {
 				if i >= 10 { // Limit preview
-					fmt.Printf("   ... and %d more files\n", len(cache.ScanResults.Files)-10)
+					fmt.Fprintf(cliOut, "   ... and %d more files\n", len(cache.ScanResults.Files)-10)
 					break
 				}
-				fmt.Printf("   %s (%s)\n", file.Path, utils.HumanizeBytes(file.Size))
+				fmt.Fprintf(cliOut, "   %s (%s)\n", file.Path, utils.HumanizeBytes(file.Size))
 			}
 		}
 
-		fmt.Println("\n💡 Use --force flag to actually delete files:")
-		fmt.Println("   moonbit clean --force")
-		return nil
+		fmt.Fprintln(cliOut, "\n💡 Use --force flag to actually delete files:")
+		fmt.Fprintln(cliOut, "   moonbit clean --force")
+		return report, nil
 	}
 
 	// Actual cleaning using cleaner package
-	fmt.Printf("🗑️  Deleting %d files (%s)...\n",
+	fmt.Fprintf(cliOut, "🗑️  Deleting %d files (%s)...\n",
 		cache.TotalFiles, utils.HumanizeBytes(cache.TotalSize))
 
-	progressCh := make(chan cleaner.CleanMsg, 10)
-	// Errors are delivered over progressCh; the return value is redundant here.
-	go func() { _ = c.CleanCategory(ctx, cache.ScanResults, dryRun, progressCh) }()
+	// Clean one category at a time so safety limits, backups and outcomes apply
+	// per category rather than to one merged list.
+	categories := cache.ByCategory()
+	lines := make(map[string]*CategoryCleanReport, len(report.Categories))
+	for i := range report.Categories {
+		lines[report.Categories[i].Name] = &report.Categories[i]
+	}
 
 	var deletedBytes uint64
 	var deletedFiles int
-	var errors []string
-
-	// Process cleaning messages
-	for msg := range progressCh {
-		if msg.Progress != nil {
-			// Progress update every 100 files
-			if msg.Progress.FilesProcessed%100 == 0 && msg.Progress.FilesProcessed > 0 {
-				fmt.Printf("   Progress: %d/%d files (%s)\n",
-					msg.Progress.FilesProcessed,
-					msg.Progress.TotalFiles,
-					utils.HumanizeBytes(msg.Progress.BytesFreed))
+	var trashedBytes uint64
+	var trashedFiles int
+	var failures []string
+	var refused []string
+
+	// A category split between root and the helper has one line, which
+	// both halves add to.
+	record := func(name string, complete *cleaner.CleanComplete) {
+		deletedFiles += complete.FilesDeleted
+		deletedBytes += complete.BytesFreed
+		trashedFiles += complete.FilesTrashed
+		trashedBytes += complete.BytesTrashed
+		failures = append(failures, complete.Errors...)
+		if complete.BackupCreated {
+			fmt.Fprintf(cliOut, "   📦 Backup created: %s\n", complete.BackupPath)
+		}
+		if line := lines[name]; line != nil {
+			line.FilesDeleted += complete.FilesDeleted
+			line.BytesFreed += complete.BytesFreed
+			line.FilesTrashed += complete.FilesTrashed
+			line.BytesTrashed += complete.BytesTrashed
+			if complete.BackupPath != "" {
+				line.BackupPath = complete.BackupPath
 			}
+			line.Errors = append(line.Errors, complete.Errors...)
+		}
+	}
+	refuse := func(name string, err error) {
+		fmt.Fprintf(cliOut, "   %s %v\n", S.Warning("Skipped:"), err)
+		refused = append(refused, fmt.Sprintf("%s: %v", name, err))
+		if line := lines[name]; line != nil {
+			line.Error = err.Error()
 		}
+	}
 
-		if msg.Complete != nil {
-			deletedFiles = msg.Complete.FilesDeleted
-			deletedBytes = msg.Complete.BytesFreed
-			errors = msg.Complete.Errors
+	// The size limit covers the whole category, however it is split below:
+	// each part alone could be under it.
+	var checked []config.Category
+	for i := range categories {
+		if err := c.CheckDeletionSize(&categories[i]); err != nil {
+			refuse(categories[i].Name, err)
+			continue
+		}
+		checked = append(checked, categories[i])
+	}
+	categories = checked
 
-			if msg.Complete.BackupCreated {
-				fmt.Printf("   📦 Backup created: %s\n", msg.Complete.BackupPath)
-			}
-			break
+	// Run as root on a user's behalf, files under their home are cleaned by a
+	// helper running as them and never as root. Files in anyone else's home
+	// are not cleaned at all. See package privsep.
+	owner, err := privsep.InvokingUser()
+	if err != nil {
+		return report, err
+	}
+	var owned []config.Category
+	var home string
+	if owner != nil {
+		if home, err = paths.HomeDir(); err != nil {
+			return report, err
 		}
+		categories, owned = privsep.Split(categories, home)
+	}
+	if os.Geteuid() == 0 {
+		var foreign []config.Category
+		categories, foreign = privsep.SplitOtherHomes(categories)
+		for _, category := range foreign {
+			refuse(category.Name, otherHomeError(category))
+		}
+	}
 
-		if msg.Error != nil {
-			return fmt.Errorf("cleaning failed: %w", msg.Error)
+	for i := range categories {
+		category := &categories[i]
+		fmt.Fprintf(cliOut, "Cleaning %s (%d/%d)...\n", category.Name, i+1, len(categories))
+
+		complete, err := cleanOneCategory(ctx, c, category)
+		if err != nil {
+			refuse(category.Name, err)
+			continue
 		}
+		record(category.Name, complete)
 	}
 
-	fmt.Println()
-	fmt.Println(S.Header("Cleaning Complete"))
-	fmt.Println(S.Separator())
-	fmt.Printf("  %s %d\n", S.Bold("Files deleted:"), deletedFiles)
-	fmt.Printf("  %s %s\n", S.Bold("Space freed:"), S.Success(utils.HumanizeBytes(deletedBytes)))
+	if len(owned) > 0 {
+		fmt.Fprintf(cliOut, "Cleaning %d categories under %s as %s...\n", len(owned), home, owner.Username)
+		resp, err := privsep.CleanAsUser(ctx, owner, home, cfg, owned)
+		if err != nil {
+			for _, category := range owned {
+				refuse(category.Name, err)
+			}
+		} else {
+			for _, result := range resp.Results {
+				if result.Complete == nil {
+					refuse(result.Name, fmt.Errorf("%s", result.Error))
+					continue
+				}
+				record(result.Name, result.Complete)
+			}
+			report.BackupsPruned = append(report.BackupsPruned, resp.BackupsPruned...)
+		}
+	}
 
-	if len(errors) > 0 {
-		fmt.Printf("  %s %d files could not be deleted\n", S.Warning("Errors:"), len(errors))
-		if len(errors) <= 5 {
-			for _, err := range errors {
-				fmt.Printf("      - %s\n", err)
+	// Apply the backup limits now that this run's backup is complete.
+	if pruned, err := c.PruneBackups(); err != nil {
+		fmt.Fprintf(cliOut, "   ⚠️  Warning: Could not prune old backups: %v\n", err)
+	} else if pruned != nil && len(pruned.Removed) > 0 {
+		fmt.Fprintf(cliOut, "   📦 Pruned %d old backups, freed %s\n",
+			len(pruned.Removed), utils.HumanizeBytes(pruned.BytesFreed))
+		report.BackupsPruned = append(report.BackupsPruned, pruned.Removed...)
+	}
+
+	report.FilesDeleted = deletedFiles
+	report.BytesFreed = deletedBytes
+	report.FilesTrashed = trashedFiles
+	report.BytesTrashed = trashedBytes
+	if failures != nil {
+		report.Errors = failures
+	}
+
+	fmt.Fprintln(cliOut)
+	fmt.Fprintln(cliOut, S.Header("Cleaning Complete"))
+	fmt.Fprintln(cliOut, S.Separator())
+	fmt.Fprintf(cliOut, "  %s %d\n", S.Bold("Files deleted:"), deletedFiles)
+	fmt.Fprintf(cliOut, "  %s %s\n", S.Bold("Space freed:"), S.Success(utils.HumanizeBytes(deletedBytes)))
+	if trashedFiles > 0 {
+		fmt.Fprintf(cliOut, "  %s %d files (%s), restore with 'moonbit trash restore'\n",
+			S.Bold("Moved to trash:"), trashedFiles, utils.HumanizeBytes(trashedBytes))
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintf(cliOut, "  %s %d files could not be deleted\n", S.Warning("Errors:"), len(failures))
+		if len(failures) <= 5 {
+			for _, err := range failures {
+				fmt.Fprintf(cliOut, "      - %s\n", err)
 			}
 		}
-		return fmt.Errorf("cleaning incomplete: %d file(s) could not be deleted", len(errors))
+		return report, fmt.Errorf("cleaning incomplete: %d file(s) could not be deleted", len(failures))
+	}
+	if len(refused) > 0 {
+		return report, fmt.Errorf("cleaning failed: %s", strings.Join(refused, "; "))
 	}
 
-	fmt.Printf("   ⚡ Scan data cleared\n")
+	fmt.Fprintf(cliOut, "   ⚡ Scan data cleared\n")
 
 	if err := clearSessionCache(); err != nil && !os.IsNotExist(err) {
-		fmt.Printf("   ⚠️  Warning: Could not clear cache file: %v\n", err)
+		fmt.Fprintf(cliOut, "   ⚠️  Warning: Could not clear cache file: %v\n", err)
 	}
 
-	return nil
+	return report, nil
+}
+
+// otherHomeError explains why root refused category's files in another
+// user's home.
+func otherHomeError(category config.Category) error {
+	return fmt.Errorf("%s: %d files in another user's home are never cleaned as root; run moonbit as that user to clean them",
+		category.Name, category.FileCount)
+}
+
+// cleanOneCategory runs the cleaner over one category, echoing progress, and
+// returns its completion. An error with no completion means the cleaner refused
+// the category before touching anything, such as a failed safety check.
+func cleanOneCategory(ctx context.Context, c *cleaner.Cleaner, category *config.Category) (*cleaner.CleanComplete, error) {
+	progressCh := make(chan cleaner.CleanMsg, 10)
+	// Errors are delivered over progressCh; the return value is redundant here.
+	go func() { _ = c.CleanCategory(ctx, category, false, progressCh) }()
+
+	var complete *cleaner.CleanComplete
+	var refused error
+	for msg := range progressCh {
+		if msg.Progress != nil {
+			// Progress update every 100 files
+			if msg.Progress.FilesProcessed%100 == 0 && msg.Progress.FilesProcessed > 0 {
+				fmt.Fprintf(cliOut, "   Progress: %d/%d files (%s)\n",
+					msg.Progress.FilesProcessed,
+					msg.Progress.TotalFiles,
+					utils.HumanizeBytes(msg.Progress.BytesFreed))
+			}
+		}
+		if msg.Complete != nil {
+			complete = msg.Complete
+		}
+		// Per-file failures arrive as an Error after Complete and are already
+		// listed in Complete.Errors; only an Error before it is a refusal.
+		if msg.Error != nil && complete == nil {
+			refused = msg.Error
+		}
+	}
+
+	if complete == nil {
+		if refused == nil {
+			refused = fmt.Errorf("cleaner finished without a result")
+		}
+		return nil, refused
+	}
+	return complete, nil
 }
 
 // detectAvailableCategories dynamically finds available cleaning targets
@@ -636,19 +996,26 @@ func clearSessionCache() error {
 // reports what it discarded. Returns an error only when the cache as a whole is
 // unusable (stale, unverifiable); individual bad entries are dropped.
 func revalidateSessionCache(cache *config.SessionCache, cfg *config.Config) (*config.SessionCache, error) {
+	verified, _, err := revalidateSessionCacheReport(cache, cfg)
+	return verified, err
+}
+
+// revalidateSessionCacheReport is revalidateSessionCache that also hands back
+// the gate's report, for callers that surface drop reasons themselves.
+func revalidateSessionCacheReport(cache *config.SessionCache, cfg *config.Config) (*config.SessionCache, *validation.Report, error) {
 	verified, report, err := validation.RevalidateCache(
-		cache, config.AuthoritativeCategories(cfg), validation.CacheOptions{})
+		cache, config.AuthoritativeCategories(cfg), validation.CacheOptions{IgnorePatterns: cfg.Scan.IgnorePatterns})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if report.TotalDropped() > 0 {
-		fmt.Printf("%s %d of %d scanned files no longer verify against config and will be skipped (%s)\n",
+		fmt.Fprintf(cliOut, "%s %d of %d scanned files no longer verify against config and will be skipped (%s)\n",
 			S.Warning("Note:"), report.TotalDropped(),
 			report.TotalDropped()+report.Accepted, report.Summary())
 	}
 
-	return verified, nil
+	return verified, report, nil
 }
 
 // filterCacheByMode filters cached files based on clean mode
@@ -697,6 +1064,7 @@ func filterCacheByMode(cache *config.SessionCache, cfg *config.Config, mode stri
 	}
 
 	return &config.SessionCache{
+		Version: cache.Version,
 		ScanResults: &config.Category{
 			Name:      cache.ScanResults.Name,
 			Files:     filteredFiles,
@@ -704,6 +1072,7 @@ func filterCacheByMode(cache *config.SessionCache, cfg *config.Config, mode stri
 			Size:      filteredSize,
 			Risk:      cache.ScanResults.Risk,
 		},
+		Categories: config.SummarizeCategories(filteredFiles, cache.Categories),
 		TotalSize:  filteredSize,
 		TotalFiles: len(filteredFiles),
 		ScannedAt:  cache.ScannedAt,
@@ -790,6 +1159,7 @@ func filterCacheByCategorySelection(cache *config.SessionCache, includes, exclud
 	}
 
 	return &config.SessionCache{
+		Version: cache.Version,
 		ScanResults: &config.Category{
 			Name:      cache.ScanResults.Name,
 			Files:     filteredFiles,
@@ -797,6 +1167,7 @@ func filterCacheByCategorySelection(cache *config.SessionCache, includes, exclud
 			Size:      filteredSize,
 			Risk:      cache.ScanResults.Risk,
 		},
+		Categories: config.SummarizeCategories(filteredFiles, cache.Categories),
 		TotalSize:  filteredSize,
 		TotalFiles: len(filteredFiles),
 		ScannedAt:  cache.ScannedAt,
@@ -851,25 +1222,164 @@ var backupListCmd = &cobra.Command{
 var backupRestoreCmd = &cobra.Command{
 	Use:   "restore [backup-name]",
 	Short: "Restore files from a backup",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: "Restores the files in a backup, or those selected by --path and --category, with\n" +
+		"the mode, owner and modification time they had when backed up. Files that\n" +
+		"already exist are skipped unless --conflict says otherwise.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		backupName := args[0]
 
 		backupDir, err := paths.DataDir("backups")
 		if err != nil {
-			fmt.Printf("❌ Failed to determine backup directory: %v\n", err)
-			return
+			return fmt.Errorf("failed to determine backup directory: %w", err)
 		}
 		backupPath := filepath.Join(backupDir, backupName)
 
+		opts := backup.RestoreOptions{}
+		opts.Paths, _ = cmd.Flags().GetStringSlice("path")
+		opts.Categories, _ = cmd.Flags().GetStringSlice("category")
+		opts.Conflict, _ = cmd.Flags().GetString("conflict")
+		if to, _ := cmd.Flags().GetString("to"); to != "" {
+			if opts.To, err = filepath.Abs(to); err != nil {
+				return err
+			}
+		}
+
 		fmt.Printf("🔄 Restoring backup: %s\n", backupName)
 
-		if err := cleaner.RestoreBackup(backupPath); err != nil {
-			fmt.Printf("❌ Failed to restore backup: %v\n", err)
-			return
+		result, err := cleaner.RestoreBackupFiles(backupPath, opts)
+		for _, f := range result.Restored {
+			if f.Target != f.Path {
+				fmt.Printf("   %s → %s\n", f.Path, f.Target)
+			}
+		}
+		for _, failure := range result.Failed {
+			fmt.Printf("❌ %s\n", failure)
+		}
+		if len(result.Restored) > 0 {
+			fmt.Printf("✅ Restored %d files\n", len(result.Restored))
+		}
+		if len(result.Skipped) > 0 {
+			fmt.Printf("⚠️  Skipped %d files that already exist; use --conflict overwrite or --conflict rename\n", len(result.Skipped))
+			for _, path := range result.Skipped {
+				fmt.Printf("   %s\n", path)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+		if len(result.Restored) == 0 && len(result.Skipped) == 0 {
+			fmt.Println("✅ Backup restored successfully!")
+		}
+		return nil
+	},
+}
+
+var backupShowCmd = &cobra.Command{
+	Use:   "show [backup-name]",
+	Short: "Show the files in a backup",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := backup.Open()
+		if err != nil {
+			return err
+		}
+		manifest, err := cleaner.LoadBackup(store.Path(args[0]))
+		if err != nil {
+			return err
 		}
 
-		fmt.Println("✅ Backup restored successfully!")
+		fmt.Println(S.Header("Backup " + manifest.Name))
+		fmt.Println(S.Separator())
+		fmt.Printf("  %s %s\n", S.Bold("Created:"), manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("  %s %s\n", S.Bold("Categories:"), strings.Join(manifest.Categories, ", "))
+		fmt.Printf("  %s %d (%s)\n", S.Bold("Files:"), manifest.FileCount, utils.HumanizeBytes(manifest.TotalSize))
+		if manifest.Compression == "" {
+			fmt.Printf("  %s legacy per-file copies\n", S.Bold("Format:"))
+		} else {
+			fmt.Printf("  %s %s added to the store\n", S.Bold("Stored:"), utils.HumanizeBytes(manifest.StoredSize))
+		}
+		fmt.Println()
+		for _, f := range manifest.Files {
+			mode := "-"
+			if f.Mode != 0 {
+				mode = f.Mode.String()
+			}
+			modTime := "-"
+			if !f.ModTime.IsZero() {
+				modTime = f.ModTime.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("  %s  %s  %10s  %s  %s\n", mode, modTime,
+				utils.HumanizeBytes(uint64(f.Size)), S.Muted("["+f.Category+"]"), f.Path)
+		}
+		return nil
+	},
+}
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old backups",
+	Long: "Removes backups beyond the [backup] limits in the config, or the limits given as\n" +
+		"flags, then deletes stored contents no remaining backup uses. The newest backup is\n" +
+		"always kept.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Mirror `clean`: preview by default, --force applies.
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if force, _ := cmd.Flags().GetBool("force"); force {
+			dryRun = false
+		}
+
+		cfg, err := config.Load("")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		limits := cfg.Backup
+		if cmd.Flags().Changed("keep-last") {
+			limits.KeepLast, _ = cmd.Flags().GetInt("keep-last")
+		}
+		if cmd.Flags().Changed("max-age") {
+			limits.MaxAge, _ = cmd.Flags().GetString("max-age")
+		}
+		if cmd.Flags().Changed("max-size") {
+			limits.MaxSize, _ = cmd.Flags().GetString("max-size")
+		}
+		policy, err := cleaner.BackupPolicy(limits)
+		if err != nil {
+			return err
+		}
+		if policy.IsZero() {
+			return fmt.Errorf("no limits set: pass --keep-last, --max-age or --max-size, or set them under [backup] in the config")
+		}
+
+		store, err := backup.Open()
+		if err != nil {
+			return err
+		}
+		result, err := store.Prune(policy, time.Now(), dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range result.Skipped {
+			fmt.Printf("⚠️  Skipped unreadable backup %s; no stored contents were collected\n", name)
+		}
+		if len(result.Removed) == 0 && result.BlobsRemoved == 0 {
+			fmt.Println("Nothing to prune")
+			return nil
+		}
+
+		verb := "Removed"
+		if dryRun {
+			verb = "DRY RUN - Would remove"
+		}
+		fmt.Printf("%s %d backups, freeing %s:\n", verb, len(result.Removed), utils.HumanizeBytes(result.BytesFreed))
+		for _, name := range result.Removed {
+			fmt.Printf("   %s\n", name)
+		}
+		if dryRun {
+			fmt.Println("\n💡 Use --force to actually prune backups")
+		}
+		return nil
 	},
 }
 
@@ -885,18 +1395,27 @@ var journalCmd = &cobra.Command{
 var journalVacuumCmd = &cobra.Command{
 	Use:   "vacuum",
 	Short: "Reclaim systemd journal space",
-	Long:  "Runs journalctl --vacuum-size / --vacuum-time to shrink the journal safely",
+	Long: "Runs journalctl --vacuum-size / --vacuum-time / --vacuum-files to shrink the journal safely.\n" +
+		"Only archived journal files are removed; the active ones stay.",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		size, _ := cmd.Flags().GetString("size")
 		age, _ := cmd.Flags().GetString("time")
+		files, _ := cmd.Flags().GetInt("files")
 		// Mirror `clean`: preview by default, --force applies.
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		if force, _ := cmd.Flags().GetBool("force"); force {
 			dryRun = false
 		}
 
-		if size == "" && age == "" {
-			return fmt.Errorf("specify --size (e.g. 500M) or --time (e.g. 14d)")
+		if size == "" && age == "" && files == 0 {
+			return fmt.Errorf("specify --size (e.g. 500M), --time (e.g. 14d) or --files (e.g. 10)")
+		}
+		if files < 0 {
+			return fmt.Errorf("--files must be positive, got %d", files)
+		}
+		vacuumArgs, err := journalVacuumArgs(size, age, files)
+		if err != nil {
+			return fmt.Errorf("--size: %w", err)
 		}
 
 		if _, err := exec.LookPath("journalctl"); err != nil {
@@ -911,17 +1430,11 @@ var journalVacuumCmd = &cobra.Command{
 		fmt.Println(S.Header("Systemd Journal"))
 		fmt.Println(S.Separator())
 
-		usage := exec.Command("journalctl", "--disk-usage")
-		usage.Stdout = os.Stdout
-		usage.Stderr = os.Stderr
-		usage.Run()
-
-		var vacuumArgs []string
-		if size != "" {
-			vacuumArgs = append(vacuumArgs, "--vacuum-size="+size)
-		}
-		if age != "" {
-			vacuumArgs = append(vacuumArgs, "--vacuum-time="+age)
+		usage, usageErr := journalDiskUsage()
+		if usageErr != nil {
+			fmt.Println(S.Warning(fmt.Sprintf("⚠️  %v", usageErr)))
+		} else {
+			fmt.Printf("Journal size: %s\n", utils.HumanizeBytes(usage))
 		}
 
 		if dryRun {
@@ -932,7 +1445,17 @@ var journalVacuumCmd = &cobra.Command{
 			if age != "" {
 				invocation = append(invocation, "--time="+age)
 			}
+			if files > 0 {
+				invocation = append(invocation, fmt.Sprintf("--files=%d", files))
+			}
 			fmt.Printf("\nDRY RUN - would run: journalctl %s\n", strings.Join(vacuumArgs, " "))
+			if usageErr == nil {
+				if savings, ok := projectedJournalSavings(usage, size); ok {
+					fmt.Printf("Would free up to %s (archived files only)\n", utils.HumanizeBytes(savings))
+				} else {
+					fmt.Println("Savings depend on how old and how many the archived files are")
+				}
+			}
 			fmt.Println("\n💡 Use --force to actually vacuum the journal:")
 			fmt.Printf("   moonbit journal vacuum %s --force\n", strings.Join(invocation, " "))
 			return nil
@@ -943,141 +1466,17 @@ var journalVacuumCmd = &cobra.Command{
 		}
 
 		fmt.Printf("\n🗑️  Running: journalctl %s\n", strings.Join(vacuumArgs, " "))
-		vacuum := exec.Command("journalctl", vacuumArgs...)
-		vacuum.Stdout = os.Stdout
-		vacuum.Stderr = os.Stderr
-		err := vacuum.Run()
-
-		if auditLog != nil {
-			result := "success"
-			if err != nil {
-				result = "failed"
-			}
-			auditLog.LogSystemdOperation("journal_vacuum", strings.Join(vacuumArgs, " "), result, err)
-		}
+		freed, err := runJournalVacuum(vacuumArgs, os.Stdout, auditLog)
 		if err != nil {
-			return fmt.Errorf("journal vacuum failed: %w", err)
+			return err
 		}
 
 		fmt.Println()
-		usageAfter := exec.Command("journalctl", "--disk-usage")
-		usageAfter.Stdout = os.Stdout
-		usageAfter.Stderr = os.Stderr
-		usageAfter.Run()
-
-		fmt.Println(S.Success("✅ Journal vacuumed"))
-		return nil
-	},
-}
-
-var dockerCmd = &cobra.Command{
-	Use:   "docker",
-	Short: "Clean Docker resources",
-	Long:  "Clean unused Docker images, containers, volumes, and build cache using Docker CLI",
-}
-
-var dockerImagesCmd = &cobra.Command{
-	Use:   "images",
-	Short: "Remove unused Docker images",
-	Run: func(cmd *cobra.Command, args []string) {
-		auditLog, _ := audit.NewLogger()
-		if auditLog != nil {
-			defer auditLog.Close()
-		}
-
-		fmt.Println("🐳 Cleaning unused Docker images...")
-
-		checkCmd := exec.Command("docker", "version")
-		if err := checkCmd.Run(); err != nil {
-			fmt.Println("❌ Docker is not installed or not running")
-			if auditLog != nil {
-				auditLog.LogDockerOperation("prune_images", []string{}, "failed", err)
-			}
-			return
-		}
-
-		dfCmd := exec.Command("docker", "system", "df")
-		dfCmd.Stdout = os.Stdout
-		dfCmd.Stderr = os.Stderr
-		dfCmd.Run()
-
-		fmt.Println("\n🗑️  Running: docker image prune -a")
-
-		pruneCmd := exec.Command("docker", "image", "prune", "-a", "-f")
-		pruneCmd.Stdout = os.Stdout
-		pruneCmd.Stderr = os.Stderr
-
-		err := pruneCmd.Run()
-		if auditLog != nil {
-			result := "success"
-			if err != nil {
-				result = "failed"
-			}
-			auditLog.LogDockerOperation("prune_images", []string{"-a", "-f"}, result, err)
-		}
-
-		if err != nil {
-			fmt.Printf("❌ Failed to prune images: %v\n", err)
-			return
-		}
-
-		fmt.Println("✅ Docker images cleaned successfully!")
-	},
-}
-
-var dockerAllCmd = &cobra.Command{
-	Use:   "all",
-	Short: "Remove all unused Docker resources",
-	Run: func(cmd *cobra.Command, args []string) {
-		auditLog, _ := audit.NewLogger()
-		if auditLog != nil {
-			defer auditLog.Close()
-		}
-
-		fmt.Println("🐳 Cleaning all unused Docker resources...")
-
-		checkCmd := exec.Command("docker", "version")
-		if err := checkCmd.Run(); err != nil {
-			fmt.Println("❌ Docker is not installed or not running")
-			if auditLog != nil {
-				auditLog.LogDockerOperation("prune_all", []string{}, "failed", err)
-			}
-			return
-		}
-
-		fmt.Println("\n📊 Current Docker disk usage:")
-		dfCmd := exec.Command("docker", "system", "df")
-		dfCmd.Stdout = os.Stdout
-		dfCmd.Stderr = os.Stderr
-		dfCmd.Run()
-
-		fmt.Println("\n🗑️  Running: docker system prune -a --volumes")
-
-		pruneCmd := exec.Command("docker", "system", "prune", "-a", "--volumes", "-f")
-		pruneCmd.Stdout = os.Stdout
-		pruneCmd.Stderr = os.Stderr
-
-		err := pruneCmd.Run()
-		if auditLog != nil {
-			result := "success"
-			if err != nil {
-				result = "failed"
-			}
-			auditLog.LogDockerOperation("prune_all", []string{"-a", "--volumes", "-f"}, result, err)
+		if after, err := journalDiskUsage(); err == nil {
+			fmt.Printf("Journal size: %s\n", utils.HumanizeBytes(after))
 		}
-
-		if err != nil {
-			fmt.Printf("❌ Failed to prune Docker resources: %v\n", err)
-			return
-		}
-
-		fmt.Println("\n📊 Updated Docker disk usage:")
-		dfCmd2 := exec.Command("docker", "system", "df")
-		dfCmd2.Stdout = os.Stdout
-		dfCmd2.Stderr = os.Stderr
-		dfCmd2.Run()
-
-		fmt.Println("\nDocker cleanup complete!")
+		fmt.Println(S.Success(fmt.Sprintf("✅ Journal vacuumed, freed %s", utils.HumanizeBytes(freed))))
+		return nil
 	},
 }
 
@@ -1103,6 +1502,13 @@ var duplicatesFindCmd = &cobra.Command{
 		}
 
 		minSize, _ := cmd.Flags().GetInt64("min-size")
+		verify, _ := cmd.Flags().GetBool("verify")
+		reportPath, _ := cmd.Flags().GetString("report")
+		keep, err := keepPolicyFromFlags(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 
 		fmt.Println("🔍 Scanning for duplicate files...")
 		fmt.Printf("📁 Paths: %v\n", paths)
@@ -1111,7 +1517,13 @@ var duplicatesFindCmd = &cobra.Command{
 		opts := duplicates.ScanOptions{
 			Paths:   paths,
 			MinSize: minSize,
+			Verify:  verify,
+			Keep:    keep,
 		}
+		applyScanScope(cmd, &opts)
+		saveCache := useHashCache(cmd, &opts)
+		ctx, stop := scanContext(cmd)
+		defer stop()
 
 		scanner := duplicates.NewScanner(opts)
 		progressCh := make(chan duplicates.ScanProgress, 10)
@@ -1128,21 +1540,45 @@ var duplicatesFindCmd = &cobra.Command{
 			}
 		}()
 
-		result, err := scanner.Scan(progressCh)
+		result, err := scanner.Scan(ctx, progressCh)
 		if err != nil {
 			fmt.Printf("\n❌ Error: %v\n", err)
-			return
+			os.Exit(1)
 		}
+		saveCache()
 
 		fmt.Printf("\n\n📊 Scan Results\n")
 		fmt.Println("================")
 		fmt.Printf("Files scanned: %d\n", result.FilesScanned)
+		fmt.Printf("Same size: %d, partially hashed: %d, fully hashed: %d",
+			result.Stages.SizeMatched, result.Stages.PartialHashed, result.Stages.FullHashed)
+		if verify {
+			fmt.Printf(", verified: %d", result.Stages.Verified)
+		}
+		if result.Stages.CacheHits > 0 {
+			fmt.Printf(" (%d hashes from cache)", result.Stages.CacheHits)
+		}
+		fmt.Println()
 		fmt.Printf("Duplicate groups: %d\n", len(result.Groups))
 		fmt.Printf("Duplicate files: %d\n", result.TotalDupes)
-		fmt.Printf("Wasted space: %s\n\n", utils.HumanizeBytes(uint64(result.WastedSpace)))
+		fmt.Printf("Wasted space: %s\n", utils.HumanizeBytes(uint64(result.WastedSpace)))
+		printHardlinked(result)
+		fmt.Println()
+
+		// The report is written even when it is empty, so scripts always
+		// find one.
+		if reportPath != "" {
+			if err := duplicates.WriteReport(reportPath, duplicates.NewReport(result, paths)); err != nil {
+				fmt.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		if len(result.Groups) == 0 {
 			fmt.Println("No duplicate files found.")
+			if reportPath != "" {
+				fmt.Printf("📝 Empty report written to %s\n", reportPath)
+			}
 			return
 		}
 
@@ -1162,15 +1598,22 @@ var duplicatesFindCmd = &cobra.Command{
 
 			for j, file := range group.Files {
 				var marker string
-				if j == 0 {
-					marker = "✓ " // Keep oldest
+				if j < group.KeepCount {
+					marker = "✓ " // Keep
 				} else {
 					marker = "✗ " // Duplicate
 				}
 				fmt.Printf("  %s %s\n", marker, file.Path)
+				for _, link := range file.Links {
+					fmt.Printf("     %s %s\n", S.Muted("= hardlink"), link)
+				}
 			}
 		}
 
+		if reportPath != "" {
+			fmt.Printf("\n📝 Report written to %s; edit the actions, then run 'moonbit duplicates apply %s'\n", reportPath, reportPath)
+		}
+
 		fmt.Printf("\n💡 Use 'moonbit duplicates clean' to interactively remove duplicates\n")
 	},
 }
@@ -1191,6 +1634,12 @@ var duplicatesCleanCmd = &cobra.Command{
 		}
 
 		minSize, _ := cmd.Flags().GetInt64("min-size")
+		verify, _ := cmd.Flags().GetBool("verify")
+		keep, err := keepPolicyFromFlags(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 
 		fmt.Println(S.Header("🔍 Scanning for duplicate files..."))
@@ -1205,7 +1654,13 @@ var duplicatesCleanCmd = &cobra.Command{
 		opts := duplicates.ScanOptions{
 			Paths:   paths,
 			MinSize: minSize,
+			Verify:  verify,
+			Keep:    keep,
 		}
+		applyScanScope(cmd, &opts)
+		saveCache := useHashCache(cmd, &opts)
+		ctx, stop := scanContext(cmd)
+		defer stop()
 
 		scanner := duplicates.NewScanner(opts)
 		progressCh := make(chan duplicates.ScanProgress, 10)
@@ -1222,18 +1677,21 @@ var duplicatesCleanCmd = &cobra.Command{
 			}
 		}()
 
-		result, err := scanner.Scan(progressCh)
+		result, err := scanner.Scan(ctx, progressCh)
 		if err != nil {
 			fmt.Printf("\n%s Error: %v\n", S.Error("❌"), err)
 			os.Exit(1)
 		}
+		saveCache()
 
 		fmt.Printf("\n\n%s\n", S.Header("📊 Scan Results"))
 		fmt.Println(S.Separator())
 		fmt.Printf("Files scanned: %d\n", result.FilesScanned)
 		fmt.Printf("Duplicate groups: %d\n", len(result.Groups))
 		fmt.Printf("Duplicate files: %d\n", result.TotalDupes)
-		fmt.Printf("Wasted space: %s\n\n", utils.HumanizeBytes(uint64(result.WastedSpace)))
+		fmt.Printf("Wasted space: %s\n", utils.HumanizeBytes(uint64(result.WastedSpace)))
+		printHardlinked(result)
+		fmt.Println()
 
 		if len(result.Groups) == 0 {
 			fmt.Println(S.Success("✅ No duplicate files found."))
@@ -1241,7 +1699,7 @@ var duplicatesCleanCmd = &cobra.Command{
 		}
 
 		// Interactive selection
-		fmt.Println(S.Info("💡 For each duplicate group, the oldest file will be kept."))
+		fmt.Println(S.Info("💡 For each duplicate group, " + describeKeepPolicy(keep) + " will be kept."))
 		fmt.Println(S.Info("   All other duplicates in the group will be removed.\n"))
 
 		var filesToRemove []string
@@ -1256,22 +1714,24 @@ var duplicatesCleanCmd = &cobra.Command{
 				utils.HumanizeBytes(uint64(group.Size)),
 				utils.HumanizeBytes(uint64(group.TotalSize)))
 
-			// Show files (oldest first, keep first one)
+			// Show files (kept copies first)
 			groupFilesToRemove := []string{}
 			groupSpaceToFree := int64(0)
-			for j, file := range group.Files {
-				if j == 0 {
-					fmt.Printf("  %s %s %s\n", S.Success("✓ KEEP"), S.Muted("(oldest)"), file.Path)
-				} else {
-					fmt.Printf("  %s %s\n", S.Error("✗ REMOVE"), file.Path)
-					groupFilesToRemove = append(groupFilesToRemove, file.Path)
-					groupSpaceToFree += file.Size
+			for _, file := range group.Kept() {
+				fmt.Printf("  %s %s\n", S.Success("✓ KEEP"), file.Path)
+			}
+			for _, file := range group.Removable() {
+				// Every name of a hardlinked copy goes, or nothing is freed.
+				for _, path := range file.Paths() {
+					fmt.Printf("  %s %s\n", S.Error("✗ REMOVE"), path)
+					groupFilesToRemove = append(groupFilesToRemove, path)
 				}
+				groupSpaceToFree += file.Reclaimable()
 			}
 
 			// Ask for confirmation for each group
 			if !dryRun {
-				fmt.Printf("\n%s Remove %d duplicate(s) from this group? [y/N]: ", S.Warning("⚠️"), len(group.Files)-1)
+				fmt.Printf("\n%s Remove %d duplicate(s) from this group? [y/N]: ", S.Warning("⚠️"), len(groupFilesToRemove))
 				var response string
 				fmt.Scanln(&response)
 
@@ -1317,8 +1777,9 @@ var duplicatesCleanCmd = &cobra.Command{
 
 		// Validate paths before deletion
 		var validatedPaths []string
+		protected := protectedPaths()
 		for _, path := range filesToRemove {
-			if err := validation.ValidateFilePath(path); err != nil {
+			if err := validation.ValidateFilePath(path, protected...); err != nil {
 				fmt.Printf("%s Skipping invalid path: %s (%v)\n", S.Warning("⚠️"), path, err)
 				continue
 			}
@@ -1580,23 +2041,31 @@ func init() {
 	rootCmd.AddCommand(scanCmd)
 	rootCmd.AddCommand(cleanCmd)
 	rootCmd.AddCommand(backupCmd)
-	rootCmd.AddCommand(dockerCmd)
 	rootCmd.AddCommand(journalCmd)
 	rootCmd.AddCommand(duplicatesCmd)
 	rootCmd.AddCommand(pkgCmd)
 
 	backupCmd.AddCommand(backupListCmd)
 	backupCmd.AddCommand(backupRestoreCmd)
+	backupRestoreCmd.Flags().StringSlice("path", nil, "Restore only files matching this glob, or beneath this directory (repeatable)")
+	backupRestoreCmd.Flags().StringSlice("category", nil, "Restore only files from this category (repeatable)")
+	backupRestoreCmd.Flags().String("to", "", "Restore beneath this directory instead of the original locations")
+	backupRestoreCmd.Flags().String("conflict", backup.ConflictSkip, "When a file exists: skip, overwrite or rename (restore as <name>.restored)")
+	backupCmd.AddCommand(backupShowCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	backupPruneCmd.Flags().Int("keep-last", 0, "Keep the newest N backups (overrides backup.keep_last)")
+	backupPruneCmd.Flags().String("max-age", "", "Remove backups older than this, e.g. 30d (overrides backup.max_age)")
+	backupPruneCmd.Flags().String("max-size", "", "Cap the backup store at this size, e.g. 5GiB (overrides backup.max_size)")
+	backupPruneCmd.Flags().Bool("dry-run", true, "Preview only (default); pass --force to apply")
+	backupPruneCmd.Flags().Bool("force", false, "Actually remove backups")
 
 	journalCmd.AddCommand(journalVacuumCmd)
 	journalVacuumCmd.Flags().String("size", "", "Shrink journal to this size (e.g. 500M, 1G)")
 	journalVacuumCmd.Flags().String("time", "", "Drop journal entries older than this (e.g. 14d, 1month)")
+	journalVacuumCmd.Flags().Int("files", 0, "Keep at most this many archived journal files")
 	journalVacuumCmd.Flags().Bool("dry-run", true, "Preview only (default); pass --force to apply")
 	journalVacuumCmd.Flags().Bool("force", false, "Actually vacuum the journal")
 
-	dockerCmd.AddCommand(dockerImagesCmd)
-	dockerCmd.AddCommand(dockerAllCmd)
-
 	duplicatesCmd.AddCommand(duplicatesFindCmd)
 	duplicatesCmd.AddCommand(duplicatesCleanCmd)
 
@@ -1605,6 +2074,8 @@ func init() {
 
 	duplicatesFindCmd.Flags().Int64("min-size", int64(duplicates.DefaultMinSize), "Minimum file size to consider (bytes)")
 	duplicatesCleanCmd.Flags().Int64("min-size", int64(duplicates.DefaultMinSize), "Minimum file size to consider (bytes)")
+	duplicatesFindCmd.Flags().Bool("verify", false, "Compare matching files byte by byte after hashing")
+	duplicatesCleanCmd.Flags().Bool("verify", false, "Compare matching files byte by byte after hashing")
 	duplicatesCleanCmd.Flags().Bool("dry-run", false, "Preview only, don't delete files")
 
 	pkgOrphansCmd.Flags().Bool("dry-run", true, "Preview orphaned packages without removing")
@@ -1631,6 +2102,7 @@ func init() {
 	// Scan mode flags
 	scanCmd.Flags().StringVarP(&scanMode, "mode", "m", "", "Scan mode: 'quick' (safe caches only) or 'deep' (all categories)")
 	scanCmd.Flags().BoolVar(&scanNoPrompt, "no-prompt", false, "Do not prompt to clean after scanning")
+	scanCmd.Flags().BoolVar(&scanFull, "full", false, "Re-read every directory instead of reusing unchanged ones from the directory index")
 	scanCmd.Flags().BoolVar(&listCategories, "list-categories", false, "List categories selected by the current filters and exit")
 	scanCmd.Flags().StringSliceVar(&includeCategories, "include-category", nil, "Only include categories by name (repeat or comma-separate)")
 	scanCmd.Flags().StringSliceVar(&excludeCategories, "exclude-category", nil, "Exclude categories by name (repeat or comma-separate)")
@@ -1640,6 +2112,9 @@ func init() {
 	cleanCmd.Flags().StringVarP(&scanMode, "mode", "m", "", "Clean mode: 'quick' (safe caches only) or 'deep' (all categories)")
 	cleanCmd.Flags().StringSliceVar(&includeCategories, "include-category", nil, "Only clean categories by name (repeat or comma-separate)")
 	cleanCmd.Flags().StringSliceVar(&excludeCategories, "exclude-category", nil, "Exclude categories by name (repeat or comma-separate)")
+
+	scanCmd.Flags().StringVarP(&outputFormat, "output", "o", OutputText, "Output format: 'text' or 'json' (JSON goes to stdout, progress to stderr)")
+	cleanCmd.Flags().StringVarP(&outputFormat, "output", "o", OutputText, "Output format: 'text' or 'json' (JSON goes to stdout, progress to stderr)")
 }
 
 // SetVersion wires build metadata injected via -ldflags into the root command,
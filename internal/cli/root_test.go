@@ -17,6 +17,13 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain keeps these tests on the per-user scan cache even when they run
+// as root; the root-owned cache is covered in package session.
+func TestMain(m *testing.M) {
+	session.RootOwned = func() bool { return false }
+	os.Exit(m.Run())
+}
+
 func TestCleanCommandFlags(t *testing.T) {
 	dryRunFlag := cleanCmd.Flags().Lookup("dry-run")
 	if assert.NotNil(t, dryRunFlag, "clean command should expose --dry-run") {
@@ -127,6 +134,85 @@ func TestCleanSessionPreservesCacheOnPartialFailure(t *testing.T) {
 	assert.True(t, sessionMgr.Exists(), "failed clean should keep cache for retry")
 }
 
+func TestCleanSessionRefusesUntrustedRootCache(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("the root-owned cache is only used as root")
+	}
+	originalScanMode := scanMode
+	defer func() { scanMode = originalScanMode }()
+	scanMode = ""
+
+	stateDir := filepath.Join(t.TempDir(), "state")
+	originalDir, originalLockDir, originalRootOwned := session.StateDir, session.LockDir, session.RootOwned
+	session.StateDir, session.LockDir, session.RootOwned = stateDir, t.TempDir(), func() bool { return true }
+	defer func() {
+		session.StateDir, session.LockDir, session.RootOwned = originalDir, originalLockDir, originalRootOwned
+	}()
+	t.Setenv("MOONBIT_HOME", t.TempDir())
+
+	// A cache written straight into the state directory carries no MAC.
+	require.NoError(t, os.MkdirAll(stateDir, 0755))
+	data, err := json.Marshal(&config.SessionCache{
+		ScanResults: &config.Category{Name: "Test", Files: []config.FileInfo{{Path: "/etc/passwd", Size: 1}}},
+		TotalSize:   1,
+		TotalFiles:  1,
+		ScannedAt:   time.Now(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(stateDir, "scan_results.json"), data, 0600))
+
+	err = CleanSession(false)
+	require.ErrorIs(t, err, session.ErrUntrusted)
+	assert.Contains(t, err.Error(), "refusing to clean")
+	assert.Contains(t, err.Error(), "sudo moonbit scan")
+	_, statErr := os.Stat("/etc/passwd")
+	assert.NoError(t, statErr)
+}
+
+func TestCleanSessionFailsWhileLocked(t *testing.T) {
+	originalScanMode, originalWait := scanMode, waitForLock
+	defer func() { scanMode, waitForLock = originalScanMode, originalWait }()
+	scanMode, waitForLock = "", false
+
+	t.Setenv("HOME", t.TempDir())
+	sessionMgr, err := session.NewManager()
+	require.NoError(t, err)
+	require.NoError(t, sessionMgr.Save(&config.SessionCache{
+		ScanResults: &config.Category{Name: "Test"},
+		ScannedAt:   time.Now(),
+	}))
+
+	held, err := sessionMgr.TryLock("scan")
+	require.NoError(t, err)
+	defer held.Unlock()
+
+	err = CleanSession(true)
+	var locked *session.LockedError
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, os.Getpid(), locked.PID)
+	assert.Contains(t, err.Error(), "locked by PID")
+	assert.Contains(t, err.Error(), "--wait")
+}
+
+func TestApplyLockFlags(t *testing.T) {
+	originalWait := waitForLock
+	defer func() { waitForLock = originalWait }()
+
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("wait", false, "")
+		cmd.Flags().Bool("no-wait", false, "")
+		require.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	require.NoError(t, applyLockFlags(newCmd("--wait")))
+	assert.True(t, waitForLock)
+	require.NoError(t, applyLockFlags(newCmd("--no-wait")))
+	assert.False(t, waitForLock)
+	assert.Error(t, applyLockFlags(newCmd("--wait", "--no-wait")))
+}
+
 func TestFilterCacheByModeUsesFileCategoryProvenance(t *testing.T) {
 	cache := &config.SessionCache{
 		ScanResults: &config.Category{
@@ -345,3 +431,42 @@ func TestIsRunningAsRoot(t *testing.T) {
 	// We just verify it returns a boolean without panicking
 	assert.IsType(t, true, result)
 }
+
+func TestScanAndCleanExposeOutputFlag(t *testing.T) {
+	for _, cmd := range []*cobra.Command{scanCmd, cleanCmd} {
+		flag := cmd.Flags().Lookup("output")
+		if assert.NotNil(t, flag, "%s should expose --output", cmd.Name()) {
+			assert.Equal(t, OutputText, flag.DefValue)
+		}
+	}
+}
+
+func TestApplyOutputFlagRoutesHumanOutputToStderr(t *testing.T) {
+	prevFormat, prevOut := outputFormat, cliOut
+	defer func() { outputFormat, cliOut = prevFormat, prevOut }()
+
+	outputFormat = OutputJSON
+	require.NoError(t, applyOutputFlag())
+	assert.Equal(t, os.Stderr, cliOut, "stdout must be left for the JSON document")
+
+	outputFormat = OutputText
+	require.NoError(t, applyOutputFlag())
+	assert.Equal(t, os.Stdout, cliOut)
+
+	outputFormat = "yaml"
+	assert.Error(t, applyOutputFlag())
+}
+
+func TestCategoryCleanReportsFollowCacheSummaries(t *testing.T) {
+	files := []config.FileInfo{
+		{Path: "/tmp/a", Size: 10, CategoryName: "B Cache"},
+		{Path: "/tmp/b", Size: 20, CategoryName: "A Cache"},
+		{Path: "/tmp/c", Size: 30, CategoryName: "B Cache"},
+	}
+
+	got := categoryCleanReports(config.SummarizeCategories(files, nil))
+
+	require.Len(t, got, 2)
+	assert.Equal(t, CategoryCleanReport{Name: "B Cache", Files: 2, Bytes: 40}, got[0])
+	assert.Equal(t, CategoryCleanReport{Name: "A Cache", Files: 1, Bytes: 20}, got[1])
+}
@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/audit"
+	"github.com/Nomadcxx/moonbit/internal/trash"
+	"github.com/Nomadcxx/moonbit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage files moved to the trash by cleaning",
+	Long: "Categories with action = \"trash\" move files aside instead of deleting them.\n" +
+		"Files from your home go to the desktop trash (~/.local/share/Trash); others go to\n" +
+		"moonbit's holding area. Space is only reclaimed once the trash is purged.\n" +
+		"As root, only the holding area is managed; run without sudo for your home trash.\n\n" +
+		"Only entries moonbit created are listed, restored or purged.",
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List files moonbit moved to the trash",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bin, err := trash.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open trash: %w", err)
+		}
+		entries, err := bin.List()
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("Trash is empty")
+			return nil
+		}
+
+		fmt.Println(S.Header("Trash"))
+		fmt.Println(S.Separator())
+		var total int64
+		for _, e := range entries {
+			total += e.Size
+			fmt.Printf("%s  %-10s  %s\n", e.DeletedAt.Format("2006-01-02 15:04"),
+				utils.HumanizeBytes(uint64(e.Size)), e.OriginalPath)
+			fmt.Printf("    %s %s  %s %s\n", S.Muted("name:"), e.Name, S.Muted("category:"), e.Category)
+		}
+		fmt.Printf("\n%d files, %s\n", len(entries), utils.HumanizeBytes(uint64(total)))
+		return nil
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <name-or-original-path>...",
+	Short: "Move trashed files back to where they were",
+	Long: "Restores each entry, named as shown by 'moonbit trash list' or by its original\n" +
+		"path. A file that now exists at the original path is never overwritten.",
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bin, err := trash.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open trash: %w", err)
+		}
+		auditLog, _ := audit.NewLogger()
+		if auditLog != nil {
+			defer auditLog.Close()
+		}
+
+		var failed []string
+		for _, ref := range args {
+			entry, err := findTrashEntry(bin, ref)
+			if err == nil {
+				err = bin.Restore(entry)
+			}
+			if auditLog != nil {
+				result := "success"
+				if err != nil {
+					result = "failed"
+				}
+				auditLog.Log(audit.LogEntry{Operation: "trash_restore", Args: []string{ref}, Result: result, Error: err})
+			}
+			if err != nil {
+				fmt.Printf("❌ %s: %v\n", ref, err)
+				failed = append(failed, ref)
+				continue
+			}
+			fmt.Printf("✅ Restored %s\n", entry.OriginalPath)
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to restore %d of %d entries", len(failed), len(args))
+		}
+		return nil
+	},
+}
+
+// findTrashEntry resolves a restore argument to exactly one entry.
+func findTrashEntry(bin *trash.Bin, ref string) (trash.Entry, error) {
+	matches, err := bin.Find(ref)
+	if err != nil {
+		return trash.Entry{}, err
+	}
+	switch len(matches) {
+	case 0:
+		return trash.Entry{}, fmt.Errorf("no trash entry named %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, m := range matches {
+			names[i] = m.Name
+		}
+		return trash.Entry{}, fmt.Errorf("%q matches several entries (%s); restore one by name", ref, strings.Join(names, ", "))
+	}
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently delete trashed files",
+	Long:  "Deletes trash entries older than --older-than (or every entry with --all), reclaiming their space",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		all, _ := cmd.Flags().GetBool("all")
+		// Mirror `clean`: preview by default, --force applies.
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if force, _ := cmd.Flags().GetBool("force"); force {
+			dryRun = false
+		}
+
+		if olderThan == "" && !all {
+			return fmt.Errorf("specify --older-than (e.g. 30d) or --all")
+		}
+		var age time.Duration
+		if olderThan != "" {
+			var err error
+			if age, err = utils.ParseAge(olderThan); err != nil {
+				return err
+			}
+		}
+
+		bin, err := trash.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open trash: %w", err)
+		}
+		entries, err := bin.OlderThan(age, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("Nothing to purge")
+			return nil
+		}
+
+		var total int64
+		for _, e := range entries {
+			total += e.Size
+		}
+
+		if dryRun {
+			fmt.Printf("DRY RUN - Would permanently delete %d trashed files (%s):\n",
+				len(entries), utils.HumanizeBytes(uint64(total)))
+			for i, e := range entries {
+				if i >= 10 {
+					fmt.Printf("   ... and %d more files\n", len(entries)-10)
+					break
+				}
+				fmt.Printf("   %s (%s)\n", e.OriginalPath, utils.HumanizeBytes(uint64(e.Size)))
+			}
+			fmt.Println("\n💡 Use --force to actually purge them:")
+			if all {
+				fmt.Println("   moonbit trash purge --all --force")
+			} else {
+				fmt.Printf("   moonbit trash purge --older-than %s --force\n", olderThan)
+			}
+			return nil
+		}
+
+		auditLog, _ := audit.NewLogger()
+		if auditLog != nil {
+			defer auditLog.Close()
+		}
+
+		var freed int64
+		var failed []string
+		for _, e := range entries {
+			if err := bin.Purge(e); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", e.Name, err))
+				continue
+			}
+			freed += e.Size
+		}
+
+		if auditLog != nil {
+			result := fmt.Sprintf("purged=%d bytes=%d", len(entries)-len(failed), freed)
+			var purgeErr error
+			if len(failed) > 0 {
+				purgeErr = fmt.Errorf("%s", strings.Join(failed, "; "))
+			}
+			auditLog.Log(audit.LogEntry{Operation: "trash_purge", Args: []string{olderThan}, Result: result, Error: purgeErr})
+		}
+
+		fmt.Printf("🗑️  Purged %d files, freed %s\n", len(entries)-len(failed), utils.HumanizeBytes(uint64(freed)))
+		if len(failed) > 0 {
+			for _, f := range failed {
+				fmt.Printf("   - %s\n", f)
+			}
+			return fmt.Errorf("purge incomplete: %d file(s) could not be deleted", len(failed))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+	trashCmd.AddCommand(trashListCmd)
+	trashCmd.AddCommand(trashRestoreCmd)
+	trashCmd.AddCommand(trashPurgeCmd)
+
+	trashPurgeCmd.Flags().String("older-than", "", "Purge entries trashed longer ago than this (e.g. 30d, 2w, 36h)")
+	trashPurgeCmd.Flags().Bool("all", false, "Purge every moonbit entry in the trash")
+	trashPurgeCmd.Flags().Bool("dry-run", true, "Preview only (default); pass --force to apply")
+	trashPurgeCmd.Flags().Bool("force", false, "Actually purge the entries")
+}
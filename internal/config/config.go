@@ -9,6 +9,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/Nomadcxx/moonbit/internal/paths"
+	"github.com/Nomadcxx/moonbit/internal/utils"
 )
 
 // RiskLevel represents the risk level of a cleaning category
@@ -81,8 +82,22 @@ const (
 	ActionDelete CleanAction = ""
 	// ActionTruncate truncates the file to zero length, leaving it in place.
 	ActionTruncate CleanAction = "truncate"
+	// ActionTrash moves the file to a trash it can be restored from with
+	// `moonbit trash restore`. No space is reclaimed until the trash is purged.
+	ActionTrash CleanAction = "trash"
 )
 
+// Validate reports an action moonbit does not know. An unknown action must not
+// fall back to deleting files the config meant to keep recoverable.
+func (a CleanAction) Validate() error {
+	switch a {
+	case ActionDelete, ActionTruncate, ActionTrash:
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q (want %q, %q or empty for delete)", string(a), ActionTruncate, ActionTrash)
+	}
+}
+
 // FileInfo represents information about a file
 type FileInfo struct {
 	Path             string    `json:"path"`
@@ -110,10 +125,13 @@ type Category struct {
 	Files           []FileInfo `toml:"files,omitempty" json:"files,omitempty"`
 	Selected        bool       `toml:"selected,omitempty" json:"selected,omitempty"`
 	ShredEnabled    bool       `toml:"shred,omitempty" json:"shred,omitempty"`
-	// Action selects delete (default) or truncate. Truncate is required for files
-	// a running daemon holds open -- see CleanAction.
+	// Action selects delete (default), truncate or trash. Truncate is required
+	// for files a running daemon holds open -- see CleanAction.
 	Action     CleanAction `toml:"action,omitempty" json:"action,omitempty"`
 	MinAgeDays int         `toml:"min_age_days,omitempty" json:"min_age_days,omitempty"` // Only clean files older than this many days
+	// Retention keeps some matching files regardless, such as the newest few
+	// package versions or the newest files up to a size cap.
+	Retention *RetentionPolicy `toml:"retention,omitempty" json:"retention,omitempty"`
 }
 
 // Config represents the main configuration
@@ -129,15 +147,205 @@ type Config struct {
 		DryRunDefault  bool     `toml:"dry_run_default"`
 		WorkerCount    int      `toml:"worker_count"` // Number of parallel workers (0 = auto-detect)
 	} `toml:"scan"`
-	Categories []Category `toml:"categories"`
+	Backup     BackupConfig  `toml:"backup"`
+	Audit      AuditConfig   `toml:"audit"`
+	Journal    JournalConfig `toml:"journal"`
+	Safety     SafetyConfig  `toml:"safety"`
+	Categories []Category    `toml:"categories"`
+}
+
+// JournalConfig is a retention policy for the systemd journal, applied with
+// journalctl's vacuum options by the daemon after each scheduled clean.
+// Limits left empty or zero do not apply.
+type JournalConfig struct {
+	Enabled bool `toml:"enabled"`
+	// MaxSize shrinks archived journal files to this total, e.g. "500M". It
+	// is parsed like other moonbit sizes and handed to journalctl in bytes.
+	MaxSize string `toml:"max_size"`
+	// MaxAge drops archived journal files older than this, e.g. "14d".
+	MaxAge string `toml:"max_age"`
+	// MaxFiles keeps at most this many archived journal files.
+	MaxFiles int `toml:"max_files"`
+}
+
+// Validate reports limits that cannot be parsed, and an enabled policy
+// without any limit.
+func (j JournalConfig) Validate() error {
+	if j.MaxFiles < 0 {
+		return fmt.Errorf("max_files must not be negative, got %d", j.MaxFiles)
+	}
+	if j.MaxSize != "" {
+		if _, err := utils.ParseSize(j.MaxSize); err != nil {
+			return fmt.Errorf("max_size: %w", err)
+		}
+	}
+	if j.MaxAge != "" {
+		if _, err := utils.ParseAge(j.MaxAge); err != nil {
+			return fmt.Errorf("max_age: %w", err)
+		}
+	}
+	if j.Enabled && j.MaxSize == "" && j.MaxAge == "" && j.MaxFiles == 0 {
+		return fmt.Errorf("enabled but sets none of max_size, max_age or max_files")
+	}
+	return nil
+}
+
+// AuditConfig controls what cleaning records in the audit log.
+type AuditConfig struct {
+	// PerFile records the path, action and outcome of every file a clean
+	// touches, not only the per-category totals.
+	PerFile bool `toml:"per_file"`
+}
+
+// BackupConfig controls the backup taken before cleaning and how long backups
+// are kept. Limits left empty or zero do not apply.
+type BackupConfig struct {
+	// Enabled backs up every file before it is deleted or truncated.
+	Enabled bool `toml:"enabled"`
+	// KeepLast keeps the newest N backups.
+	KeepLast int `toml:"keep_last"`
+	// MaxAge removes backups older than this, e.g. "30d".
+	MaxAge string `toml:"max_age"`
+	// MaxSize caps the backup store's compressed size, e.g. "5GiB".
+	MaxSize string `toml:"max_size"`
+}
+
+// Validate reports limits that cannot be parsed.
+func (b BackupConfig) Validate() error {
+	if b.KeepLast < 0 {
+		return fmt.Errorf("keep_last must not be negative, got %d", b.KeepLast)
+	}
+	if b.MaxAge != "" {
+		if _, err := utils.ParseAge(b.MaxAge); err != nil {
+			return fmt.Errorf("max_age: %w", err)
+		}
+	}
+	if b.MaxSize != "" {
+		if _, err := utils.ParseSize(b.MaxSize); err != nil {
+			return fmt.Errorf("max_size: %w", err)
+		}
+	}
+	return nil
+}
+
+// SessionCacheVersion is the session cache format written by this build.
+// Version 2 added the per-category Categories section; caches without a
+// version field are version 1 and carry only the aggregate ScanResults.
+const SessionCacheVersion = 2
+
+// CategorySummary is one category's entry in the session cache.
+//
+// Like everything else in the cache it is informational until revalidated:
+// RevalidateCache recomputes Size and FileCount from the files that survive,
+// and takes Risk, Selected and ScanRoots from config. ScanRoots then holds the
+// category's configured paths, which ByCategory hands the cleaner as Paths.
+type CategorySummary struct {
+	Name       string    `json:"name"`
+	Risk       RiskLevel `json:"risk"`
+	Selected   bool      `json:"selected,omitempty"`
+	Size       uint64    `json:"size"`
+	FileCount  int       `json:"file_count"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Errors     []string  `json:"errors,omitempty"`
+	ScanRoots  []string  `json:"scan_roots,omitempty"`
 }
 
 // SessionCache stores scan results for the current session
 type SessionCache struct {
+	Version     int       `json:"version,omitempty"`
 	ScanResults *Category `json:"scan_results"`
-	TotalSize   uint64    `json:"total_size"`
-	TotalFiles  int       `json:"total_files"`
-	ScannedAt   time.Time `json:"scanned_at"`
+	// Categories breaks ScanResults down per category. Empty in version 1
+	// caches; use SummarizeCategories to derive it from file provenance.
+	Categories []CategorySummary `json:"categories,omitempty"`
+	TotalSize  uint64            `json:"total_size"`
+	TotalFiles int               `json:"total_files"`
+	ScannedAt  time.Time         `json:"scanned_at"`
+}
+
+// SummarizeCategories recomputes per-category sizes and file counts from the
+// per-file provenance in files, carrying over the scan-time details (duration,
+// errors, roots) of the matching entry in previous. Categories appear in the
+// order of previous, then in order of first appearance in files; categories
+// left with no files are omitted.
+func SummarizeCategories(files []FileInfo, previous []CategorySummary) []CategorySummary {
+	byName := make(map[string]*CategorySummary)
+	var order []string
+	for _, prev := range previous {
+		if _, dup := byName[prev.Name]; dup {
+			continue
+		}
+		entry := prev
+		entry.Size = 0
+		entry.FileCount = 0
+		byName[prev.Name] = &entry
+		order = append(order, prev.Name)
+	}
+
+	for _, file := range files {
+		entry, ok := byName[file.CategoryName]
+		if !ok {
+			entry = &CategorySummary{
+				Name:     file.CategoryName,
+				Risk:     file.CategoryRisk,
+				Selected: file.CategorySelected,
+			}
+			byName[file.CategoryName] = entry
+			order = append(order, file.CategoryName)
+		}
+		entry.Size += file.Size
+		entry.FileCount++
+	}
+
+	summaries := make([]CategorySummary, 0, len(order))
+	for _, name := range order {
+		if entry := byName[name]; entry.FileCount > 0 {
+			summaries = append(summaries, *entry)
+		}
+	}
+	return summaries
+}
+
+// CategorySummaries returns the per-category section, deriving it from file
+// provenance for version 1 caches that predate it.
+func (c *SessionCache) CategorySummaries() []CategorySummary {
+	if len(c.Categories) > 0 {
+		return c.Categories
+	}
+	if c.ScanResults == nil {
+		return nil
+	}
+	return SummarizeCategories(c.ScanResults.Files, nil)
+}
+
+// ByCategory splits the aggregate file list into one Category per summary, so
+// callers can clean and report per category. Files without provenance are not
+// returned; the revalidation gate refuses such caches anyway.
+func (c *SessionCache) ByCategory() []Category {
+	if c.ScanResults == nil {
+		return nil
+	}
+	summaries := c.CategorySummaries()
+	index := make(map[string]int, len(summaries))
+	out := make([]Category, len(summaries))
+	for i, summary := range summaries {
+		index[summary.Name] = i
+		out[i] = Category{
+			Name:     summary.Name,
+			Risk:     summary.Risk,
+			Selected: summary.Selected,
+			Paths:    summary.ScanRoots,
+		}
+	}
+	for _, file := range c.ScanResults.Files {
+		i, ok := index[file.CategoryName]
+		if !ok {
+			continue
+		}
+		out[i].Files = append(out[i].Files, file)
+		out[i].Size += file.Size
+		out[i].FileCount++
+	}
+	return out
 }
 
 // getRealUserHome returns the actual user's home directory, even when running as root
@@ -201,6 +409,12 @@ func DefaultConfig() *Config {
 			DryRunDefault:  true,
 			WorkerCount:    0, // 0 = auto-detect based on CPU count
 		},
+		Backup: BackupConfig{
+			Enabled:  false,
+			KeepLast: 10,
+			MaxAge:   "30d",
+		},
+		Safety: DefaultSafetyConfig(),
 		Categories: []Category{
 			{
 				Name:         "Pacman Cache",
@@ -489,6 +703,24 @@ func Load(path string) (*Config, error) {
 	}
 	cfg.Normalize()
 
+	// A retention policy that cannot be enforced must not quietly become no
+	// policy at all, which would clean everything the category matches.
+	if err := cfg.validateRetention(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateActions(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Backup.Validate(); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	if err := cfg.Journal.Validate(); err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	if err := cfg.Safety.Validate(); err != nil {
+		return nil, fmt.Errorf("safety: %w", err)
+	}
+
 	return cfg, nil
 }
 
@@ -586,5 +818,42 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if err := cfg.validateRetention(); err != nil {
+		return err
+	}
+	if err := cfg.validateActions(); err != nil {
+		return err
+	}
+	if err := cfg.Backup.Validate(); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	if err := cfg.Journal.Validate(); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := cfg.Safety.Validate(); err != nil {
+		return fmt.Errorf("safety: %w", err)
+	}
+	return nil
+}
+
+func (cfg *Config) validateActions() error {
+	for _, cat := range cfg.Categories {
+		if err := cat.Action.Validate(); err != nil {
+			return fmt.Errorf("category %s: %w", cat.Name, err)
+		}
+		// Shredding destroys the contents the trash exists to keep.
+		if cat.Action == ActionTrash && cat.ShredEnabled {
+			return fmt.Errorf("category %s: shred cannot be combined with action %q", cat.Name, ActionTrash)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) validateRetention() error {
+	for _, cat := range cfg.Categories {
+		if err := cat.Retention.Validate(); err != nil {
+			return fmt.Errorf("category %s: retention: %w", cat.Name, err)
+		}
+	}
 	return nil
 }
@@ -164,3 +164,185 @@ func categoryNames(categories []Category) []string {
 	}
 	return names
 }
+
+func TestSummarizeCategoriesRecomputesFromFiles(t *testing.T) {
+	files := []FileInfo{
+		{Path: "/a/1", Size: 10, CategoryName: "A", CategoryRisk: Low},
+		{Path: "/b/1", Size: 5, CategoryName: "B", CategoryRisk: Medium},
+		{Path: "/a/2", Size: 20, CategoryName: "A", CategoryRisk: Low},
+	}
+	previous := []CategorySummary{
+		// Stale counts must be replaced, scan details kept.
+		{Name: "B", Size: 999, FileCount: 99, DurationMs: 7, ScanRoots: []string{"/b"}},
+		{Name: "Gone", Size: 1, FileCount: 1},
+	}
+
+	got := SummarizeCategories(files, previous)
+
+	assert.Len(t, got, 2)
+	assert.Equal(t, "B", got[0].Name, "previous order comes first")
+	assert.Equal(t, uint64(5), got[0].Size)
+	assert.Equal(t, 1, got[0].FileCount)
+	assert.Equal(t, int64(7), got[0].DurationMs)
+	assert.Equal(t, []string{"/b"}, got[0].ScanRoots)
+	assert.Equal(t, "A", got[1].Name)
+	assert.Equal(t, uint64(30), got[1].Size)
+	assert.Equal(t, 2, got[1].FileCount)
+}
+
+func TestSessionCacheByCategoryDerivesLegacySections(t *testing.T) {
+	cache := &SessionCache{
+		ScanResults: &Category{Files: []FileInfo{
+			{Path: "/a/1", Size: 10, CategoryName: "A"},
+			{Path: "/b/1", Size: 5, CategoryName: "B"},
+			{Path: "/a/2", Size: 20, CategoryName: "A"},
+		}},
+	}
+
+	got := cache.ByCategory()
+
+	assert.Len(t, got, 2)
+	assert.Equal(t, "A", got[0].Name)
+	assert.Equal(t, 2, got[0].FileCount)
+	assert.Equal(t, uint64(30), got[0].Size)
+	assert.Len(t, got[1].Files, 1)
+}
+
+func TestLoadRejectsUnknownOrConflictingActions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+		return path
+	}
+
+	cfg, err := Load(write("trash.toml", `
+[[categories]]
+name = "Thumbnails"
+paths = ["/tmp/thumbs"]
+action = "trash"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, ActionTrash, cfg.Categories[0].Action)
+
+	_, err = Load(write("typo.toml", `
+[[categories]]
+name = "Thumbnails"
+paths = ["/tmp/thumbs"]
+action = "tarsh"
+`))
+	assert.ErrorContains(t, err, "unknown action")
+
+	_, err = Load(write("shred.toml", `
+[[categories]]
+name = "Thumbnails"
+paths = ["/tmp/thumbs"]
+action = "trash"
+shred = true
+`))
+	assert.ErrorContains(t, err, "shred")
+}
+
+func TestLoadParsesAndValidatesBackupLimits(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.toml")
+	require.NoError(t, os.WriteFile(good, []byte(`
+[backup]
+enabled = true
+keep_last = 5
+max_age = "2w"
+max_size = "1GiB"
+`), 0644))
+	cfg, err := Load(good)
+	require.NoError(t, err)
+	assert.True(t, cfg.Backup.Enabled)
+	assert.Equal(t, 5, cfg.Backup.KeepLast)
+	assert.Equal(t, "1GiB", cfg.Backup.MaxSize)
+
+	defaults, err := Load(filepath.Join(dir, "absent.toml"))
+	require.NoError(t, err)
+	assert.False(t, defaults.Backup.Enabled, "backups stay opt-in")
+	assert.Equal(t, 10, defaults.Backup.KeepLast)
+
+	bad := filepath.Join(dir, "bad.toml")
+	require.NoError(t, os.WriteFile(bad, []byte("[backup]\nmax_age = \"a while\"\n"), 0644))
+	_, err = Load(bad)
+	assert.ErrorContains(t, err, "backup")
+}
+
+func TestLoadParsesAndValidatesJournalPolicy(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.toml")
+	require.NoError(t, os.WriteFile(good, []byte(`
+[journal]
+enabled = true
+max_size = "500M"
+max_files = 20
+`), 0644))
+	cfg, err := Load(good)
+	require.NoError(t, err)
+	assert.True(t, cfg.Journal.Enabled)
+	assert.Equal(t, "500M", cfg.Journal.MaxSize)
+	assert.Equal(t, 20, cfg.Journal.MaxFiles)
+
+	defaults, err := Load(filepath.Join(dir, "absent.toml"))
+	require.NoError(t, err)
+	assert.False(t, defaults.Journal.Enabled, "journal vacuuming stays opt-in")
+
+	for name, data := range map[string]string{
+		"no limit": "[journal]\nenabled = true\n",
+		"bad age":  "[journal]\nmax_age = \"a while\"\n",
+		"bad size": "[journal]\nmax_size = \"lots\"\n",
+	} {
+		bad := filepath.Join(dir, "bad.toml")
+		require.NoError(t, os.WriteFile(bad, []byte(data), 0644))
+		_, err = Load(bad)
+		assert.ErrorContains(t, err, "journal", name)
+	}
+}
+
+func TestLoadParsesAndValidatesSafetyPolicy(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.toml")
+	require.NoError(t, os.WriteFile(good, []byte(`
+[safety]
+max_deletion_size = "50G"
+protected_paths = ["/srv/data"]
+
+[safety.categories."System Logs"]
+max_deletion_size = "2G"
+safe_mode = false
+`), 0644))
+	cfg, err := Load(good)
+	require.NoError(t, err)
+
+	global := cfg.Safety.Limits("")
+	assert.Equal(t, uint64(50<<30), global.MaxDeletionSize)
+	assert.True(t, global.SafeMode, "safe mode stays on unless set")
+	assert.Equal(t, DefaultShredPasses, global.ShredPasses)
+
+	logs := cfg.Safety.Limits("System Logs")
+	assert.Equal(t, uint64(2<<30), logs.MaxDeletionSize)
+	assert.False(t, logs.SafeMode)
+	assert.Equal(t, global, cfg.Safety.Limits("User Cache"))
+
+	protected := cfg.Safety.EffectiveProtectedPaths()
+	assert.Contains(t, protected, "/srv/data")
+	assert.Subset(t, protected, DefaultProtectedPaths(), "configured paths cannot drop the built-in ones")
+
+	defaults, err := Load(filepath.Join(dir, "absent.toml"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(500<<30), defaults.Safety.Limits("").MaxDeletionSize)
+
+	for name, data := range map[string]string{
+		"bad size":          "[safety]\nmax_deletion_size = \"huge\"\n",
+		"negative passes":   "[safety]\nshred_passes = -1\n",
+		"relative path":     "[safety]\nprotected_paths = [\"srv\"]\n",
+		"bad category size": "[safety.categories.\"System Logs\"]\nmax_deletion_size = \"2 lots\"\n",
+	} {
+		bad := filepath.Join(dir, "bad.toml")
+		require.NoError(t, os.WriteFile(bad, []byte(data), 0644))
+		_, err = Load(bad)
+		assert.ErrorContains(t, err, "safety", name)
+	}
+}
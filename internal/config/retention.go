@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/utils"
+)
+
+// Retention grouping modes for RetentionPolicy.GroupBy.
+const (
+	// GroupByDirectory counts keep_last per directory. It is the default.
+	GroupByDirectory = "directory"
+	// GroupByPackage counts keep_last per package name and architecture, parsed
+	// from pacman-style file names (name-pkgver-pkgrel-arch.pkg.tar.*), the way
+	// paccache does.
+	GroupByPackage = "package"
+)
+
+// RetentionPolicy limits which of a category's matching files may be cleaned.
+// Without one, every file that passes the category's filters is cleanable.
+//
+// KeepLast and MaxSize are counted among the files that are otherwise eligible
+// for cleaning, after filters, exclude patterns, min_age_days and unused_days.
+// Files held back by those rules are kept anyway and do not count, so a policy
+// can only ever make a category clean less.
+type RetentionPolicy struct {
+	// KeepLast keeps the newest N files (by mtime) in each group.
+	KeepLast int `toml:"keep_last,omitempty" json:"keep_last,omitempty"`
+	// GroupBy chooses the KeepLast groups: "directory" (default) or "package".
+	GroupBy string `toml:"group_by,omitempty" json:"group_by,omitempty"`
+	// MaxSize keeps the newest files up to this total size (e.g. "2GiB") and
+	// makes the older remainder cleanable.
+	MaxSize string `toml:"max_size,omitempty" json:"max_size,omitempty"`
+	// UnusedDays only cleans files not accessed (atime) for this many days.
+	UnusedDays int `toml:"unused_days,omitempty" json:"unused_days,omitempty"`
+}
+
+// Validate reports a policy that cannot be enforced as written.
+func (p *RetentionPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.KeepLast < 0 {
+		return fmt.Errorf("keep_last must not be negative, got %d", p.KeepLast)
+	}
+	if p.UnusedDays < 0 {
+		return fmt.Errorf("unused_days must not be negative, got %d", p.UnusedDays)
+	}
+	switch p.GroupBy {
+	case "", GroupByDirectory, GroupByPackage:
+	default:
+		return fmt.Errorf("group_by must be %q or %q, got %q", GroupByDirectory, GroupByPackage, p.GroupBy)
+	}
+	if p.MaxSize != "" {
+		if _, err := utils.ParseSize(p.MaxSize); err != nil {
+			return fmt.Errorf("max_size: %w", err)
+		}
+	}
+	return nil
+}
+
+// NeedsAccessTime reports whether enforcing the policy reads file atimes.
+func (p *RetentionPolicy) NeedsAccessTime() bool {
+	return p != nil && p.UnusedDays > 0
+}
+
+// AllowsAccessTime reports whether a file with this FileInfo has gone unused
+// long enough to be cleaned. A file whose atime cannot be read is kept.
+func (p *RetentionPolicy) AllowsAccessTime(info os.FileInfo, now time.Time) bool {
+	if !p.NeedsAccessTime() {
+		return true
+	}
+	atime, ok := AccessTime(info)
+	if !ok {
+		return false
+	}
+	return now.Sub(atime) >= time.Duration(p.UnusedDays)*24*time.Hour
+}
+
+// AccessTime returns the file's last access time when the platform reports it.
+func AccessTime(info os.FileInfo) (time.Time, bool) {
+	if info == nil {
+		return time.Time{}, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec)), true
+}
+
+// Cleanable applies KeepLast and MaxSize to a category's eligible files and
+// returns the ones that may be cleaned, in their original order. A policy
+// whose MaxSize cannot be parsed keeps everything.
+//
+// files must be the whole eligible set. Applied to a subset, such as a list
+// Cleanable already trimmed, the limits keep the newest files of that subset
+// and so keep more than they should.
+func (p *RetentionPolicy) Cleanable(files []FileInfo) []FileInfo {
+	if p == nil || (p.KeepLast == 0 && p.MaxSize == "") {
+		return files
+	}
+
+	// Newest first; ties broken by path so the outcome is stable.
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	modTimes := make([]time.Time, len(files))
+	for i, f := range files {
+		// An unparseable timestamp sorts as newest, so it is kept.
+		t, err := time.Parse(time.RFC3339, f.ModTime)
+		if err != nil {
+			t = time.Unix(1<<62, 0)
+		}
+		modTimes[i] = t
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ta, tb := modTimes[order[a]], modTimes[order[b]]
+		if !ta.Equal(tb) {
+			return ta.After(tb)
+		}
+		return files[order[a]].Path < files[order[b]].Path
+	})
+
+	cleanable := make([]bool, len(files))
+
+	if p.KeepLast > 0 {
+		seen := make(map[string]int)
+		for _, i := range order {
+			key := p.groupKey(files[i].Path)
+			seen[key]++
+			if seen[key] > p.KeepLast {
+				cleanable[i] = true
+			}
+		}
+	}
+
+	if p.MaxSize != "" {
+		limit, err := utils.ParseSize(p.MaxSize)
+		if err != nil {
+			return nil
+		}
+		// Once the running total passes the cap, everything older goes too,
+		// even files small enough to fit, so the newest files are the ones
+		// kept.
+		var total uint64
+		over := false
+		for _, i := range order {
+			total += files[i].Size
+			if over || total > limit {
+				over = true
+				cleanable[i] = true
+			}
+		}
+	}
+
+	out := make([]FileInfo, 0, len(files))
+	for i, f := range files {
+		if cleanable[i] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// pacmanPackage matches name-pkgver-pkgrel-arch.pkg.tar[.ext][.sig].
+var pacmanPackage = regexp.MustCompile(`^(.+)-[^-]+-[^-]+-([^-]+)\.pkg\.tar(\.[A-Za-z0-9]+)?(\.sig)?$`)
+
+func (p *RetentionPolicy) groupKey(path string) string {
+	if p.GroupBy != GroupByPackage {
+		return filepath.Dir(path)
+	}
+	base := filepath.Base(path)
+	m := pacmanPackage.FindStringSubmatch(base)
+	if m == nil {
+		// Not a package file: a group of its own, so KeepLast never cleans it.
+		return path
+	}
+	// Signatures are grouped apart from their packages so each keeps N.
+	return strings.Join([]string{filepath.Dir(path), m[1], m[2], m[4]}, "\x00")
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func retentionFile(path string, size uint64, age time.Duration) FileInfo {
+	return FileInfo{
+		Path:    path,
+		Size:    size,
+		ModTime: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Add(-age).Format(time.RFC3339),
+	}
+}
+
+func cleanablePaths(files []FileInfo) []string {
+	out := []string{}
+	for _, f := range files {
+		out = append(out, f.Path)
+	}
+	return out
+}
+
+func TestRetentionKeepLastGroupsByPackage(t *testing.T) {
+	dir := "/var/cache/pacman/pkg/"
+	files := []FileInfo{
+		retentionFile(dir+"linux-6.9.1.arch1-1-x86_64.pkg.tar.zst", 100, 4*time.Hour),
+		retentionFile(dir+"linux-6.9.2.arch1-1-x86_64.pkg.tar.zst", 100, 3*time.Hour),
+		retentionFile(dir+"linux-6.9.3.arch1-1-x86_64.pkg.tar.zst", 100, 2*time.Hour),
+		retentionFile(dir+"linux-6.9.3.arch1-1-x86_64.pkg.tar.zst.sig", 1, 2*time.Hour),
+		retentionFile(dir+"lib32-glibc-2.39-1-x86_64.pkg.tar.zst", 100, 5*time.Hour),
+		retentionFile(dir+"download-abc.part", 100, 9*time.Hour),
+	}
+	policy := &RetentionPolicy{KeepLast: 2, GroupBy: GroupByPackage}
+
+	got := cleanablePaths(policy.Cleanable(files))
+
+	assert.Equal(t, []string{dir + "linux-6.9.1.arch1-1-x86_64.pkg.tar.zst"}, got,
+		"only the oldest linux package exceeds keep_last; signatures and unrelated files are grouped apart")
+}
+
+func TestRetentionKeepLastDefaultsToDirectory(t *testing.T) {
+	files := []FileInfo{
+		retentionFile("/cache/a/old", 1, 3*time.Hour),
+		retentionFile("/cache/a/new", 1, time.Hour),
+		retentionFile("/cache/b/only", 1, 5*time.Hour),
+	}
+	policy := &RetentionPolicy{KeepLast: 1}
+
+	assert.Equal(t, []string{"/cache/a/old"}, cleanablePaths(policy.Cleanable(files)))
+}
+
+func TestRetentionMaxSizeCleansOldestFirst(t *testing.T) {
+	files := []FileInfo{
+		retentionFile("/cache/oldest", 600, 3*time.Hour),
+		retentionFile("/cache/middle", 600, 2*time.Hour),
+		retentionFile("/cache/newest", 600, time.Hour),
+		retentionFile("/cache/tiny-but-old", 1, 4*time.Hour),
+	}
+	policy := &RetentionPolicy{MaxSize: "1K"}
+
+	got := cleanablePaths(policy.Cleanable(files))
+
+	assert.Equal(t, []string{"/cache/oldest", "/cache/middle", "/cache/tiny-but-old"}, got,
+		"everything older than the file that crosses the cap is cleanable, even if it would fit")
+}
+
+func TestRetentionIsMonotoneOverSubsets(t *testing.T) {
+	all := []FileInfo{
+		retentionFile("/cache/p-1-1-any.pkg.tar.zst", 400, 5*time.Hour),
+		retentionFile("/cache/p-2-1-any.pkg.tar.zst", 400, 4*time.Hour),
+		retentionFile("/cache/p-3-1-any.pkg.tar.zst", 400, 3*time.Hour),
+		retentionFile("/cache/p-4-1-any.pkg.tar.zst", 400, 2*time.Hour),
+		retentionFile("/cache/q-1-1-any.pkg.tar.zst", 400, time.Hour),
+	}
+	policy := &RetentionPolicy{KeepLast: 2, GroupBy: GroupByPackage, MaxSize: "1000"}
+
+	full := make(map[string]bool)
+	for _, f := range policy.Cleanable(all) {
+		full[f.Path] = true
+	}
+
+	// Every subset must only clean files the full set would also clean.
+	for mask := 0; mask < 1<<len(all); mask++ {
+		var subset []FileInfo
+		for i, f := range all {
+			if mask&(1<<i) != 0 {
+				subset = append(subset, f)
+			}
+		}
+		for _, f := range policy.Cleanable(subset) {
+			assert.True(t, full[f.Path], "subset %b cleaned %s", mask, f.Path)
+		}
+	}
+}
+
+func TestRetentionWithoutLimitsCleansEverything(t *testing.T) {
+	files := []FileInfo{retentionFile("/cache/a", 1, time.Hour)}
+
+	var none *RetentionPolicy
+	assert.Equal(t, files, none.Cleanable(files))
+	assert.Equal(t, files, (&RetentionPolicy{UnusedDays: 5}).Cleanable(files))
+}
+
+func TestRetentionAccessTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+	atime := time.Now().Add(-10 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(path, atime, time.Now()))
+	info, err := os.Lstat(path)
+	require.NoError(t, err)
+
+	assert.True(t, (&RetentionPolicy{UnusedDays: 7}).AllowsAccessTime(info, time.Now()))
+	assert.False(t, (&RetentionPolicy{UnusedDays: 14}).AllowsAccessTime(info, time.Now()))
+	assert.True(t, (*RetentionPolicy)(nil).AllowsAccessTime(info, time.Now()))
+}
+
+func TestRetentionValidate(t *testing.T) {
+	assert.NoError(t, (*RetentionPolicy)(nil).Validate())
+	assert.NoError(t, (&RetentionPolicy{KeepLast: 3, GroupBy: GroupByPackage, MaxSize: "2GiB", UnusedDays: 30}).Validate())
+	assert.Error(t, (&RetentionPolicy{KeepLast: -1}).Validate())
+	assert.Error(t, (&RetentionPolicy{GroupBy: "version"}).Validate())
+	assert.Error(t, (&RetentionPolicy{MaxSize: "two gigs"}).Validate())
+}
+
+func TestLoadParsesAndValidatesRetention(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.toml")
+	require.NoError(t, os.WriteFile(good, []byte(`
+[[categories]]
+name = "Pacman Cache"
+paths = ["/var/cache/pacman/pkg"]
+risk = 0
+
+[categories.retention]
+keep_last = 3
+group_by = "package"
+max_size = "2GiB"
+`), 0644))
+
+	cfg, err := Load(good)
+	require.NoError(t, err)
+	var pacman *Category
+	for i := range cfg.Categories {
+		if cfg.Categories[i].Name == "Pacman Cache" {
+			pacman = &cfg.Categories[i]
+		}
+	}
+	require.NotNil(t, pacman)
+	require.NotNil(t, pacman.Retention)
+	assert.Equal(t, 3, pacman.Retention.KeepLast)
+	assert.Equal(t, GroupByPackage, pacman.Retention.GroupBy)
+
+	bad := filepath.Join(dir, "bad.toml")
+	require.NoError(t, os.WriteFile(bad, []byte(`
+[[categories]]
+name = "Pacman Cache"
+paths = ["/var/cache/pacman/pkg"]
+
+[categories.retention]
+max_size = "huge"
+`), 0644))
+	_, err = Load(bad)
+	assert.ErrorContains(t, err, "retention")
+}
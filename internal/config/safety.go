@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/Nomadcxx/moonbit/internal/utils"
+)
+
+// DefaultMaxDeletionSize is the largest category a clean accepts unless
+// [safety] says otherwise. It is generous so systemd journals and large
+// caches fit.
+const DefaultMaxDeletionSize = "500GiB"
+
+// DefaultShredPasses is how often a shredded file is overwritten.
+const DefaultShredPasses = 1
+
+// DefaultProtectedPaths are never cleaned, nor anything below them. They are
+// always protected: [safety] protected_paths can add to them, not remove
+// them. /var/lib is deliberately absent so the Docker categories work;
+// categories are expected to be specific about what they clean.
+func DefaultProtectedPaths() []string {
+	return []string{"/bin", "/usr/bin", "/usr/sbin", "/sbin", "/etc", "/boot", "/sys", "/proc", "/dev"}
+}
+
+// SafetyConfig is the safety policy every clean is checked against. Fields
+// left empty or zero take the defaults; SafetyConfig.Limits resolves them.
+type SafetyConfig struct {
+	// ProtectedPaths are protected in addition to DefaultProtectedPaths.
+	ProtectedPaths []string `toml:"protected_paths"`
+	// MaxDeletionSize refuses to clean a category larger than this, e.g.
+	// "100G".
+	MaxDeletionSize string `toml:"max_deletion_size"`
+	// SafeMode refuses to clean high-risk categories and aborts a clean whose
+	// backup could not be made. On unless set to false.
+	SafeMode *bool `toml:"safe_mode"`
+	// ShredPasses is how often shredded files are overwritten.
+	ShredPasses int `toml:"shred_passes"`
+	// Categories override the limits above per category name, e.g.
+	// [safety.categories."System Logs"].
+	Categories map[string]SafetyOverride `toml:"categories"`
+}
+
+// SafetyOverride is one category's [safety.categories] entry. Fields left
+// empty or zero keep the [safety] value.
+type SafetyOverride struct {
+	MaxDeletionSize string `toml:"max_deletion_size"`
+	SafeMode        *bool  `toml:"safe_mode"`
+	ShredPasses     int    `toml:"shred_passes"`
+}
+
+// SafetyLimits are the limits that apply to one category.
+type SafetyLimits struct {
+	MaxDeletionSize uint64 // bytes
+	SafeMode        bool
+	ShredPasses     int
+}
+
+// DefaultSafetyConfig returns the policy written to new config files.
+func DefaultSafetyConfig() SafetyConfig {
+	safeMode := true
+	return SafetyConfig{
+		MaxDeletionSize: DefaultMaxDeletionSize,
+		SafeMode:        &safeMode,
+		ShredPasses:     DefaultShredPasses,
+	}
+}
+
+// EffectiveProtectedPaths is DefaultProtectedPaths followed by the
+// configured paths, cleaned and without duplicates.
+func (s SafetyConfig) EffectiveProtectedPaths() []string {
+	var configured []string
+	for _, path := range s.ProtectedPaths {
+		if path != "" {
+			configured = append(configured, filepath.Clean(path))
+		}
+	}
+	return mergeStrings(DefaultProtectedPaths(), configured)
+}
+
+// Limits resolves the limits for the named category: its override where it
+// sets one, else the [safety] value, else the default. An empty name gives
+// the [safety] limits. Sizes are checked by Validate when the config loads;
+// one that still does not parse falls back to the default.
+func (s SafetyConfig) Limits(category string) SafetyLimits {
+	def, _ := utils.ParseSize(DefaultMaxDeletionSize)
+	limits := SafetyLimits{MaxDeletionSize: def, SafeMode: true, ShredPasses: DefaultShredPasses}
+	apply := func(size string, safeMode *bool, passes int) {
+		if size != "" {
+			if n, err := utils.ParseSize(size); err == nil {
+				limits.MaxDeletionSize = n
+			}
+		}
+		if safeMode != nil {
+			limits.SafeMode = *safeMode
+		}
+		if passes > 0 {
+			limits.ShredPasses = passes
+		}
+	}
+	apply(s.MaxDeletionSize, s.SafeMode, s.ShredPasses)
+	if o, ok := s.Categories[category]; ok && category != "" {
+		apply(o.MaxDeletionSize, o.SafeMode, o.ShredPasses)
+	}
+	return limits
+}
+
+// Validate reports limits that cannot be parsed and protected paths that
+// are not absolute.
+func (s SafetyConfig) Validate() error {
+	for _, path := range s.ProtectedPaths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("protected_paths: %q is not an absolute path", path)
+		}
+	}
+	if err := validateSafetyLimits(s.MaxDeletionSize, s.ShredPasses); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(s.Categories))
+	for name := range s.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		o := s.Categories[name]
+		if err := validateSafetyLimits(o.MaxDeletionSize, o.ShredPasses); err != nil {
+			return fmt.Errorf("categories.%q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateSafetyLimits(maxDeletionSize string, shredPasses int) error {
+	if maxDeletionSize != "" {
+		if _, err := utils.ParseSize(maxDeletionSize); err != nil {
+			return fmt.Errorf("max_deletion_size: %w", err)
+		}
+	}
+	if shredPasses < 0 {
+		return fmt.Errorf("shred_passes must not be negative, got %d", shredPasses)
+	}
+	return nil
+}
+
+// UnknownSafetyOverrides lists [safety.categories] entries that name no
+// category in categories. They are not an error, since a config may be
+// shared by hosts with different categories, but they apply to nothing.
+func (s SafetyConfig) UnknownSafetyOverrides(categories []Category) []string {
+	known := make(map[string]bool, len(categories))
+	for _, cat := range categories {
+		known[cat.Name] = true
+	}
+	var unknown []string
+	for name := range s.Categories {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
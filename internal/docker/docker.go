@@ -0,0 +1,204 @@
+// Package docker prunes unused container objects through the docker, podman
+// or nerdctl CLI, for rootful and rootless storage alike.
+//
+// Everything goes through the Client interface, so commands can preview a
+// prune from the runtime's disk usage and tests can substitute a fake.
+// Filters are handed to the runtime's own --filter flags for the prune
+// itself; previews apply the same filters to the disk usage it reports.
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Resource is a kind of Docker object that can be pruned.
+type Resource string
+
+const (
+	// Containers are stopped containers.
+	Containers Resource = "containers"
+	// Images are images no container uses, tagged or not.
+	Images Resource = "images"
+	// Volumes are volumes no container uses. Docker 23 and later only prune
+	// anonymous volumes unless Filters.AllVolumes is set.
+	Volumes Resource = "volumes"
+	// BuildCache is build cache no running build uses.
+	BuildCache Resource = "builder"
+	// Networks are custom networks no container uses. They take no space.
+	Networks Resource = "networks"
+)
+
+// AllResources is everything `docker all` prunes, containers first so that
+// the images, networks and volumes they held are unused by the time those
+// are pruned.
+var AllResources = []Resource{Containers, Images, Networks, Volumes, BuildCache}
+
+// Filters narrows a prune.
+type Filters struct {
+	// Until prunes only objects created longer ago than this; zero means any
+	// age. Build cache is judged by when it was last used.
+	Until time.Duration
+	// Labels are "key" or "key=value"; an object must match all of them.
+	Labels []string
+	// AllVolumes prunes unused named volumes as well as anonymous ones.
+	AllVolumes bool
+}
+
+// Validate reports filters docker cannot apply to r.
+func (f Filters) Validate(r Resource) error {
+	switch r {
+	case Containers, Images, Volumes, BuildCache, Networks:
+	default:
+		return fmt.Errorf("unknown docker resource %q", r)
+	}
+	if f.Until < 0 {
+		return fmt.Errorf("until must not be negative")
+	}
+	if f.Until > 0 && r == Volumes {
+		return fmt.Errorf("docker cannot filter volumes by age")
+	}
+	if len(f.Labels) > 0 && r == BuildCache {
+		return fmt.Errorf("docker cannot filter build cache by label")
+	}
+	for _, label := range f.Labels {
+		if strings.TrimSpace(label) == "" || strings.HasPrefix(label, "=") {
+			return fmt.Errorf("invalid label filter %q; want key or key=value", label)
+		}
+	}
+	return nil
+}
+
+// PruneResult is the outcome of one prune.
+type PruneResult struct {
+	Args      []string // arguments docker was run with
+	Output    string
+	Reclaimed uint64 // from docker's "Total reclaimed space" line
+}
+
+// ErrNoDiskUsage is returned by runtimes that cannot report what their
+// objects take up; prunes still work, but cannot be previewed with sizes.
+var ErrNoDiskUsage = errors.New("runtime does not report disk usage")
+
+// Client is the part of a container runtime's CLI moonbit uses.
+type Client interface {
+	// Runtime is the runtime the client drives.
+	Runtime() Runtime
+	// Ping fails when the runtime is missing or its daemon is unreachable.
+	Ping(ctx context.Context) error
+	// DiskUsage lists images, containers, volumes and build cache with
+	// their sizes, as `docker system df -v` does.
+	DiskUsage(ctx context.Context) (*DiskUsage, error)
+	// ImageLabels returns the labels of each image ID given.
+	ImageLabels(ctx context.Context, ids []string) (map[string]map[string]string, error)
+	// Prune removes unused objects of kind r that f selects.
+	Prune(ctx context.Context, r Resource, f Filters) (PruneResult, error)
+}
+
+// CLIClient is a Client that runs a runtime's CLI.
+type CLIClient struct {
+	rt Runtime
+}
+
+// NewClient returns a client for rt.
+func NewClient(rt Runtime) *CLIClient {
+	return &CLIClient{rt: rt}
+}
+
+// Runtime returns the runtime c drives.
+func (c *CLIClient) Runtime() Runtime {
+	return c.rt
+}
+
+func (c *CLIClient) run(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := c.rt.command(ctx, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%s %s: %w: %s", c.rt.Name, args[0], err, msg)
+		}
+		return out, fmt.Errorf("%s %s: %w", c.rt.Name, args[0], err)
+	}
+	return out, nil
+}
+
+// Ping runs `<runtime> version`, which fails without a reachable daemon.
+func (c *CLIClient) Ping(ctx context.Context) error {
+	_, err := c.run(ctx, "version")
+	return err
+}
+
+// DiskUsage runs `docker system df -v --format json`. Podman's verbose df
+// has no JSON form, so its usage is assembled from its JSON listings
+// instead; nerdctl reports none.
+func (c *CLIClient) DiskUsage(ctx context.Context) (*DiskUsage, error) {
+	switch c.rt.Name {
+	case Podman:
+		return c.podmanDiskUsage(ctx)
+	case Nerdctl:
+		return nil, ErrNoDiskUsage
+	}
+	out, err := c.run(ctx, "system", "df", "-v", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiskUsage(out)
+}
+
+// ImageLabels runs `<runtime> image inspect` on ids.
+func (c *CLIClient) ImageLabels(ctx context.Context, ids []string) (map[string]map[string]string, error) {
+	labels := make(map[string]map[string]string)
+	if len(ids) == 0 {
+		return labels, nil
+	}
+	args := append([]string{"image", "inspect", "--format", "{{json .Id}} {{json .Config.Labels}}"}, ids...)
+	out, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		idJSON, labelsJSON, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		var id string
+		var l map[string]string
+		if json.Unmarshal([]byte(idJSON), &id) != nil || json.Unmarshal([]byte(labelsJSON), &l) != nil {
+			return nil, fmt.Errorf("unexpected docker image inspect output: %q", line)
+		}
+		labels[ShortID(id)] = l
+	}
+	return labels, nil
+}
+
+// Prune runs the prune command Runtime.PruneArgs builds.
+func (c *CLIClient) Prune(ctx context.Context, r Resource, f Filters) (PruneResult, error) {
+	args, err := c.rt.PruneArgs(r, f)
+	if err != nil {
+		return PruneResult{}, err
+	}
+	out, err := c.run(ctx, args...)
+	result := PruneResult{Args: args, Output: string(out), Reclaimed: parseReclaimed(string(out))}
+	return result, err
+}
+
+var reclaimedPattern = regexp.MustCompile(`Total reclaimed space:\s*(\S+)`)
+
+// parseReclaimed reads the "Total reclaimed space: 1.2GB" line docker
+// prints after a prune; zero if there is none.
+func parseReclaimed(output string) uint64 {
+	m := reclaimedPattern.FindStringSubmatch(output)
+	if m == nil {
+		return 0
+	}
+	size, _ := ParseSize(m[1])
+	return size
+}
@@ -0,0 +1,224 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient serves a fixed disk usage.
+type fakeClient struct {
+	usage  *DiskUsage
+	labels map[string]map[string]string
+}
+
+func (c *fakeClient) Runtime() Runtime { return Runtime{Name: Docker} }
+
+func (c *fakeClient) Ping(context.Context) error { return nil }
+
+func (c *fakeClient) DiskUsage(context.Context) (*DiskUsage, error) { return c.usage, nil }
+
+func (c *fakeClient) ImageLabels(context.Context, []string) (map[string]map[string]string, error) {
+	return c.labels, nil
+}
+
+func (c *fakeClient) Prune(_ context.Context, r Resource, f Filters) (PruneResult, error) {
+	args, err := c.Runtime().PruneArgs(r, f)
+	return PruneResult{Args: args}, err
+}
+
+// dfOutput is `docker system df -v --format json` output, trimmed.
+const dfOutput = `{
+  "Images": [
+    {"Containers":"0","CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"sha256:aaaaaaaaaaaaaaaa","Repository":"old","Size":"1.5GB","Tag":"1.0"},
+    {"Containers":"0","CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"sha256:aaaaaaaaaaaaaaaa","Repository":"old","Size":"1.5GB","Tag":"latest"},
+    {"Containers":"1","CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"sha256:bbbbbbbbbbbbbbbb","Repository":"used","Size":"300MB","Tag":"latest"},
+    {"Containers":"0","CreatedAt":"NOW","ID":"sha256:cccccccccccccccc","Repository":"<none>","Size":"20kB","Tag":"<none>"}
+  ],
+  "Containers": [
+    {"CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"c1","Labels":"env=dev,team=a","Names":"stopped","Size":"12MB (virtual 100MB)","State":"exited"},
+    {"CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"c2","Labels":"env=prod","Names":"web","Size":"5MB","State":"running"},
+    {"CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"c3","Labels":"","Names":"done","Size":"0B","State":"created"}
+  ],
+  "Volumes": [
+    {"Labels":"","Links":"0","Name":"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef","Size":"2GB"},
+    {"Labels":"","Links":"0","Name":"pgdata","Size":"4GB"},
+    {"Labels":"","Links":"1","Name":"inuse","Size":"1GB"}
+  ],
+  "BuildCache": [
+    {"CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"b1","InUse":"false","LastUsedAt":"","Size":"700MB","Description":"RUN make"},
+    {"CreatedAt":"2020-01-01 10:00:00 +0000 UTC","ID":"b2","InUse":"true","LastUsedAt":"","Size":"1GB","Description":"RUN build"}
+  ]
+}`
+
+func fixture(t *testing.T) *fakeClient {
+	t.Helper()
+	du, err := ParseDiskUsage([]byte(dfOutput))
+	require.NoError(t, err)
+	for i := range du.Images {
+		if du.Images[i].CreatedAt == "NOW" {
+			du.Images[i].CreatedAt = time.Now().UTC().Format("2006-01-02 15:04:05 -0700 MST")
+		}
+	}
+	return &fakeClient{usage: du}
+}
+
+func names(items []Item) []string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.Name)
+	}
+	return out
+}
+
+func TestPreviewAppliesPruneRules(t *testing.T) {
+	c := fixture(t)
+	ctx := context.Background()
+
+	images, err := Preview(ctx, c, Images, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old:1.0, old:latest", "<none>"}, names(images), "unused images, one entry per ID")
+	assert.Equal(t, uint64(1_500_000_000), images[0].Size)
+
+	containers, err := Preview(ctx, c, Containers, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stopped", "done"}, names(containers), "running containers stay")
+	assert.Equal(t, uint64(12_000_000), containers[0].Size)
+
+	volumes, err := Preview(ctx, c, Volumes, Filters{})
+	require.NoError(t, err)
+	assert.Len(t, volumes, 1, "only the anonymous unused volume by default")
+
+	volumes, err = Preview(ctx, c, Volumes, Filters{AllVolumes: true})
+	require.NoError(t, err)
+	assert.Equal(t, "pgdata", volumes[0].Name, "named volumes with --all, largest first")
+	assert.Len(t, volumes, 2)
+
+	cache, err := Preview(ctx, c, BuildCache, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RUN make"}, names(cache))
+}
+
+func TestPreviewFilters(t *testing.T) {
+	c := fixture(t)
+	ctx := context.Background()
+
+	images, err := Preview(ctx, c, Images, Filters{Until: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old:1.0, old:latest"}, names(images), "the new image is too young")
+
+	containers, err := Preview(ctx, c, Containers, Filters{Labels: []string{"env=dev"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stopped"}, names(containers))
+
+	containers, err = Preview(ctx, c, Containers, Filters{Labels: []string{"team"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stopped"}, names(containers), "a bare key matches any value")
+
+	c.labels = map[string]map[string]string{"cccccccccccc": {"keep": "no"}}
+	images, err = Preview(ctx, c, Images, Filters{Labels: []string{"keep=no"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"<none>"}, names(images), "image labels come from the client")
+}
+
+func TestFiltersRejectWhatDockerCannotApply(t *testing.T) {
+	assert.Error(t, Filters{Until: time.Hour}.Validate(Volumes))
+	assert.Error(t, Filters{Labels: []string{"a"}}.Validate(BuildCache))
+	assert.Error(t, Filters{Labels: []string{"=x"}}.Validate(Images))
+	assert.Error(t, Filters{}.Validate("pods"))
+	assert.NoError(t, Filters{Until: time.Hour, Labels: []string{"a=b"}}.Validate(Containers))
+}
+
+func TestPruneArgs(t *testing.T) {
+	dockerRT := Runtime{Name: Docker}
+	args, err := dockerRT.PruneArgs(Images, Filters{Until: 48 * time.Hour, Labels: []string{"env=dev"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"image", "prune", "-a", "-f", "--filter", "until=48h0m0s", "--filter", "label=env=dev"}, args)
+
+	args, err = dockerRT.PruneArgs(Volumes, Filters{AllVolumes: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"volume", "prune", "-f", "--all"}, args)
+}
+
+func TestPruneArgsPerRuntime(t *testing.T) {
+	podman := Runtime{Name: Podman}
+	_, err := podman.PruneArgs(Volumes, Filters{})
+	assert.Error(t, err, "podman cannot spare named volumes")
+	args, err := podman.PruneArgs(Volumes, Filters{AllVolumes: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"volume", "prune", "-f"}, args, "podman has no --all")
+	_, err = podman.PruneArgs(BuildCache, Filters{})
+	assert.Error(t, err)
+	args, err = podman.PruneArgs(Containers, Filters{Until: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"container", "prune", "-f", "--filter", "until=1h0m0s"}, args)
+
+	nerdctl := Runtime{Name: Nerdctl}
+	args, err = nerdctl.PruneArgs(BuildCache, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"builder", "prune", "-a", "-f"}, args)
+	_, err = nerdctl.PruneArgs(Images, Filters{Labels: []string{"env=dev"}})
+	assert.Error(t, err)
+}
+
+func TestParsePodmanDiskUsage(t *testing.T) {
+	images := `[
+	  {"Id":"aaaaaaaaaaaaaaaa","Names":["localhost:5000/old:1.0","docker.io/library/old"],"Created":1577872800,"Size":1500000000,"Containers":0},
+	  {"Id":"bbbbbbbbbbbbbbbb","Created":1577872800,"Size":20000,"Containers":1}
+	]`
+	containers := `[
+	  {"Id":"c1","Names":["stopped"],"State":"exited","Created":1577872800,"Labels":{"team":"a","env":"dev"},"Size":{"rootFsSize":100000000,"rwSize":12000000}}
+	]`
+	volumes := `[{"Name":"pgdata","Labels":{}}]`
+
+	du, err := parsePodmanDiskUsage([]byte(images), []byte(containers), []byte(volumes))
+	require.NoError(t, err)
+	require.Len(t, du.Images, 3, "one row per name")
+	assert.Equal(t, "localhost:5000/old", du.Images[0].Repository)
+	assert.Equal(t, "1.0", du.Images[0].Tag)
+	assert.Equal(t, "latest", du.Images[1].Tag)
+	assert.Equal(t, "<none>", du.Images[2].Repository)
+
+	c := &fakeClient{usage: du}
+	ctx := context.Background()
+	items, err := Preview(ctx, c, Images, Filters{Until: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost:5000/old:1.0, docker.io/library/old:latest"}, names(items))
+	assert.Equal(t, uint64(1_500_000_000), items[0].Size)
+
+	items, err = Preview(ctx, c, Containers, Filters{Labels: []string{"env=dev"}})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, uint64(12_000_000), items[0].Size, "the writable layer, not the image")
+
+	items, err = Preview(ctx, c, Volumes, Filters{AllVolumes: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pgdata"}, names(items))
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]uint64{
+		"0B":                 0,
+		"512B":               512,
+		"20kB":               20_000,
+		"1.5GB":              1_500_000_000,
+		"12MB (virtual 1GB)": 12_000_000,
+		"3.2TB":              3_200_000_000_000,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := ParseSize("N/A")
+	assert.Error(t, err)
+}
+
+func TestParseReclaimed(t *testing.T) {
+	out := "Deleted Images:\nuntagged: old:1.0\n\nTotal reclaimed space: 1.5GB\n"
+	assert.Equal(t, uint64(1_500_000_000), parseReclaimed(out))
+	assert.Zero(t, parseReclaimed("nothing"))
+}
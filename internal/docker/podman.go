@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// podmanImage is one entry of `podman images --format json`.
+type podmanImage struct {
+	ID         string            `json:"Id"`
+	Names      []string          `json:"Names"`
+	Created    int64             `json:"Created"`
+	Size       int64             `json:"Size"`
+	Containers int               `json:"Containers"`
+	Labels     map[string]string `json:"Labels"`
+}
+
+// podmanContainer is one entry of `podman ps -a --size --format json`.
+type podmanContainer struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	State   string            `json:"State"`
+	Created int64             `json:"Created"`
+	Labels  map[string]string `json:"Labels"`
+	Size    *struct {
+		RwSize int64 `json:"rwSize"`
+	} `json:"Size"`
+}
+
+// podmanVolume is one entry of `podman volume ls --format json`.
+type podmanVolume struct {
+	Name   string            `json:"Name"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// podmanDiskUsage builds a DiskUsage from podman's JSON listings.
+func (c *CLIClient) podmanDiskUsage(ctx context.Context) (*DiskUsage, error) {
+	images, err := c.run(ctx, "images", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	containers, err := c.run(ctx, "ps", "-a", "--size", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := c.run(ctx, "volume", "ls", "--filter", "dangling=true", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	return parsePodmanDiskUsage(images, containers, volumes)
+}
+
+// parsePodmanDiskUsage converts podman's listings into the display text
+// `docker system df -v` would print. volumes lists dangling volumes only,
+// and podman does not size them, so they count as empty.
+func parsePodmanDiskUsage(images, containers, volumes []byte) (*DiskUsage, error) {
+	var (
+		pi []podmanImage
+		pc []podmanContainer
+		pv []podmanVolume
+	)
+	if err := json.Unmarshal(images, &pi); err != nil {
+		return nil, fmt.Errorf("unexpected podman images output: %w", err)
+	}
+	if err := json.Unmarshal(containers, &pc); err != nil {
+		return nil, fmt.Errorf("unexpected podman ps output: %w", err)
+	}
+	if err := json.Unmarshal(volumes, &pv); err != nil {
+		return nil, fmt.Errorf("unexpected podman volume ls output: %w", err)
+	}
+
+	var du DiskUsage
+	for _, img := range pi {
+		row := ImageUsage{
+			ID:         img.ID,
+			CreatedAt:  unixTime(img.Created),
+			Size:       fmt.Sprintf("%dB", img.Size),
+			Containers: strconv.Itoa(img.Containers),
+		}
+		if len(img.Names) == 0 {
+			row.Repository, row.Tag = "<none>", "<none>"
+			du.Images = append(du.Images, row)
+			continue
+		}
+		for _, name := range img.Names {
+			// The tag follows the last colon after the last slash, so a
+			// registry port is not mistaken for one.
+			row.Repository, row.Tag = name, "latest"
+			if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+				row.Repository, row.Tag = name[:i], name[i+1:]
+			}
+			du.Images = append(du.Images, row)
+		}
+	}
+	for _, ctr := range pc {
+		var size int64
+		if ctr.Size != nil {
+			size = ctr.Size.RwSize
+		}
+		du.Containers = append(du.Containers, ContainerUsage{
+			ID:        ctr.ID,
+			Names:     strings.Join(ctr.Names, ","),
+			Image:     ctr.Image,
+			CreatedAt: unixTime(ctr.Created),
+			State:     ctr.State,
+			Size:      fmt.Sprintf("%dB", size),
+			Labels:    formatLabels(ctr.Labels),
+		})
+	}
+	for _, v := range pv {
+		du.Volumes = append(du.Volumes, VolumeUsage{
+			Name:   v.Name,
+			Links:  "0",
+			Size:   "0B",
+			Labels: formatLabels(v.Labels),
+		})
+	}
+	return &du, nil
+}
+
+func unixTime(sec int64) string {
+	return time.Unix(sec, 0).UTC().Format(time.RFC3339Nano)
+}
+
+// formatLabels writes labels as docker's "k=v,k2=v2" text, sorted.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
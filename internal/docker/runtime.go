@@ -0,0 +1,186 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// Runtime names.
+const (
+	Docker  = "docker"
+	Podman  = "podman"
+	Nerdctl = "nerdctl"
+)
+
+// RuntimeNames are the runtimes Detect looks for, in order.
+var RuntimeNames = []string{Docker, Podman, Nerdctl}
+
+// ErrNoRuntime is returned when no container runtime is installed.
+var ErrNoRuntime = errors.New("no container runtime found (docker, podman or nerdctl)")
+
+// Runtime is one container runtime's CLI and the storage it manages. The
+// same binary manages different storage rootful and rootless, so a host can
+// have several Runtimes with one Name.
+type Runtime struct {
+	Name   string // Docker, Podman or Nerdctl
+	Binary string // path to the CLI
+	// User, when set, runs the CLI as this user, to reach their rootless
+	// storage while moonbit runs as root under sudo.
+	User *user.User
+	// Rootless and StorageRoot are filled in by Detect from the runtime's
+	// own `info`; StorageRoot is empty if that failed.
+	Rootless    bool
+	StorageRoot string
+}
+
+// String names the runtime and whose storage it manages.
+func (rt Runtime) String() string {
+	mode := "rootful"
+	if rt.Rootless {
+		mode = "rootless"
+	}
+	if rt.User != nil {
+		mode += ", user " + rt.User.Username
+	}
+	if rt.StorageRoot != "" {
+		return fmt.Sprintf("%s (%s, %s)", rt.Name, mode, rt.StorageRoot)
+	}
+	return fmt.Sprintf("%s (%s)", rt.Name, mode)
+}
+
+// command builds the exec.Cmd running the CLI with args, as rt.User if set.
+// Rootless podman and nerdctl find their storage through XDG_RUNTIME_DIR
+// and HOME, which sudo does not carry over, so they are set explicitly.
+func (rt Runtime) command(ctx context.Context, args ...string) *exec.Cmd {
+	if rt.User == nil {
+		return exec.CommandContext(ctx, rt.Binary, args...)
+	}
+	full := append([]string{"-u", rt.User.Username, "--", "env",
+		"HOME=" + rt.User.HomeDir,
+		"XDG_RUNTIME_DIR=/run/user/" + rt.User.Uid,
+		rt.Binary}, args...)
+	return exec.CommandContext(ctx, "runuser", full...)
+}
+
+// Detect finds the installed runtimes named in names, every one of
+// RuntimeNames if names is empty, and asks each where its storage is. A
+// runtime whose daemon is down is still returned; Ping reports that.
+//
+// Running as root under sudo, podman and nerdctl are also returned as the
+// invoking user, whose rootless storage root cannot otherwise see.
+func Detect(ctx context.Context, names ...string) ([]Runtime, error) {
+	if len(names) == 0 {
+		names = RuntimeNames
+	}
+	var invoker *user.User
+	if os.Geteuid() == 0 {
+		if name := os.Getenv("SUDO_USER"); name != "" && name != "root" {
+			invoker, _ = user.Lookup(name)
+		}
+	}
+
+	var found []Runtime
+	for _, name := range names {
+		if !isRuntimeName(name) {
+			return nil, fmt.Errorf("unknown container runtime %q; want %s", name, strings.Join(RuntimeNames, ", "))
+		}
+		binary, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		rt := Runtime{Name: name, Binary: binary}
+		rt.describe(ctx)
+		found = append(found, rt)
+
+		if invoker != nil && name != Docker {
+			// Rootless docker needs its own daemon per user; rootless
+			// podman and nerdctl only need the user's environment.
+			rootless := Runtime{Name: name, Binary: binary, User: invoker}
+			rootless.describe(ctx)
+			if rootless.Rootless && rootless.StorageRoot != "" && rootless.StorageRoot != rt.StorageRoot {
+				found = append(found, rootless)
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, ErrNoRuntime
+	}
+	return found, nil
+}
+
+func isRuntimeName(name string) bool {
+	for _, n := range RuntimeNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// describe fills in Rootless and StorageRoot from the runtime's `info`.
+func (rt *Runtime) describe(ctx context.Context) {
+	var format string
+	switch rt.Name {
+	case Podman:
+		format = "{{.Host.Security.Rootless}} {{.Store.GraphRoot}}"
+	default:
+		// docker and nerdctl list "name=rootless" among the security options.
+		format = "{{range .SecurityOptions}}{{if eq . \"name=rootless\"}}true{{end}}{{end}} {{.DockerRootDir}}"
+	}
+	out, err := rt.command(ctx, "info", "--format", format).Output()
+	if err != nil {
+		// Guess: podman and nerdctl run by a user are rootless; docker
+		// users usually reach the rootful daemon through the docker group.
+		rt.Rootless = rt.User != nil || (os.Geteuid() != 0 && rt.Name != Docker)
+		return
+	}
+	rootless, root, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+	rt.Rootless = rootless == "true"
+	rt.StorageRoot = strings.TrimSpace(root)
+}
+
+// PruneArgs returns the CLI arguments that prune r with f, or an error when
+// this runtime cannot prune r that way.
+func (rt Runtime) PruneArgs(r Resource, f Filters) ([]string, error) {
+	if err := f.Validate(r); err != nil {
+		return nil, err
+	}
+	var args []string
+	switch r {
+	case Containers:
+		args = []string{"container", "prune", "-f"}
+	case Images:
+		args = []string{"image", "prune", "-a", "-f"}
+	case Volumes:
+		args = []string{"volume", "prune", "-f"}
+		switch {
+		case rt.Name == Podman && !f.AllVolumes:
+			// podman has no anonymous-only volume prune.
+			return nil, fmt.Errorf("podman prunes named volumes too; pass the flag for named volumes to confirm")
+		case rt.Name != Podman && f.AllVolumes:
+			args = append(args, "--all")
+		}
+	case BuildCache:
+		if rt.Name == Podman {
+			return nil, fmt.Errorf("podman keeps build layers as images; prune images instead")
+		}
+		args = []string{"builder", "prune", "-a", "-f"}
+	case Networks:
+		args = []string{"network", "prune", "-f"}
+	}
+	if (f.Until > 0 || len(f.Labels) > 0) && rt.Name == Nerdctl {
+		return nil, fmt.Errorf("nerdctl prune does not take --until or --label filters")
+	}
+	if f.Until > 0 {
+		args = append(args, "--filter", "until="+f.Until.String())
+	}
+	for _, label := range f.Labels {
+		args = append(args, "--filter", "label="+label)
+	}
+	return args, nil
+}
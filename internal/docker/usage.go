@@ -0,0 +1,282 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiskUsage is the verbose output of `docker system df -v --format json`.
+// Docker reports every field as display text, which Preview parses.
+type DiskUsage struct {
+	Images     []ImageUsage      `json:"Images"`
+	Containers []ContainerUsage  `json:"Containers"`
+	Volumes    []VolumeUsage     `json:"Volumes"`
+	BuildCache []BuildCacheUsage `json:"BuildCache"`
+}
+
+// ImageUsage is one image row. An image with several tags has one row each.
+type ImageUsage struct {
+	ID         string `json:"ID"`
+	Repository string `json:"Repository"`
+	Tag        string `json:"Tag"`
+	CreatedAt  string `json:"CreatedAt"`
+	Size       string `json:"Size"`
+	Containers string `json:"Containers"`
+}
+
+// ContainerUsage is one container row.
+type ContainerUsage struct {
+	ID        string `json:"ID"`
+	Names     string `json:"Names"`
+	Image     string `json:"Image"`
+	CreatedAt string `json:"CreatedAt"`
+	State     string `json:"State"`
+	Size      string `json:"Size"`
+	Labels    string `json:"Labels"`
+}
+
+// VolumeUsage is one volume row.
+type VolumeUsage struct {
+	Name   string `json:"Name"`
+	Links  string `json:"Links"`
+	Size   string `json:"Size"`
+	Labels string `json:"Labels"`
+}
+
+// BuildCacheUsage is one build cache record.
+type BuildCacheUsage struct {
+	ID          string `json:"ID"`
+	CacheType   string `json:"CacheType"`
+	Description string `json:"Description"`
+	CreatedAt   string `json:"CreatedAt"`
+	LastUsedAt  string `json:"LastUsedAt"`
+	InUse       string `json:"InUse"`
+	Size        string `json:"Size"`
+}
+
+// ParseDiskUsage decodes `docker system df -v --format json` output.
+func ParseDiskUsage(data []byte) (*DiskUsage, error) {
+	var du DiskUsage
+	if err := json.Unmarshal(data, &du); err != nil {
+		return nil, fmt.Errorf("unexpected docker system df output: %w", err)
+	}
+	return &du, nil
+}
+
+// Item is one object a prune would remove.
+type Item struct {
+	Resource Resource
+	ID       string
+	Name     string
+	Size     uint64
+}
+
+// Preview lists what pruning r with f would remove, largest first, from
+// the disk usage c reports. Networks take no space and are not listed.
+// The sizes are the runtime's own and include layers shared with images
+// that stay, so the total is an upper bound. Runtimes without disk usage
+// return ErrNoDiskUsage.
+func Preview(ctx context.Context, c Client, r Resource, f Filters) ([]Item, error) {
+	if err := f.Validate(r); err != nil {
+		return nil, err
+	}
+	if r == Networks {
+		return nil, nil
+	}
+	du, err := c.DiskUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var imageLabels map[string]map[string]string
+	if r == Images && len(f.Labels) > 0 {
+		var ids []string
+		for _, img := range du.Images {
+			ids = append(ids, img.ID)
+		}
+		if imageLabels, err = c.ImageLabels(ctx, uniq(ids)); err != nil {
+			return nil, err
+		}
+	}
+
+	items := candidates(du, r, f, imageLabels, time.Now())
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Size > items[j].Size })
+	return items, nil
+}
+
+// candidates applies docker's prune rules and f to du.
+func candidates(du *DiskUsage, r Resource, f Filters, imageLabels map[string]map[string]string, now time.Time) []Item {
+	olderThan := func(created string) bool {
+		if f.Until == 0 {
+			return true
+		}
+		t, ok := parseTime(created)
+		return ok && t.Before(now.Add(-f.Until))
+	}
+
+	var items []Item
+	switch r {
+	case Containers:
+		for _, c := range du.Containers {
+			switch c.State {
+			case "running", "paused", "restarting":
+				continue
+			}
+			if !olderThan(c.CreatedAt) || !matchLabels(parseLabels(c.Labels), f.Labels) {
+				continue
+			}
+			size, _ := ParseSize(c.Size)
+			items = append(items, Item{Resource: r, ID: c.ID, Name: c.Names, Size: size})
+		}
+
+	case Images:
+		index := make(map[string]int)
+		for _, img := range du.Images {
+			if img.Containers != "0" || !olderThan(img.CreatedAt) {
+				continue
+			}
+			if len(f.Labels) > 0 && !matchLabels(imageLabels[ShortID(img.ID)], f.Labels) {
+				continue
+			}
+			name := img.Repository + ":" + img.Tag
+			if img.Repository == "<none>" {
+				name = "<none>"
+			}
+			if i, ok := index[img.ID]; ok {
+				items[i].Name += ", " + name
+				continue
+			}
+			size, _ := ParseSize(img.Size)
+			index[img.ID] = len(items)
+			items = append(items, Item{Resource: r, ID: img.ID, Name: name, Size: size})
+		}
+
+	case Volumes:
+		for _, v := range du.Volumes {
+			labels := parseLabels(v.Labels)
+			if v.Links != "0" || !matchLabels(labels, f.Labels) {
+				continue
+			}
+			if !f.AllVolumes && !isAnonymousVolume(v.Name, labels) {
+				continue
+			}
+			size, _ := ParseSize(v.Size)
+			items = append(items, Item{Resource: r, ID: v.Name, Name: v.Name, Size: size})
+		}
+
+	case BuildCache:
+		for _, b := range du.BuildCache {
+			if b.InUse == "true" {
+				continue
+			}
+			used := b.LastUsedAt
+			if _, ok := parseTime(used); !ok {
+				used = b.CreatedAt
+			}
+			if !olderThan(used) {
+				continue
+			}
+			size, _ := ParseSize(b.Size)
+			items = append(items, Item{Resource: r, ID: b.ID, Name: b.Description, Size: size})
+		}
+	}
+	return items
+}
+
+// anonymousVolumeName is the random name docker gives unnamed volumes.
+var anonymousVolumeName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func isAnonymousVolume(name string, labels map[string]string) bool {
+	if _, ok := labels["com.docker.volume.anonymous"]; ok {
+		return true
+	}
+	return anonymousVolumeName.MatchString(name)
+}
+
+// parseLabels reads docker's "k=v,k2=v2" label text.
+func parseLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		labels[k] = v
+	}
+	return labels
+}
+
+// matchLabels reports whether labels satisfy every "key" or "key=value"
+// filter, as docker's label filter does.
+func matchLabels(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		k, v, hasValue := strings.Cut(filter, "=")
+		got, ok := labels[k]
+		if !ok || (hasValue && got != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// timeLayouts are the formats docker prints times in.
+var timeLayouts = []string{"2006-01-02 15:04:05 -0700 MST", time.RFC3339Nano}
+
+func parseTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ShortID is an ID without its "sha256:" prefix, cut to the 12 characters
+// docker shows.
+func ShortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
+
+func uniq(ss []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+var sizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([kKMGTP]?)i?B`)
+
+// ParseSize reads a size as docker prints it: decimal units such as
+// "1.2GB" or "512kB". Container sizes like "2B (virtual 100MB)" give the
+// leading figure.
+func ParseSize(s string) (uint64, error) {
+	m := sizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid docker size %q", s)
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid docker size %q", s)
+	}
+	multiplier := 1.0
+	if m[2] != "" {
+		multiplier = map[string]float64{"k": 1e3, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15}[m[2]]
+	}
+	return uint64(value * multiplier), nil
+}
@@ -0,0 +1,221 @@
+package duplicates
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/paths"
+)
+
+// HashCacheVersion is the on-disk format of the hash cache.
+const HashCacheVersion = 1
+
+// cacheRacyWindow guards against timestamps too coarse to tell two writes
+// apart: a file modified this close to when it was hashed may have changed
+// again within the same tick, so its hashes are not reused.
+const cacheRacyWindow = 2 * time.Second
+
+// CachedHash is what the cache remembers about one file, identified by device
+// and inode. Its hashes are reused only while size and mtime still match.
+type CachedHash struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mtime"`     // UnixNano
+	HashedAt int64  `json:"hashed_at"` // UnixNano
+	Partial  string `json:"partial,omitempty"`
+	Full     string `json:"full,omitempty"`
+}
+
+// HashCache remembers partial and full hashes between duplicate scans, so a
+// repeat scan of the same trees only reads files that changed.
+//
+// Nothing read from the cache is trusted for destructive work: dedupe
+// compares files byte by byte before replacing one, and when run as root a
+// cache not owned by root is ignored.
+type HashCache struct {
+	Version int                    `json:"version"`
+	Files   map[string]*CachedHash `json:"files"` // keyed by "dev:inode"
+
+	mu     sync.Mutex
+	seen   map[string]bool
+	hits   int
+	misses int
+}
+
+// hashKind selects which of a file's hashes is wanted.
+type hashKind int
+
+const (
+	partialKind hashKind = iota
+	fullKind
+)
+
+// HashCachePath is where the hash cache is kept.
+func HashCachePath() (string, error) {
+	return paths.DataDir("duplicates", "hashes.json")
+}
+
+// NewHashCache returns an empty cache.
+func NewHashCache() *HashCache {
+	return &HashCache{Version: HashCacheVersion, Files: make(map[string]*CachedHash), seen: make(map[string]bool)}
+}
+
+// LoadHashCache reads a cache from path. A missing file yields an empty
+// cache. An unreadable, incompatible or untrusted file also yields an empty
+// cache, along with an error saying why, so callers can warn and carry on.
+func LoadHashCache(path string) (*HashCache, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewHashCache(), nil
+		}
+		return NewHashCache(), fmt.Errorf("failed to read hash cache: %w", err)
+	}
+	defer f.Close()
+
+	if os.Geteuid() == 0 {
+		if info, err := f.Stat(); err == nil {
+			if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid != 0 {
+				return NewHashCache(), fmt.Errorf("ignoring hash cache %s: not owned by root", path)
+			}
+		}
+	}
+
+	var cache HashCache
+	if err := json.NewDecoder(f).Decode(&cache); err != nil {
+		return NewHashCache(), fmt.Errorf("failed to parse hash cache: %w", err)
+	}
+	if cache.Version != HashCacheVersion {
+		return NewHashCache(), fmt.Errorf("hash cache format v%d is not supported; rebuilding", cache.Version)
+	}
+
+	clean := NewHashCache()
+	for key, entry := range cache.Files {
+		if entry != nil && filepath.IsAbs(entry.Path) {
+			clean.Files[key] = entry
+		}
+	}
+	return clean, nil
+}
+
+// Save prunes the cache and writes it to path through a temporary file, so
+// an interrupted save never leaves a truncated cache behind.
+func (c *HashCache) Save(path string) error {
+	c.Prune()
+
+	c.mu.Lock()
+	data, err := json.Marshal(c)
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal hash cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create hash cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	return nil
+}
+
+// Prune drops entries for files that have disappeared or changed since they
+// were hashed. Files looked up since the cache was loaded are known to be
+// current and are not checked again. It returns the number dropped.
+func (c *HashCache) Prune() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dropped := 0
+	for key, entry := range c.Files {
+		if c.seen[key] {
+			continue
+		}
+		info, err := os.Lstat(entry.Path)
+		if err != nil || !info.Mode().IsRegular() || cacheKey(info) != key ||
+			info.Size() != entry.Size || info.ModTime().UnixNano() != entry.ModTime {
+			delete(c.Files, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Stats reports how many hashes were reused from the cache and how many had
+// to be computed since it was loaded.
+func (c *HashCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// hash returns f's hash of the given kind from the cache, or computes and
+// records it. With rehash, cached hashes are ignored but fresh ones are
+// still recorded. A nil cache always computes.
+func (c *HashCache) hash(f FileInfo, kind hashKind, rehash bool, compute func() (string, error)) (string, error) {
+	if c == nil || f.Inode == 0 {
+		return compute()
+	}
+	key := fmt.Sprintf("%d:%d", f.Device, f.Inode)
+
+	c.mu.Lock()
+	c.seen[key] = true
+	entry := c.Files[key]
+	if entry != nil && (entry.Size != f.Size || entry.ModTime != f.modTimeNano) {
+		entry = nil
+	}
+	if entry != nil && !rehash && f.modTimeNano < entry.HashedAt-int64(cacheRacyWindow) {
+		cached := entry.Partial
+		if kind == fullKind {
+			cached = entry.Full
+		}
+		if cached != "" {
+			c.hits++
+			c.mu.Unlock()
+			return cached, nil
+		}
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	hashedAt := time.Now().UnixNano()
+	sum, err := compute()
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry = c.Files[key]
+	if entry == nil || entry.Size != f.Size || entry.ModTime != f.modTimeNano {
+		entry = &CachedHash{Size: f.Size, ModTime: f.modTimeNano, HashedAt: hashedAt}
+		c.Files[key] = entry
+	}
+	// A hardlink may be reached under another name; remember the latest.
+	entry.Path = f.Path
+	if kind == fullKind {
+		entry.Full = sum
+	} else {
+		entry.Partial = sum
+	}
+	return sum, nil
+}
+
+// cacheKey identifies a file by device and inode.
+func cacheKey(info os.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", uint64(st.Dev), uint64(st.Ino))
+}
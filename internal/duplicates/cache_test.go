@@ -0,0 +1,133 @@
+package duplicates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeAged writes a file whose mtime is safely outside the racy window.
+func writeAged(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashCacheReusesHashesOfUnchangedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	big := make([]byte, 3*PartialHashSize)
+	for _, name := range []string{"a", "b", "c"} {
+		writeAged(t, filepath.Join(tmpDir, name), big)
+	}
+	cachePath := filepath.Join(t.TempDir(), "hashes.json")
+	opts := ScanOptions{Paths: []string{tmpDir}, MinSize: 1}
+
+	cache, err := LoadHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Cache = cache
+	first := scanDir(t, opts)
+	if first.Stages.CacheHits != 0 {
+		t.Errorf("Expected no hits on an empty cache, got %d", first.Stages.CacheHits)
+	}
+	if err := cache.Save(cachePath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Change one file; the other two hash from the cache.
+	changed := append([]byte{}, big...)
+	changed[len(changed)/2] = 1
+	writeAged(t, filepath.Join(tmpDir, "c"), changed)
+	later := time.Now().Add(-30 * time.Minute)
+	if err := os.Chtimes(filepath.Join(tmpDir, "c"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = LoadHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Cache = cache
+	second := scanDir(t, opts)
+	// a and b: partial and full from cache; c: partial and full recomputed.
+	if second.Stages.CacheHits != 4 {
+		t.Errorf("Expected 4 cache hits, got %d", second.Stages.CacheHits)
+	}
+	if len(second.Groups) != 1 || len(second.Groups[0].Files) != 2 {
+		t.Fatalf("Expected a and b as the only duplicates, got %+v", second.Groups)
+	}
+
+	opts.Rehash = true
+	third := scanDir(t, opts)
+	if third.Stages.CacheHits != 0 {
+		t.Errorf("Expected --rehash to bypass the cache, got %d hits", third.Stages.CacheHits)
+	}
+}
+
+func TestHashCacheIgnoresRecentlyModifiedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("fresh content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := ScanOptions{Paths: []string{tmpDir}, MinSize: 1, Cache: NewHashCache()}
+	scanDir(t, opts)
+	second := scanDir(t, opts)
+	if second.Stages.CacheHits != 0 {
+		t.Errorf("Expected files modified within the racy window to be rehashed, got %d hits", second.Stages.CacheHits)
+	}
+}
+
+func TestHashCachePruneDropsMissingFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		writeAged(t, filepath.Join(tmpDir, name), []byte("same content"))
+	}
+	cachePath := filepath.Join(t.TempDir(), "hashes.json")
+	cache := NewHashCache()
+	scanDir(t, ScanOptions{Paths: []string{tmpDir}, MinSize: 1, Cache: cache})
+	if err := cache.Save(cachePath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Files) != 2 {
+		t.Fatalf("Expected 2 cached files, got %d", len(loaded.Files))
+	}
+	if dropped := loaded.Prune(); dropped != 1 {
+		t.Errorf("Expected 1 entry dropped, got %d", dropped)
+	}
+	for _, entry := range loaded.Files {
+		if entry.Path != filepath.Join(tmpDir, "b") {
+			t.Errorf("Expected only b to remain, got %s", entry.Path)
+		}
+	}
+}
+
+func TestLoadHashCacheRejectsOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "files": {}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := LoadHashCache(path)
+	if err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+	if cache == nil || len(cache.Files) != 0 {
+		t.Error("Expected an empty cache to carry on with")
+	}
+}
@@ -1,14 +1,17 @@
 package duplicates
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"regexp"
 	"sort"
-	"sync"
+	"syscall"
+
+	"github.com/Nomadcxx/moonbit/internal/scanner"
 )
 
 // FileInfo represents a file with metadata
@@ -17,23 +20,83 @@ type FileInfo struct {
 	Size    int64
 	Hash    string
 	ModTime int64
+	// Device and Inode identify the file on disk; zero when unknown.
+	Device uint64
+	Inode  uint64
+	// Links are the file's other names found by the scan, and Nlink its
+	// link count. A file is only freed once every name is removed.
+	Links []string
+	Nlink uint64
+
+	modTimeNano int64
+}
+
+// Paths returns every name the scan found for the file.
+func (f FileInfo) Paths() []string {
+	return append([]string{f.Path}, f.Links...)
+}
+
+// Reclaimable is the space removing every name in Paths frees: the file's
+// size, or nothing when it has other names outside the scanned paths.
+func (f FileInfo) Reclaimable() int64 {
+	if f.Nlink > uint64(1+len(f.Links)) {
+		return 0
+	}
+	return f.Size
 }
 
 // DuplicateGroup represents a group of duplicate files
 type DuplicateGroup struct {
-	Hash      string
-	Size      int64
-	Files     []FileInfo
-	TotalSize int64 // Size * (count - 1), space that can be freed
+	Hash  string
+	Size  int64
+	Files []FileInfo // files to keep first, as chosen by the KeepPolicy
+	// KeepCount is how many of Files are kept; at least one.
+	KeepCount int
+	TotalSize int64 // space freed by removing every removable copy
+}
+
+// Kept returns the files the keep policy keeps.
+func (g DuplicateGroup) Kept() []FileInfo {
+	return g.Files[:g.keepCount()]
+}
+
+// Removable returns the redundant copies.
+func (g DuplicateGroup) Removable() []FileInfo {
+	return g.Files[g.keepCount():]
+}
+
+func (g DuplicateGroup) keepCount() int {
+	if g.KeepCount < 1 {
+		return min(1, len(g.Files))
+	}
+	return min(g.KeepCount, len(g.Files))
 }
 
 // ScanOptions controls duplicate scanning behavior
 type ScanOptions struct {
-	Paths          []string
-	MinSize        int64 // Minimum file size to consider (default: 1KB)
-	MaxSize        int64 // Maximum file size to consider (0 = unlimited)
+	Paths   []string
+	MinSize int64 // Minimum file size to consider (default: 1KB)
+	MaxSize int64 // Maximum file size to consider (0 = unlimited)
+	// IgnorePatterns are regular expressions matched anywhere in a full
+	// path, the same as scan.ignore_patterns. A matching directory is not
+	// descended into.
 	IgnorePatterns []string
-	MaxDepth       int
+	// MaxDepth limits how many directory levels below each path are read:
+	// 1 reads only the files directly in it. Zero means DefaultMaxDepth and
+	// a negative value means no limit.
+	MaxDepth int
+	// FS is the filesystem to scan; nil means the real one. Cache and
+	// hardlink detection only apply on the real filesystem.
+	FS scanner.FileSystem
+	// Verify compares hash matches byte by byte before reporting them.
+	Verify bool
+	// Cache, when set, supplies hashes of unchanged files from earlier scans
+	// and records new ones. Rehash ignores what it holds but still records.
+	Cache  *HashCache
+	Rehash bool
+	// Keep chooses which copy in each group is kept. The zero value keeps
+	// the oldest.
+	Keep KeepPolicy
 }
 
 // ScanProgress reports scanning progress
@@ -51,6 +114,12 @@ type ScanResult struct {
 	WastedSpace        int64
 	FilesScanned       int
 	DirectoriesScanned int
+	// HardlinkedFiles counts names found that are hardlinks to a file
+	// already seen, and HardlinkedSpace the space those links already save.
+	// Neither is part of the duplicates above.
+	HardlinkedFiles int
+	HardlinkedSpace int64
+	Stages          StageCounts
 }
 
 // Constants for duplicate scanning
@@ -74,9 +143,15 @@ const (
 
 // Scanner finds duplicate files
 type Scanner struct {
-	opts ScanOptions
+	opts      ScanOptions
+	fs        scanner.FileSystem
+	filter    *regexp.Regexp
+	filterErr error
 }
 
+// osFS is the real filesystem, for work that only makes sense there.
+var osFS scanner.FileSystem = &scanner.OsFileSystem{}
+
 // NewScanner creates a new duplicate file scanner
 func NewScanner(opts ScanOptions) *Scanner {
 	if opts.MinSize == 0 {
@@ -85,177 +160,155 @@ func NewScanner(opts ScanOptions) *Scanner {
 	if opts.MaxDepth == 0 {
 		opts.MaxDepth = DefaultMaxDepth
 	}
-	return &Scanner{opts: opts}
+	s := &Scanner{opts: opts, fs: opts.FS}
+	if s.fs == nil {
+		s.fs = osFS
+	}
+	s.filter, s.filterErr = scanner.CompileIgnorePatterns(opts.IgnorePatterns)
+	return s
 }
 
-// Scan finds duplicate files in the specified paths
-func (s *Scanner) Scan(progressCh chan<- ScanProgress) (*ScanResult, error) {
+// ignored reports whether path, or for a directory everything below it, is
+// excluded by IgnorePatterns.
+func (s *Scanner) ignored(path string) bool {
+	return s.filter != nil && s.filter.MatchString(path)
+}
+
+// Scan finds duplicate files in the specified paths. It stops early with
+// ctx's error when ctx is cancelled.
+func (s *Scanner) Scan(ctx context.Context, progressCh chan<- ScanProgress) (*ScanResult, error) {
 	defer close(progressCh)
 
+	if s.filterErr != nil {
+		return nil, fmt.Errorf("invalid ignore pattern: %w", s.filterErr)
+	}
+
 	// Phase 1: Collect all files and group by size
 	progressCh <- ScanProgress{Phase: "Collecting files..."}
 
-	sizeMap := make(map[int64][]FileInfo)
-	filesScanned := 0
-	bytesScanned := int64(0)
-	dirsScanned := 0
-
+	c := newCollector(ctx, s, progressCh)
 	for _, rootPath := range s.opts.Paths {
-		err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil // Skip errors, continue scanning
-			}
-
-			if info.IsDir() {
-				dirsScanned++
-				return nil
-			}
-
-			// Apply size filters
-			if info.Size() < s.opts.MinSize {
-				return nil
-			}
-			if s.opts.MaxSize > 0 && info.Size() > s.opts.MaxSize {
-				return nil
-			}
-
-			// Check ignore patterns
-			for _, pattern := range s.opts.IgnorePatterns {
-				matched, _ := filepath.Match(pattern, filepath.Base(path))
-				if matched {
-					return nil
-				}
-			}
-
-			fileInfo := FileInfo{
-				Path:    path,
-				Size:    info.Size(),
-				ModTime: info.ModTime().Unix(),
-			}
-
-			sizeMap[info.Size()] = append(sizeMap[info.Size()], fileInfo)
-			filesScanned++
-			bytesScanned += info.Size()
-
-			if filesScanned%ProgressUpdateInterval == 0 {
-				progressCh <- ScanProgress{
-					FilesScanned: filesScanned,
-					BytesScanned: bytesScanned,
-					CurrentFile:  path,
-					Phase:        "Collecting files...",
-				}
-			}
-
-			return nil
-		})
-
-		if err != nil {
-			return nil, fmt.Errorf("walk error: %w", err)
+		if err := c.walkRoot(rootPath); err != nil {
+			return nil, err
 		}
 	}
+	filesScanned := c.filesScanned
 
-	// Phase 2: Hash files with duplicate sizes
-	progressCh <- ScanProgress{
-		FilesScanned: filesScanned,
-		Phase:        "Computing hashes...",
+	sizeMap := make(map[int64][]FileInfo)
+	hardlinkedFiles := 0
+	hardlinkedSpace := int64(0)
+	for _, f := range c.files {
+		sizeMap[f.Size] = append(sizeMap[f.Size], *f)
+		hardlinkedFiles += len(f.Links)
+		hardlinkedSpace += f.Size * int64(len(f.Links))
 	}
 
-	hashMap := make(map[string][]FileInfo)
-	hashCount := 0
+	// Phase 2: Hash the heads and tails of same-size files. Small files go
+	// straight to the full hash, which reads no more.
+	var stages StageCounts
+	var hitsBefore int
+	if s.opts.Cache != nil {
+		hitsBefore, _ = s.opts.Cache.Stats()
+	}
+	var small, large []candidateGroup
+	for size, files := range sizeMap {
+		if len(files) < 2 {
+			continue // No duplicates possible
+		}
+		stages.SizeMatched += len(files)
+		g := candidateGroup{key: fmt.Sprint(size), files: files}
+		if size <= 2*PartialHashSize {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
 
-	// Use goroutines for parallel hashing
-	type hashJob struct {
-		files []FileInfo
-		size  int64
+	stages.PartialHashed = countFiles(large)
+	phase := fmt.Sprintf("Partial hashing %d of %d same-size files...", stages.PartialHashed, stages.SizeMatched)
+	progressCh <- ScanProgress{FilesScanned: filesScanned, Phase: phase}
+	large, err := hashStage(ctx, large, phase, func(f FileInfo) (string, error) {
+		return s.opts.Cache.hash(f, partialKind, s.opts.Rehash, func() (string, error) {
+			return partialHash(s.fs, f.Path, f.Size)
+		})
+	}, progressCh)
+	if err != nil {
+		return nil, err
 	}
 
-	jobs := make(chan hashJob, 100)
-	results := make(chan map[string][]FileInfo, 100)
-	var wg sync.WaitGroup
-
-	// Start worker goroutines for parallel hashing
-	numWorkers := DefaultHashWorkers
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			localHashMap := make(map[string][]FileInfo)
-
-			for job := range jobs {
-				if len(job.files) < 2 {
-					continue // No duplicates possible
-				}
-
-				for _, file := range job.files {
-					hash, err := hashFile(file.Path)
-					if err != nil {
-						continue // Skip files we can't hash
-					}
-
-					file.Hash = hash
-					localHashMap[hash] = append(localHashMap[hash], file)
-				}
-			}
+	// Phase 3: Fully hash what is left.
+	candidates := append(small, large...)
+	stages.FullHashed = countFiles(candidates)
+	phase = fmt.Sprintf("Full hashing %d files...", stages.FullHashed)
+	progressCh <- ScanProgress{FilesScanned: filesScanned, Phase: phase}
+	hashed, err := hashStage(ctx, candidates, phase, func(f FileInfo) (string, error) {
+		return s.opts.Cache.hash(f, fullKind, s.opts.Rehash, func() (string, error) {
+			return hashFile(s.fs, f.Path)
+		})
+	}, progressCh)
+	if err != nil {
+		return nil, err
+	}
 
-			results <- localHashMap
-		}()
+	if s.opts.Cache != nil {
+		hits, _ := s.opts.Cache.Stats()
+		stages.CacheHits = hits - hitsBefore
 	}
 
-	// Send jobs
-	go func() {
-		for size, files := range sizeMap {
-			if len(files) < 2 {
-				continue // No duplicates possible
-			}
-			jobs <- hashJob{files: files, size: size}
-		}
-		close(jobs)
-	}()
-
-	// Wait for all workers and close results
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Merge results
-	for localMap := range results {
-		for hash, files := range localMap {
-			hashMap[hash] = append(hashMap[hash], files...)
-		}
-		hashCount++
+	// Phase 4: Optionally compare byte by byte, guarding against hash
+	// collisions and files changed since they were hashed.
+	var sets [][]FileInfo
+	if s.opts.Verify {
+		stages.Verified = countFiles(hashed)
 		progressCh <- ScanProgress{
 			FilesScanned: filesScanned,
-			Phase:        fmt.Sprintf("Computing hashes... (%d groups)", hashCount),
+			Phase:        fmt.Sprintf("Verifying %d files byte by byte...", stages.Verified),
+		}
+	}
+	for _, g := range hashed {
+		for i := range g.files {
+			g.files[i].Hash = g.key
+		}
+		if s.opts.Verify {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			sets = append(sets, verifyGroup(s.fs, g.files)...)
+		} else {
+			sets = append(sets, g.files)
 		}
 	}
 
-	// Phase 3: Build duplicate groups
+	// Phase 5: Build duplicate groups
 	progressCh <- ScanProgress{Phase: "Building results..."}
 
 	var groups []DuplicateGroup
 	totalDupes := 0
 	wastedSpace := int64(0)
 
-	for hash, files := range hashMap {
+	for _, files := range sets {
 		if len(files) < 2 {
 			continue // Not a duplicate
 		}
 
-		// Sort files by modification time (oldest first)
-		sort.Slice(files, func(i, j int) bool {
-			return files[i].ModTime < files[j].ModTime
-		})
+		kept := s.opts.Keep.Apply(files)
+		removable := len(files) - kept
+		if removable == 0 {
+			continue // Every copy is protected
+		}
 
 		group := DuplicateGroup{
-			Hash:      hash,
+			Hash:      files[0].Hash,
 			Size:      files[0].Size,
 			Files:     files,
-			TotalSize: files[0].Size * int64(len(files)-1),
+			KeepCount: kept,
+		}
+		for _, f := range group.Removable() {
+			group.TotalSize += f.Reclaimable()
 		}
 
 		groups = append(groups, group)
-		totalDupes += len(files) - 1
+		totalDupes += removable
 		wastedSpace += group.TotalSize
 	}
 
@@ -269,13 +322,16 @@ func (s *Scanner) Scan(progressCh chan<- ScanProgress) (*ScanResult, error) {
 		TotalDupes:         totalDupes,
 		WastedSpace:        wastedSpace,
 		FilesScanned:       filesScanned,
-		DirectoriesScanned: dirsScanned,
+		DirectoriesScanned: c.dirsScanned,
+		HardlinkedFiles:    hardlinkedFiles,
+		HardlinkedSpace:    hardlinkedSpace,
+		Stages:             stages,
 	}, nil
 }
 
 // hashFile computes SHA256 hash of a file
-func hashFile(path string) (string, error) {
-	file, err := os.Open(path)
+func hashFile(fs scanner.FileSystem, path string) (string, error) {
+	file, err := fs.Open(path)
 	if err != nil {
 		return "", err
 	}
@@ -296,13 +352,22 @@ func RemoveDuplicates(filesToRemove []string) (int, int64, []string) {
 	var errors []string
 
 	for _, path := range filesToRemove {
-		info, err := os.Stat(path)
+		// Lstat: a symlink is never followed to remove what it points at.
+		info, err := os.Lstat(path)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", path, err))
 			continue
 		}
+		if !info.Mode().IsRegular() {
+			errors = append(errors, fmt.Sprintf("%s: not a regular file (mode %s)", path, info.Mode()))
+			continue
+		}
 
+		// Removing one name of a hardlinked file frees nothing.
 		size := info.Size()
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+			size = 0
+		}
 
 		if err := os.Remove(path); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", path, err))
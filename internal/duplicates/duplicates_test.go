@@ -1,9 +1,11 @@
 package duplicates
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewScanner(t *testing.T) {
@@ -36,7 +38,7 @@ func TestHashFile(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	hash1, err := hashFile(testFile)
+	hash1, err := hashFile(osFS, testFile)
 	if err != nil {
 		t.Fatalf("Failed to hash file: %v", err)
 	}
@@ -46,7 +48,7 @@ func TestHashFile(t *testing.T) {
 	}
 
 	// Hash same file again, should get same hash
-	hash2, err := hashFile(testFile)
+	hash2, err := hashFile(osFS, testFile)
 	if err != nil {
 		t.Fatalf("Failed to hash file second time: %v", err)
 	}
@@ -61,7 +63,7 @@ func TestHashFile(t *testing.T) {
 		t.Fatalf("Failed to create test file 2: %v", err)
 	}
 
-	hash3, err := hashFile(testFile2)
+	hash3, err := hashFile(osFS, testFile2)
 	if err != nil {
 		t.Fatalf("Failed to hash file 2: %v", err)
 	}
@@ -98,7 +100,7 @@ func TestScanNoDuplicates(t *testing.T) {
 		}
 	}()
 
-	result, err := scanner.Scan(progressCh)
+	result, err := scanner.Scan(context.Background(), progressCh)
 	if err != nil {
 		t.Fatalf("Scan failed: %v", err)
 	}
@@ -148,7 +150,7 @@ func TestScanWithDuplicates(t *testing.T) {
 		}
 	}()
 
-	result, err := scanner.Scan(progressCh)
+	result, err := scanner.Scan(context.Background(), progressCh)
 	if err != nil {
 		t.Fatalf("Scan failed: %v", err)
 	}
@@ -244,7 +246,7 @@ func TestScanOptionsMinSize(t *testing.T) {
 		}
 	}()
 
-	result, err := scanner.Scan(progressCh)
+	result, err := scanner.Scan(context.Background(), progressCh)
 	if err != nil {
 		t.Fatalf("Scan failed: %v", err)
 	}
@@ -254,3 +256,198 @@ func TestScanOptionsMinSize(t *testing.T) {
 		t.Errorf("Expected 1 file scanned (>= 1KB), got %d", result.FilesScanned)
 	}
 }
+
+func scanDir(t *testing.T, opts ScanOptions) *ScanResult {
+	t.Helper()
+	progressCh := make(chan ScanProgress, 10)
+	go func() {
+		for range progressCh {
+		}
+	}()
+	result, err := NewScanner(opts).Scan(context.Background(), progressCh)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	return result
+}
+
+func TestScanStagesNarrowCandidates(t *testing.T) {
+	tmpDir := t.TempDir()
+	size := 4 * PartialHashSize
+	write := func(name string, edit func([]byte)) {
+		data := make([]byte, size)
+		edit(data)
+		if err := os.WriteFile(filepath.Join(tmpDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a", func([]byte) {})
+	write("b", func([]byte) {})
+	write("head", func(d []byte) { d[0] = 1 })
+	write("tail", func(d []byte) { d[len(d)-1] = 1 })
+	write("middle", func(d []byte) { d[len(d)/2] = 1 }) // survives the partial hash
+	// Small files skip the partial hash.
+	if err := os.WriteFile(filepath.Join(tmpDir, "small1"), []byte("same small content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "small2"), []byte("same small content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := scanDir(t, ScanOptions{Paths: []string{tmpDir}, MinSize: 1, Verify: true})
+
+	want := StageCounts{SizeMatched: 7, PartialHashed: 5, FullHashed: 5, Verified: 4}
+	if result.Stages != want {
+		t.Errorf("Expected stages %+v, got %+v", want, result.Stages)
+	}
+	if len(result.Groups) != 2 {
+		t.Fatalf("Expected 2 duplicate groups, got %d", len(result.Groups))
+	}
+	for _, g := range result.Groups {
+		if len(g.Files) != 2 {
+			t.Errorf("Expected pairs, got %d files in group of size %d", len(g.Files), g.Size)
+		}
+	}
+}
+
+func TestPartialHashReadsHeadAndTail(t *testing.T) {
+	tmpDir := t.TempDir()
+	data := make([]byte, 3*PartialHashSize)
+	a := filepath.Join(tmpDir, "a")
+	b := filepath.Join(tmpDir, "b")
+	if err := os.WriteFile(a, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	data[PartialHashSize+1] = 1 // outside both ends
+	if err := os.WriteFile(b, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ha, err := partialHash(osFS, a, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := partialHash(osFS, b, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ha != hb {
+		t.Error("Expected a middle-only difference to pass the partial hash")
+	}
+	if same, _ := sameContents(osFS, a, b); same {
+		t.Error("Expected byte comparison to tell the files apart")
+	}
+}
+
+func TestVerifyGroupSplitsDifferentContents(t *testing.T) {
+	tmpDir := t.TempDir()
+	var files []FileInfo
+	for name, content := range map[string]string{"x1": "xxxx", "x2": "xxxx", "y1": "yyyy"} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, FileInfo{Path: path, Size: 4})
+	}
+
+	sets := verifyGroup(osFS, files)
+	if len(sets) != 2 {
+		t.Fatalf("Expected 2 sets, got %d", len(sets))
+	}
+	if len(sets[0])+len(sets[1]) != 3 {
+		t.Errorf("Expected every file placed, got %v", sets)
+	}
+}
+
+func TestScanTreatsHardlinksAsOneFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := []byte("hardlinked content")
+	a := filepath.Join(tmpDir, "a")
+	writeAged(t, a, content)
+	if err := os.Link(a, filepath.Join(tmpDir, "a-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(a, filepath.Join(tmpDir, "a-symlink")); err != nil {
+		t.Fatal(err)
+	}
+
+	result := scanDir(t, ScanOptions{Paths: []string{tmpDir}, MinSize: 1})
+	if len(result.Groups) != 0 || result.WastedSpace != 0 {
+		t.Errorf("Expected hardlinks and symlinks not to be duplicates, got %+v", result.Groups)
+	}
+	if result.HardlinkedFiles != 1 || result.HardlinkedSpace != int64(len(content)) {
+		t.Errorf("Expected 1 hardlink saving %d, got %d saving %d",
+			len(content), result.HardlinkedFiles, result.HardlinkedSpace)
+	}
+	if result.FilesScanned != 2 {
+		t.Errorf("Expected both names scanned and the symlink skipped, got %d", result.FilesScanned)
+	}
+}
+
+func TestScanReclaimsHardlinkedCopyOnlyWhenEveryNameIsFound(t *testing.T) {
+	tmpDir := t.TempDir()
+	outside := t.TempDir()
+	content := []byte("copied content")
+	keep := filepath.Join(tmpDir, "keep")
+	copyA := filepath.Join(tmpDir, "copy")
+	writeAged(t, keep, content)
+	writeAged(t, copyA, content)
+	older := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(keep, older, older); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(copyA, filepath.Join(tmpDir, "copy-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	result := scanDir(t, ScanOptions{Paths: []string{tmpDir}, MinSize: 1})
+	if len(result.Groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(result.Groups))
+	}
+	removable := result.Groups[0].Removable()
+	if len(removable) != 1 || len(removable[0].Paths()) != 2 {
+		t.Fatalf("Expected the copy with both its names, got %+v", removable)
+	}
+	if result.WastedSpace != int64(len(content)) {
+		t.Errorf("Expected %d reclaimable, got %d", len(content), result.WastedSpace)
+	}
+
+	// A third name outside the scanned tree keeps the copy alive.
+	if err := os.Link(copyA, filepath.Join(outside, "elsewhere")); err != nil {
+		t.Fatal(err)
+	}
+	result = scanDir(t, ScanOptions{Paths: []string{tmpDir}, MinSize: 1})
+	if result.WastedSpace != 0 {
+		t.Errorf("Expected nothing reclaimable, got %d", result.WastedSpace)
+	}
+}
+
+func TestRemoveDuplicatesDoesNotFollowSymlinksOrCountSharedInodes(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+	link := filepath.Join(tmpDir, "symlink")
+	hard := filepath.Join(tmpDir, "hard")
+	if err := os.WriteFile(target, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(target, hard); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, freed, errs := RemoveDuplicates([]string{link, hard})
+	if removed != 1 || freed != 0 {
+		t.Errorf("Expected only the hardlink removed, freeing nothing; got %d removed, %d freed", removed, freed)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected the symlink to be refused, got %v", errs)
+	}
+	if _, err := os.Lstat(link); err != nil {
+		t.Error("Expected the symlink to remain")
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Error("Expected the target to remain")
+	}
+}
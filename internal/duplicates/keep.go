@@ -0,0 +1,133 @@
+package duplicates
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// KeepRule orders the files of a duplicate group when choosing which copy to
+// keep.
+type KeepRule string
+
+const (
+	// KeepOldest keeps the least recently modified copy (the default).
+	KeepOldest KeepRule = "oldest"
+	// KeepNewest keeps the most recently modified copy.
+	KeepNewest KeepRule = "newest"
+	// KeepShortestPath keeps the copy with the shortest path.
+	KeepShortestPath KeepRule = "shortest-path"
+)
+
+// ParseKeepRule validates a --keep value.
+func ParseKeepRule(s string) (KeepRule, error) {
+	switch rule := KeepRule(s); rule {
+	case KeepOldest, KeepNewest, KeepShortestPath:
+		return rule, nil
+	}
+	return "", fmt.Errorf("keep rule must be %q, %q or %q, got %q", KeepOldest, KeepNewest, KeepShortestPath, s)
+}
+
+// KeepPolicy decides which files of a duplicate group are kept and which
+// may be removed. Every group keeps at least one file.
+type KeepPolicy struct {
+	// Rule picks the one copy to keep when nothing else decides. Empty means
+	// KeepOldest.
+	Rule KeepRule
+	// Prefer lists directories, most preferred first. A copy beneath one of
+	// them is kept over copies elsewhere, whatever Rule says.
+	Prefer []string
+	// Protect lists directories never deleted from. Every copy beneath one
+	// of them is kept.
+	Protect []string
+	// KeepMatching keeps every copy whose path matches one of these.
+	KeepMatching []*regexp.Regexp
+}
+
+// Apply orders files so that the ones to keep come first and returns how
+// many that is. Files matched by Protect or KeepMatching are all kept; if
+// there are none, the single best copy by Prefer and then Rule is kept.
+func (p KeepPolicy) Apply(files []FileInfo) int {
+	sort.SliceStable(files, func(i, j int) bool {
+		ki, kj := p.mustKeep(files[i]), p.mustKeep(files[j])
+		if ki != kj {
+			return ki
+		}
+		pi, pj := p.preference(files[i]), p.preference(files[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return p.before(files[i], files[j])
+	})
+
+	kept := 0
+	for kept < len(files) && p.mustKeep(files[kept]) {
+		kept++
+	}
+	if kept == 0 && len(files) > 0 {
+		kept = 1
+	}
+	return kept
+}
+
+// mustKeep reports whether any of f's names is protected or matches
+// KeepMatching.
+func (p KeepPolicy) mustKeep(f FileInfo) bool {
+	for _, path := range f.Paths() {
+		for _, dir := range p.Protect {
+			if underDir(path, dir) {
+				return true
+			}
+		}
+		for _, re := range p.KeepMatching {
+			if re.MatchString(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// preference is the index of the first Prefer directory holding one of f's
+// names, or len(Prefer) when none does.
+func (p KeepPolicy) preference(f FileInfo) int {
+	for i, dir := range p.Prefer {
+		for _, path := range f.Paths() {
+			if underDir(path, dir) {
+				return i
+			}
+		}
+	}
+	return len(p.Prefer)
+}
+
+// before orders two copies by Rule, falling back to the path so the choice
+// is stable between runs.
+func (p KeepPolicy) before(a, b FileInfo) bool {
+	switch p.Rule {
+	case KeepNewest:
+		if a.ModTime != b.ModTime {
+			return a.ModTime > b.ModTime
+		}
+	case KeepShortestPath:
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+	default:
+		if a.ModTime != b.ModTime {
+			return a.ModTime < b.ModTime
+		}
+	}
+	return a.Path < b.Path
+}
+
+// underDir reports whether path is dir or lies beneath it.
+func underDir(path, dir string) bool {
+	dir = filepath.Clean(dir)
+	if dir == string(filepath.Separator) {
+		return true
+	}
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
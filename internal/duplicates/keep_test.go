@@ -0,0 +1,73 @@
+package duplicates
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestKeepPolicyApply(t *testing.T) {
+	files := func() []FileInfo {
+		return []FileInfo{
+			{Path: "/home/u/Downloads/very/deep/img.jpg", ModTime: 300},
+			{Path: "/home/u/Photos/2024/img.jpg", ModTime: 200},
+			{Path: "/home/u/img.jpg", ModTime: 100},
+			{Path: "/mnt/archive/img.jpg", ModTime: 400},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		policy   KeepPolicy
+		wantKept []string
+	}{
+		{"oldest by default", KeepPolicy{}, []string{"/home/u/img.jpg"}},
+		{"newest", KeepPolicy{Rule: KeepNewest}, []string{"/mnt/archive/img.jpg"}},
+		{"shortest path", KeepPolicy{Rule: KeepShortestPath}, []string{"/home/u/img.jpg"}},
+		{"prefer beats rule", KeepPolicy{Rule: KeepNewest, Prefer: []string{"/home/u/Photos"}}, []string{"/home/u/Photos/2024/img.jpg"}},
+		{"prefer order", KeepPolicy{Prefer: []string{"/mnt/archive", "/home/u/Photos"}}, []string{"/mnt/archive/img.jpg"}},
+		{"prefer is a directory, not a prefix", KeepPolicy{Prefer: []string{"/home/u/Photo"}}, []string{"/home/u/img.jpg"}},
+		{"protect keeps every copy beneath", KeepPolicy{Protect: []string{"/home/u"}}, []string{
+			"/home/u/img.jpg", "/home/u/Photos/2024/img.jpg", "/home/u/Downloads/very/deep/img.jpg",
+		}},
+		{"keep matching", KeepPolicy{KeepMatching: []*regexp.Regexp{regexp.MustCompile(`/(archive|Photos)/`)}}, []string{
+			"/home/u/Photos/2024/img.jpg", "/mnt/archive/img.jpg",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := files()
+			kept := tt.policy.Apply(group)
+			if kept != len(tt.wantKept) {
+				t.Fatalf("Expected %d kept, got %d: %v", len(tt.wantKept), kept, group)
+			}
+			for i, want := range tt.wantKept {
+				if group[i].Path != want {
+					t.Errorf("Expected kept[%d] = %s, got %s", i, want, group[i].Path)
+				}
+			}
+		})
+	}
+}
+
+func TestDuplicateGroupKeptAndRemovable(t *testing.T) {
+	g := DuplicateGroup{Files: []FileInfo{{Path: "a"}, {Path: "b"}, {Path: "c"}}}
+	if len(g.Kept()) != 1 || len(g.Removable()) != 2 {
+		t.Errorf("Expected a zero KeepCount to keep one file, got %d kept", len(g.Kept()))
+	}
+	g.KeepCount = 2
+	if len(g.Kept()) != 2 || g.Removable()[0].Path != "c" {
+		t.Errorf("Expected a and b kept, got %v", g.Kept())
+	}
+}
+
+func TestScanSkipsGroupsWithEveryCopyProtected(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeAged(t, filepath.Join(tmpDir, "a"), []byte("same content"))
+	writeAged(t, filepath.Join(tmpDir, "b"), []byte("same content"))
+
+	result := scanDir(t, ScanOptions{Paths: []string{tmpDir}, MinSize: 1, Keep: KeepPolicy{Protect: []string{tmpDir}}})
+	if len(result.Groups) != 0 || result.WastedSpace != 0 {
+		t.Errorf("Expected nothing reclaimable, got %d groups, %d bytes", len(result.Groups), result.WastedSpace)
+	}
+}
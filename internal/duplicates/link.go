@@ -0,0 +1,220 @@
+package duplicates
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// LinkMode is how DedupeDuplicates replaces a redundant copy.
+type LinkMode string
+
+const (
+	// ModeHardlink makes the copy another name for the kept file. Both must
+	// be on the same filesystem, and they share mode, owner and mtime after.
+	ModeHardlink LinkMode = "hardlink"
+	// ModeReflink makes the copy a copy-on-write clone of the kept file
+	// (btrfs, xfs, bcachefs). The two stay independent files that share data
+	// blocks until one is written, and the copy keeps its own metadata.
+	ModeReflink LinkMode = "reflink"
+	// ModeSymlink replaces the copy with a symlink to the kept file's
+	// absolute path. Moving or deleting the kept file breaks the link.
+	ModeSymlink LinkMode = "symlink"
+)
+
+// ficlone is the FICLONE ioctl request, _IOW(0x94, 9, int).
+const ficlone = 0x40049409
+
+// ErrReflinkUnsupported is returned when the filesystem cannot clone files.
+// The duplicate is left as it was.
+var ErrReflinkUnsupported = errors.New("filesystem does not support reflinks")
+
+// ParseLinkMode validates a --mode value.
+func ParseLinkMode(s string) (LinkMode, error) {
+	switch mode := LinkMode(s); mode {
+	case ModeHardlink, ModeReflink, ModeSymlink:
+		return mode, nil
+	}
+	return "", fmt.Errorf("mode must be %q, %q or %q, got %q", ModeHardlink, ModeReflink, ModeSymlink, s)
+}
+
+// Replacement pairs a redundant copy with the file it is replaced by a link to.
+type Replacement struct {
+	Keep      string
+	Duplicate string
+}
+
+// DedupeDuplicates replaces each duplicate with a link to its kept file. It
+// returns the number replaced, the space freed (shared, for ModeReflink; see
+// ReplaceWithLink) and one message per failure; a failed replacement leaves
+// the duplicate untouched.
+func DedupeDuplicates(replacements []Replacement, mode LinkMode) (int, int64, []string) {
+	replaced := 0
+	freedSpace := int64(0)
+	var errors []string
+
+	for _, r := range replacements {
+		freed, err := ReplaceWithLink(r.Keep, r.Duplicate, mode)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", r.Duplicate, err))
+			continue
+		}
+		replaced++
+		freedSpace += freed
+	}
+
+	return replaced, freedSpace, errors
+}
+
+// ReplaceWithLink replaces dup with a link to keep. Immediately beforehand it
+// checks that both are regular files with the same contents. The link is
+// made under a temporary name and renamed over dup, which is only done if
+// dup has not changed since it was compared.
+//
+// It returns the bytes freed: dup's size, or 0 while dup has other hardlinks,
+// since its data stays on disk under those names. With ModeReflink the bytes
+// are shared with keep rather than freed outright; DedupeDuplicates callers
+// report them as such.
+func ReplaceWithLink(keep, dup string, mode LinkMode) (int64, error) {
+	if _, err := ParseLinkMode(string(mode)); err != nil {
+		return 0, err
+	}
+	keepInfo, err := regularFile(keep)
+	if err != nil {
+		return 0, err
+	}
+	dupInfo, err := regularFile(dup)
+	if err != nil {
+		return 0, err
+	}
+	if os.SameFile(keepInfo, dupInfo) {
+		return 0, fmt.Errorf("already a hardlink of %s", keep)
+	}
+	if keepInfo.Size() != dupInfo.Size() {
+		return 0, fmt.Errorf("size differs from %s", keep)
+	}
+	if mode != ModeSymlink && device(keepInfo) != device(dupInfo) {
+		return 0, fmt.Errorf("refusing to %s across filesystems (%s is on another one)", mode, keep)
+	}
+
+	same, err := sameContents(osFS, keep, dup)
+	if err != nil {
+		return 0, err
+	}
+	if !same {
+		return 0, fmt.Errorf("contents differ from %s", keep)
+	}
+
+	tmp, err := makeLink(keep, dup, dupInfo, mode)
+	if err != nil {
+		return 0, err
+	}
+	// Anything written to dup since the comparison would be lost.
+	if now, err := os.Lstat(dup); err != nil || !os.SameFile(now, dupInfo) ||
+		now.Size() != dupInfo.Size() || !now.ModTime().Equal(dupInfo.ModTime()) {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("changed while being replaced, left as is")
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to replace: %w", err)
+	}
+	if st, ok := dupInfo.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+		return 0, nil
+	}
+	return dupInfo.Size(), nil
+}
+
+// makeLink creates the link that will replace dup, beside it, and returns
+// its path.
+func makeLink(keep, dup string, dupInfo os.FileInfo, mode LinkMode) (string, error) {
+	target := keep
+	if mode == ModeSymlink {
+		abs, err := filepath.Abs(keep)
+		if err != nil {
+			return "", err
+		}
+		target = abs
+	}
+
+	for attempt := 0; attempt < 100; attempt++ {
+		tmp := filepath.Join(filepath.Dir(dup), fmt.Sprintf(".%s.moonbit-dedupe-%d", filepath.Base(dup), rand.Int63()))
+		var err error
+		switch mode {
+		case ModeHardlink:
+			err = os.Link(target, tmp)
+		case ModeSymlink:
+			err = os.Symlink(target, tmp)
+		case ModeReflink:
+			err = reflink(target, tmp, dupInfo)
+		}
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return tmp, nil
+	}
+	return "", fmt.Errorf("no free temporary name beside %s", dup)
+}
+
+// reflink creates dst as a clone of src, with the mode, owner and mtime of
+// the file it replaces.
+func reflink(src, dst string, like os.FileInfo) error {
+	in, err := os.OpenFile(src, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, like.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	if errno != 0 {
+		out.Close()
+		os.Remove(dst)
+		switch errno {
+		case syscall.EOPNOTSUPP, syscall.ENOTTY, syscall.EINVAL, syscall.EXDEV, syscall.ENOSYS:
+			return ErrReflinkUnsupported
+		}
+		return fmt.Errorf("reflink failed: %w", errno)
+	}
+	if st, ok := like.Sys().(*syscall.Stat_t); ok {
+		_ = out.Chown(int(st.Uid), int(st.Gid))
+	}
+	_ = out.Chmod(like.Mode().Perm())
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := os.Chtimes(dst, like.ModTime(), like.ModTime()); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// regularFile is Lstat that rejects anything but a regular file.
+func regularFile(path string) (os.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file (mode %s)", path, info.Mode())
+	}
+	return info, nil
+}
+
+func device(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev)
+	}
+	return 0
+}
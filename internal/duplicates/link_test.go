@@ -0,0 +1,143 @@
+package duplicates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePair(t *testing.T, keepContent, dupContent string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "keep")
+	dup := filepath.Join(dir, "dup")
+	if err := os.WriteFile(keep, []byte(keepContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dup, []byte(dupContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keep, dup
+}
+
+func TestReplaceWithHardlink(t *testing.T) {
+	keep, dup := writePair(t, "same bytes", "same bytes")
+
+	freed, err := ReplaceWithLink(keep, dup, ModeHardlink)
+	if err != nil {
+		t.Fatalf("ReplaceWithLink failed: %v", err)
+	}
+	if freed != int64(len("same bytes")) {
+		t.Errorf("Expected %d bytes freed, got %d", len("same bytes"), freed)
+	}
+	a, _ := os.Stat(keep)
+	b, _ := os.Stat(dup)
+	if !os.SameFile(a, b) {
+		t.Error("Expected dup to be a hardlink of keep")
+	}
+
+	if _, err := ReplaceWithLink(keep, dup, ModeHardlink); err == nil {
+		t.Error("Expected an already-linked pair to be refused")
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(dup), ".*moonbit-dedupe*"))
+	if len(matches) != 0 {
+		t.Errorf("Expected no temporary files left, got %v", matches)
+	}
+}
+
+func TestReplaceWithLinkFreesNothingWhileOtherNamesRemain(t *testing.T) {
+	keep, dup := writePair(t, "same bytes", "same bytes")
+	other := filepath.Join(filepath.Dir(dup), "other")
+	if err := os.Link(dup, other); err != nil {
+		t.Fatal(err)
+	}
+
+	freed, err := ReplaceWithLink(keep, dup, ModeHardlink)
+	if err != nil {
+		t.Fatalf("ReplaceWithLink failed: %v", err)
+	}
+	if freed != 0 {
+		t.Errorf("Expected nothing freed while %s still holds the data, got %d", other, freed)
+	}
+
+	freed, err = ReplaceWithLink(keep, other, ModeHardlink)
+	if err != nil {
+		t.Fatalf("ReplaceWithLink failed: %v", err)
+	}
+	if freed != int64(len("same bytes")) {
+		t.Errorf("Expected the last name to free %d bytes, got %d", len("same bytes"), freed)
+	}
+}
+
+func TestReplaceWithSymlink(t *testing.T) {
+	keep, dup := writePair(t, "same bytes", "same bytes")
+
+	if _, err := ReplaceWithLink(keep, dup, ModeSymlink); err != nil {
+		t.Fatalf("ReplaceWithLink failed: %v", err)
+	}
+	target, err := os.Readlink(dup)
+	if err != nil {
+		t.Fatalf("Expected dup to be a symlink: %v", err)
+	}
+	if target != keep {
+		t.Errorf("Expected link to %s, got %s", keep, target)
+	}
+
+	// The symlink is not a regular file, so it is never replaced again.
+	if _, err := ReplaceWithLink(keep, dup, ModeHardlink); err == nil {
+		t.Error("Expected a symlink duplicate to be refused")
+	}
+}
+
+func TestReplaceWithReflinkLeavesDuplicateWhenUnsupported(t *testing.T) {
+	keep, dup := writePair(t, "same bytes", "same bytes")
+
+	_, err := ReplaceWithLink(keep, dup, ModeReflink)
+	if err != nil && !errors.Is(err, ErrReflinkUnsupported) {
+		t.Fatalf("Expected success or ErrReflinkUnsupported, got %v", err)
+	}
+	info, statErr := os.Lstat(dup)
+	if statErr != nil || !info.Mode().IsRegular() {
+		t.Fatalf("Expected dup to remain a regular file: %v", statErr)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected dup to keep its own mode, got %v", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(dup)
+	if string(data) != "same bytes" {
+		t.Errorf("Expected dup contents unchanged, got %q", data)
+	}
+}
+
+func TestReplaceWithLinkRefusesDifferentContents(t *testing.T) {
+	keep, dup := writePair(t, "original A", "original B")
+
+	if _, err := ReplaceWithLink(keep, dup, ModeHardlink); err == nil {
+		t.Fatal("Expected different contents to be refused")
+	}
+	data, _ := os.ReadFile(dup)
+	if string(data) != "original B" {
+		t.Errorf("Expected dup untouched, got %q", data)
+	}
+}
+
+func TestDedupeDuplicatesCollectsErrors(t *testing.T) {
+	keep, dup := writePair(t, "same bytes", "same bytes")
+	missing := filepath.Join(filepath.Dir(dup), "missing")
+
+	replaced, freed, errs := DedupeDuplicates([]Replacement{
+		{Keep: keep, Duplicate: dup},
+		{Keep: keep, Duplicate: missing},
+	}, ModeHardlink)
+	if replaced != 1 || freed != int64(len("same bytes")) {
+		t.Errorf("Expected 1 replaced freeing %d, got %d freeing %d", len("same bytes"), replaced, freed)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 error, got %v", errs)
+	}
+
+	if _, err := ParseLinkMode("copy"); err == nil {
+		t.Error("Expected unknown mode to be rejected")
+	}
+}
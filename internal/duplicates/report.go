@@ -0,0 +1,388 @@
+package duplicates
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ReportVersion is the format version of reports written by WriteReport.
+const ReportVersion = 1
+
+// ReportAction is what applying a report does to one file.
+type ReportAction string
+
+const (
+	// ActionKeep leaves the file alone. The first kept file in a group is
+	// the one links point to.
+	ActionKeep ReportAction = "keep"
+	// ActionRemove deletes the file and every hardlink to it.
+	ActionRemove ReportAction = "remove"
+	// ActionHardlink, ActionReflink and ActionSymlink replace the file with
+	// a link of that kind to the group's first kept file.
+	ActionHardlink ReportAction = ReportAction(ModeHardlink)
+	ActionReflink  ReportAction = ReportAction(ModeReflink)
+	ActionSymlink  ReportAction = ReportAction(ModeSymlink)
+)
+
+// ParseReportAction validates an action read from a report. An empty action
+// is ActionKeep, so clearing a cell never deletes anything.
+func ParseReportAction(s string) (ReportAction, error) {
+	switch action := ReportAction(strings.ToLower(strings.TrimSpace(s))); action {
+	case "":
+		return ActionKeep, nil
+	case ActionKeep, ActionRemove, ActionHardlink, ActionReflink, ActionSymlink:
+		return action, nil
+	}
+	return "", fmt.Errorf("action must be %q, %q, %q, %q or %q, got %q",
+		ActionKeep, ActionRemove, ActionHardlink, ActionReflink, ActionSymlink, s)
+}
+
+// Report is a duplicate scan written out for review. Each file carries the
+// action applying the report takes; a scan marks kept copies "keep" and the
+// rest "remove", and reviewers edit that before applying.
+type Report struct {
+	Version int           `json:"version"`
+	Created time.Time     `json:"created"`
+	Paths   []string      `json:"paths,omitempty"`
+	Groups  []ReportGroup `json:"groups"`
+}
+
+// ReportGroup is one set of identical files.
+type ReportGroup struct {
+	ID    int          `json:"id"`
+	Hash  string       `json:"hash"`
+	Size  int64        `json:"size"`
+	Files []ReportFile `json:"files"`
+}
+
+// ReportFile is one file in a group, as it was when scanned.
+type ReportFile struct {
+	Action  ReportAction `json:"action"`
+	Path    string       `json:"path"`
+	Links   []string     `json:"links,omitempty"` // other names of the same file
+	ModTime time.Time    `json:"mtime"`
+}
+
+// NewReport records result's groups, marking kept copies ActionKeep and the
+// rest ActionRemove. paths are the scanned paths, for reference.
+func NewReport(result *ScanResult, paths []string) *Report {
+	r := &Report{Version: ReportVersion, Created: time.Now(), Paths: paths, Groups: []ReportGroup{}}
+	for i, g := range result.Groups {
+		group := ReportGroup{ID: i + 1, Hash: g.Hash, Size: g.Size}
+		for j, f := range g.Files {
+			action := ActionRemove
+			if j < g.keepCount() {
+				action = ActionKeep
+			}
+			group.Files = append(group.Files, ReportFile{
+				Action:  action,
+				Path:    f.Path,
+				Links:   f.Links,
+				ModTime: time.Unix(0, f.modTimeNano),
+			})
+		}
+		r.Groups = append(r.Groups, group)
+	}
+	return r
+}
+
+// Validate checks that every action is known and every group that changes
+// anything keeps at least one copy.
+func (r *Report) Validate() error {
+	if r.Version > ReportVersion {
+		return fmt.Errorf("report version %d is newer than this moonbit supports (%d)", r.Version, ReportVersion)
+	}
+	for _, g := range r.Groups {
+		kept, changed := 0, 0
+		for _, f := range g.Files {
+			if _, err := ParseReportAction(string(f.Action)); err != nil {
+				return fmt.Errorf("group %d, %s: %w", g.ID, f.Path, err)
+			}
+			if f.Path == "" {
+				return fmt.Errorf("group %d: file without a path", g.ID)
+			}
+			if f.Action == ActionKeep || f.Action == "" {
+				kept++
+			} else {
+				changed++
+			}
+		}
+		if changed > 0 && kept == 0 {
+			return fmt.Errorf("group %d marks no file %q; at least one copy must be kept", g.ID, ActionKeep)
+		}
+	}
+	return nil
+}
+
+// WriteReport writes r to path: CSV when path ends in .csv, otherwise JSON.
+func WriteReport(path string, r *Report) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if isCSV(path) {
+		err = writeReportCSV(f, r)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// ReadReport reads and validates a report written by WriteReport, possibly
+// since edited. The format follows the extension as for WriteReport.
+func ReadReport(path string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	defer f.Close()
+
+	var r *Report
+	if isCSV(path) {
+		r, err = readReportCSV(f)
+	} else {
+		r = &Report{}
+		err = json.NewDecoder(f).Decode(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	for i := range r.Groups {
+		for j := range r.Groups[i].Files {
+			f := &r.Groups[i].Files[j]
+			action, err := ParseReportAction(string(f.Action))
+			if err != nil {
+				return nil, fmt.Errorf("group %d, %s: %w", r.Groups[i].ID, f.Path, err)
+			}
+			f.Action = action
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func isCSV(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+// csvColumns are the report's CSV columns. One row per file; a group's size
+// and hash repeat on each of its rows. Hardlinks share a row, separated by
+// linkSeparator.
+var csvColumns = []string{"group", "action", "path", "size", "mtime", "hash", "links"}
+
+const linkSeparator = ";"
+
+func writeReportCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, g := range r.Groups {
+		for _, f := range g.Files {
+			if err := cw.Write([]string{
+				strconv.Itoa(g.ID),
+				string(f.Action),
+				f.Path,
+				strconv.FormatInt(g.Size, 10),
+				f.ModTime.Format(time.RFC3339Nano),
+				g.Hash,
+				strings.Join(f.Links, linkSeparator),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readReportCSV reads columns by their header name, so a spreadsheet may
+// reorder them or add its own. Rows are grouped by the group column.
+func readReportCSV(rd io.Reader) (*Report, error) {
+	cr := csv.NewReader(rd)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := make(map[string]int)
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range csvColumns {
+		if _, ok := col[name]; !ok && name != "links" {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	r := &Report{Version: ReportVersion}
+	groups := make(map[int]*ReportGroup)
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		if strings.Join(row, "") == "" {
+			continue // blank rows a spreadsheet left behind
+		}
+
+		id, err := strconv.Atoi(field("group"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid group %q", line, field("group"))
+		}
+		size, err := strconv.ParseInt(field("size"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid size %q", line, field("size"))
+		}
+		mtime, err := time.Parse(time.RFC3339Nano, field("mtime"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid mtime %q", line, field("mtime"))
+		}
+		file := ReportFile{Action: ReportAction(field("action")), Path: field("path"), ModTime: mtime}
+		if links := field("links"); links != "" {
+			file.Links = strings.Split(links, linkSeparator)
+		}
+
+		g, ok := groups[id]
+		if !ok {
+			g = &ReportGroup{ID: id, Hash: field("hash"), Size: size}
+			groups[id] = g
+		} else if g.Hash != field("hash") || g.Size != size {
+			return nil, fmt.Errorf("line %d: size or hash differs from the rest of group %d", line, id)
+		}
+		g.Files = append(g.Files, file)
+	}
+
+	ids := make([]int, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		r.Groups = append(r.Groups, *groups[id])
+	}
+	return r, nil
+}
+
+// ReportStep is one change applying a report makes.
+type ReportStep struct {
+	Action ReportAction
+	Paths  []string // the file's name and its hardlinks
+	Keep   string   // what a link points to
+	Size   int64    // space freed
+}
+
+// Plan re-checks every file a report names against the disk and returns the
+// changes to make. A file is only changed when it still has the size, mtime
+// and hash the report recorded, and its group still has a kept copy that
+// does too; everything else is skipped with a reason.
+func (r *Report) Plan() (steps []ReportStep, skipped []string) {
+	for _, g := range r.Groups {
+		var keep string
+		var changes []ReportFile
+		for _, f := range g.Files {
+			if f.Action != ActionKeep {
+				changes = append(changes, f)
+				continue
+			}
+			if keep != "" {
+				continue
+			}
+			if _, err := verifyReportFile(g, f); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s (kept copy): %v", f.Path, err))
+				continue
+			}
+			keep = f.Path
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if keep == "" {
+			for _, f := range changes {
+				skipped = append(skipped, fmt.Sprintf("%s: no kept copy in group %d is unchanged", f.Path, g.ID))
+			}
+			continue
+		}
+
+		for _, f := range changes {
+			freed, err := verifyReportFile(g, f)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s: %v", f.Path, err))
+				continue
+			}
+			steps = append(steps, ReportStep{
+				Action: f.Action,
+				Paths:  append([]string{f.Path}, f.Links...),
+				Keep:   keep,
+				Size:   freed,
+			})
+		}
+	}
+	return steps, skipped
+}
+
+// verifyReportFile checks that f is still the file the report describes,
+// hardlinks included, and returns the space removing every name frees.
+func verifyReportFile(g ReportGroup, f ReportFile) (int64, error) {
+	info, err := regularFile(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() != g.Size {
+		return 0, fmt.Errorf("size changed since the report (%d, was %d)", info.Size(), g.Size)
+	}
+	if !info.ModTime().Equal(f.ModTime) {
+		return 0, fmt.Errorf("modified since the report")
+	}
+	for _, link := range f.Links {
+		linkInfo, err := os.Lstat(link)
+		if err != nil || !os.SameFile(info, linkInfo) {
+			return 0, fmt.Errorf("%s is no longer a hardlink of it", link)
+		}
+	}
+	hash, err := hashFile(osFS, f.Path)
+	if err != nil {
+		return 0, err
+	}
+	if hash != g.Hash {
+		return 0, fmt.Errorf("contents changed since the report")
+	}
+
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && uint64(st.Nlink) > uint64(1+len(f.Links)) {
+		return 0, nil
+	}
+	return info.Size(), nil
+}
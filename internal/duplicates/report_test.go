@@ -0,0 +1,198 @@
+package duplicates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportFixture scans a directory holding three identical files, the oldest
+// first, and returns the report of that scan.
+func reportFixture(t *testing.T) (string, *Report) {
+	t.Helper()
+	dir := t.TempDir()
+	content := []byte("identical content for the report tests")
+	base := time.Now().Add(-time.Hour)
+	for i, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result := scanDir(t, ScanOptions{Paths: []string{dir}, MinSize: 1})
+	return dir, NewReport(result, []string{dir})
+}
+
+func TestNewReportMarksKeptCopies(t *testing.T) {
+	dir, r := reportFixture(t)
+	if len(r.Groups) != 1 || len(r.Groups[0].Files) != 3 {
+		t.Fatalf("Expected one group of 3 files, got %+v", r.Groups)
+	}
+	files := r.Groups[0].Files
+	if files[0].Path != filepath.Join(dir, "a") || files[0].Action != ActionKeep {
+		t.Errorf("Expected the oldest file kept first, got %+v", files[0])
+	}
+	for _, f := range files[1:] {
+		if f.Action != ActionRemove {
+			t.Errorf("Expected %s marked remove, got %q", f.Path, f.Action)
+		}
+	}
+}
+
+func TestReportRoundTrip(t *testing.T) {
+	for _, name := range []string{"dupes.json", "dupes.csv"} {
+		t.Run(name, func(t *testing.T) {
+			_, r := reportFixture(t)
+			r.Groups[0].Files[1].Links = []string{"/x/link1", "/x/link2"}
+			path := filepath.Join(t.TempDir(), name)
+			if err := WriteReport(path, r); err != nil {
+				t.Fatalf("WriteReport failed: %v", err)
+			}
+			got, err := ReadReport(path)
+			if err != nil {
+				t.Fatalf("ReadReport failed: %v", err)
+			}
+			if len(got.Groups) != 1 {
+				t.Fatalf("Expected 1 group, got %d", len(got.Groups))
+			}
+			want, g := r.Groups[0], got.Groups[0]
+			if g.ID != want.ID || g.Hash != want.Hash || g.Size != want.Size || len(g.Files) != len(want.Files) {
+				t.Fatalf("Expected group %+v, got %+v", want, g)
+			}
+			for i, f := range g.Files {
+				w := want.Files[i]
+				if f.Path != w.Path || f.Action != w.Action || !f.ModTime.Equal(w.ModTime) ||
+					strings.Join(f.Links, ",") != strings.Join(w.Links, ",") {
+					t.Errorf("Expected file %+v, got %+v", w, f)
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyReportRoundTrip(t *testing.T) {
+	for _, name := range []string{"dupes.json", "dupes.csv"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := WriteReport(path, NewReport(&ScanResult{}, []string{"/data"})); err != nil {
+				t.Fatalf("WriteReport failed: %v", err)
+			}
+			got, err := ReadReport(path)
+			if err != nil {
+				t.Fatalf("ReadReport failed: %v", err)
+			}
+			if len(got.Groups) != 0 {
+				t.Errorf("Expected no groups, got %d", len(got.Groups))
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "dupes.json")
+	if err := WriteReport(path, NewReport(&ScanResult{}, nil)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"groups": []`) {
+		t.Errorf("Expected an empty groups list, got %s", data)
+	}
+}
+
+func TestReadReportCSVEdited(t *testing.T) {
+	_, r := reportFixture(t)
+	g := r.Groups[0]
+	mtime := g.Files[0].ModTime.Format(time.RFC3339Nano)
+	// Columns reordered, an extra column, an empty action and a blank row,
+	// as a spreadsheet might leave them.
+	csv := "path,note,group,hash,size,mtime,action\n" +
+		g.Files[0].Path + ",original,1," + g.Hash + ",39," + mtime + ",\n" +
+		",,,,,,\n" +
+		g.Files[1].Path + ",,1," + g.Hash + ",39," + mtime + ",HARDLINK\n"
+	path := filepath.Join(t.TempDir(), "edited.csv")
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadReport(path)
+	if err != nil {
+		t.Fatalf("ReadReport failed: %v", err)
+	}
+	files := got.Groups[0].Files
+	if len(files) != 2 || files[0].Action != ActionKeep || files[1].Action != ActionHardlink {
+		t.Errorf("Expected keep and hardlink, got %+v", files)
+	}
+}
+
+func TestReadReportRejectsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown action": `{"version":1,"groups":[{"id":1,"files":[{"action":"keep","path":"/a"},{"action":"shred","path":"/b"}]}]}`,
+		"nothing kept":   `{"version":1,"groups":[{"id":1,"files":[{"action":"remove","path":"/a"},{"action":"remove","path":"/b"}]}]}`,
+		"newer version":  `{"version":99,"groups":[]}`,
+	}
+	for name, data := range tests {
+		path := filepath.Join(t.TempDir(), "report.json")
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadReport(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReportPlanRechecksFiles(t *testing.T) {
+	dir, r := reportFixture(t)
+
+	steps, skipped := r.Plan()
+	if len(steps) != 2 || len(skipped) != 0 {
+		t.Fatalf("Expected 2 steps and nothing skipped, got %+v, %v", steps, skipped)
+	}
+	for _, step := range steps {
+		if step.Keep != filepath.Join(dir, "a") || step.Action != ActionRemove {
+			t.Errorf("Unexpected step %+v", step)
+		}
+	}
+
+	// Same size, different contents, mtime put back.
+	c := filepath.Join(dir, "c")
+	info, _ := os.Stat(c)
+	if err := os.WriteFile(c, []byte("IDENTICAL content for the report tests"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(c, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	// Touched.
+	b := filepath.Join(dir, "b")
+	if err := os.Chtimes(b, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	steps, skipped = r.Plan()
+	if len(steps) != 0 || len(skipped) != 2 {
+		t.Errorf("Expected both changed files skipped, got %+v, %v", steps, skipped)
+	}
+}
+
+func TestReportPlanNeedsUnchangedKeptCopy(t *testing.T) {
+	dir, r := reportFixture(t)
+	if err := os.Remove(filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	steps, skipped := r.Plan()
+	if len(steps) != 0 {
+		t.Errorf("Expected no steps without a kept copy, got %+v", steps)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("Expected the kept copy and both changes skipped, got %v", skipped)
+	}
+}
@@ -0,0 +1,224 @@
+package duplicates
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Nomadcxx/moonbit/internal/scanner"
+)
+
+// PartialHashSize is how much of each end of a file the partial-hash stage
+// reads. Files up to twice this size skip straight to the full hash, which
+// costs no more.
+const PartialHashSize = 4096
+
+// StageCounts records how many files entered each stage of a scan. Each
+// stage only sees files that still have a possible duplicate.
+type StageCounts struct {
+	// SizeMatched files share their size with at least one other file.
+	SizeMatched int
+	// PartialHashed files had their head and tail hashed.
+	PartialHashed int
+	// FullHashed files were read in full.
+	FullHashed int
+	// Verified files were compared byte by byte (ScanOptions.Verify).
+	Verified int
+	// CacheHits counts hashes taken from ScanOptions.Cache rather than
+	// computed.
+	CacheHits int
+}
+
+// candidateGroup is a set of files that may still be identical, with the key
+// that brought them together.
+type candidateGroup struct {
+	key   string
+	files []FileInfo
+}
+
+// hashStage splits every group by the key hashFn returns, hashing files on
+// DefaultHashWorkers goroutines. Files that cannot be read are dropped, as
+// are groups left with a single file. Progress is reported under phase.
+func hashStage(ctx context.Context, groups []candidateGroup, phase string, hashFn func(FileInfo) (string, error), progressCh chan<- ScanProgress) ([]candidateGroup, error) {
+	type job struct {
+		group int
+		file  FileInfo
+	}
+	type result struct {
+		job
+		key string
+		err error
+	}
+
+	total := 0
+	for _, g := range groups {
+		total += len(g.files)
+	}
+
+	jobs := make(chan job)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < DefaultHashWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := ctx.Err(); err != nil {
+					results <- result{job: j, err: err}
+					continue
+				}
+				key, err := hashFn(j.file)
+				results <- result{job: j, key: key, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i, g := range groups {
+			for _, f := range g.files {
+				select {
+				case jobs <- job{group: i, file: f}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	split := make([]map[string][]FileInfo, len(groups))
+	done := 0
+	var bytesRead int64
+	for r := range results {
+		done++
+		bytesRead += r.file.Size
+		if done%ProgressUpdateInterval == 0 {
+			progressCh <- ScanProgress{
+				FilesScanned: done,
+				BytesScanned: bytesRead,
+				CurrentFile:  r.file.Path,
+				Phase:        fmt.Sprintf("%s (%d/%d files)", phase, done, total),
+			}
+		}
+		if r.err != nil {
+			continue // Skip files we can't hash
+		}
+		if split[r.group] == nil {
+			split[r.group] = make(map[string][]FileInfo)
+		}
+		split[r.group][r.key] = append(split[r.group][r.key], r.file)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []candidateGroup
+	for _, byKey := range split {
+		for key, files := range byKey {
+			if len(files) > 1 {
+				out = append(out, candidateGroup{key: key, files: files})
+			}
+		}
+	}
+	return out, nil
+}
+
+// countFiles returns the number of files across groups.
+func countFiles(groups []candidateGroup) int {
+	n := 0
+	for _, g := range groups {
+		n += len(g.files)
+	}
+	return n
+}
+
+// partialHash hashes the first and last PartialHashSize bytes of a file,
+// which tells apart most same-size files without reading them in full.
+func partialHash(fs scanner.FileSystem, path string, size int64) (string, error) {
+	file, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, file, PartialHashSize); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(size-PartialHashSize, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := io.CopyN(hash, file, PartialHashSize); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyGroup compares the files of a hash group byte by byte and splits it
+// into sets of identical files, dropping files that cannot be read.
+func verifyGroup(fs scanner.FileSystem, files []FileInfo) [][]FileInfo {
+	var sets [][]FileInfo
+	for _, f := range files {
+		placed := false
+		for i, set := range sets {
+			same, err := sameContents(fs, set[0].Path, f.Path)
+			if err != nil {
+				placed = true // unreadable: leave it out
+				break
+			}
+			if same {
+				sets[i] = append(sets[i], f)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			sets = append(sets, []FileInfo{f})
+		}
+	}
+	return sets
+}
+
+// sameContents reports whether two files hold the same bytes.
+func sameContents(fs scanner.FileSystem, a, b string) (bool, error) {
+	fa, err := fs.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := fs.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			if errB == io.EOF || errB == io.ErrUnexpectedEOF {
+				return false, nil
+			}
+			return false, errB
+		}
+	}
+}
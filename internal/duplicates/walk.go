@@ -0,0 +1,135 @@
+package duplicates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// inodeKey identifies a file on disk.
+type inodeKey struct{ dev, ino uint64 }
+
+// collector gathers the files a scan considers, one FileInfo per inode:
+// hardlinks to one inode are a single file with several names, never
+// duplicates of each other.
+type collector struct {
+	s          *Scanner
+	ctx        context.Context
+	progressCh chan<- ScanProgress
+
+	files     []*FileInfo
+	inodes    map[inodeKey]*FileInfo
+	seenPaths map[string]bool
+
+	filesScanned int
+	bytesScanned int64
+	dirsScanned  int
+}
+
+func newCollector(ctx context.Context, s *Scanner, progressCh chan<- ScanProgress) *collector {
+	return &collector{
+		s:          s,
+		ctx:        ctx,
+		progressCh: progressCh,
+		inodes:     make(map[inodeKey]*FileInfo),
+		seenPaths:  make(map[string]bool),
+	}
+}
+
+// walkRoot collects root, or everything beneath it when it is a directory.
+// An unreadable root is skipped, like any unreadable directory below it.
+func (c *collector) walkRoot(root string) error {
+	root = filepath.Clean(root)
+	info, err := c.s.fs.Stat(root)
+	if err != nil || c.s.ignored(root) {
+		return nil
+	}
+	if !info.IsDir() {
+		c.addFile(root, info)
+		return nil
+	}
+	return c.walkDir(root, 0)
+}
+
+// walkDir collects the files in dir, which lies depth levels below its root,
+// and descends while MaxDepth allows.
+func (c *collector) walkDir(dir string, depth int) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	c.dirsScanned++
+
+	entries, err := c.s.fs.ReadDir(dir)
+	if err != nil {
+		return nil // Skip directories we can't read
+	}
+	for _, info := range entries {
+		path := filepath.Join(dir, info.Name())
+		if c.s.ignored(path) {
+			continue
+		}
+		if info.IsDir() {
+			if c.s.opts.MaxDepth < 0 || depth+1 < c.s.opts.MaxDepth {
+				if err := c.walkDir(path, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		c.addFile(path, info)
+	}
+	return nil
+}
+
+// addFile records a file that passes the size filters.
+func (c *collector) addFile(path string, info os.FileInfo) {
+	// Listings report entries as lstat sees them: symlinks, devices and
+	// sockets are not files whose copies could be removed.
+	if !info.Mode().IsRegular() {
+		return
+	}
+	if info.Size() < c.s.opts.MinSize {
+		return
+	}
+	if c.s.opts.MaxSize > 0 && info.Size() > c.s.opts.MaxSize {
+		return
+	}
+	// Overlapping roots reach the same path twice.
+	if c.seenPaths[path] {
+		return
+	}
+	c.seenPaths[path] = true
+	c.filesScanned++
+
+	fileInfo := &FileInfo{
+		Path:        path,
+		Size:        info.Size(),
+		ModTime:     info.ModTime().Unix(),
+		Nlink:       1,
+		modTimeNano: info.ModTime().UnixNano(),
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		fileInfo.Device = uint64(st.Dev)
+		fileInfo.Inode = uint64(st.Ino)
+		fileInfo.Nlink = uint64(st.Nlink)
+		key := inodeKey{fileInfo.Device, fileInfo.Inode}
+		if first, ok := c.inodes[key]; ok {
+			first.Links = append(first.Links, path)
+			return
+		}
+		c.inodes[key] = fileInfo
+	}
+
+	c.files = append(c.files, fileInfo)
+	c.bytesScanned += info.Size()
+
+	if c.filesScanned%ProgressUpdateInterval == 0 {
+		c.progressCh <- ScanProgress{
+			FilesScanned: c.filesScanned,
+			BytesScanned: c.bytesScanned,
+			CurrentFile:  path,
+			Phase:        "Collecting files...",
+		}
+	}
+}
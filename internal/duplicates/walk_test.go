@@ -0,0 +1,121 @@
+package duplicates
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Nomadcxx/moonbit/internal/scanner"
+	"github.com/spf13/afero"
+)
+
+// memFS returns an in-memory filesystem holding files, all with the same
+// content so every one is a duplicate of the others.
+func memFS(t *testing.T, files ...string) scanner.FileSystem {
+	t.Helper()
+	fs := afero.NewMemMapFs()
+	for _, name := range files {
+		if err := afero.WriteFile(fs, name, []byte("same content in every file"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return scanner.NewAferoFileSystem(fs)
+}
+
+// scannedPaths lists every file in the scan's duplicate groups.
+func scannedPaths(result *ScanResult) []string {
+	var paths []string
+	for _, g := range result.Groups {
+		for _, f := range g.Files {
+			paths = append(paths, f.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestScanMaxDepth(t *testing.T) {
+	fs := memFS(t, "/r/a", "/r/b", "/r/d1/c", "/r/d1/d2/d", "/r/d1/d2/d3/e")
+
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{1, []string{"/r/a", "/r/b"}},
+		{2, []string{"/r/a", "/r/b", "/r/d1/c"}},
+		{3, []string{"/r/a", "/r/b", "/r/d1/c", "/r/d1/d2/d"}},
+		{-1, []string{"/r/a", "/r/b", "/r/d1/c", "/r/d1/d2/d", "/r/d1/d2/d3/e"}},
+	}
+	for _, tt := range tests {
+		result := scanDir(t, ScanOptions{Paths: []string{"/r"}, MinSize: 1, MaxDepth: tt.depth, FS: fs})
+		got := scannedPaths(result)
+		if len(got) != len(tt.want) {
+			t.Errorf("MaxDepth %d: expected %v, got %v", tt.depth, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("MaxDepth %d: expected %v, got %v", tt.depth, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestScanIgnorePatternsMatchFullPath(t *testing.T) {
+	fs := memFS(t, "/r/keep1", "/r/keep2", "/r/node_modules/x/dep", "/r/build/out.log", "/r/src/out.log")
+
+	result := scanDir(t, ScanOptions{
+		Paths:          []string{"/r"},
+		MinSize:        1,
+		IgnorePatterns: []string{"node_modules", `^/r/build/`},
+		FS:             fs,
+	})
+	want := []string{"/r/keep1", "/r/keep2", "/r/src/out.log"}
+	got := scannedPaths(result)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestScanInvalidIgnorePattern(t *testing.T) {
+	progressCh := make(chan ScanProgress, 10)
+	go func() {
+		for range progressCh {
+		}
+	}()
+	s := NewScanner(ScanOptions{Paths: []string{"/r"}, IgnorePatterns: []string{"("}, FS: memFS(t)})
+	if _, err := s.Scan(context.Background(), progressCh); err == nil {
+		t.Error("Expected an error for an invalid ignore pattern")
+	}
+}
+
+func TestScanCancelled(t *testing.T) {
+	var files []string
+	for i := 0; i < 20; i++ {
+		files = append(files, filepath.Join("/r", string(rune('a'+i))))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	progressCh := make(chan ScanProgress, 10)
+	go func() {
+		for range progressCh {
+		}
+	}()
+	s := NewScanner(ScanOptions{Paths: []string{"/r"}, MinSize: 1, FS: memFS(t, files...)})
+	result, err := s.Scan(ctx, progressCh)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if result != nil {
+		t.Errorf("Expected no result from a cancelled scan, got %+v", result)
+	}
+}
@@ -102,6 +102,16 @@ func CacheFile() (string, error) {
 	return filepath.Join(home, ".cache", "moonbit", "scan_results.json"), nil
 }
 
+// DirIndexFile is the scanner's directory index for an unprivileged scan, kept
+// beside the session cache. Root scans use session.DirIndexPath instead.
+func DirIndexFile() (string, error) {
+	cacheFile, err := CacheFile()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(cacheFile), "dir_index.json"), nil
+}
+
 func DataDir(parts ...string) (string, error) {
 	var base string
 	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
@@ -117,3 +127,16 @@ func DataDir(parts ...string) (string, error) {
 	allParts := append([]string{base}, parts...)
 	return filepath.Join(allParts...), nil
 }
+
+// TrashDir is the user's home trash from the freedesktop.org Trash
+// specification, $XDG_DATA_HOME/Trash, which file managers also show.
+func TrashDir() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "Trash"), nil
+	}
+	home, err := HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
@@ -34,6 +34,14 @@ func TestCacheFileUsesXDGCacheHome(t *testing.T) {
 	assert.Equal(t, filepath.Join("/tmp/xdg-cache", "moonbit", "scan_results.json"), path)
 }
 
+func TestDirIndexFileSitsBesideCacheFile(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg-cache")
+
+	path, err := DirIndexFile()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/tmp/xdg-cache", "moonbit", "dir_index.json"), path)
+}
+
 func TestDataDirUsesXDGDataHome(t *testing.T) {
 	t.Setenv("XDG_DATA_HOME", "/tmp/xdg-data")
 
@@ -42,6 +50,20 @@ func TestDataDirUsesXDGDataHome(t *testing.T) {
 	assert.Equal(t, filepath.Join("/tmp/xdg-data", "moonbit", "logs"), path)
 }
 
+func TestTrashDirFollowsXDGDataHome(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/tmp/xdg-data")
+
+	path, err := TrashDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/tmp/xdg-data", "Trash"), path)
+
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("MOONBIT_HOME", "/home/someone")
+	path, err = TrashDir()
+	require.NoError(t, err)
+	assert.Equal(t, "/home/someone/.local/share/Trash", path)
+}
+
 func TestHomeDirWorksWithoutHomeEnv(t *testing.T) {
 	t.Setenv("MOONBIT_HOME", "")
 	t.Setenv("HOME", "")
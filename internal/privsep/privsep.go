@@ -0,0 +1,361 @@
+// Package privsep cleans the invoking user's files with that user's rights.
+//
+// `moonbit clean --force` re-executes itself with sudo (or is started through
+// pkexec) so it can clean system caches. Everything under the invoking user's
+// home is user-writable, though, and a hostile layout there -- a directory
+// swapped for a symlink, a hard link to a root-owned file -- must never be
+// acted on as root. So a clean running as root splits each category: files
+// under the home directory go to a helper process, a re-execution of moonbit
+// running as the invoking user, and only the rest are cleaned as root.
+//
+// Files in any other user's home are never cleaned as root at all. The
+// daemon, which runs as root with no invoking user, and a sudo clean whose
+// categories reach into a third user's home both refuse them.
+//
+// The helper is the hidden `moonbit clean-helper` command. It reads one
+// Request as JSON on stdin and writes one Response as JSON on stdout.
+package privsep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Nomadcxx/moonbit/internal/cleaner"
+	"github.com/Nomadcxx/moonbit/internal/config"
+)
+
+// HelperCommand is the hidden subcommand that runs the helper.
+const HelperCommand = "clean-helper"
+
+// InvokingUser returns the user who ran moonbit through sudo or pkexec, or
+// nil when moonbit is not running as root or was started by root itself.
+func InvokingUser() (*user.User, error) {
+	if os.Geteuid() != 0 {
+		return nil, nil
+	}
+	var u *user.User
+	var err error
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		u, err = user.Lookup(name)
+	} else if uid := os.Getenv("PKEXEC_UID"); uid != "" {
+		u, err = user.LookupId(uid)
+	} else {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up the invoking user: %w", err)
+	}
+	if u.Uid == "0" {
+		return nil, nil
+	}
+	return u, nil
+}
+
+// Split moves the files under home out of categories. system keeps the rest
+// of each category; owned holds a category of the same name for each one
+// that had files under home. Sizes and file counts are recomputed for both.
+func Split(categories []config.Category, home string) (system, owned []config.Category) {
+	homes := []string{filepath.Clean(home)}
+	if resolved, err := filepath.EvalSymlinks(home); err == nil && resolved != homes[0] {
+		homes = append(homes, resolved)
+	}
+	underHome := func(path string) bool {
+		for _, h := range homes {
+			if path == h || strings.HasPrefix(path, strings.TrimSuffix(h, "/")+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	return splitBy(categories, underHome)
+}
+
+// SplitOtherHomes moves out of categories the files that lie in the home
+// directory of a user other than root, for a clean running as root to
+// refuse. A directory is such a home when a user other than root owns it
+// and the user database names it as that user's home.
+func SplitOtherHomes(categories []config.Category) (system, foreign []config.Category) {
+	isHome := make(map[string]bool)
+	inOtherHome := func(path string) bool {
+		dir := filepath.Dir(filepath.Clean(path))
+		for {
+			home, seen := isHome[dir]
+			if !seen {
+				uid, ok := dirOwner(dir)
+				home = ok && uid != 0 && userHome(uid) == dir
+				isHome[dir] = home
+			}
+			if home {
+				return true
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return false
+			}
+			dir = parent
+		}
+	}
+	return splitBy(categories, inOtherHome)
+}
+
+// dirOwner and userHome look up a directory's owner and a user's home. They
+// are variables so tests can describe users without creating them.
+var (
+	dirOwner = func(dir string) (uint32, bool) {
+		info, err := os.Lstat(dir)
+		if err != nil || !info.IsDir() {
+			return 0, false
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return 0, false
+		}
+		return st.Uid, true
+	}
+	userHome = func(uid uint32) string {
+		u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+		if err != nil || u.HomeDir == "" {
+			return ""
+		}
+		return filepath.Clean(u.HomeDir)
+	}
+)
+
+// splitBy moves the files matching out of categories, recomputing sizes and
+// file counts for both halves and dropping halves left empty.
+func splitBy(categories []config.Category, out func(path string) bool) (kept, moved []config.Category) {
+	for _, cat := range categories {
+		keep, move := cat, cat
+		keep.Files, move.Files = nil, nil
+		keep.Size, move.Size = 0, 0
+		for _, file := range cat.Files {
+			if out(file.Path) {
+				move.Files = append(move.Files, file)
+				move.Size += file.Size
+			} else {
+				keep.Files = append(keep.Files, file)
+				keep.Size += file.Size
+			}
+		}
+		keep.FileCount, move.FileCount = len(keep.Files), len(move.Files)
+		if len(keep.Files) > 0 {
+			kept = append(kept, keep)
+		}
+		if len(move.Files) > 0 {
+			moved = append(moved, move)
+		}
+	}
+	return kept, moved
+}
+
+// Request is what the privileged clean sends the helper. The categories
+// have been revalidated against config already; the helper trusts them as
+// it would trust its own config, since they can only name files the
+// invoking user may delete anyway.
+type Request struct {
+	Safety     config.SafetyConfig `json:"safety"`
+	Backup     config.BackupConfig `json:"backup"`
+	Audit      config.AuditConfig  `json:"audit"`
+	Categories []Category          `json:"categories"`
+}
+
+// Category is a category as sent to the helper. The per-file shred and
+// action settings the revalidation gate takes from config are not part of
+// config.FileInfo's JSON, so they travel here.
+type Category struct {
+	Name  string           `json:"name"`
+	Risk  config.RiskLevel `json:"risk"`
+	Paths []string         `json:"paths"`
+	Files []File           `json:"files"`
+}
+
+// File is one file to clean.
+type File struct {
+	Path   string             `json:"path"`
+	Size   uint64             `json:"size"`
+	Shred  bool               `json:"shred,omitempty"`
+	Action config.CleanAction `json:"action,omitempty"`
+}
+
+// Response is the helper's answer: one Result per category, in order.
+type Response struct {
+	Results []Result `json:"results"`
+	// BackupsPruned lists backups the helper removed under the backup limits.
+	BackupsPruned []string `json:"backups_pruned,omitempty"`
+}
+
+// Result is the outcome of one category. Error is set, and Complete nil,
+// when the cleaner refused the category before touching anything.
+type Result struct {
+	Name     string                 `json:"name"`
+	Complete *cleaner.CleanComplete `json:"complete,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// NewRequest builds the helper's request from cfg and categories.
+func NewRequest(cfg *config.Config, categories []config.Category) Request {
+	req := Request{Safety: cfg.Safety, Backup: cfg.Backup, Audit: cfg.Audit}
+	for _, cat := range categories {
+		out := Category{Name: cat.Name, Risk: cat.Risk, Paths: cat.Paths}
+		for _, file := range cat.Files {
+			out.Files = append(out.Files, File{
+				Path:   file.Path,
+				Size:   file.Size,
+				Shred:  cat.ShredEnabled || file.CategoryShred,
+				Action: fileAction(cat, file),
+			})
+		}
+		req.Categories = append(req.Categories, out)
+	}
+	return req
+}
+
+func fileAction(cat config.Category, file config.FileInfo) config.CleanAction {
+	if file.CategoryAction != config.ActionDelete {
+		return file.CategoryAction
+	}
+	return cat.Action
+}
+
+// categories converts the request back into categories for the cleaner.
+func (r Request) categories() []config.Category {
+	out := make([]config.Category, 0, len(r.Categories))
+	for _, c := range r.Categories {
+		cat := config.Category{Name: c.Name, Risk: c.Risk, Paths: c.Paths, Selected: true}
+		for _, f := range c.Files {
+			cat.Files = append(cat.Files, config.FileInfo{
+				Path:           f.Path,
+				Size:           f.Size,
+				CategoryName:   c.Name,
+				CategoryRisk:   c.Risk,
+				CategoryShred:  f.Shred,
+				CategoryAction: f.Action,
+			})
+			cat.Size += f.Size
+		}
+		cat.FileCount = len(cat.Files)
+		out = append(out, cat)
+	}
+	return out
+}
+
+// CleanAsUser cleans categories in a helper process running as u, with home
+// as its home directory, and returns the helper's response. An error means
+// the helper could not be run or answered nothing; nothing is retried as root.
+func CleanAsUser(ctx context.Context, u *user.User, home string, cfg *config.Config, categories []config.Category) (*Response, error) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid %q for %s", u.Uid, u.Username)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q for %s", u.Gid, u.Username)
+	}
+	var groups []uint32
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(g))
+			}
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate the moonbit executable: %w", err)
+	}
+	body, err := json.Marshal(NewRequest(cfg, categories))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, exe, HelperCommand)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups},
+	}
+	cmd.Dir = home
+	cmd.Env = helperEnv(u, home)
+	cmd.Stdin = bytes.NewReader(body)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("clean helper as %s: %w: %s", u.Username, err, msg)
+		}
+		return nil, fmt.Errorf("clean helper as %s: %w", u.Username, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("clean helper as %s: unreadable response: %w", u.Username, err)
+	}
+	return &resp, nil
+}
+
+// helperEnv is the helper's whole environment. Nothing else of root's is
+// passed on; the XDG directories are, so the helper's trash and backups are
+// the ones the user's own moonbit sees.
+func helperEnv(u *user.User, home string) []string {
+	env := []string{
+		"HOME=" + home,
+		"MOONBIT_HOME=" + home,
+		"USER=" + u.Username,
+		"LOGNAME=" + u.Username,
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	}
+	for _, key := range []string{"XDG_DATA_HOME", "XDG_CACHE_HOME", "XDG_CONFIG_HOME"} {
+		if v := os.Getenv(key); v != "" {
+			env = append(env, key+"="+v)
+		}
+	}
+	return env
+}
+
+// Serve is the helper: it reads a Request from in, cleans each category and
+// writes a Response to out. Backups, the trash and the audit log are the
+// running user's own.
+func Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	var req Request
+	if err := json.NewDecoder(in).Decode(&req); err != nil {
+		return fmt.Errorf("invalid clean helper request: %w", err)
+	}
+
+	c := cleaner.NewCleaner(&config.Config{Safety: req.Safety, Backup: req.Backup, Audit: req.Audit})
+	defer func() { _ = c.Close() }()
+
+	var resp Response
+	for _, category := range req.categories() {
+		result := Result{Name: category.Name}
+		progressCh := make(chan cleaner.CleanMsg, 10)
+		go func() { _ = c.CleanCategory(ctx, &category, false, progressCh) }()
+		for msg := range progressCh {
+			if msg.Complete != nil {
+				result.Complete = msg.Complete
+			}
+			// Per-file failures arrive after Complete and are listed in it.
+			if msg.Error != nil && result.Complete == nil {
+				result.Error = msg.Error.Error()
+			}
+		}
+		if result.Complete == nil && result.Error == "" {
+			result.Error = "cleaner finished without a result"
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	if pruned, err := c.PruneBackups(); err == nil && pruned != nil {
+		resp.BackupsPruned = pruned.Removed
+	}
+	return json.NewEncoder(out).Encode(resp)
+}
@@ -0,0 +1,206 @@
+package privsep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	home := t.TempDir()
+	categories := []config.Category{
+		{
+			Name: "Mixed",
+			Files: []config.FileInfo{
+				{Path: "/var/cache/app/a", Size: 10},
+				{Path: filepath.Join(home, ".cache", "b"), Size: 20},
+				{Path: home + "-other/c", Size: 40},
+			},
+			FileCount: 3,
+			Size:      70,
+		},
+		{Name: "System", Files: []config.FileInfo{{Path: "/tmp/x", Size: 1}}, FileCount: 1, Size: 1},
+		{Name: "Home", Files: []config.FileInfo{{Path: filepath.Join(home, "y"), Size: 2}}, FileCount: 1, Size: 2},
+	}
+
+	system, owned := Split(categories, home)
+
+	require.Len(t, system, 2)
+	assert.Equal(t, "Mixed", system[0].Name)
+	assert.Equal(t, uint64(50), system[0].Size)
+	assert.Equal(t, 2, system[0].FileCount, "a sibling sharing the home prefix is not under home")
+	assert.Equal(t, "System", system[1].Name)
+
+	require.Len(t, owned, 2)
+	assert.Equal(t, "Mixed", owned[0].Name)
+	assert.Equal(t, uint64(20), owned[0].Size)
+	assert.Equal(t, 1, owned[0].FileCount)
+	assert.Equal(t, "Home", owned[1].Name)
+}
+
+func TestSplitResolvesSymlinkedHome(t *testing.T) {
+	real := t.TempDir()
+	link := filepath.Join(t.TempDir(), "home")
+	require.NoError(t, os.Symlink(real, link))
+
+	categories := []config.Category{{
+		Name:  "Cache",
+		Files: []config.FileInfo{{Path: filepath.Join(real, "a"), Size: 1}, {Path: filepath.Join(link, "b"), Size: 1}},
+	}}
+	system, owned := Split(categories, link)
+	assert.Empty(t, system)
+	require.Len(t, owned, 1)
+	assert.Equal(t, 2, owned[0].FileCount)
+}
+
+// The daemon runs as root with no invoking user, so it has no helper to hand
+// home files to: every file in a home other than root's is refused.
+func TestSplitOtherHomesRefusesHomesForTheDaemon(t *testing.T) {
+	base := t.TempDir()
+	alice := filepath.Join(base, "home", "alice")
+	rootHome := filepath.Join(base, "root")
+	owners := map[string]uint32{alice: 1000, rootHome: 0, filepath.Join(alice, "shared"): 1001}
+	homes := map[uint32]string{1000: alice, 1001: filepath.Join(base, "home", "bob"), 0: rootHome}
+
+	origOwner, origHome := dirOwner, userHome
+	t.Cleanup(func() { dirOwner, userHome = origOwner, origHome })
+	dirOwner = func(dir string) (uint32, bool) {
+		uid, ok := owners[dir]
+		return uid, ok
+	}
+	userHome = func(uid uint32) string { return homes[uid] }
+
+	categories := []config.Category{
+		{
+			Name: "Caches",
+			Files: []config.FileInfo{
+				{Path: filepath.Join(alice, ".cache", "a"), Size: 1},
+				{Path: filepath.Join(alice, "shared", "b"), Size: 2},
+				{Path: filepath.Join(rootHome, ".cache", "c"), Size: 4},
+				{Path: "/var/cache/app/d", Size: 8},
+				{Path: alice + "-old/e", Size: 16},
+			},
+			FileCount: 5,
+			Size:      31,
+		},
+		{Name: "Alice", Files: []config.FileInfo{{Path: filepath.Join(alice, "f"), Size: 32}}, FileCount: 1, Size: 32},
+	}
+
+	system, foreign := SplitOtherHomes(categories)
+
+	require.Len(t, system, 1)
+	assert.Equal(t, "Caches", system[0].Name)
+	assert.Equal(t, uint64(28), system[0].Size, "root's home, system paths and a home's sibling stay")
+	require.Len(t, foreign, 2)
+	assert.Equal(t, "Caches", foreign[0].Name)
+	assert.Equal(t, 2, foreign[0].FileCount, "a directory owned by another user inside a home is still that home")
+	assert.Equal(t, "Alice", foreign[1].Name)
+}
+
+func TestNewRequestCarriesFileSettings(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Safety.ShredPasses = 3
+	categories := []config.Category{{
+		Name:         "Logs",
+		Risk:         config.Low,
+		Paths:        []string{"/home/u/.local/state/logs"},
+		ShredEnabled: true,
+		Action:       config.ActionTruncate,
+		Files: []config.FileInfo{
+			{Path: "/home/u/.local/state/logs/a.log", Size: 5},
+			{Path: "/home/u/.local/state/logs/b.log", Size: 6, CategoryAction: config.ActionTrash},
+		},
+	}}
+
+	// The request must survive the trip through JSON intact.
+	data, err := json.Marshal(NewRequest(cfg, categories))
+	require.NoError(t, err)
+	var req Request
+	require.NoError(t, json.Unmarshal(data, &req))
+
+	assert.Equal(t, 3, req.Safety.ShredPasses)
+	require.Len(t, req.Categories, 1)
+	got := req.categories()[0]
+	assert.Equal(t, "Logs", got.Name)
+	assert.Equal(t, config.Low, got.Risk)
+	assert.Equal(t, uint64(11), got.Size)
+	require.Len(t, got.Files, 2)
+	assert.True(t, got.Files[0].CategoryShred)
+	assert.Equal(t, config.ActionTruncate, got.Files[0].CategoryAction)
+	assert.Equal(t, config.ActionTrash, got.Files[1].CategoryAction)
+	assert.Equal(t, "Logs", got.Files[1].CategoryName)
+}
+
+func TestServe(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+	deleted := filepath.Join(dir, "deleted")
+	truncated := filepath.Join(dir, "truncated.log")
+	require.NoError(t, os.WriteFile(deleted, []byte("12345"), 0644))
+	require.NoError(t, os.WriteFile(truncated, []byte("123"), 0644))
+
+	cfg := config.DefaultConfig()
+	cfg.Backup.Enabled = false
+	req := NewRequest(cfg, []config.Category{
+		{Name: "Cache", Risk: config.Low, Paths: []string{dir}, Files: []config.FileInfo{{Path: deleted, Size: 5}}},
+		{Name: "Logs", Risk: config.Low, Paths: []string{dir}, Action: config.ActionTruncate, Files: []config.FileInfo{{Path: truncated, Size: 3}}},
+	})
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, Serve(context.Background(), bytes.NewReader(body), &out))
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(out.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+	for _, result := range resp.Results {
+		require.NotNil(t, result.Complete, result.Error)
+		assert.Empty(t, result.Complete.Errors)
+	}
+	assert.Equal(t, "Cache", resp.Results[0].Name)
+	assert.Equal(t, 1, resp.Results[0].Complete.FilesDeleted)
+
+	_, err = os.Lstat(deleted)
+	assert.True(t, os.IsNotExist(err))
+	info, err := os.Stat(truncated)
+	require.NoError(t, err, "a truncate action keeps the file")
+	assert.Zero(t, info.Size())
+}
+
+func TestServeRejectsInvalidRequest(t *testing.T) {
+	var out bytes.Buffer
+	err := Serve(context.Background(), bytes.NewReader([]byte("{")), &out)
+	assert.Error(t, err)
+	assert.Zero(t, out.Len())
+}
+
+func TestHelperEnvPassesOnlyTheUsersEnvironment(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/home/u/.cache")
+	t.Setenv("MOONBIT_SECRET", "root's")
+	env := helperEnv(&user.User{Username: "u"}, "/home/u")
+
+	assert.Contains(t, env, "HOME=/home/u")
+	assert.Contains(t, env, "MOONBIT_HOME=/home/u")
+	assert.Contains(t, env, "USER=u")
+	assert.Contains(t, env, "XDG_CACHE_HOME=/home/u/.cache")
+	for _, kv := range env {
+		assert.NotContains(t, kv, "MOONBIT_SECRET")
+	}
+}
+
+func TestInvokingUserIgnoresRoot(t *testing.T) {
+	t.Setenv("SUDO_USER", "root")
+	t.Setenv("PKEXEC_UID", "")
+	u, err := InvokingUser()
+	require.NoError(t, err)
+	assert.Nil(t, u)
+}
@@ -0,0 +1,231 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirIndexVersion is the on-disk format of the directory index.
+const DirIndexVersion = 1
+
+const (
+	// indexRacyWindow guards against directory timestamps that are too coarse to
+	// tell two changes apart. A directory whose mtime falls this close to the
+	// moment it was listed could have changed again within the same tick, so its
+	// listing is never trusted.
+	indexRacyWindow = 2 * time.Second
+
+	// indexEntryMaxAge bounds how long a listing is reused. A directory's mtime
+	// only moves when entries are added, removed or renamed; a file rewritten in
+	// place leaves it untouched. Re-listing once a day keeps recorded sizes and
+	// timestamps from drifting indefinitely.
+	indexEntryMaxAge = 24 * time.Hour
+
+	// indexPruneAge drops directories that no scan has visited for this long,
+	// such as categories removed from the config.
+	indexPruneAge = 7 * 24 * time.Hour
+)
+
+// IndexedEntry is one regular file or subdirectory in an indexed listing.
+type IndexedEntry struct {
+	Name    string      `json:"name"`
+	Dir     bool        `json:"dir,omitempty"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime int64       `json:"mtime,omitempty"` // UnixNano
+	Mode    os.FileMode `json:"mode,omitempty"`
+}
+
+// IndexedDir is the listing of one directory as of ListedAt.
+type IndexedDir struct {
+	ModTime  int64          `json:"mtime"`     // UnixNano of the directory itself
+	ListedAt int64          `json:"listed_at"` // UnixNano
+	SeenAt   int64          `json:"seen_at"`   // UnixNano of the last scan that visited it
+	Entries  []IndexedEntry `json:"entries,omitempty"`
+}
+
+// DirIndex remembers directory listings between scans so unchanged directories
+// are not read again. Every directory is still stat'ed on each scan; only the
+// listing and the per-file stat calls are skipped when its mtime is unchanged.
+//
+// The index is an optimisation only, but a scan does trust it to skip
+// re-listing directories, so it must be as trustworthy as the scan itself: a
+// root scan keeps it in the root-owned state directory (see
+// session.OpenDirIndex), never in the invoking user's cache. Even so, a
+// doctored index can at most make a scan report stale or missing files;
+// validation.RevalidateCache re-checks every path before anything is deleted.
+type DirIndex struct {
+	Version int                    `json:"version"`
+	Dirs    map[string]*IndexedDir `json:"dirs"`
+
+	mu     sync.Mutex
+	reused int
+	listed int
+}
+
+// NewDirIndex returns an empty index.
+func NewDirIndex() *DirIndex {
+	return &DirIndex{Version: DirIndexVersion, Dirs: make(map[string]*IndexedDir)}
+}
+
+// LoadDirIndex reads an index from path. A missing file yields an empty index.
+// An unreadable or incompatible file also yields an empty index, along with an
+// error describing why, so callers can warn and carry on with a full walk.
+func LoadDirIndex(path string) (*DirIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewDirIndex(), nil
+		}
+		return NewDirIndex(), fmt.Errorf("failed to read directory index: %w", err)
+	}
+	return ParseDirIndex(data)
+}
+
+// ParseDirIndex decodes an index read by the caller. Like LoadDirIndex it
+// always returns a usable index, empty when data cannot be used.
+func ParseDirIndex(data []byte) (*DirIndex, error) {
+	var idx DirIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return NewDirIndex(), fmt.Errorf("failed to parse directory index: %w", err)
+	}
+	if idx.Version != DirIndexVersion {
+		return NewDirIndex(), fmt.Errorf("directory index format v%d is not supported; rebuilding", idx.Version)
+	}
+
+	clean := NewDirIndex()
+	for dir, entry := range idx.Dirs {
+		if entry == nil || !filepath.IsAbs(dir) || filepath.Clean(dir) != dir || !validEntries(entry.Entries) {
+			continue
+		}
+		clean.Dirs[dir] = entry
+	}
+	return clean, nil
+}
+
+// validEntries rejects listings whose names could point outside their directory.
+func validEntries(entries []IndexedEntry) bool {
+	for _, e := range entries {
+		if e.Name == "" || e.Name == "." || e.Name == ".." || strings.ContainsRune(e.Name, filepath.Separator) {
+			return false
+		}
+	}
+	return true
+}
+
+// Save writes the index to path, dropping directories no scan has visited
+// recently. The write goes through a uniquely named temporary file beside it
+// and a rename, so an interrupted save never leaves a truncated index behind
+// and a symlink planted in the directory is replaced rather than followed.
+func (idx *DirIndex) Save(path string) error {
+	idx.mu.Lock()
+	cutoff := time.Now().Add(-indexPruneAge).UnixNano()
+	for dir, entry := range idx.Dirs {
+		if entry.SeenAt < cutoff {
+			delete(idx.Dirs, dir)
+		}
+	}
+	data, err := json.Marshal(idx)
+	idx.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal directory index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".dir_index-*")
+	if err != nil {
+		return fmt.Errorf("failed to write directory index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write directory index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write directory index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write directory index: %w", err)
+	}
+	return nil
+}
+
+// Stats reports how many directory listings were reused from the index and how
+// many had to be read from disk since the index was loaded.
+func (idx *DirIndex) Stats() (reused, listed int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.reused, idx.listed
+}
+
+// lookup returns the recorded listing for dir if it is still current.
+func (idx *DirIndex) lookup(dir string, modTime, now time.Time) ([]IndexedEntry, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	entry, ok := idx.Dirs[dir]
+	if !ok || entry.ModTime != modTime.UnixNano() {
+		return nil, false
+	}
+	listedAt := time.Unix(0, entry.ListedAt)
+	if !modTime.Before(listedAt.Add(-indexRacyWindow)) || now.Sub(listedAt) > indexEntryMaxAge {
+		return nil, false
+	}
+	entry.SeenAt = now.UnixNano()
+	idx.reused++
+	return entry.Entries, true
+}
+
+// store records a fresh listing for dir.
+func (idx *DirIndex) store(dir string, modTime, now time.Time, entries []IndexedEntry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.Dirs[dir] = &IndexedDir{
+		ModTime:  modTime.UnixNano(),
+		ListedAt: now.UnixNano(),
+		SeenAt:   now.UnixNano(),
+		Entries:  entries,
+	}
+	idx.listed++
+}
+
+// indexEntries converts a directory listing into index entries, keeping only
+// what a scan can act on: regular files and real (non-symlink) directories.
+func indexEntries(infos []os.FileInfo) []IndexedEntry {
+	entries := make([]IndexedEntry, 0, len(infos))
+	for _, info := range infos {
+		switch {
+		case info.IsDir():
+			entries = append(entries, IndexedEntry{Name: info.Name(), Dir: true})
+		case info.Mode().IsRegular():
+			entries = append(entries, IndexedEntry{
+				Name:    info.Name(),
+				Size:    info.Size(),
+				ModTime: info.ModTime().UnixNano(),
+				Mode:    info.Mode(),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// indexedFileInfo presents a listed or recorded file as an os.FileInfo so the
+// usual include checks can run against it.
+type indexedFileInfo struct {
+	entry IndexedEntry
+}
+
+func (fi indexedFileInfo) Name() string       { return fi.entry.Name }
+func (fi indexedFileInfo) Size() int64        { return fi.entry.Size }
+func (fi indexedFileInfo) Mode() os.FileMode  { return fi.entry.Mode }
+func (fi indexedFileInfo) ModTime() time.Time { return time.Unix(0, fi.entry.ModTime) }
+func (fi indexedFileInfo) IsDir() bool        { return fi.entry.Dir }
+func (fi indexedFileInfo) Sys() interface{}   { return nil }
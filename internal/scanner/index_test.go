@@ -0,0 +1,164 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanWithIndex(t *testing.T, idx *DirIndex, cat *config.Category) []string {
+	t.Helper()
+	s := NewScanner(&config.Config{})
+	s.UseIndex(idx)
+	ch := make(chan ScanMsg, 64)
+	go s.ScanCategory(context.Background(), cat, ch)
+
+	var paths []string
+	for msg := range ch {
+		require.NoError(t, msg.Error)
+		if msg.Complete != nil {
+			for _, f := range msg.Complete.Stats.Files {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+	return paths
+}
+
+// backdate moves a directory's mtime out of the index's racy window, as if it
+// had last changed an hour ago.
+func backdate(t *testing.T, dir string, offset time.Duration) {
+	t.Helper()
+	then := time.Now().Add(-offset)
+	require.NoError(t, os.Chtimes(dir, then, then))
+}
+
+func TestIndexedScanReusesUnchangedDirectories(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "registry", "pkg")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "top.bin"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "deep.bin"), []byte("bb"), 0644))
+	for _, dir := range []string{root, filepath.Join(root, "registry"), sub} {
+		backdate(t, dir, time.Hour)
+	}
+	cat := &config.Category{Name: "Build Cache", Paths: []string{root}}
+
+	idx := NewDirIndex()
+	first := scanWithIndex(t, idx, cat)
+	reused, listed := idx.Stats()
+	assert.Equal(t, 0, reused)
+	assert.Equal(t, 3, listed)
+
+	second := scanWithIndex(t, idx, cat)
+	reused, listed = idx.Stats()
+	assert.Equal(t, 3, reused, "unchanged directories come from the index")
+	assert.Equal(t, 3, listed, "nothing was read from disk again")
+	assert.Equal(t, first, second)
+	assert.Equal(t, []string{filepath.Join(sub, "deep.bin"), filepath.Join(root, "top.bin")}, second)
+}
+
+func TestIndexedScanRereadsChangedDirectories(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "old.bin"), []byte("a"), 0644))
+	backdate(t, root, 2*time.Hour)
+	backdate(t, sub, 2*time.Hour)
+	cat := &config.Category{Name: "Build Cache", Paths: []string{root}}
+
+	idx := NewDirIndex()
+	scanWithIndex(t, idx, cat)
+
+	// A new file in a nested directory moves only that directory's mtime; the
+	// parent's listing is still reused but the change must be found.
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "new.bin"), []byte("b"), 0644))
+	backdate(t, sub, time.Hour)
+
+	got := scanWithIndex(t, idx, cat)
+	assert.ElementsMatch(t, []string{filepath.Join(sub, "old.bin"), filepath.Join(sub, "new.bin")}, got)
+}
+
+func TestIndexedScanDoesNotTrustRacyListings(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.bin"), []byte("a"), 0644))
+	cat := &config.Category{Name: "Fresh", Paths: []string{root}}
+
+	idx := NewDirIndex()
+	scanWithIndex(t, idx, cat)
+	scanWithIndex(t, idx, cat)
+
+	reused, _ := idx.Stats()
+	assert.Equal(t, 0, reused, "a directory modified moments before listing may change again unseen")
+}
+
+func TestDirIndexSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moonbit", "dir_index.json")
+	idx := NewDirIndex()
+	now := time.Now()
+	idx.store("/var/cache/pkg", now.Add(-time.Hour), now, []IndexedEntry{{Name: "a.pkg", Size: 10}})
+
+	require.NoError(t, idx.Save(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadDirIndex(path)
+	require.NoError(t, err)
+	require.Contains(t, loaded.Dirs, "/var/cache/pkg")
+	assert.Equal(t, "a.pkg", loaded.Dirs["/var/cache/pkg"].Entries[0].Name)
+}
+
+func TestDirIndexSaveDoesNotFollowPlantedSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	victim := filepath.Join(dir, "victim")
+	require.NoError(t, os.WriteFile(victim, []byte("keep"), 0644))
+	path := filepath.Join(dir, "dir_index.json")
+	require.NoError(t, os.Symlink(victim, path+".tmp"))
+
+	require.NoError(t, NewDirIndex().Save(path))
+
+	data, err := os.ReadFile(victim)
+	require.NoError(t, err)
+	assert.Equal(t, "keep", string(data))
+	info, err := os.Lstat(path)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+}
+
+func TestLoadDirIndexDropsEntriesThatEscapeTheirDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir_index.json")
+	data := `{"version":1,"dirs":{
+		"/var/cache/pkg":{"mtime":1,"listed_at":1,"seen_at":1,"entries":[{"name":"../../etc/shadow","size":1}]},
+		"relative/dir":{"mtime":1,"listed_at":1,"seen_at":1},
+		"/var/cache/ok":{"mtime":1,"listed_at":1,"seen_at":1,"entries":[{"name":"fine.bin"}]}
+	}}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+
+	idx, err := LoadDirIndex(path)
+	require.NoError(t, err)
+	assert.NotContains(t, idx.Dirs, "/var/cache/pkg")
+	assert.NotContains(t, idx.Dirs, "relative/dir")
+	assert.Contains(t, idx.Dirs, "/var/cache/ok")
+}
+
+func TestLoadDirIndexFallsBackToEmptyIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	idx, err := LoadDirIndex(filepath.Join(dir, "missing.json"))
+	require.NoError(t, err)
+	assert.Empty(t, idx.Dirs)
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{not json"), 0600))
+	idx, err = LoadDirIndex(corrupt)
+	assert.Error(t, err)
+	require.NotNil(t, idx)
+	assert.Empty(t, idx.Dirs)
+}
@@ -19,8 +19,11 @@ import (
 // FileSystem provides an abstraction for filesystem operations
 type FileSystem interface {
 	Stat(name string) (os.FileInfo, error)
+	// Lstat is Stat that describes a symlink itself rather than its target.
+	Lstat(name string) (os.FileInfo, error)
 	Walk(root string, walkFunc filepath.WalkFunc) error
 	ReadDir(dirname string) ([]os.FileInfo, error)
+	Open(name string) (afero.File, error)
 }
 
 // OsFileSystem implements FileSystem for real OS filesystem using godirwalk
@@ -30,6 +33,10 @@ func (fs *OsFileSystem) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
 
+func (fs *OsFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
 func (fs *OsFileSystem) Walk(root string, walkFunc filepath.WalkFunc) error {
 	return godirwalk.Walk(root, &godirwalk.Options{
 		FollowSymbolicLinks: false,
@@ -49,6 +56,10 @@ func (fs *OsFileSystem) Walk(root string, walkFunc filepath.WalkFunc) error {
 	})
 }
 
+func (fs *OsFileSystem) Open(name string) (afero.File, error) {
+	return os.Open(name)
+}
+
 func (fs *OsFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) {
 	entries, err := afero.ReadDir(afero.NewOsFs(), dirname)
 	if err != nil {
@@ -73,10 +84,22 @@ func (fs *AferoFileSystem) Stat(name string) (os.FileInfo, error) {
 	return fs.fs.Stat(name)
 }
 
+func (fs *AferoFileSystem) Lstat(name string) (os.FileInfo, error) {
+	if lstater, ok := fs.fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+	return fs.fs.Stat(name)
+}
+
 func (fs *AferoFileSystem) Walk(root string, walkFunc filepath.WalkFunc) error {
 	return afero.Walk(fs.fs, root, walkFunc)
 }
 
+func (fs *AferoFileSystem) Open(name string) (afero.File, error) {
+	return fs.fs.Open(name)
+}
+
 func (fs *AferoFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) {
 	return afero.ReadDir(fs.fs, dirname)
 }
@@ -95,10 +118,17 @@ type ScanComplete struct {
 	Category string
 	Stats    *config.Category
 	Duration time.Duration
+	// Roots are the expanded category paths that existed and were walked.
+	Roots []string
+	// Errors are non-fatal problems, such as a root that could not be read.
+	Errors []string
 }
 
 // ScanMsg represents messages from the scanner
 type ScanMsg struct {
+	// Category names the category the message belongs to. It is set on
+	// messages from ScanCategories.
+	Category string
 	Progress *ScanProgress
 	Complete *ScanComplete
 	Error    error
@@ -110,6 +140,10 @@ type Scanner struct {
 	filter  *regexp.Regexp
 	workers int
 	fs      FileSystem
+	// index, when set, lets walks skip re-reading unchanged directories.
+	index *DirIndex
+	// slots bounds how many walks run at once across categories and subtrees.
+	slots chan struct{}
 }
 
 // NewScanner creates a new scanner instance
@@ -131,6 +165,7 @@ func NewScanner(cfg *config.Config) *Scanner {
 		filter:  compileIgnoreFilter(cfg.Scan.IgnorePatterns),
 		workers: workerCount,
 		fs:      &OsFileSystem{},
+		slots:   make(chan struct{}, workerCount),
 	}
 }
 
@@ -153,10 +188,29 @@ func NewScannerWithFs(cfg *config.Config, fs FileSystem) *Scanner {
 		filter:  compileIgnoreFilter(cfg.Scan.IgnorePatterns),
 		workers: workerCount,
 		fs:      fs,
+		slots:   make(chan struct{}, workerCount),
 	}
 }
 
+// UseIndex makes subsequent scans read and update idx, so directories whose
+// mtime has not changed since they were last listed are not read again. The
+// caller owns loading and saving the index.
+func (s *Scanner) UseIndex(idx *DirIndex) {
+	s.index = idx
+}
+
 func compileIgnoreFilter(patterns []string) *regexp.Regexp {
+	filter, err := CompileIgnorePatterns(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+// CompileIgnorePatterns joins scan.ignore_patterns into one regular
+// expression matched anywhere in a full path. It returns nil when there are
+// no patterns.
+func CompileIgnorePatterns(patterns []string) (*regexp.Regexp, error) {
 	cleaned := make([]string, 0, len(patterns))
 	for _, pattern := range patterns {
 		if pattern != "" {
@@ -164,9 +218,9 @@ func compileIgnoreFilter(patterns []string) *regexp.Regexp {
 		}
 	}
 	if len(cleaned) == 0 {
-		return nil
+		return nil, nil
 	}
-	return regexp.MustCompile("(" + strings.Join(cleaned, "|") + ")")
+	return regexp.Compile("(" + strings.Join(cleaned, "|") + ")")
 }
 
 // ScanCategory scans a specific category
@@ -181,28 +235,56 @@ func (s *Scanner) ScanCategory(ctx context.Context, category *config.Category, p
 	stats.FileCount = 0
 	stats.Selected = category.Selected
 
+	complete := &ScanComplete{Category: category.Name, Stats: &stats}
+
 	// Process each path in the category
 	for _, pathPattern := range category.Paths {
-		if err := s.scanPath(ctx, pathPattern, &stats, progressCh); err != nil {
+		if err := s.scanPath(ctx, pathPattern, &stats, complete, progressCh); err != nil {
 			progressCh <- ScanMsg{Error: err}
 			return
 		}
 	}
 
-	duration := time.Since(start)
+	// Keep-last and size-cap retention can only be judged once every file in
+	// the category is known.
+	if category.Retention != nil {
+		applyRetention(&stats)
+	}
+
+	complete.Duration = time.Since(start)
 
 	// Send completion message
-	progressCh <- ScanMsg{
-		Complete: &ScanComplete{
-			Category: category.Name,
-			Stats:    &stats,
-			Duration: duration,
-		},
+	progressCh <- ScanMsg{Complete: complete}
+}
+
+// EligibleFiles lists the category's files that pass its filters, exclude
+// patterns, min_age_days and unused_days, before keep_last and max_size are
+// applied: the set those two limits are judged against.
+func (s *Scanner) EligibleFiles(ctx context.Context, category *config.Category) ([]config.FileInfo, error) {
+	eligible := *category
+	if category.Retention != nil {
+		policy := *category.Retention
+		policy.KeepLast, policy.MaxSize = 0, ""
+		eligible.Retention = &policy
 	}
+
+	ch := make(chan ScanMsg, 10)
+	go s.ScanCategory(ctx, &eligible, ch)
+	var files []config.FileInfo
+	var err error
+	for msg := range ch {
+		if msg.Error != nil {
+			err = msg.Error
+		}
+		if msg.Complete != nil {
+			files = msg.Complete.Stats.Files
+		}
+	}
+	return files, err
 }
 
 // ScanPath scans a specific path with pattern support
-func (s *Scanner) scanPath(ctx context.Context, pathPattern string, stats *config.Category, progressCh chan<- ScanMsg) error {
+func (s *Scanner) scanPath(ctx context.Context, pathPattern string, stats *config.Category, complete *ScanComplete, progressCh chan<- ScanMsg) error {
 	// Expand path patterns (supports wildcards like /home/*/.cache)
 	paths, err := expandPathPattern(pathPattern)
 	if err != nil {
@@ -210,9 +292,14 @@ func (s *Scanner) scanPath(ctx context.Context, pathPattern string, stats *confi
 	}
 
 	for _, path := range paths {
+		if _, err := s.fs.Stat(path); err == nil {
+			complete.Roots = append(complete.Roots, path)
+		}
 		if err := s.walkDirectory(ctx, path, stats, progressCh); err != nil {
 			if os.IsPermission(err) {
-				// Skip permission errors silently for cleaner UX
+				// Skip permission errors for cleaner UX, but keep a record so the
+				// session cache can say which roots were not scanned.
+				complete.Errors = append(complete.Errors, err.Error())
 				continue
 			}
 			return err
@@ -224,7 +311,8 @@ func (s *Scanner) scanPath(ctx context.Context, pathPattern string, stats *confi
 
 // WalkDirectory performs the actual directory walking
 func (s *Scanner) walkDirectory(ctx context.Context, rootPath string, stats *config.Category, progressCh chan<- ScanMsg) error {
-	if info, err := s.fs.Stat(rootPath); err != nil {
+	info, err := s.fs.Stat(rootPath)
+	if err != nil {
 		if os.IsNotExist(err) {
 			// Silently skip non-existent paths (normal for wildcard patterns)
 			return nil
@@ -232,7 +320,8 @@ func (s *Scanner) walkDirectory(ctx context.Context, rootPath string, stats *con
 		// Only log actual errors (not permission issues or missing paths)
 		log.Printf("ERROR: Failed to stat path %s: %v", rootPath, err)
 		return err
-	} else if !info.IsDir() {
+	}
+	if !info.IsDir() {
 		// Category paths are glob-expanded, so this entry may be a symlink that
 		// Stat resolved to a regular file. Judge it by Lstat before collecting it.
 		linfo, lerr := os.Lstat(rootPath)
@@ -245,7 +334,12 @@ func (s *Scanner) walkDirectory(ctx context.Context, rootPath string, stats *con
 		return nil
 	}
 
-	// Use our filesystem abstraction to walk directories
+	if s.index != nil || s.workers > 1 {
+		return s.walkTree(ctx, rootPath, info, stats, progressCh)
+	}
+
+	// A single worker without an index walks sequentially through the
+	// filesystem abstraction.
 	return s.fs.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
 		// Check for context cancellation
 		select {
@@ -283,28 +377,53 @@ func (s *Scanner) walkDirectory(ctx context.Context, rootPath string, stats *con
 			return nil
 		}
 
-		// Process file based on category filters
-		if s.shouldIncludeFile(path, info, stats) {
-			addFileToStats(stats, path, info)
-
-			// Send progress update periodically (every 100 files or every 10MB)
-			shouldUpdate := stats.FileCount%100 == 0 || stats.Size%10485760 == 0 // 10MB chunks
-			if shouldUpdate && stats.FileCount > 0 {
-				progressCh <- ScanMsg{
-					Progress: &ScanProgress{
-						Path:         path,
-						Bytes:        stats.Size,
-						FilesScanned: stats.FileCount,
-						CurrentDir:   filepath.Dir(path),
-					},
-				}
-			}
-		}
-
+		s.collectFile(path, info, stats, progressCh)
 		return nil
 	})
 }
 
+// skipPath reports whether the scan-wide ignore patterns or the category's
+// exclude patterns rule out path (and, for a directory, everything below it).
+func (s *Scanner) skipPath(path string, stats *config.Category) bool {
+	if s.filter != nil && s.filter.MatchString(path) {
+		return true
+	}
+	return matchesAnyPattern(categoryExcludePatterns(stats), path)
+}
+
+// collectFile adds path to stats when the category's filters accept it, sending
+// a progress update every so often.
+func (s *Scanner) collectFile(path string, info os.FileInfo, stats *config.Category, progressCh chan<- ScanMsg) {
+	if !s.shouldIncludeFile(path, info, stats) {
+		return
+	}
+	addFileToStats(stats, path, info)
+
+	// Send progress update periodically (every 100 files or every 10MB)
+	shouldUpdate := stats.FileCount%100 == 0 || stats.Size%10485760 == 0 // 10MB chunks
+	if shouldUpdate && stats.FileCount > 0 {
+		progressCh <- ScanMsg{
+			Progress: &ScanProgress{
+				Path:         path,
+				Bytes:        stats.Size,
+				FilesScanned: stats.FileCount,
+				CurrentDir:   filepath.Dir(path),
+			},
+		}
+	}
+}
+
+// applyRetention narrows stats to the files its retention policy allows to be
+// cleaned.
+func applyRetention(stats *config.Category) {
+	stats.Files = stats.Retention.Cleanable(stats.Files)
+	stats.Size = 0
+	for _, f := range stats.Files {
+		stats.Size += f.Size
+	}
+	stats.FileCount = len(stats.Files)
+}
+
 func addFileToStats(stats *config.Category, path string, info os.FileInfo) {
 	cleanPath := filepath.Clean(path)
 	for _, existing := range stats.Files {
@@ -349,6 +468,11 @@ func (s *Scanner) shouldIncludeFile(path string, info os.FileInfo, category *con
 		}
 	}
 
+	// Check access-time retention (if unused_days is set)
+	if !category.Retention.AllowsAccessTime(info, time.Now()) {
+		return false
+	}
+
 	// Apply category-specific filters (FIXED: OR logic, not AND)
 	if len(category.Filters) > 0 {
 		// File must match at least one filter to be included
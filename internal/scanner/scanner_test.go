@@ -466,3 +466,67 @@ func TestWalkDirectory_NonexistentPath(t *testing.T) {
 	err := s.walkDirectory(ctx, "/nonexistent/path/that/does/not/exist", category, progressCh)
 	assert.NoError(t, err)
 }
+
+func TestScanCategoryReportsScannedRoots(t *testing.T) {
+	tempDir := t.TempDir()
+	present := filepath.Join(tempDir, "present")
+	require.NoError(t, os.MkdirAll(present, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(present, "a.bin"), []byte("data"), 0644))
+
+	s := NewScanner(&config.Config{})
+	category := &config.Category{
+		Name:  "Roots",
+		Paths: []string{present, filepath.Join(tempDir, "absent")},
+	}
+	progressCh := make(chan ScanMsg, 10)
+
+	go s.ScanCategory(context.Background(), category, progressCh)
+
+	var complete *ScanComplete
+	for msg := range progressCh {
+		if msg.Error != nil {
+			t.Fatalf("scan returned error: %v", msg.Error)
+		}
+		if msg.Complete != nil {
+			complete = msg.Complete
+		}
+	}
+
+	require.NotNil(t, complete)
+	assert.Equal(t, []string{present}, complete.Roots, "only roots that exist were scanned")
+	assert.Empty(t, complete.Errors)
+}
+
+func TestScanCategoryAppliesRetentionPolicy(t *testing.T) {
+	tempDir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"a-1-1-any.pkg.tar.zst", "a-2-1-any.pkg.tar.zst", "a-3-1-any.pkg.tar.zst"} {
+		path := filepath.Join(tempDir, name)
+		require.NoError(t, os.WriteFile(path, []byte("pkg"), 0644))
+		mtime := now.Add(-time.Duration(3-i) * time.Hour)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	category := &config.Category{
+		Name:      "Pacman Cache",
+		Paths:     []string{tempDir},
+		Retention: &config.RetentionPolicy{KeepLast: 1, GroupBy: config.GroupByPackage},
+	}
+	progressCh := make(chan ScanMsg, 10)
+	go NewScanner(&config.Config{}).ScanCategory(context.Background(), category, progressCh)
+
+	var stats *config.Category
+	for msg := range progressCh {
+		require.NoError(t, msg.Error)
+		if msg.Complete != nil {
+			stats = msg.Complete.Stats
+		}
+	}
+
+	require.NotNil(t, stats)
+	require.Equal(t, 2, stats.FileCount, "the newest version is kept")
+	assert.Equal(t, uint64(6), stats.Size)
+	for _, f := range stats.Files {
+		assert.NotContains(t, f.Path, "a-3-1")
+	}
+}
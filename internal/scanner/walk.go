@@ -0,0 +1,234 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
+)
+
+// ScanCategories scans several categories at once on the scanner's worker pool.
+//
+// Progress messages are forwarded as they arrive and carry the category name.
+// Each category then ends with exactly one Complete or Error message, and those
+// are delivered in the order of categories no matter which scan finishes
+// first, so callers can merge results deterministically by counting them.
+func (s *Scanner) ScanCategories(ctx context.Context, categories []config.Category, progressCh chan<- ScanMsg) {
+	defer close(progressCh)
+
+	results := make([]ScanMsg, len(categories))
+	done := make([]chan struct{}, len(categories))
+	for i := range categories {
+		done[i] = make(chan struct{})
+		go func(i int) {
+			defer close(done[i])
+			category := &categories[i]
+
+			select {
+			case s.slots <- struct{}{}:
+				defer func() { <-s.slots }()
+			case <-ctx.Done():
+				results[i] = ScanMsg{Category: category.Name, Error: ctx.Err()}
+				return
+			}
+
+			ch := make(chan ScanMsg, 10)
+			go s.ScanCategory(ctx, category, ch)
+			for msg := range ch {
+				msg.Category = category.Name
+				if msg.Progress != nil {
+					progressCh <- msg
+					continue
+				}
+				results[i] = msg
+			}
+			if results[i].Complete == nil && results[i].Error == nil {
+				results[i].Error = fmt.Errorf("scan completed without results")
+			}
+		}(i)
+	}
+
+	for i := range categories {
+		<-done[i]
+		progressCh <- results[i]
+	}
+}
+
+// walkTree walks root depth first with entries in lexical order, the same order
+// fs.Walk produces, while handing subdirectories to idle workers. Directory
+// listings come from the index when one is in use and the directory is
+// unchanged. Files are merged into stats only after every worker has finished,
+// so the result is identical to a sequential walk.
+func (s *Scanner) walkTree(ctx context.Context, root string, info os.FileInfo, stats *config.Category, progressCh chan<- ScanMsg) error {
+	if s.skipPath(root, stats) {
+		return nil
+	}
+
+	w := &treeWalk{s: s, ctx: ctx, stats: stats, progressCh: progressCh}
+	w.files.Store(int64(stats.FileCount))
+	w.bytes.Store(stats.Size)
+
+	node := &walkNode{}
+	w.walk(root, info, node)
+	w.wg.Wait()
+	return node.merge(stats)
+}
+
+// treeWalk is one root being walked. stats is only read while the walk runs.
+type treeWalk struct {
+	s          *Scanner
+	ctx        context.Context
+	stats      *config.Category
+	progressCh chan<- ScanMsg
+	wg         sync.WaitGroup
+	files      atomic.Int64
+	bytes      atomic.Uint64
+}
+
+// walkNode holds one directory's contribution in walk order. A subdirectory
+// walked by another worker is a child node filled in by that worker.
+type walkNode struct {
+	items []walkItem
+	err   error
+}
+
+type walkItem struct {
+	path string
+	info os.FileInfo
+	sub  *walkNode
+}
+
+func (w *treeWalk) walk(dir string, info os.FileInfo, node *walkNode) {
+	select {
+	case <-w.ctx.Done():
+		node.err = w.ctx.Err()
+		return
+	default:
+	}
+
+	entries, err := w.s.listDir(dir, info)
+	if err != nil {
+		node.err = err
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name)
+		if w.s.skipPath(path, w.stats) {
+			continue
+		}
+
+		if !entry.Dir {
+			var fi os.FileInfo = indexedFileInfo{entry: entry}
+			if w.stats.Retention.NeedsAccessTime() {
+				// Access times move without touching the directory, and
+				// listings do not record them, so stat the file itself.
+				// Lstat: a symlink swapped in since the listing is rejected
+				// by shouldIncludeFile, not judged by its target.
+				fresh, err := w.s.fs.Lstat(path)
+				if err != nil {
+					continue
+				}
+				fi = fresh
+			}
+			if w.s.shouldIncludeFile(path, fi, w.stats) {
+				node.items = append(node.items, walkItem{path: path, info: fi})
+				w.progress(path, fi)
+			}
+			continue
+		}
+
+		// A reused listing says nothing about the subdirectory's own contents,
+		// so always look at its current mtime. Lstat, so a directory swapped
+		// for a symlink since the listing is never descended: run as root,
+		// that would scan wherever the link points.
+		sub, err := w.s.fs.Lstat(path)
+		if err != nil {
+			if !os.IsPermission(err) && !os.IsNotExist(err) {
+				log.Printf("ERROR: Unexpected error accessing %s: %v", path, err)
+			}
+			continue
+		}
+		if !sub.IsDir() {
+			continue
+		}
+
+		child := &walkNode{}
+		node.items = append(node.items, walkItem{sub: child})
+		select {
+		case w.s.slots <- struct{}{}:
+			w.wg.Add(1)
+			go func() {
+				defer w.wg.Done()
+				defer func() { <-w.s.slots }()
+				w.walk(path, sub, child)
+			}()
+		default:
+			// Every worker is busy; walk it here rather than wait for one.
+			w.walk(path, sub, child)
+		}
+	}
+}
+
+// progress sends an update every 100 collected files. The counts cover the
+// whole category, across every worker walking it.
+func (w *treeWalk) progress(path string, info os.FileInfo) {
+	files := w.files.Add(1)
+	bytes := w.bytes.Add(uint64(info.Size()))
+	if files%100 != 0 {
+		return
+	}
+	w.progressCh <- ScanMsg{
+		Progress: &ScanProgress{
+			Path:         path,
+			Bytes:        bytes,
+			FilesScanned: int(files),
+			CurrentDir:   filepath.Dir(path),
+		},
+	}
+}
+
+// merge adds the node's files to stats in walk order. It stops at the first
+// directory that could not be read, as a sequential walk would.
+func (n *walkNode) merge(stats *config.Category) error {
+	if n.err != nil {
+		return n.err
+	}
+	for _, item := range n.items {
+		if item.sub != nil {
+			if err := item.sub.merge(stats); err != nil {
+				return err
+			}
+			continue
+		}
+		addFileToStats(stats, item.path, item.info)
+	}
+	return nil
+}
+
+// listDir returns dir's regular files and subdirectories in lexical order,
+// from the index when the directory is unchanged since it was recorded.
+func (s *Scanner) listDir(dir string, info os.FileInfo) ([]IndexedEntry, error) {
+	now := time.Now()
+	if s.index != nil {
+		if entries, ok := s.index.lookup(dir, info.ModTime(), now); ok {
+			return entries, nil
+		}
+	}
+
+	infos, err := s.fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := indexEntries(infos)
+	if s.index != nil {
+		s.index.store(dir, info.ModTime(), now, entries)
+	}
+	return entries, nil
+}
@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanPathsWithWorkers(t *testing.T, workers int, cat *config.Category) []string {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Scan.WorkerCount = workers
+	s := NewScanner(cfg)
+	ch := make(chan ScanMsg, 64)
+	go s.ScanCategory(context.Background(), cat, ch)
+
+	var paths []string
+	for msg := range ch {
+		require.NoError(t, msg.Error)
+		if msg.Complete != nil {
+			for _, f := range msg.Complete.Stats.Files {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+	return paths
+}
+
+func TestParallelWalkMatchesSequentialOrder(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 6; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%d", i), "nested")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		for j := 0; j < 5; j++ {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d.bin", j)), []byte("x"), 0644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(root, fmt.Sprintf("d%d.log", i)), []byte("y"), 0644))
+	}
+	cat := &config.Category{Name: "Tree", Paths: []string{root}}
+
+	sequential := scanPathsWithWorkers(t, 1, cat)
+	parallel := scanPathsWithWorkers(t, 8, cat)
+
+	require.Len(t, sequential, 36)
+	assert.Equal(t, sequential, parallel, "worker pool must not change result order")
+}
+
+func TestScanCategoriesDeliversCompletionsInOrder(t *testing.T) {
+	var categories []config.Category
+	for i := 0; i < 5; i++ {
+		dir := t.TempDir()
+		for j := 0; j <= i*20; j++ {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", j)), []byte("z"), 0644))
+		}
+		categories = append(categories, config.Category{Name: fmt.Sprintf("cat-%d", i), Paths: []string{dir}})
+	}
+
+	cfg := &config.Config{}
+	cfg.Scan.WorkerCount = 4
+	s := NewScanner(cfg)
+	ch := make(chan ScanMsg, 10)
+	go s.ScanCategories(context.Background(), categories, ch)
+
+	var order []string
+	for msg := range ch {
+		require.NoError(t, msg.Error)
+		if msg.Complete != nil {
+			assert.Equal(t, msg.Category, msg.Complete.Category)
+			order = append(order, msg.Complete.Category)
+			assert.Equal(t, len(order)*20-19, msg.Complete.Stats.FileCount)
+		}
+	}
+	assert.Equal(t, []string{"cat-0", "cat-1", "cat-2", "cat-3", "cat-4"}, order)
+}
+
+func TestScanCategoriesReportsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := NewScanner(&config.Config{})
+	ch := make(chan ScanMsg, 10)
+	go s.ScanCategories(ctx, []config.Category{{Name: "a", Paths: []string{t.TempDir()}}}, ch)
+
+	var terminal []ScanMsg
+	for msg := range ch {
+		if msg.Complete != nil || msg.Error != nil {
+			terminal = append(terminal, msg)
+		}
+	}
+	require.Len(t, terminal, 1, "every category ends with exactly one terminal message")
+}
+
+// swappedFS lists symlinks as the directories they point to, as a listing
+// taken just before a directory was swapped for a symlink would.
+type swappedFS struct{ OsFileSystem }
+
+func (fs *swappedFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	infos, err := fs.OsFileSystem.ReadDir(dirname)
+	for i, info := range infos {
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(filepath.Join(dirname, info.Name())); err == nil {
+				infos[i] = target
+			}
+		}
+	}
+	return infos, err
+}
+
+func TestWalkNeverDescendsSwappedSymlink(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("s"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "kept.log"), []byte("k"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "sub")))
+
+	s := NewScannerWithFs(&config.Config{}, &swappedFS{})
+	ch := make(chan ScanMsg, 16)
+	go s.ScanCategory(context.Background(), &config.Category{Name: "Tree", Paths: []string{root}}, ch)
+
+	var paths []string
+	for msg := range ch {
+		require.NoError(t, msg.Error)
+		if msg.Complete != nil {
+			for _, f := range msg.Complete.Stats.Files {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+	assert.Equal(t, []string{filepath.Join(root, "kept.log")}, paths)
+}
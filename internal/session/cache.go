@@ -13,10 +13,18 @@ import (
 // Manager handles session cache operations
 type Manager struct {
 	cachePath string
+	// sealed marks the root-owned cache in StateDir, which is authenticated
+	// with a MAC and bound to the home it was scanned for.
+	sealed bool
 }
 
-// NewManager creates a new session cache manager
+// NewManager creates a new session cache manager. Running as root, it uses
+// the root-owned cache in StateDir: a cache in a user-writable directory is
+// never what a root clean acts on.
 func NewManager() (*Manager, error) {
+	if RootOwned() {
+		return &Manager{cachePath: filepath.Join(StateDir, sealedCacheName), sealed: true}, nil
+	}
 	cachePath, err := paths.CacheFile()
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine session cache path: %w", err)
@@ -35,16 +43,29 @@ func (m *Manager) Save(cache *config.SessionCache) error {
 		return fmt.Errorf("cache cannot be nil")
 	}
 
-	cacheDir := filepath.Dir(m.cachePath)
-	if err := os.MkdirAll(cacheDir, 0700); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
+	stamped := *cache
+	if stamped.Version == 0 {
+		stamped.Version = config.SessionCacheVersion
 	}
 
-	data, err := json.MarshalIndent(cache, "", "  ")
+	data, err := json.MarshalIndent(&stamped, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal cache: %w", err)
 	}
 
+	if m.sealed {
+		home, err := cacheHome()
+		if err != nil {
+			return err
+		}
+		return m.saveSealed(data, home)
+	}
+
+	cacheDir := filepath.Dir(m.cachePath)
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
 	if err := os.WriteFile(m.cachePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
@@ -52,21 +73,42 @@ func (m *Manager) Save(cache *config.SessionCache) error {
 	return nil
 }
 
-// Load reads the session cache from disk
+// Load reads the session cache from disk. A root-owned cache that fails
+// its checks is reported with ErrUntrusted.
 func (m *Manager) Load() (*config.SessionCache, error) {
-	data, err := os.ReadFile(m.cachePath)
+	data, err := m.read()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read cache file: %w", err)
+		return nil, err
 	}
 
 	var cache config.SessionCache
 	if err := json.Unmarshal(data, &cache); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal cache: %w", err)
 	}
+	if cache.Version > config.SessionCacheVersion {
+		return nil, fmt.Errorf("scan cache format v%d was written by a newer moonbit (this build reads up to v%d); re-run 'moonbit scan'",
+			cache.Version, config.SessionCacheVersion)
+	}
 
 	return &cache, nil
 }
 
+// read returns the cache file's contents, verified first if it is sealed.
+func (m *Manager) read() ([]byte, error) {
+	if m.sealed {
+		home, err := cacheHome()
+		if err != nil {
+			return nil, err
+		}
+		return m.loadSealed(home)
+	}
+	data, err := os.ReadFile(m.cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+	return data, nil
+}
+
 // Clear removes the session cache file
 func (m *Manager) Clear() error {
 	if err := os.Remove(m.cachePath); err != nil && !os.IsNotExist(err) {
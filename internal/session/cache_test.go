@@ -11,6 +11,13 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain keeps the per-user cache tests on the per-user cache even when
+// they run as root; sealed_test.go switches to the root-owned one itself.
+func TestMain(m *testing.M) {
+	RootOwned = func() bool { return false }
+	os.Exit(m.Run())
+}
+
 func TestNewManager(t *testing.T) {
 	manager, err := NewManager()
 	require.NoError(t, err)
@@ -215,3 +222,50 @@ func TestManager_Save_CreatesDirectory(t *testing.T) {
 	_, err = os.Stat(cacheDir)
 	assert.NoError(t, err)
 }
+
+func TestManager_SaveStampsVersionAndKeepsCategories(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", "")
+
+	manager, err := NewManager()
+	require.NoError(t, err)
+
+	cache := &config.SessionCache{
+		ScanResults: &config.Category{
+			Name:  "Total Cleanable",
+			Files: []config.FileInfo{{Path: "/test/a", Size: 10, CategoryName: "Test Cache"}},
+		},
+		Categories: []config.CategorySummary{{
+			Name: "Test Cache", Size: 10, FileCount: 1, DurationMs: 42,
+			ScanRoots: []string{"/test"}, Errors: []string{"/test/locked: permission denied"},
+		}},
+		TotalSize:  10,
+		TotalFiles: 1,
+		ScannedAt:  time.Now(),
+	}
+	require.NoError(t, manager.Save(cache))
+	assert.Equal(t, 0, cache.Version, "Save must not mutate the caller's cache")
+
+	loaded, err := manager.Load()
+	require.NoError(t, err)
+	assert.Equal(t, config.SessionCacheVersion, loaded.Version)
+	require.Len(t, loaded.Categories, 1)
+	assert.Equal(t, cache.Categories[0], loaded.Categories[0])
+}
+
+func TestManager_LoadRejectsNewerFormat(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", "")
+
+	manager, err := NewManager()
+	require.NoError(t, err)
+	require.NoError(t, manager.Save(&config.SessionCache{
+		Version:     config.SessionCacheVersion + 1,
+		ScanResults: &config.Category{Name: "Total Cleanable"},
+		ScannedAt:   time.Now(),
+	}))
+
+	_, err = manager.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "re-run 'moonbit scan'")
+}
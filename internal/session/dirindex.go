@@ -0,0 +1,72 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Nomadcxx/moonbit/internal/paths"
+	"github.com/Nomadcxx/moonbit/internal/scanner"
+)
+
+const dirIndexName = "dir_index.json"
+
+// DirIndexPath is where the scanner's directory index is kept: in StateDir
+// beside the sealed cache when moonbit runs as root, otherwise beside the
+// user's own cache. A root scan must never read or write an index the
+// invoking user can replace.
+func DirIndexPath() (string, error) {
+	if RootOwned() {
+		return filepath.Join(StateDir, dirIndexName), nil
+	}
+	return paths.DirIndexFile()
+}
+
+// OpenDirIndex loads the directory index from DirIndexPath and returns it
+// with the path to save it back to. It always returns a usable index; the
+// error explains why an existing one was thrown away. As root, an index that
+// is not a root-owned regular file in a root-owned StateDir is discarded.
+func OpenDirIndex() (*scanner.DirIndex, string, error) {
+	path, err := DirIndexPath()
+	if err != nil {
+		return scanner.NewDirIndex(), "", err
+	}
+	if !RootOwned() {
+		idx, err := scanner.LoadDirIndex(path)
+		return idx, path, err
+	}
+
+	if err := checkStateDir(); err != nil {
+		if os.IsNotExist(err) {
+			return scanner.NewDirIndex(), path, nil
+		}
+		return scanner.NewDirIndex(), path, fmt.Errorf("discarding directory index: %w", err)
+	}
+	data, err := readRootOwned(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return scanner.NewDirIndex(), path, nil
+		}
+		if !errors.Is(err, ErrUntrusted) {
+			err = fmt.Errorf("failed to read directory index: %w", err)
+		}
+		return scanner.NewDirIndex(), path, fmt.Errorf("discarding directory index: %w", err)
+	}
+	idx, err := scanner.ParseDirIndex(data)
+	return idx, path, err
+}
+
+// SaveDirIndex writes idx to path. As root it first makes sure StateDir
+// exists and is root's, as saveSealed does for the cache.
+func SaveDirIndex(idx *scanner.DirIndex, path string) error {
+	if RootOwned() {
+		if err := os.MkdirAll(StateDir, 0755); err != nil {
+			return fmt.Errorf("failed to create state directory: %w", err)
+		}
+		if err := checkStateDir(); err != nil {
+			return err
+		}
+	}
+	return idx.Save(path)
+}
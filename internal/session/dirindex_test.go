@@ -0,0 +1,57 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nomadcxx/moonbit/internal/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirIndexPathFollowsTheUsersCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg-cache")
+
+	path, err := DirIndexPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/tmp/xdg-cache", "moonbit", "dir_index.json"), path)
+}
+
+func TestRootDirIndexLivesInStateDir(t *testing.T) {
+	dir := useRootOwnedCache(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	idx, path, err := OpenDirIndex()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "dir_index.json"), path)
+	assert.Empty(t, idx.Dirs)
+
+	require.NoError(t, SaveDirIndex(scanner.NewDirIndex(), path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, _, err = OpenDirIndex()
+	require.NoError(t, err)
+}
+
+func TestRootDirIndexDiscardsUntrustedIndex(t *testing.T) {
+	dir := useRootOwnedCache(t)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	path := filepath.Join(dir, "dir_index.json")
+	data := `{"version":1,"dirs":{"/var/cache/pkg":{"mtime":1,"listed_at":2,"seen_at":2}}}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+	require.NoError(t, os.Chown(path, 65534, 65534))
+
+	idx, _, err := OpenDirIndex()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrUntrusted)
+	assert.Empty(t, idx.Dirs)
+
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.Symlink("/etc/passwd", path))
+	idx, _, err = OpenDirIndex()
+	require.Error(t, err)
+	assert.Empty(t, idx.Dirs)
+}
@@ -0,0 +1,137 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LockDir holds the operation lock when moonbit runs as root. /run is
+// emptied at boot, so a lock file never outlives the processes using it.
+var LockDir = "/run/moonbit"
+
+const lockName = "moonbit.lock"
+
+// lockPollInterval is how often WaitLock retries a held lock.
+const lockPollInterval = 250 * time.Millisecond
+
+// LockedError reports that another process holds the operation lock.
+type LockedError struct {
+	Path string
+	// PID and Op identify the holder, as it recorded itself in the lock
+	// file. PID is 0 if it had not yet done so.
+	PID int
+	Op  string
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("another moonbit operation is running (%s is locked)", e.Path)
+	}
+	return fmt.Sprintf("another moonbit operation is running: %s is locked by PID %d (%s)", e.Path, e.PID, e.Op)
+}
+
+// Lock is a held operation lock.
+type Lock struct {
+	f *os.File
+}
+
+// LockPath is the lock file serialising operations on this cache: in
+// LockDir for the root-owned cache, otherwise beside the cache file.
+func (m *Manager) LockPath() string {
+	if m.sealed {
+		return filepath.Join(LockDir, lockName)
+	}
+	return filepath.Join(filepath.Dir(m.cachePath), lockName)
+}
+
+// TryLock takes the exclusive lock that serialises scans and cleans across
+// processes, recording op and this process's PID in it. If another process
+// holds the lock it returns a *LockedError at once.
+//
+// The lock is flock(2) on the lock file, so it is released when its holder
+// exits, however it exits; the file itself is left in place.
+func (m *Manager) TryLock(op string) (*Lock, error) {
+	path := m.LockPath()
+	perm := os.FileMode(0700)
+	if m.sealed {
+		perm = 0755
+	}
+	if err := os.MkdirAll(filepath.Dir(path), perm); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|syscall.O_NOFOLLOW, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		defer f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			pid, holder := readLockHolder(f)
+			return nil, &LockedError{Path: path, PID: pid, Op: holder}
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	// The PID is informational, for the error other processes report.
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(fmt.Sprintf("%d %s\n", os.Getpid(), op)), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// WaitLock is TryLock that waits for the lock to be released, until ctx is
+// done.
+func (m *Manager) WaitLock(ctx context.Context, op string) (*Lock, error) {
+	for {
+		lock, err := m.TryLock(op)
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ctx.Err(), locked)
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	_ = l.f.Truncate(0)
+	err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// readLockHolder reads the "PID op" line a holder wrote into the lock file.
+func readLockHolder(f *os.File) (int, string) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 256))
+	if err != nil {
+		return 0, ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, ""
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, ""
+	}
+	return pid, strings.Join(fields[1:], " ")
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLockTestManager(t *testing.T) *Manager {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	manager, err := NewManager()
+	require.NoError(t, err)
+	return manager
+}
+
+func TestTryLockReportsHolder(t *testing.T) {
+	manager := newLockTestManager(t)
+
+	lock, err := manager.TryLock("scan")
+	require.NoError(t, err)
+
+	// flock locks belong to the open file, so a second open conflicts even
+	// within one process.
+	_, err = manager.TryLock("clean")
+	var locked *LockedError
+	require.True(t, errors.As(err, &locked), "got %v", err)
+	assert.Equal(t, os.Getpid(), locked.PID)
+	assert.Equal(t, "scan", locked.Op)
+	assert.Equal(t, manager.LockPath(), locked.Path)
+	assert.Contains(t, err.Error(), "locked by PID")
+
+	require.NoError(t, lock.Unlock())
+	again, err := manager.TryLock("clean")
+	require.NoError(t, err)
+	assert.NoError(t, again.Unlock())
+}
+
+func TestWaitLockWaitsForRelease(t *testing.T) {
+	manager := newLockTestManager(t)
+	lock, err := manager.TryLock("clean")
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = lock.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	second, err := manager.WaitLock(ctx, "scan")
+	require.NoError(t, err)
+	assert.NoError(t, second.Unlock())
+}
+
+func TestWaitLockGivesUpWithContext(t *testing.T) {
+	manager := newLockTestManager(t)
+	lock, err := manager.TryLock("clean")
+	require.NoError(t, err)
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = manager.WaitLock(ctx, "scan")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "locked by PID")
+}
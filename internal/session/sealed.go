@@ -0,0 +1,254 @@
+package session
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Nomadcxx/moonbit/internal/paths"
+)
+
+// StateDir is the root-owned directory that holds the scan cache and the
+// key authenticating it when moonbit runs as root. There is one cache per
+// host: every root scan replaces it, whichever user ran moonbit through sudo,
+// and a clean for any other home refuses it until that user scans again.
+var StateDir = "/var/lib/moonbit"
+
+// RootOwned reports whether NewManager uses the root-owned, authenticated
+// cache. It does whenever moonbit runs as root; a variable so tests that run
+// as root can still exercise the per-user cache.
+var RootOwned = func() bool { return os.Geteuid() == 0 }
+
+const (
+	sealedCacheName = "scan_results.json"
+	cacheKeyName    = "scan_cache.key"
+	cacheKeySize    = 32
+)
+
+// ErrUntrusted is returned by Load when a root-owned cache fails its
+// ownership or MAC check. Nothing in such a cache may be acted on.
+var ErrUntrusted = errors.New("scan cache is not trusted")
+
+// sealedCache is the on-disk form of a root-owned cache. MAC is
+// HMAC-SHA256 over Home and Cache, keyed with the key in StateDir. Home
+// binds the cache to the user it was scanned for, since StateDir is shared
+// by everyone who runs moonbit through sudo.
+type sealedCache struct {
+	Home  string          `json:"home"`
+	MAC   string          `json:"mac"`
+	Cache json.RawMessage `json:"cache"`
+}
+
+// sealedMAC computes the MAC over the compact form of cache, since the
+// encoder re-indents a RawMessage inside the envelope.
+func sealedMAC(key []byte, home string, cache []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, cache); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(home))
+	mac.Write([]byte{0})
+	mac.Write(compact.Bytes())
+	return mac.Sum(nil), nil
+}
+
+// untrusted wraps ErrUntrusted with what failed and how to recover.
+func untrusted(path, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s; re-run 'sudo moonbit scan' to replace it",
+		ErrUntrusted, path, fmt.Sprintf(format, args...))
+}
+
+// checkRootOwned reports whether st is root-owned and not writable by group
+// or others.
+func checkRootOwned(st *syscall.Stat_t) error {
+	if st.Uid != 0 {
+		return fmt.Errorf("owned by uid %d, not root", st.Uid)
+	}
+	if st.Mode&0o022 != 0 {
+		return fmt.Errorf("writable by users other than root (mode %04o)", st.Mode&0o7777)
+	}
+	return nil
+}
+
+// readRootOwned reads the regular file at path, refusing symlinks and files
+// that are not root-owned or are writable by anyone else.
+func readRootOwned(path string) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, untrusted(path, "not a regular file")
+	}
+	if err := checkRootOwned(info.Sys().(*syscall.Stat_t)); err != nil {
+		return nil, untrusted(path, "%v", err)
+	}
+	return io.ReadAll(f)
+}
+
+// checkStateDir refuses a StateDir that anyone but root could rearrange.
+func checkStateDir() error {
+	info, err := os.Lstat(StateDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return untrusted(StateDir, "not a directory")
+	}
+	if err := checkRootOwned(info.Sys().(*syscall.Stat_t)); err != nil {
+		return untrusted(StateDir, "%v", err)
+	}
+	return nil
+}
+
+// cacheKey returns the MAC key, creating it if create is set and there is
+// none yet.
+func cacheKey(create bool) ([]byte, error) {
+	path := filepath.Join(StateDir, cacheKeyName)
+	key, err := readRootOwned(path)
+	if err == nil {
+		if len(key) != cacheKeySize {
+			return nil, untrusted(path, "key is %d bytes, want %d", len(key), cacheKeySize)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, err
+	}
+
+	key = make([]byte, cacheKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate scan cache key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0600)
+	if os.IsExist(err) {
+		// Another scan created it first.
+		return cacheKey(false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan cache key: %w", err)
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write scan cache key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write scan cache key: %w", err)
+	}
+	return key, nil
+}
+
+// saveSealed writes data as a root-owned cache for home.
+func (m *Manager) saveSealed(data []byte, home string) error {
+	if err := os.MkdirAll(StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := checkStateDir(); err != nil {
+		return err
+	}
+	key, err := cacheKey(true)
+	if err != nil {
+		return err
+	}
+
+	mac, err := sealedMAC(key, home, data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+	sealed, err := json.MarshalIndent(sealedCache{
+		Home:  home,
+		MAC:   hex.EncodeToString(mac),
+		Cache: data,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+
+	// Write beside the cache and rename over it, so a reader never sees a
+	// half-written cache and a symlink planted at the name is replaced, not
+	// followed.
+	tmp, err := os.CreateTemp(StateDir, ".scan_results-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.cachePath); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
+
+// loadSealed reads a root-owned cache and returns its contents once the
+// directory, file and key are root's, the MAC matches and the cache was
+// scanned for home.
+func (m *Manager) loadSealed(home string) ([]byte, error) {
+	if err := checkStateDir(); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read cache file: %w", err)
+		}
+		return nil, err
+	}
+	raw, err := readRootOwned(m.cachePath)
+	if err != nil {
+		if errors.Is(err, ErrUntrusted) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+	key, err := cacheKey(false)
+	if err != nil {
+		if errors.Is(err, ErrUntrusted) {
+			return nil, err
+		}
+		return nil, untrusted(m.cachePath, "cannot read its key: %v", err)
+	}
+
+	var sealed sealedCache
+	if err := json.Unmarshal(raw, &sealed); err != nil || sealed.MAC == "" {
+		return nil, untrusted(m.cachePath, "not an authenticated cache")
+	}
+	mac, err := hex.DecodeString(sealed.MAC)
+	if err != nil {
+		return nil, untrusted(m.cachePath, "not an authenticated cache")
+	}
+	want, err := sealedMAC(key, sealed.Home, sealed.Cache)
+	if err != nil || !hmac.Equal(mac, want) {
+		return nil, untrusted(m.cachePath, "MAC does not match its contents")
+	}
+	if sealed.Home != home {
+		return nil, untrusted(m.cachePath, "scanned for %s, not %s", sealed.Home, home)
+	}
+	return sealed.Cache, nil
+}
+
+// cacheHome is the home a root-owned cache is bound to.
+func cacheHome() (string, error) {
+	home, err := paths.HomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return home, nil
+}
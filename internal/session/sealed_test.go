@@ -0,0 +1,165 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useRootOwnedCache points the root-owned cache at a temporary StateDir for
+// the rest of the test. The checks need the files to be root's, so the
+// test is skipped when not running as root.
+func useRootOwnedCache(t *testing.T) string {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("the root-owned cache is only used as root")
+	}
+	dir := filepath.Join(t.TempDir(), "state")
+	oldDir, oldLockDir, oldRootOwned := StateDir, LockDir, RootOwned
+	StateDir, LockDir, RootOwned = dir, t.TempDir(), func() bool { return true }
+	t.Cleanup(func() { StateDir, LockDir, RootOwned = oldDir, oldLockDir, oldRootOwned })
+	t.Setenv("MOONBIT_HOME", "/home/alice")
+	return dir
+}
+
+func sealedTestCache() *config.SessionCache {
+	return &config.SessionCache{
+		ScanResults: &config.Category{
+			Name:  "Test",
+			Files: []config.FileInfo{{Path: "/var/cache/app/file", Size: 10}},
+		},
+		TotalSize:  10,
+		TotalFiles: 1,
+		ScannedAt:  time.Now(),
+	}
+}
+
+func TestRootOwnedCacheRoundTrip(t *testing.T) {
+	dir := useRootOwnedCache(t)
+
+	manager, err := NewManager()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "scan_results.json"), manager.Path())
+	require.NoError(t, manager.Save(sealedTestCache()))
+
+	for _, name := range []string{"scan_results.json", "scan_cache.key"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), name)
+	}
+	var sealed sealedCache
+	data, err := os.ReadFile(manager.Path())
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &sealed))
+	assert.Equal(t, "/home/alice", sealed.Home)
+	assert.NotEmpty(t, sealed.MAC)
+
+	loaded, err := manager.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/cache/app/file", loaded.ScanResults.Files[0].Path)
+
+	// A second save reuses the key.
+	key, err := os.ReadFile(filepath.Join(dir, "scan_cache.key"))
+	require.NoError(t, err)
+	require.NoError(t, manager.Save(sealedTestCache()))
+	again, err := os.ReadFile(filepath.Join(dir, "scan_cache.key"))
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+}
+
+func TestRootOwnedCacheRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, path string)
+		want   string
+	}{
+		{
+			name: "edited contents",
+			tamper: func(t *testing.T, path string) {
+				var sealed sealedCache
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(data, &sealed))
+				var cache config.SessionCache
+				require.NoError(t, json.Unmarshal(sealed.Cache, &cache))
+				cache.ScanResults.Files[0].Path = "/etc/shadow"
+				sealed.Cache, err = json.Marshal(&cache)
+				require.NoError(t, err)
+				data, err = json.Marshal(&sealed)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, data, 0600))
+			},
+			want: "MAC does not match",
+		},
+		{
+			name: "plain cache without a MAC",
+			tamper: func(t *testing.T, path string) {
+				data, err := json.Marshal(sealedTestCache())
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, data, 0600))
+			},
+			want: "not an authenticated cache",
+		},
+		{
+			name: "owned by a user",
+			tamper: func(t *testing.T, path string) {
+				require.NoError(t, os.Chown(path, 1000, 1000))
+			},
+			want: "not root",
+		},
+		{
+			name: "group-writable",
+			tamper: func(t *testing.T, path string) {
+				require.NoError(t, os.Chmod(path, 0620))
+			},
+			want: "writable by users other than root",
+		},
+		{
+			name: "world-writable state directory",
+			tamper: func(t *testing.T, path string) {
+				require.NoError(t, os.Chmod(filepath.Dir(path), 0777))
+			},
+			want: "writable by users other than root",
+		},
+		{
+			name: "scanned for another user",
+			tamper: func(t *testing.T, path string) {
+				t.Setenv("MOONBIT_HOME", "/home/mallory")
+			},
+			want: "scanned for /home/alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRootOwnedCache(t)
+			manager, err := NewManager()
+			require.NoError(t, err)
+			require.NoError(t, manager.Save(sealedTestCache()))
+
+			tt.tamper(t, manager.Path())
+
+			_, err = manager.Load()
+			require.ErrorIs(t, err, ErrUntrusted)
+			assert.Contains(t, err.Error(), tt.want)
+			assert.Contains(t, err.Error(), "sudo moonbit scan")
+		})
+	}
+}
+
+func TestRootOwnedCacheMissing(t *testing.T) {
+	useRootOwnedCache(t)
+	manager, err := NewManager()
+	require.NoError(t, err)
+
+	_, err = manager.Load()
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUntrusted)
+	assert.Contains(t, err.Error(), "failed to read cache file")
+}
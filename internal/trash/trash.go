@@ -0,0 +1,709 @@
+// Package trash moves cleaned files aside instead of unlinking them, so a clean
+// can be undone until the trash is purged.
+//
+// Every trash uses the layout of the freedesktop.org Trash specification: the
+// files themselves under files/, and one info/<name>.trashinfo per file that
+// records where it came from and when. Files from the user's home go to the
+// home trash, where file managers show them as well; everything else goes to a
+// moonbit-owned holding area with the same layout.
+//
+// moonbit marks its own info files with X-Moonbit-* keys and only ever lists,
+// restores or purges entries carrying them, leaving anything the desktop put
+// in the home trash alone.
+package trash
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Nomadcxx/moonbit/internal/paths"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	infoSuffix  = ".trashinfo"
+	infoHeader  = "[Trash Info]"
+	dateLayout  = "2006-01-02T15:04:05"
+	categoryKey = "X-Moonbit-Category"
+	sizeKey     = "X-Moonbit-Size"
+
+	// maxNameBytes leaves room for a ".N" collision suffix and ".trashinfo"
+	// within the usual 255-byte name limit.
+	maxNameBytes = 200
+	// maxCollisions bounds the search for a free name.
+	maxCollisions = 10000
+)
+
+// SystemHoldingDir is the holding area used when running as root.
+const SystemHoldingDir = "/var/lib/moonbit/trash"
+
+// Entry is one file moonbit moved to a trash.
+type Entry struct {
+	// Name identifies the entry within its trash: files/<Name> and
+	// info/<Name>.trashinfo.
+	Name         string    `json:"name"`
+	OriginalPath string    `json:"original_path"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	Category     string    `json:"category"`
+	Size         int64     `json:"size"`
+	// Trash is the root directory of the trash holding the entry.
+	Trash string `json:"trash"`
+
+	store *Store
+}
+
+// Store is a single trash directory.
+type Store struct {
+	root string
+	// confine, when set, is the only directory tree entries may be restored
+	// into. The home trash is confined to the home it belongs to: its info
+	// files are writable by that user, and a root-run restore must not let
+	// them name a destination such as /etc.
+	confine string
+}
+
+// NewStore returns the trash rooted at root. Nothing is created until a file
+// is put into it.
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+// Root is the directory holding files/ and info/.
+func (s *Store) Root() string {
+	return s.root
+}
+
+func (s *Store) filesDir() string { return filepath.Join(s.root, "files") }
+func (s *Store) infoDir() string  { return filepath.Join(s.root, "info") }
+
+// ensure creates the trash directories, refusing any that already exist as
+// something other than a directory, such as a symlink to elsewhere.
+func (s *Store) ensure() error {
+	for _, dir := range []string{s.root, s.filesDir(), s.infoDir()} {
+		info, err := os.Lstat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("refusing to use trash directory %s: not a directory (mode %s)", dir, info.Mode())
+			}
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create trash directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Put moves the regular file at path into the trash and records its original
+// location. The file keeps its inode, owner and timestamps when the trash is
+// on the same filesystem; otherwise it is copied and the original removed.
+func (s *Store) Put(path, category string) (Entry, error) {
+	path = filepath.Clean(path)
+	dirfd, err := openDir(filepath.Dir(path))
+	if err != nil {
+		return Entry{}, err
+	}
+	defer syscall.Close(dirfd)
+	return s.PutAt(dirfd, filepath.Base(path), path, category)
+}
+
+// PutAt is Put for the file called name in the directory open as dirfd,
+// which the caller has reached without following symlinks; path is only
+// recorded as its original location. The file is judged with fstat on an
+// O_PATH|O_NOFOLLOW descriptor and moved with renameat, so nothing outside
+// dirfd can be pulled into the trash by swapping a parent directory.
+func (s *Store) PutAt(dirfd int, name, path, category string) (Entry, error) {
+	path = filepath.Clean(path)
+	st, err := statAt(dirfd, name, path)
+	if err != nil {
+		return Entry{}, err
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return Entry{}, fmt.Errorf("refusing to trash %s: not a regular file (mode %s)", path, fileMode(st.Mode))
+	}
+	if err := s.ensure(); err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		OriginalPath: path,
+		DeletedAt:    time.Now().Truncate(time.Second),
+		Category:     category,
+		Size:         st.Size,
+		Trash:        s.root,
+		store:        s,
+	}
+
+	infoFile, entryName, err := s.reserve(filepath.Base(path))
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Name = entryName
+	infoPath := infoFile.Name()
+
+	_, err = infoFile.WriteString(entry.marshal())
+	if closeErr := infoFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(infoPath)
+		return Entry{}, fmt.Errorf("failed to write trash info: %w", err)
+	}
+
+	filesfd, err := openDir(s.filesDir())
+	if err == nil {
+		err = moveAt(dirfd, name, path, filesfd, entryName, &st)
+		syscall.Close(filesfd)
+	}
+	if err != nil {
+		_ = os.Remove(infoPath)
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// reserve claims a free entry name by creating its info file exclusively, so
+// two processes trashing files with the same base name cannot collide.
+func (s *Store) reserve(base string) (*os.File, string, error) {
+	if len(base) > maxNameBytes {
+		base = base[:maxNameBytes]
+	}
+	for i := 1; i <= maxCollisions; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		infoPath := filepath.Join(s.infoDir(), name+infoSuffix)
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0600)
+		if err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return nil, "", fmt.Errorf("failed to create trash info: %w", err)
+		}
+		// An orphaned file without its info file still owns the name.
+		if _, err := os.Lstat(filepath.Join(s.filesDir(), name)); err == nil {
+			f.Close()
+			_ = os.Remove(infoPath)
+			continue
+		}
+		return f, name, nil
+	}
+	return nil, "", fmt.Errorf("no free trash name for %s", base)
+}
+
+// List returns the entries moonbit put in this trash, oldest first. A missing
+// trash is empty.
+func (s *Store) List() ([]Entry, error) {
+	dirEntries, err := os.ReadDir(s.infoDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var entries []Entry
+	for _, d := range dirEntries {
+		name, ok := strings.CutSuffix(d.Name(), infoSuffix)
+		if !ok || name == "" || !d.Type().IsRegular() {
+			continue
+		}
+		entry, ok := s.readInfo(name)
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+// readInfo parses info/<name>.trashinfo. It reports false for files that are
+// malformed or were not written by moonbit.
+func (s *Store) readInfo(name string) (Entry, bool) {
+	f, err := os.Open(filepath.Join(s.infoDir(), name+infoSuffix))
+	if err != nil {
+		return Entry{}, false
+	}
+	defer f.Close()
+
+	entry := Entry{Name: name, Trash: s.root, store: s}
+	ours := false
+	section := ""
+	scanner := bufio.NewScanner(io.LimitReader(f, 64<<10))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if section != infoHeader || !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			p, err := url.PathUnescape(value)
+			if err != nil {
+				return Entry{}, false
+			}
+			entry.OriginalPath = p
+		case "DeletionDate":
+			t, err := time.ParseInLocation(dateLayout, value, time.Local)
+			if err != nil {
+				return Entry{}, false
+			}
+			entry.DeletedAt = t
+		case categoryKey:
+			entry.Category = value
+			ours = true
+		case sizeKey:
+			entry.Size, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	if !ours || !filepath.IsAbs(entry.OriginalPath) || entry.DeletedAt.IsZero() {
+		return Entry{}, false
+	}
+	entry.OriginalPath = filepath.Clean(entry.OriginalPath)
+	return entry, true
+}
+
+func (e Entry) marshal() string {
+	var sb strings.Builder
+	sb.WriteString(infoHeader + "\n")
+	sb.WriteString("Path=" + (&url.URL{Path: e.OriginalPath}).EscapedPath() + "\n")
+	sb.WriteString("DeletionDate=" + e.DeletedAt.Format(dateLayout) + "\n")
+	sb.WriteString(categoryKey + "=" + strings.ReplaceAll(e.Category, "\n", " ") + "\n")
+	sb.WriteString(sizeKey + "=" + strconv.FormatInt(e.Size, 10) + "\n")
+	return sb.String()
+}
+
+// Restore moves the entry back to its original path. It refuses to replace
+// anything that now exists there.
+func (s *Store) Restore(e Entry) error {
+	target := e.OriginalPath
+	if err := s.checkTarget(target); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("cannot restore %s: a file already exists there", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to recreate %s: %w", filepath.Dir(target), err)
+	}
+
+	src := filepath.Join(s.filesDir(), e.Name)
+	info, err := os.Lstat(src)
+	if err != nil {
+		return fmt.Errorf("trashed file for %s is missing: %w", target, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("refusing to restore %s: trashed file is not a regular file (mode %s)", target, info.Mode())
+	}
+	if err := move(src, target); err != nil {
+		return err
+	}
+	return removeIfExists(filepath.Join(s.infoDir(), e.Name+infoSuffix))
+}
+
+// checkTarget keeps restores from a confined trash inside its tree, judging
+// the destination both as written and through any symlinks along the part of
+// it that already exists.
+func (s *Store) checkTarget(target string) error {
+	if s.confine == "" {
+		return nil
+	}
+	if !within(target, s.confine) {
+		return fmt.Errorf("refusing to restore %s: outside %s", target, s.confine)
+	}
+
+	existing := filepath.Dir(target)
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return fmt.Errorf("cannot restore %s: %w", target, err)
+	}
+	root, err := filepath.EvalSymlinks(s.confine)
+	if err != nil {
+		root = s.confine
+	}
+	if !within(resolved, root) {
+		return fmt.Errorf("refusing to restore %s: %s resolves outside %s", target, existing, s.confine)
+	}
+	return nil
+}
+
+// Purge permanently deletes the entry.
+func (s *Store) Purge(e Entry) error {
+	if err := removeIfExists(filepath.Join(s.filesDir(), e.Name)); err != nil {
+		return err
+	}
+	return removeIfExists(filepath.Join(s.infoDir(), e.Name+infoSuffix))
+}
+
+// Bin routes files between the home trash and the holding area.
+type Bin struct {
+	home    string
+	Home    *Store
+	Holding *Store
+	// foreign are trashes the bin does not manage but still refuses to take
+	// files from.
+	foreign []string
+}
+
+// NewBin returns a bin that sends files under home to homeTrash and all other
+// files to holding. Either trash may be nil, in which case the other takes
+// every file.
+func NewBin(home string, homeTrash, holding *Store) *Bin {
+	if homeTrash != nil && home != "" {
+		homeTrash.confine = filepath.Clean(home)
+	}
+	return &Bin{home: filepath.Clean(home), Home: homeTrash, Holding: holding}
+}
+
+// runningAsRoot reports whether Open is choosing trashes for root; a
+// variable so tests can take either side.
+var runningAsRoot = func() bool { return os.Geteuid() == 0 }
+
+// Open returns the bin for the current user: the freedesktop.org home trash
+// and a holding area under the moonbit data directory.
+//
+// As root it returns SystemHoldingDir alone. The home trash and every
+// directory above it belong to the user, who could swap any of them for a
+// symlink between root's checks and its mkdir, rename or unlink, so root
+// never writes, restores or purges there. Home files reach the home trash
+// through the helper that cleans them as the user.
+func Open() (*Bin, error) {
+	home, err := paths.HomeDir()
+	if err != nil {
+		return nil, err
+	}
+	homeDir, err := paths.TrashDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if runningAsRoot() {
+		bin := NewBin(home, nil, NewStore(SystemHoldingDir))
+		bin.foreign = []string{filepath.Clean(homeDir)}
+		return bin, nil
+	}
+	holdingDir, err := paths.DataDir("trash")
+	if err != nil {
+		return nil, err
+	}
+	return NewBin(home, NewStore(homeDir), NewStore(holdingDir)), nil
+}
+
+// StoreFor returns the trash a file at path goes to.
+func (b *Bin) StoreFor(path string) *Store {
+	if b.Home != nil && (b.Holding == nil || within(filepath.Clean(path), b.home)) {
+		return b.Home
+	}
+	return b.Holding
+}
+
+// Put moves path into the trash chosen by StoreFor. Files already inside a
+// trash, such as those the "Trash" category cleans, are refused rather than
+// shuffled between names.
+func (b *Bin) Put(path, category string) (Entry, error) {
+	store, err := b.storeForPut(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	return store.Put(path, category)
+}
+
+// PutAt is Put for a file the caller has already reached as name in the
+// directory open as dirfd; see Store.PutAt.
+func (b *Bin) PutAt(dirfd int, name, path, category string) (Entry, error) {
+	store, err := b.storeForPut(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	return store.PutAt(dirfd, name, path, category)
+}
+
+// storeForPut returns the trash path goes to, refusing files already in one.
+func (b *Bin) storeForPut(path string) (*Store, error) {
+	roots := b.foreign
+	for _, s := range b.stores() {
+		roots = append(roots, s.root)
+	}
+	for _, root := range roots {
+		if within(filepath.Clean(path), root) {
+			return nil, fmt.Errorf("refusing to trash %s: already in the trash", path)
+		}
+	}
+	store := b.StoreFor(path)
+	if store == nil {
+		return nil, fmt.Errorf("no trash available for %s", path)
+	}
+	return store, nil
+}
+
+// List returns the moonbit entries from every trash, oldest first.
+func (b *Bin) List() ([]Entry, error) {
+	var all []Entry
+	for _, store := range b.stores() {
+		entries, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+	}
+	sortEntries(all)
+	return all, nil
+}
+
+// Find returns the entries whose name or original path is ref.
+func (b *Bin) Find(ref string) ([]Entry, error) {
+	entries, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Entry
+	for _, e := range entries {
+		if e.Name == ref || e.OriginalPath == filepath.Clean(ref) {
+			matches = append(matches, e)
+		}
+	}
+	return matches, nil
+}
+
+// Restore moves e back to its original path.
+func (b *Bin) Restore(e Entry) error {
+	if e.store == nil {
+		return fmt.Errorf("entry %s was not listed from a trash", e.Name)
+	}
+	return e.store.Restore(e)
+}
+
+// Purge permanently deletes e.
+func (b *Bin) Purge(e Entry) error {
+	if e.store == nil {
+		return fmt.Errorf("entry %s was not listed from a trash", e.Name)
+	}
+	return e.store.Purge(e)
+}
+
+// OlderThan returns the entries trashed more than age before now.
+func (b *Bin) OlderThan(age time.Duration, now time.Time) ([]Entry, error) {
+	entries, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := now.Add(-age)
+	var old []Entry
+	for _, e := range entries {
+		if e.DeletedAt.Before(cutoff) {
+			old = append(old, e)
+		}
+	}
+	return old, nil
+}
+
+func (b *Bin) stores() []*Store {
+	var stores []*Store
+	for _, s := range []*Store{b.Home, b.Holding} {
+		if s == nil {
+			continue
+		}
+		if len(stores) == 1 && stores[0].root == s.root {
+			continue
+		}
+		stores = append(stores, s)
+	}
+	return stores
+}
+
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].DeletedAt.Equal(entries[j].DeletedAt) {
+			return entries[i].DeletedAt.Before(entries[j].DeletedAt)
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// openDir opens path as a directory, refusing a final component that is a
+// symlink.
+func openDir(path string) (int, error) {
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		if err == syscall.ELOOP {
+			return -1, fmt.Errorf("refusing to use %s: not a directory", path)
+		}
+		return -1, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return fd, nil
+}
+
+// statAt stats name in dirfd through an O_PATH|O_NOFOLLOW descriptor, which
+// sees a symlink as itself and never opens a device or FIFO.
+func statAt(dirfd int, name, path string) (syscall.Stat_t, error) {
+	var st syscall.Stat_t
+	fd, err := syscall.Openat(dirfd, name, unix.O_PATH|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return st, &os.PathError{Op: "openat", Path: path, Err: err}
+	}
+	err = syscall.Fstat(fd, &st)
+	syscall.Close(fd)
+	if err != nil {
+		return st, &os.PathError{Op: "fstat", Path: path, Err: err}
+	}
+	return st, nil
+}
+
+// move renames src to dst, falling back to copy and remove when they are on
+// different filesystems. dst must not exist.
+func move(src, dst string) error {
+	srcfd, err := openDir(filepath.Dir(src))
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(srcfd)
+	dstfd, err := openDir(filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(dstfd)
+	return moveAt(srcfd, filepath.Base(src), src, dstfd, filepath.Base(dst), nil)
+}
+
+// moveAt renames srcName in srcDir to dstName in dstDir without replacing
+// anything already there, falling back to copy and unlink across
+// filesystems. When want is set, the copy is refused unless it reads the
+// inode want describes.
+func moveAt(srcDir int, srcName, src string, dstDir int, dstName string, want *syscall.Stat_t) error {
+	err := unix.Renameat2(srcDir, srcName, dstDir, dstName, unix.RENAME_NOREPLACE)
+	if err == unix.EINVAL || err == unix.ENOSYS {
+		// The filesystem cannot refuse to replace; the caller has already
+		// checked that dstName is free.
+		err = unix.Renameat(srcDir, srcName, dstDir, dstName)
+	}
+	if err == nil {
+		return nil
+	}
+	if err != syscall.EXDEV {
+		return fmt.Errorf("failed to move %s: %w", src, &os.PathError{Op: "renameat", Path: src, Err: err})
+	}
+
+	if err := copyAt(srcDir, srcName, dstDir, dstName, want); err != nil {
+		_ = unix.Unlinkat(dstDir, dstName, 0)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := unix.Unlinkat(srcDir, srcName, 0); err != nil {
+		_ = unix.Unlinkat(dstDir, dstName, 0)
+		return fmt.Errorf("failed to remove %s after copying: %w", src, err)
+	}
+	return nil
+}
+
+// copyFile copies a regular file, keeping its mode, mtime and, where
+// permitted, its owner.
+func copyFile(src, dst string) error {
+	srcfd, err := openDir(filepath.Dir(src))
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(srcfd)
+	dstfd, err := openDir(filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(dstfd)
+	return copyAt(srcfd, filepath.Base(src), dstfd, filepath.Base(dst), nil)
+}
+
+// copyAt is copyFile between names in two open directories.
+func copyAt(srcDir int, srcName string, dstDir int, dstName string, want *syscall.Stat_t) error {
+	infd, err := syscall.Openat(srcDir, srcName, syscall.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	in := os.NewFile(uintptr(infd), srcName)
+	defer in.Close()
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(infd, &st); err != nil {
+		return err
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return fmt.Errorf("not a regular file (mode %s)", fileMode(st.Mode))
+	}
+	if want != nil && (st.Dev != want.Dev || st.Ino != want.Ino) {
+		return fmt.Errorf("replaced since it was checked")
+	}
+
+	outfd, err := syscall.Openat(dstDir, dstName, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_EXCL|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, st.Mode&0o777)
+	if err != nil {
+		return err
+	}
+	out := os.NewFile(uintptr(outfd), dstName)
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	_ = out.Chown(int(st.Uid), int(st.Gid))
+	if err := out.Close(); err != nil {
+		return err
+	}
+	mtime := unix.NsecToTimespec(syscall.TimespecToNsec(st.Mtim))
+	return unix.UtimesNanoAt(dstDir, dstName, []unix.Timespec{mtime, mtime}, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// fileMode converts a raw st_mode file type for error messages.
+func fileMode(mode uint32) os.FileMode {
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		return os.ModeDir
+	case syscall.S_IFLNK:
+		return os.ModeSymlink
+	case syscall.S_IFIFO:
+		return os.ModeNamedPipe
+	case syscall.S_IFSOCK:
+		return os.ModeSocket
+	case syscall.S_IFCHR:
+		return os.ModeDevice | os.ModeCharDevice
+	case syscall.S_IFBLK:
+		return os.ModeDevice
+	}
+	return 0
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// within reports whether path is dir or lies beneath it.
+func within(path, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,229 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0640))
+}
+
+func TestPutListRestoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "Trash"))
+	original := filepath.Join(dir, "cache", "with space %.bin")
+	writeFile(t, original, "payload")
+	mtime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(original, mtime, mtime))
+
+	entry, err := store.Put(original, "Thumbnails")
+	require.NoError(t, err)
+	assert.NoFileExists(t, original)
+	assert.FileExists(t, filepath.Join(store.Root(), "files", entry.Name))
+
+	info, err := os.ReadFile(filepath.Join(store.Root(), "info", entry.Name+".trashinfo"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(info), "[Trash Info]\n"))
+	assert.Contains(t, string(info), "with%20space%20%25.bin", "Path is URL-encoded as the spec requires")
+
+	entries, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, original, entries[0].OriginalPath)
+	assert.Equal(t, "Thumbnails", entries[0].Category)
+	assert.Equal(t, int64(7), entries[0].Size)
+
+	require.NoError(t, store.Restore(entries[0]))
+	data, err := os.ReadFile(original)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(data))
+	st, err := os.Stat(original)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), st.Mode().Perm())
+	assert.True(t, st.ModTime().Equal(mtime))
+
+	entries, err = store.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPutRenamesOnCollision(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "Trash"))
+
+	var names []string
+	for _, sub := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, sub, "same.log")
+		writeFile(t, path, sub)
+		entry, err := store.Put(path, "Logs")
+		require.NoError(t, err)
+		names = append(names, entry.Name)
+	}
+	assert.Equal(t, []string{"same.log", "same.log.2", "same.log.3"}, names)
+}
+
+func TestRestoreRefusesToOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "Trash"))
+	path := filepath.Join(dir, "f")
+	writeFile(t, path, "old")
+	entry, err := store.Put(path, "Logs")
+	require.NoError(t, err)
+
+	writeFile(t, path, "new")
+	assert.Error(t, store.Restore(entry))
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "new", string(data))
+}
+
+func TestListIgnoresForeignEntries(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "Trash"))
+	writeFile(t, filepath.Join(store.Root(), "files", "photo.jpg"), "x")
+	writeFile(t, filepath.Join(store.Root(), "info", "photo.jpg.trashinfo"),
+		"[Trash Info]\nPath=/home/u/photo.jpg\nDeletionDate=2020-01-01T00:00:00\n")
+
+	entries, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries, "entries the desktop trashed are not moonbit's to manage")
+}
+
+func TestBinRoutesByHome(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	bin := NewBin(home, NewStore(filepath.Join(home, ".local/share/Trash")), NewStore(filepath.Join(dir, "holding")))
+
+	assert.Equal(t, bin.Home, bin.StoreFor(filepath.Join(home, ".cache", "x")))
+	assert.Equal(t, bin.Holding, bin.StoreFor(filepath.Join(dir, "var", "cache", "x")))
+	assert.Equal(t, bin.Holding, bin.StoreFor(home+"-other/x"))
+
+	inTrash := filepath.Join(bin.Home.Root(), "files", "x")
+	writeFile(t, inTrash, "x")
+	_, err := bin.Put(inTrash, "Trash")
+	assert.ErrorContains(t, err, "already in the trash")
+}
+
+func TestHomeTrashRestoreIsConfinedToHome(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	outside := filepath.Join(dir, "etc")
+	require.NoError(t, os.MkdirAll(outside, 0755))
+	homeTrash := NewStore(filepath.Join(home, ".local/share/Trash"))
+	bin := NewBin(home, homeTrash, NewStore(filepath.Join(dir, "holding")))
+
+	// A forged info file naming a path outside home.
+	writeFile(t, filepath.Join(homeTrash.Root(), "files", "evil"), "x")
+	writeFile(t, filepath.Join(homeTrash.Root(), "info", "evil.trashinfo"),
+		"[Trash Info]\nPath="+filepath.Join(outside, "evil")+"\nDeletionDate=2020-01-01T00:00:00\nX-Moonbit-Category=x\n")
+	// And one that reaches outside through a symlink inside home.
+	require.NoError(t, os.Symlink(outside, filepath.Join(home, "link")))
+	writeFile(t, filepath.Join(homeTrash.Root(), "files", "sneaky"), "x")
+	writeFile(t, filepath.Join(homeTrash.Root(), "info", "sneaky.trashinfo"),
+		"[Trash Info]\nPath="+filepath.Join(home, "link", "sneaky")+"\nDeletionDate=2020-01-01T00:00:00\nX-Moonbit-Category=x\n")
+
+	entries, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Error(t, bin.Restore(e), e.Name)
+	}
+	assert.NoFileExists(t, filepath.Join(outside, "evil"))
+	assert.NoFileExists(t, filepath.Join(outside, "sneaky"))
+}
+
+func TestPutRefusesSymlinkedTrashDirectory(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "Trash"))
+	elsewhere := filepath.Join(dir, "etc")
+	require.NoError(t, os.MkdirAll(elsewhere, 0755))
+	require.NoError(t, os.MkdirAll(store.Root(), 0700))
+	require.NoError(t, os.Symlink(elsewhere, filepath.Join(store.Root(), "files")))
+
+	original := filepath.Join(dir, "cache", "x")
+	writeFile(t, original, "x")
+	_, err := store.Put(original, "Test")
+	assert.ErrorContains(t, err, "not a directory")
+	assert.FileExists(t, original)
+	entries, err := os.ReadDir(elsewhere)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing is moved through the symlink")
+}
+
+func TestOpenAsRootLeavesHomeTrashAlone(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SUDO_USER", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	asRoot := runningAsRoot
+	runningAsRoot = func() bool { return true }
+	t.Cleanup(func() { runningAsRoot = asRoot })
+
+	bin, err := Open()
+	require.NoError(t, err)
+	assert.Nil(t, bin.Home, "root never writes into a trash the user controls")
+	require.NotNil(t, bin.Holding)
+	assert.Equal(t, SystemHoldingDir, bin.Holding.Root())
+	assert.Equal(t, bin.Holding, bin.StoreFor(filepath.Join(home, ".cache", "x")))
+
+	inTrash := filepath.Join(home, ".local", "share", "Trash", "files", "x")
+	writeFile(t, inTrash, "x")
+	_, err = bin.Put(inTrash, "Trash")
+	assert.ErrorContains(t, err, "already in the trash")
+}
+
+func TestOlderThanAndPurge(t *testing.T) {
+	dir := t.TempDir()
+	bin := NewBin("", nil, NewStore(filepath.Join(dir, "holding")))
+	oldPath := filepath.Join(dir, "old")
+	newPath := filepath.Join(dir, "new")
+	writeFile(t, oldPath, "o")
+	writeFile(t, newPath, "n")
+	_, err := bin.Put(oldPath, "c")
+	require.NoError(t, err)
+	_, err = bin.Put(newPath, "c")
+	require.NoError(t, err)
+
+	old, err := bin.OlderThan(24*time.Hour, time.Now().Add(36*time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, old, 2)
+	old, err = bin.OlderThan(24*time.Hour, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, old)
+
+	matches, err := bin.Find(oldPath)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.NoError(t, bin.Purge(matches[0]))
+
+	entries, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, newPath, entries[0].OriginalPath)
+	assert.NoFileExists(t, filepath.Join(dir, "holding", "files", "old"))
+}
+
+func TestCopyFilePreservesModeAndTime(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeFile(t, src, "data")
+	require.NoError(t, os.Chmod(src, 0600))
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(src, mtime, mtime))
+
+	dst := filepath.Join(dir, "dst")
+	require.NoError(t, copyFile(src, dst))
+	st, err := os.Stat(dst)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	assert.True(t, st.ModTime().Equal(mtime))
+	assert.Error(t, copyFile(src, dst), "destination must not already exist")
+}
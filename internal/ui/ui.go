@@ -2,6 +2,7 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -14,6 +15,9 @@ import (
 	"github.com/Nomadcxx/moonbit/internal/audit"
 	"github.com/Nomadcxx/moonbit/internal/cleaner"
 	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/docker"
+	"github.com/Nomadcxx/moonbit/internal/paths"
+	"github.com/Nomadcxx/moonbit/internal/privsep"
 	"github.com/Nomadcxx/moonbit/internal/scanner"
 	"github.com/Nomadcxx/moonbit/internal/session"
 	"github.com/Nomadcxx/moonbit/internal/utils"
@@ -447,70 +451,100 @@ func (m Model) startScan() (tea.Model, tea.Cmd) {
 // runScanCmd executes the scan using the scanner package directly
 func runScanCmd(cfg *config.Config, scanMode string) tea.Cmd {
 	return func() tea.Msg {
-		// Count total categories to scan for progress calculation
-		totalCategories := 0
-		for _, category := range cfg.Categories {
-			if scanMode == "quick" && !category.Selected {
-				continue
-			}
-			if uiCategoryPathExists(category) {
-				totalCategories++
+		// Hold the CLI's operation lock for the whole scan, as 'moonbit scan'
+		// does, so no clean reads the cache while it is being replaced. The
+		// TUI does not wait: the user can retry.
+		sessionMgr, err := session.NewManager()
+		if err != nil {
+			return scanCompleteMsg{
+				Success: false,
+				Error:   fmt.Sprintf("failed to create session manager: %v", err),
 			}
 		}
+		lock, err := sessionMgr.TryLock("scan")
+		if err != nil {
+			return scanCompleteMsg{Success: false, Error: err.Error()}
+		}
+		defer func() { _ = lock.Unlock() }()
 
 		ctx := context.Background()
 		s := scanner.NewScanner(cfg)
 
-		var scannedCategories []config.Category
-		var totalSize uint64
-		var totalFiles int
-		var totalFilesScanned int
-		categoriesScanned := 0
+		// Reuse unchanged directory listings from earlier scans. The index is
+		// only an optimisation, so a missing or unreadable one means a full walk.
+		idx, idxPath, _ := session.OpenDirIndex()
+		if idxPath != "" {
+			s.UseIndex(idx)
+		}
 
 		// Scan categories based on mode
+		var toScan []config.Category
 		for _, category := range cfg.Categories {
 			// In quick mode, only scan Selected:true categories
 			if scanMode == "quick" && !category.Selected {
 				continue
 			}
-
-			if !uiCategoryPathExists(category) {
-				continue
+			if uiCategoryPathExists(category) {
+				toScan = append(toScan, category)
 			}
+		}
 
-			progressCh := make(chan scanner.ScanMsg, 10)
-			go s.ScanCategory(ctx, &category, progressCh)
+		var scannedCategories []config.Category
+		var summaries []config.CategorySummary
+		var totalSize uint64
+		var totalFiles int
 
-			// Collect results for this category
-			for msg := range progressCh {
-				// Forward progress updates (but we can't send them from here)
-				// We'll track total files scanned for final progress
-				if msg.Progress != nil {
-					totalFilesScanned += msg.Progress.FilesScanned
-				}
+		progressCh := make(chan scanner.ScanMsg, 10)
+		go s.ScanCategories(ctx, toScan, progressCh)
 
-				if msg.Complete != nil {
-					scannedCategories = append(scannedCategories, *msg.Complete.Stats)
-					totalSize += msg.Complete.Stats.Size
-					totalFiles += msg.Complete.Stats.FileCount
-					categoriesScanned++
-					break
+		// Completions arrive in category order, one per category
+		done := 0
+		for msg := range progressCh {
+			if msg.Error != nil {
+				// Drain so the remaining category scans can finish
+				for range progressCh {
 				}
-				if msg.Error != nil {
-					return scanCompleteMsg{
-						Success: false,
-						Error:   msg.Error.Error(),
-					}
+				return scanCompleteMsg{
+					Success: false,
+					Error:   msg.Error.Error(),
 				}
 			}
+			if msg.Complete == nil {
+				continue
+			}
+
+			category := toScan[done]
+			done++
+			stats := msg.Complete.Stats
+			scannedCategories = append(scannedCategories, *stats)
+			totalSize += stats.Size
+			totalFiles += stats.FileCount
+			if stats.FileCount > 0 {
+				summaries = append(summaries, config.CategorySummary{
+					Name:       category.Name,
+					Risk:       category.Risk,
+					Selected:   category.Selected,
+					Size:       stats.Size,
+					FileCount:  stats.FileCount,
+					DurationMs: msg.Complete.Duration.Milliseconds(),
+					Errors:     msg.Complete.Errors,
+					ScanRoots:  msg.Complete.Roots,
+				})
+			}
+		}
+
+		if idxPath != "" {
+			_ = session.SaveDirIndex(idx, idxPath)
 		}
 
 		// Save to cache
 		cache := &config.SessionCache{
+			Version: config.SessionCacheVersion,
 			ScanResults: &config.Category{
 				Name:  "Total Cleanable",
 				Files: []config.FileInfo{},
 			},
+			Categories: summaries,
 			TotalSize:  totalSize,
 			TotalFiles: totalFiles,
 			ScannedAt:  time.Now(),
@@ -521,14 +555,6 @@ func runScanCmd(cfg *config.Config, scanMode string) tea.Cmd {
 			cache.ScanResults.Files = append(cache.ScanResults.Files, cat.Files...)
 		}
 
-		sessionMgr, err := session.NewManager()
-		if err != nil {
-			return scanCompleteMsg{
-				Success: false,
-				Error:   fmt.Sprintf("failed to create session manager: %v", err),
-			}
-		}
-
 		if err := sessionMgr.Save(cache); err != nil {
 			return scanCompleteMsg{
 				Success: false,
@@ -581,6 +607,8 @@ func (m Model) showResults() (tea.Model, tea.Cmd) {
 		m.scanResults = cache
 		m.parseScanResults(cache, nil)
 		m.mode = ModeResults
+	} else if errors.Is(err, session.ErrUntrusted) {
+		m.currentPhase = "Scan results rejected: " + err.Error()
 	} else {
 		m.currentPhase = "No scan results found. Run a scan first."
 	}
@@ -603,6 +631,16 @@ func (m *Model) parseScanResults(cache *config.SessionCache, categories []config
 				})
 			}
 		}
+	} else if cache != nil && len(cache.Categories) > 0 {
+		// Caches with a per-category section already carry the breakdown
+		for _, summary := range cache.Categories {
+			m.categories = append(m.categories, CategoryInfo{
+				Name:    summary.Name,
+				Files:   summary.FileCount,
+				Size:    utils.HumanizeBytes(summary.Size),
+				Enabled: true,
+			})
+		}
 	} else if cache != nil && cache.TotalFiles > 0 {
 		// Otherwise, try to reconstruct from cache
 		// Group files by category name from config
@@ -760,12 +798,14 @@ func (m Model) buildFilteredCache() *config.SessionCache {
 	}
 
 	return &config.SessionCache{
+		Version: m.scanResults.Version,
 		ScanResults: &config.Category{
 			Name:      "Selected Categories",
 			Files:     filteredFiles,
 			FileCount: len(filteredFiles),
 			Size:      totalSize,
 		},
+		Categories: config.SummarizeCategories(filteredFiles, m.scanResults.Categories),
 		TotalSize:  totalSize,
 		TotalFiles: len(filteredFiles),
 		ScannedAt:  m.scanResults.ScannedAt,
@@ -800,10 +840,23 @@ func runCleanCmd(cfg *config.Config, cache *config.SessionCache) tea.Cmd {
 			}
 		}
 
+		// Share the CLI's operation lock so a timer or daemon clean cannot run
+		// over this one. It is taken before revalidating, so what is verified
+		// is what gets cleaned. The TUI does not wait: the user can retry.
+		sessionMgr, err := session.NewManager()
+		if err != nil {
+			return cleanCompleteMsg{Success: false, Error: err.Error()}
+		}
+		lock, err := sessionMgr.TryLock("clean")
+		if err != nil {
+			return cleanCompleteMsg{Success: false, Error: err.Error()}
+		}
+		defer func() { _ = lock.Unlock() }()
+
 		// Same gate as the CLI path: the cache is user-writable and this runs as
 		// root, so re-derive the delete list from config before deleting anything.
 		verified, report, err := validation.RevalidateCache(
-			cache, config.AuthoritativeCategories(cfg), validation.CacheOptions{})
+			cache, config.AuthoritativeCategories(cfg), validation.CacheOptions{IgnorePatterns: cfg.Scan.IgnorePatterns})
 		if err != nil {
 			return cleanCompleteMsg{Success: false, Error: err.Error()}
 		}
@@ -819,31 +872,99 @@ func runCleanCmd(cfg *config.Config, cache *config.SessionCache) tea.Cmd {
 		c := cleaner.NewCleaner(cfg)
 		defer func() { _ = c.Close() }() // Ensure audit logger is closed and flushed
 
-		progressCh := make(chan cleaner.CleanMsg, 10)
-		// Errors are delivered over progressCh; the return value is redundant here.
-		go func() { _ = c.CleanCategory(ctx, verified.ScanResults, false, progressCh) }()
-
 		var deletedFiles int
 		var deletedBytes uint64
 		var errors []string
 
-		// Process cleaning messages
-		for msg := range progressCh {
-			if msg.Complete != nil {
-				deletedFiles = msg.Complete.FilesDeleted
-				deletedBytes = msg.Complete.BytesFreed
-				errors = msg.Complete.Errors
-				break
+		// Clean category by category so safety limits apply to each one. Run
+		// as root for a user, their home-directory files go to a helper
+		// running as them instead, and files in anyone else's home are not
+		// cleaned at all. The size limit is checked on each whole category
+		// first, since each part alone could be under it.
+		categories := verified.ByCategory()
+		for i := range categories {
+			if err := c.CheckDeletionSize(&categories[i]); err != nil {
+				return cleanCompleteMsg{Success: false, Error: err.Error()}
 			}
-
-			if msg.Error != nil {
+		}
+		owner, err := privsep.InvokingUser()
+		if err != nil {
+			return cleanCompleteMsg{Success: false, Error: err.Error()}
+		}
+		var owned []config.Category
+		var home string
+		if owner != nil {
+			if home, err = paths.HomeDir(); err != nil {
+				return cleanCompleteMsg{Success: false, Error: err.Error()}
+			}
+			categories, owned = privsep.Split(categories, home)
+		}
+		if os.Geteuid() == 0 {
+			var foreign []config.Category
+			categories, foreign = privsep.SplitOtherHomes(categories)
+			if len(foreign) > 0 {
 				return cleanCompleteMsg{
 					Success: false,
-					Error:   msg.Error.Error(),
+					Error: fmt.Sprintf("%s: %d files in another user's home are never cleaned as root; run moonbit as that user to clean them",
+						foreign[0].Name, foreign[0].FileCount),
+				}
+			}
+		}
+		for i := range categories {
+			category := &categories[i]
+			progressCh := make(chan cleaner.CleanMsg, 10)
+			// Errors are delivered over progressCh; the return value is redundant here.
+			go func() { _ = c.CleanCategory(ctx, category, false, progressCh) }()
+
+			// Process cleaning messages
+			for msg := range progressCh {
+				if msg.Complete != nil {
+					deletedFiles += msg.Complete.FilesDeleted
+					deletedBytes += msg.Complete.BytesFreed
+					errors = append(errors, msg.Complete.Errors...)
+					break
+				}
+
+				if msg.Error != nil {
+					return cleanCompleteMsg{
+						Success:      false,
+						FilesDeleted: deletedFiles,
+						BytesFreed:   deletedBytes,
+						Error:        fmt.Sprintf("%s: %v", category.Name, msg.Error),
+					}
 				}
 			}
 		}
 
+		if len(owned) > 0 {
+			resp, err := privsep.CleanAsUser(ctx, owner, home, cfg, owned)
+			if err != nil {
+				return cleanCompleteMsg{
+					Success:      false,
+					FilesDeleted: deletedFiles,
+					BytesFreed:   deletedBytes,
+					Error:        err.Error(),
+				}
+			}
+			for _, result := range resp.Results {
+				if result.Complete == nil {
+					return cleanCompleteMsg{
+						Success:      false,
+						FilesDeleted: deletedFiles,
+						BytesFreed:   deletedBytes,
+						Error:        fmt.Sprintf("%s: %s", result.Name, result.Error),
+					}
+				}
+				deletedFiles += result.Complete.FilesDeleted
+				deletedBytes += result.Complete.BytesFreed
+				errors = append(errors, result.Complete.Errors...)
+			}
+		}
+
+		// Apply the backup limits now that this run's backup is complete. A
+		// failed prune leaves old backups in place and does not fail the clean.
+		_, _ = c.PruneBackups()
+
 		errorMsg := ""
 		if len(errors) > 0 {
 			errorMsg = fmt.Sprintf("%d files failed to delete", len(errors))
@@ -2222,30 +2343,23 @@ func runDockerCleanup(operation string) tea.Cmd {
 			defer auditLog.Close()
 		}
 
-		// Check if Docker is available
-		checkCmd := exec.Command("docker", "version")
-		if err := checkCmd.Run(); err != nil {
+		ctx := context.Background()
+		runtimes, err := docker.Detect(ctx)
+		if err != nil {
 			if auditLog != nil {
 				auditLog.LogDockerOperation("check", []string{}, "failed", err)
 			}
 			return dockerCompleteMsg{
 				success: false,
-				message: "❌ Docker is not installed or not running",
+				message: "❌ No container runtime (docker, podman or nerdctl) is installed",
 			}
 		}
 
-		var cmd *exec.Cmd
-		var operationName string
-		var args []string
-
+		var resources []docker.Resource
 		if operation == "images" {
-			operationName = "prune_images"
-			cmd = exec.Command("docker", "image", "prune", "-a", "-f")
-			args = []string{"-a", "-f"}
+			resources = []docker.Resource{docker.Images}
 		} else if operation == "all" {
-			operationName = "prune_all"
-			cmd = exec.Command("docker", "system", "prune", "-a", "--volumes", "-f")
-			args = []string{"-a", "--volumes", "-f"}
+			resources = docker.AllResources
 		} else {
 			return dockerCompleteMsg{
 				success: false,
@@ -2255,23 +2369,47 @@ func runDockerCleanup(operation string) tea.Cmd {
 
 		// Capture output
 		var output strings.Builder
-		cmd.Stdout = &output
-		cmd.Stderr = &output
+		reachable := 0
+		for _, rt := range runtimes {
+			client := docker.NewClient(rt)
+			if err := client.Ping(ctx); err != nil {
+				if auditLog != nil {
+					auditLog.LogDockerOperation("check", []string{rt.Name}, "failed", err)
+				}
+				continue
+			}
+			reachable++
+			output.WriteString(rt.String() + "\n")
+
+			for _, r := range resources {
+				// Skip what this runtime cannot prune without extra
+				// flags, such as podman's volumes.
+				if _, err := rt.PruneArgs(r, docker.Filters{}); err != nil {
+					continue
+				}
+				result, err := client.Prune(ctx, r, docker.Filters{})
+				output.WriteString(result.Output)
 
-		err := cmd.Run()
-		result := "success"
-		if err != nil {
-			result = "failed"
-		}
+				status := fmt.Sprintf("success bytes=%d", result.Reclaimed)
+				if err != nil {
+					status = "failed"
+				}
+				if auditLog != nil {
+					auditLog.LogDockerOperation("prune_"+string(r), append([]string{rt.Name}, result.Args...), status, err)
+				}
 
-		if auditLog != nil {
-			auditLog.LogDockerOperation(operationName, args, result, err)
+				if err != nil {
+					return dockerCompleteMsg{
+						success: false,
+						message: fmt.Sprintf("❌ Failed: %v", err),
+					}
+				}
+			}
 		}
-
-		if err != nil {
+		if reachable == 0 {
 			return dockerCompleteMsg{
 				success: false,
-				message: fmt.Sprintf("❌ Failed: %v", err),
+				message: "❌ No container runtime is running",
 			}
 		}
 
@@ -14,6 +14,13 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain keeps these tests on the per-user scan cache even when they run
+// as root.
+func TestMain(m *testing.M) {
+	session.RootOwned = func() bool { return false }
+	os.Exit(m.Run())
+}
+
 func TestNewModel(t *testing.T) {
 	model := NewModel()
 
@@ -288,6 +295,34 @@ func TestParseScanResultsWithCacheUsesCategoryProvenance(t *testing.T) {
 	assert.Equal(t, "4.0 KB", model.categories[0].Size)
 }
 
+func TestParseScanResultsPrefersCategorySection(t *testing.T) {
+	model := NewModel()
+	model.cfg = &config.Config{}
+	cache := &config.SessionCache{
+		Version: config.SessionCacheVersion,
+		ScanResults: &config.Category{
+			Name: "Total",
+			Files: []config.FileInfo{
+				{Path: "/tmp/b", Size: 2048, CategoryName: "B Cache"},
+				{Path: "/tmp/a", Size: 1024, CategoryName: "A Cache"},
+			},
+		},
+		Categories: []config.CategorySummary{
+			{Name: "B Cache", FileCount: 1, Size: 2048},
+			{Name: "A Cache", FileCount: 1, Size: 1024},
+		},
+		TotalSize:  3072,
+		TotalFiles: 2,
+	}
+
+	model.parseScanResults(cache, nil)
+
+	require.Len(t, model.categories, 2)
+	assert.Equal(t, "B Cache", model.categories[0].Name, "section order is kept")
+	assert.Equal(t, "2.0 KB", model.categories[0].Size)
+	assert.Equal(t, "A Cache", model.categories[1].Name)
+}
+
 func TestParseScanResultsEmpty(t *testing.T) {
 	model := NewModel()
 
@@ -500,3 +535,36 @@ func TestUpdateWithTickInactive(t *testing.T) {
 	assert.NotNil(t, newModel)
 	assert.Nil(t, cmd) // Should not return another tick when inactive
 }
+
+// The TUI shares the CLI's operation lock: a scan never replaces the cache
+// and a clean never revalidates it while another operation holds the lock.
+func TestScanAndCleanRespectOperationLock(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MOONBIT_HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+
+	sessionMgr, err := session.NewManager()
+	require.NoError(t, err)
+	held, err := sessionMgr.TryLock("clean")
+	require.NoError(t, err)
+	defer func() { _ = held.Unlock() }()
+
+	scanned, ok := runScanCmd(&config.Config{}, "quick")().(scanCompleteMsg)
+	require.True(t, ok)
+	assert.False(t, scanned.Success)
+	assert.Contains(t, scanned.Error, "another moonbit operation is running")
+	assert.False(t, sessionMgr.Exists(), "a locked-out scan must not write the cache")
+
+	// This cache fails revalidation, so a clean that revalidated before
+	// taking the lock would report that instead.
+	cache := &config.SessionCache{
+		ScanResults: &config.Category{Name: "Test", Files: []config.FileInfo{{Path: "/etc/passwd", Size: 1}}},
+		TotalFiles:  1,
+	}
+	cleaned, ok := runCleanCmd(&config.Config{}, cache)().(cleanCompleteMsg)
+	require.True(t, ok)
+	assert.False(t, cleaned.Success)
+	assert.Contains(t, cleaned.Error, "another moonbit operation is running")
+}
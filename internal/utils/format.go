@@ -1,6 +1,11 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // HumanizeBytes converts bytes to human-readable format
 func HumanizeBytes(bytes uint64) string {
@@ -21,3 +26,68 @@ func HumanizeBytes(bytes uint64) string {
 		return fmt.Sprintf("%d B", bytes)
 	}
 }
+
+// ParseSize parses a byte size such as "2GiB", "500M" or "1024". Units are
+// binary (K = 1024), matching HumanizeBytes, with or without a trailing "iB"
+// or "B".
+func ParseSize(s string) (uint64, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	if text == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+	text = strings.TrimSuffix(text, "IB")
+	text = strings.TrimSuffix(text, "B")
+
+	multiplier := uint64(1)
+	if n := len(text); n > 0 {
+		switch text[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			text = strings.TrimSpace(text[:n-1])
+		}
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return uint64(value * float64(multiplier)), nil
+}
+
+// ParseAge parses an age such as "30d", "2w" or any time.ParseDuration value
+// ("36h", "90m"). Days and weeks are whole multiples of 24 hours.
+func ParseAge(s string) (time.Duration, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	if text == "" {
+		return 0, fmt.Errorf("empty age")
+	}
+
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(text, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(text, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit == 0 {
+		d, err := time.ParseDuration(text)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return d, nil
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(text[:len(text)-1]), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return time.Duration(value * float64(unit)), nil
+}
@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/scanner"
 )
 
 // DefaultMaxCacheAge bounds how long a scan result stays usable. Past this the
@@ -27,8 +29,37 @@ const (
 	DropMissing         DropReason = "file no longer exists"
 	DropNotRegular      DropReason = "not a regular file"
 	DropChanged         DropReason = "size or mtime changed since scan"
+	DropRetained        DropReason = "kept by category retention policy"
 )
 
+// Code returns a stable machine-readable identifier for the reason. The
+// DropReason text is written for people and may be reworded; the code is what
+// scripts consuming `--output json` should match on.
+func (r DropReason) Code() string {
+	switch r {
+	case DropUnknownCategory:
+		return "unknown_category"
+	case DropOutsideCategory:
+		return "outside_category"
+	case DropFilterMismatch:
+		return "filter_mismatch"
+	case DropExcluded:
+		return "excluded"
+	case DropTooRecent:
+		return "too_recent"
+	case DropMissing:
+		return "missing"
+	case DropNotRegular:
+		return "not_regular"
+	case DropChanged:
+		return "changed"
+	case DropRetained:
+		return "retained"
+	default:
+		return "other"
+	}
+}
+
 // Report summarises what the gate removed, so callers can tell the user why the
 // delete list shrank instead of silently cleaning less than was scanned.
 type Report struct {
@@ -87,6 +118,9 @@ func sortStrings(s []string) {
 type CacheOptions struct {
 	MaxAge time.Duration
 	Now    time.Time
+	// IgnorePatterns are scan.ignore_patterns, which the re-listing behind
+	// keep_last and max_size must honour as the scan did.
+	IgnorePatterns []string
 }
 
 func (o CacheOptions) maxAge() time.Duration {
@@ -224,6 +258,52 @@ func (rc *resolvedCategory) isExcluded(path string) bool {
 	return false
 }
 
+// applyRetention drops files that a category's keep_last or max_size policy
+// keeps, preserving the order of the rest.
+//
+// The policy is judged against the category's eligible files as they are on
+// disk now, re-listed from config, never against the cached list: the scan
+// already applied it, so the cache holds only what it left cleanable, and
+// applying it again to that remainder would keep the newest of the remainder
+// too. A category whose files cannot be re-listed keeps everything.
+func applyRetention(files []config.FileInfo, resolved map[string]*resolvedCategory, report *Report, opts CacheOptions) []config.FileInfo {
+	governed := make(map[string]bool)
+	for _, f := range files {
+		key := normalizeName(f.CategoryName)
+		if p := resolved[key].cat.Retention; p != nil && (p.KeepLast > 0 || p.MaxSize != "") {
+			governed[key] = true
+		}
+	}
+	if len(governed) == 0 {
+		return files
+	}
+
+	scanCfg := &config.Config{}
+	scanCfg.Scan.IgnorePatterns = opts.IgnorePatterns
+	s := scanner.NewScanner(scanCfg)
+	cleanable := make(map[string]bool)
+	for key := range governed {
+		cat := resolved[key].cat
+		eligible, err := s.EligibleFiles(context.Background(), cat)
+		if err != nil {
+			continue
+		}
+		for _, f := range cat.Retention.Cleanable(eligible) {
+			cleanable[filepath.Clean(f.Path)] = true
+		}
+	}
+
+	out := files[:0]
+	for _, f := range files {
+		if governed[normalizeName(f.CategoryName)] && !cleanable[f.Path] {
+			report.drop(DropRetained, f.Path)
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
 // RevalidateCache re-derives the authoritative delete list from config.
 //
 // The session cache lives in the invoking user's home directory and is therefore
@@ -316,6 +396,10 @@ func RevalidateCache(cache *config.SessionCache, categories []config.Category, o
 				continue
 			}
 		}
+		if !rc.cat.Retention.AllowsAccessTime(info, opts.now()) {
+			report.drop(DropRetained, file.Path)
+			continue
+		}
 
 		// The file must be the one that was scanned, not merely a file at the
 		// same path. Anything that moved since the scan is out of scope.
@@ -338,15 +422,39 @@ func RevalidateCache(cache *config.SessionCache, categories []config.Category, o
 		verified.CategoryShred = rc.cat.ShredEnabled
 		verified.CategoryAction = rc.cat.Action
 
+		accepted = append(accepted, verified)
+	}
+
+	// Keep-last and size caps are judged per category over what is on disk,
+	// so the cache can neither widen them by listing every version nor, having
+	// been trimmed by the scan already, be trimmed by them a second time.
+	accepted = applyRetention(accepted, resolved, report, opts)
+
+	for _, verified := range accepted {
+		rc := resolved[normalizeName(verified.CategoryName)]
 		if rc.cat.Risk > aggregateRisk {
 			aggregateRisk = rc.cat.Risk
 		}
 		totalSize += verified.Size
-		accepted = append(accepted, verified)
-		report.Accepted++
+	}
+	report.Accepted = len(accepted)
+
+	// Per-category totals are recomputed from what survived, and everything the
+	// safety checks could read comes from config. Only the scan-time timings
+	// and error notes are carried over from the cache, and nothing acts on them.
+	summaries := config.SummarizeCategories(accepted, cache.Categories)
+	for i := range summaries {
+		rc := resolved[normalizeName(summaries[i].Name)]
+		summaries[i].Risk = rc.cat.Risk
+		summaries[i].Selected = rc.cat.Selected
+		// The configured paths, globs and all, not what the globs matched:
+		// deletion walks from their literal prefix and trusts nothing below.
+		summaries[i].ScanRoots = append([]string(nil), rc.cat.Paths...)
 	}
 
 	out := &config.SessionCache{
+		Version:    config.SessionCacheVersion,
+		Categories: summaries,
 		ScanResults: &config.Category{
 			Name:      cache.ScanResults.Name,
 			Files:     accepted,
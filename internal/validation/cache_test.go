@@ -1,12 +1,14 @@
 package validation
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/Nomadcxx/moonbit/internal/config"
+	"github.com/Nomadcxx/moonbit/internal/scanner"
 )
 
 // helper: write a file and return the FileInfo the scanner would have recorded.
@@ -374,6 +376,116 @@ func TestRevalidateReappliesMinAgeDays(t *testing.T) {
 	}
 }
 
+// Retention is config-side policy too: the cache cannot widen keep_last by
+// simply listing every version of a package.
+func TestRevalidateEnforcesRetentionPolicy(t *testing.T) {
+	tmp := t.TempDir()
+	var files []config.FileInfo
+	for i, name := range []string{"p-1-1-any.pkg.tar.zst", "p-2-1-any.pkg.tar.zst", "p-3-1-any.pkg.tar.zst"} {
+		f := scanned(t, filepath.Join(tmp, name), []byte("pkg"), "Pacman Cache")
+		mtime := time.Now().Add(-time.Duration(3-i) * time.Hour)
+		if err := os.Chtimes(f.Path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		f.ModTime = mtime.Format(time.RFC3339)
+		files = append(files, f)
+	}
+
+	out, report, err := RevalidateCache(cacheOf(files...), []config.Category{{
+		Name:      "Pacman Cache",
+		Paths:     []string{tmp},
+		Retention: &config.RetentionPolicy{KeepLast: 2, GroupBy: config.GroupByPackage},
+	}}, CacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.TotalFiles != 1 || out.ScanResults.Files[0].Path != files[0].Path {
+		t.Fatalf("only the oldest version may be cleaned, got %+v", out.ScanResults.Files)
+	}
+	if report.Dropped[DropRetained] != 2 || report.Accepted != 1 {
+		t.Errorf("expected 2 retained drops and 1 accepted, got %v / %d", report.Dropped, report.Accepted)
+	}
+}
+
+// The scan has already applied keep_last and max_size, so the cache holds
+// only what they left cleanable. The gate must accept all of it rather than
+// apply the policy a second time to the remainder.
+func TestRevalidateAcceptsWhatTheScanLeftCleanable(t *testing.T) {
+	policies := map[string]*config.RetentionPolicy{
+		"keep_last": {KeepLast: 1, GroupBy: config.GroupByPackage},
+		"max_size":  {MaxSize: "4B"},
+	}
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			tmp := t.TempDir()
+			for i, file := range []string{"p-1-1-any.pkg.tar.zst", "p-2-1-any.pkg.tar.zst", "p-3-1-any.pkg.tar.zst"} {
+				path := filepath.Join(tmp, file)
+				if err := os.WriteFile(path, []byte("pkg"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				mtime := time.Now().Add(-time.Duration(3-i) * time.Hour)
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			category := config.Category{Name: "Pacman Cache", Paths: []string{tmp}, Retention: policy}
+
+			s := scanner.NewScanner(&config.Config{})
+			ch := make(chan scanner.ScanMsg, 10)
+			go s.ScanCategory(context.Background(), &category, ch)
+			var scannedFiles []config.FileInfo
+			for msg := range ch {
+				if msg.Error != nil {
+					t.Fatal(msg.Error)
+				}
+				if msg.Complete != nil {
+					scannedFiles = msg.Complete.Stats.Files
+				}
+			}
+			if len(scannedFiles) != 2 {
+				t.Fatalf("the scan should leave the 2 older versions cleanable, got %d", len(scannedFiles))
+			}
+
+			out, report, err := RevalidateCache(cacheOf(scannedFiles...), []config.Category{category}, CacheOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.TotalFiles != 2 || report.Dropped[DropRetained] != 0 {
+				t.Fatalf("the gate must accept both files the scan left cleanable, got %d accepted, drops %v",
+					out.TotalFiles, report.Dropped)
+			}
+		})
+	}
+}
+
+func TestRevalidateEnforcesUnusedDays(t *testing.T) {
+	tmp := t.TempDir()
+	used := scanned(t, filepath.Join(tmp, "used.tmp"), []byte("aaaa"), "Build Cache")
+	idle := scanned(t, filepath.Join(tmp, "idle.tmp"), []byte("bbbb"), "Build Cache")
+	st, err := os.Lstat(idle.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(idle.Path, time.Now().Add(-60*24*time.Hour), st.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	out, report, err := RevalidateCache(cacheOf(used, idle), []config.Category{{
+		Name:      "Build Cache",
+		Paths:     []string{tmp},
+		Retention: &config.RetentionPolicy{UnusedDays: 30},
+	}}, CacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.TotalFiles != 1 || out.ScanResults.Files[0].Path != idle.Path {
+		t.Fatalf("only the file unused for 60 days may be cleaned, got %+v", out.ScanResults.Files)
+	}
+	if report.Dropped[DropRetained] != 1 {
+		t.Errorf("expected recently used file to be retained, got %v", report.Dropped)
+	}
+}
+
 // The gate must carry the configured action through, so held-open logs get
 // truncated rather than unlinked.
 func TestRevalidateCarriesConfiguredAction(t *testing.T) {
@@ -440,3 +552,63 @@ func TestReportSummary(t *testing.T) {
 		t.Errorf("expected 2 recorded examples, got %d", len(r.Examples[DropMissing]))
 	}
 }
+
+// JSON consumers match on Code, so every declared reason needs its own.
+func TestDropReasonCodesAreDistinct(t *testing.T) {
+	reasons := []DropReason{
+		DropUnknownCategory, DropOutsideCategory, DropFilterMismatch, DropExcluded,
+		DropTooRecent, DropMissing, DropNotRegular, DropChanged,
+	}
+	seen := make(map[string]DropReason)
+	for _, r := range reasons {
+		code := r.Code()
+		if code == "other" {
+			t.Errorf("%q has no code", r)
+		}
+		if prev, dup := seen[code]; dup {
+			t.Errorf("%q and %q share code %q", prev, r, code)
+		}
+		seen[code] = r
+	}
+}
+
+// The per-category section is cache-supplied and therefore a claim like any
+// other: counts are recomputed and risk/roots come from config.
+func TestRevalidateRecomputesCategorySummaries(t *testing.T) {
+	tmp := t.TempDir()
+	a := scanned(t, filepath.Join(tmp, "a.tmp"), []byte("aaaa"), "Test Cache")
+	gone := scanned(t, filepath.Join(tmp, "gone.tmp"), []byte("bb"), "Test Cache")
+	os.Remove(gone.Path)
+
+	cache := cacheOf(a, gone)
+	cache.Categories = []config.CategorySummary{{
+		Name:       "Test Cache",
+		Risk:       config.Low,
+		Size:       1 << 40,
+		FileCount:  2,
+		DurationMs: 12,
+		ScanRoots:  []string{"/"},
+	}}
+	categories := []config.Category{{Name: "Test Cache", Paths: []string{tmp}, Risk: config.Medium}}
+
+	out, _, err := RevalidateCache(cache, categories, CacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Categories) != 1 {
+		t.Fatalf("expected one category summary, got %+v", out.Categories)
+	}
+	got := out.Categories[0]
+	if got.Size != 4 || got.FileCount != 1 {
+		t.Errorf("summary counts must come from surviving files, got size=%d files=%d", got.Size, got.FileCount)
+	}
+	if got.Risk != config.Medium {
+		t.Errorf("summary risk must come from config, got %s", got.Risk)
+	}
+	if len(got.ScanRoots) != 1 || got.ScanRoots[0] != filepath.Clean(tmp) {
+		t.Errorf("summary roots must come from config, got %v", got.ScanRoots)
+	}
+	if got.DurationMs != 12 {
+		t.Errorf("scan timing should be carried over, got %d", got.DurationMs)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/Nomadcxx/moonbit/internal/config"
 )
 
 var (
@@ -13,8 +15,10 @@ var (
 	packageNamePattern   = regexp.MustCompile(`^[a-zA-Z0-9._+-]+$`)
 )
 
-// ValidateFilePath checks if a file path is safe to use
-func ValidateFilePath(path string) error {
+// ValidateFilePath checks if a file path is safe to use. protected lists the
+// directories it must not be in; without any, config.DefaultProtectedPaths
+// apply. Callers with a config pass cfg.Safety.EffectiveProtectedPaths().
+func ValidateFilePath(path string, protected ...string) error {
 	if path == "" {
 		return fmt.Errorf("file path cannot be empty")
 	}
@@ -30,9 +34,12 @@ func ValidateFilePath(path string) error {
 		return fmt.Errorf("invalid path: %w", err)
 	}
 
-	protectedPaths := []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/boot", "/sys", "/proc", "/dev"}
-	for _, protected := range protectedPaths {
-		if strings.HasPrefix(absPath, protected) {
+	if len(protected) == 0 {
+		protected = config.DefaultProtectedPaths()
+	}
+	for _, dir := range protected {
+		dir = filepath.Clean(dir)
+		if absPath == dir || strings.HasPrefix(absPath, strings.TrimSuffix(dir, "/")+"/") {
 			return fmt.Errorf("cannot operate on protected system path: %s", absPath)
 		}
 	}
@@ -83,6 +90,16 @@ func ValidateMode(mode string) error {
 	return nil
 }
 
+// ValidateOutputFormat checks if an --output format is supported
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case "", "text", "json":
+		return nil
+	default:
+		return fmt.Errorf("invalid output format: %s (must be 'text' or 'json')", format)
+	}
+}
+
 // ValidateDirExists checks if a directory exists
 func ValidateDirExists(path string) error {
 	info, err := os.Stat(path)
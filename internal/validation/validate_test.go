@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/Nomadcxx/moonbit/internal/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -30,6 +31,8 @@ func TestValidateFilePath(t *testing.T) {
 		{"Safe path /tmp", "/tmp/test", false},
 		{"Safe path /home", "/home/user/test", false},
 		{"Safe path /var", "/var/log/test", false},
+		{"Protected path /etc", "/etc/passwd", true},
+		{"Name sharing a protected prefix", "/binaries/test", false},
 	}
 
 	for _, tt := range tests {
@@ -44,6 +47,14 @@ func TestValidateFilePath(t *testing.T) {
 	}
 }
 
+func TestValidateFilePathWithConfiguredPaths(t *testing.T) {
+	protected := config.SafetyConfig{ProtectedPaths: []string{"/srv/data/"}}.EffectiveProtectedPaths()
+
+	assert.Error(t, ValidateFilePath("/srv/data/file", protected...))
+	assert.Error(t, ValidateFilePath("/bin/ls", protected...), "the defaults still apply")
+	assert.NoError(t, ValidateFilePath("/srv/other/file", protected...))
+}
+
 func TestValidatePackage(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -134,6 +145,31 @@ func createTempFile(t *testing.T) string {
 	return tmpFile.Name()
 }
 
+func TestValidateOutputFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		wantErr bool
+	}{
+		{"Empty format", "", false},
+		{"Text format", "text", false},
+		{"JSON format", "json", false},
+		{"Uppercase JSON", "JSON", true},
+		{"Unknown format", "yaml", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOutputFormat(tt.format)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestValidateDirExists(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "moonbit-test-*")